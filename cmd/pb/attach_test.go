@@ -17,16 +17,16 @@ func TestClaudeCommandFlag(t *testing.T) {
 	cfg := config.DefaultConfig()
 
 	// The command should use --permission-mode, NOT --accept-edits
-	if strings.Contains(cfg.Claude.Command, "--accept-edits") {
-		t.Errorf("Claude command uses invalid --accept-edits flag: %s", cfg.Claude.Command)
+	if strings.Contains(cfg.Tool("claude").Command, "--accept-edits") {
+		t.Errorf("Claude command uses invalid --accept-edits flag: %s", cfg.Tool("claude").Command)
 		t.Log("This causes claude to exit immediately with 'unknown option' error")
 	}
 
-	if !strings.Contains(cfg.Claude.Command, "--permission-mode") {
-		t.Errorf("Claude command should use --permission-mode flag: %s", cfg.Claude.Command)
+	if !strings.Contains(cfg.Tool("claude").Command, "--permission-mode") {
+		t.Errorf("Claude command should use --permission-mode flag: %s", cfg.Tool("claude").Command)
 	}
 
-	t.Logf("✓ Claude command is valid: %s", cfg.Claude.Command)
+	t.Logf("✓ Claude command is valid: %s", cfg.Tool("claude").Command)
 }
 
 // TestInvalidClaudeFlagCausesExit demonstrates what happens with the bug.
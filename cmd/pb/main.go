@@ -1,35 +1,106 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"text/tabwriter"
 	"time"
+	"unicode"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/zakandrewking/pocketbot/internal/config"
+	"github.com/zakandrewking/pocketbot/internal/log"
 	"github.com/zakandrewking/pocketbot/internal/tmux"
 )
 
 var (
 	listSessionsFn     = tmux.ListSessions
+	listDetailedFn     = tmux.ListSessionsDetailed
+	getSessionCwdFn    = tmux.GetSessionCwd
 	sessionUserTasksFn = tmux.SessionUserTasks
+	sessionTasksFn     = tmux.SessionTasks
+	zombieSessionsFn   = tmux.ZombieSessions
+	sessionAliveFn     = tmux.IsSessionAlive
 	renameSessionFn    = tmux.RenameSession
+	forceRenameFn      = tmux.RenameSessionForce
 	getSessionToolFn   = tmux.GetSessionTool
-	setSessionToolFn   = tmux.SetSessionTool
-	killTaskPIDFn      = func(pid int) error {
+	getSessionYoloFn   = tmux.GetSessionYolo
+	// getSessionPBOptionsFn reads all of a session's @pb_* options at once.
+	getSessionPBOptionsFn = tmux.GetAllSessionPBOptions
+	setSessionToolFn      = tmux.SetSessionTool
+	listPanesFn           = tmux.ListPanes
+	newWindowFn           = tmux.NewWindow
+	capturePaneFn         = tmux.CapturePane
+	paneActivityFn        = tmux.PaneActivity
+	captureRecentFn       = tmux.CaptureRecent
+	setSeenHashFn         = tmux.SetSessionSeenHash
+	getSessionOptionFn    = tmux.GetSessionOption
+	getServerInfoFn       = tmux.GetServerInfo
+	setSessionOptionFn    = tmux.SetSessionOption
+	getLastAttachedFn     = tmux.GetLastAttached
+	setLastAttachedFn     = tmux.SetLastAttached
+	getGlobalYoloFn       = tmux.GetGlobalYolo
+	setGlobalYoloFn       = tmux.SetGlobalYolo
+	sessionExistsFn       = tmux.SessionExists
+	broadcastKeysFn       = tmux.BroadcastKeys
+	sendKeysFn            = tmux.SendKeys
+	killSessionFn         = tmux.KillSession
+	sessionCPUFn          = tmux.SessionCPU
+	loadConfigFn          = loadConfig
+	readOnlyFn            = tmux.ReadOnly
+	killTaskPIDFn         = func(pid int) error {
 		return syscall.Kill(pid, syscall.SIGTERM)
 	}
+	// sessionIdleFn reports how long a session has been idle; ok is false
+	// while it is active or its activity is not yet known.
+	sessionIdleFn = func(s *tmux.Session) (time.Duration, bool) {
+		if !s.ActivityKnown() || s.RecentlyActive() {
+			return 0, false
+		}
+		return s.IdleFor(), true
+	}
+	sessionActiveFn = func(s *tmux.Session) bool { return s.RecentlyActive() }
+	// sessionCaptureFn returns the pane snapshot activity tracking last
+	// confirmed; resetBaselineFn replaces it with a fresh capture.
+	sessionCaptureFn = func(s *tmux.Session) string { return s.LastCapture() }
+	resetBaselineFn  = func(s *tmux.Session) (string, error) { return s.ResetBaseline() }
 )
 
 const maxTasksShownPerAgent = 6
 
+// scratchTool is the pseudo-tool for plain shell sessions. They get their
+// own home row and reuse the attach/kill/rename machinery under scratchKey.
+const (
+	scratchTool = "scratch"
+	scratchKey  = "s"
+)
+
+// compactWidth is the terminal width below which home rows switch to the
+// compact layout.
+const compactWidth = 60
+
+// Name and repo widths in compact rows, chosen so a row fits in 50 columns.
+const (
+	compactNameWidth = 16
+	compactRepoWidth = 14
+)
+
 type viewState int
 
 const (
@@ -50,10 +121,69 @@ const (
 	modePickKillTask
 	modeRenameInput
 	modeDirJump
+	modeMemoTool
+	modePickMemo
+	modeMemoInput
+	modeSearch
+	modeCommand
+	modeHelp
+	modeBroadcastTool
+	modeBroadcastInput
+	modeNoteTool
+	modePickNote
+	modeNoteInput
+	modeSendTool
+	modePickSend
+	modeSendKeys
+	modeConfirmKillAllTasks
 )
 
 type tickMsg time.Time
 
+// tooltipMsg wakes the UI when a pending tooltip is due.
+type tooltipMsg struct{}
+
+// cpuUpdateMsg carries a CPU sample of each session's pane processes.
+type cpuUpdateMsg struct {
+	pct map[string]float64
+}
+
+// cpuSampleInterval is how often session CPU is sampled.
+const cpuSampleInterval = 5 * time.Second
+
+// doubleTapMsg fires when the double-tap window for the tool key press at
+// time at closes.
+type doubleTapMsg struct {
+	key string
+	at  time.Time
+}
+
+// doubleTapWindow is how soon a second press of a tool key must follow the
+// first to kill instead of attach (ui.double_tap_to_kill).
+const doubleTapWindow = 500 * time.Millisecond
+
+// sessionKilledMsg reports the outcome of a killSessionCmd.
+type sessionKilledMsg struct {
+	name   string
+	notice string // homeNotice once the session is gone
+	err    error
+}
+
+// tasksKilledMsg reports the outcome of a killAllTasksCmd.
+type tasksKilledMsg struct {
+	killed, errs int
+}
+
+// configChangedMsg reports that the config file changed on disk.
+type configChangedMsg struct{}
+
+// configPollInterval is how often the config watcher stats the file.
+const configPollInterval = 2 * time.Second
+
+// tooltipKeyDelay is how long the arrow-key focus must rest on a row
+// before its tooltip appears.
+const tooltipKeyDelay = 500 * time.Millisecond
+
 func tickCmd() tea.Msg {
 	time.Sleep(1 * time.Second)
 	return tickMsg(time.Now())
@@ -65,9 +195,37 @@ type commandBinding struct {
 	Running     bool
 	Yolo        bool
 	Tool        string
-	LastSeen    time.Time
+	Memo        string
+	Note        string    // Decoded @pb_note; may span several lines
+	Priority    int       // @pb_priority set by `pb label`, 1 (first) to 9; 0 if unset
+	AttachCount int       // @pb_attach_count: how often pb has attached the session
+	Command     string    // Launch command, when pb knows it
+	Created     time.Time // Session creation time; zero if unknown
+	// LastAttached is when a client last attached; zero if never or unknown.
+	LastAttached time.Time
+	LastSeen     time.Time
+	SeenHash     string // @pb_seen_hash: pane hash recorded at the last attach
+}
+
+// pickerEntry maps a picker key to the session it selects. Entries are kept
+// in display order. With reuse_sessions, a session with several windows gets
+// one entry per window; target is then the tmux pane target to attach to.
+type pickerEntry struct {
+	key     string
+	session string
+	target  string
+	window  int
 }
 
+// pickerSort orders picker targets before keys are assigned; tab toggles it
+// inside a picker.
+type pickerSort int
+
+const (
+	sortImportance pickerSort = iota // priority label, then most active/recent first
+	sortAlpha                        // priority label, then session name
+)
+
 type taskKillTarget struct {
 	Session string
 	PID     int
@@ -82,30 +240,109 @@ type model struct {
 	taskCounts      map[string]int
 	taskCommands    map[string][]string
 	taskRefreshAt   time.Time
-	showTaskDetails bool
-	taskKillTargets map[string]taskKillTarget
-	windowWidth     int
-	viewState       viewState
-	mode            uiMode
-	pickerTool      string
-	pickerTargets   map[string]string
-	renameTarget    string
-	renameInput     string
-	renameCursor    int
-	shouldAttach    bool
-	sessionToAttach string // Name of session to attach to
-	homeNotice      string
-	newToolFresh    bool
-	newToolYolo     bool
-	newToolAuto     bool
-	dirQuery        string
-	dirCursor       int
-	dirSuggestions  []string
-	dirSelection    int
-	hasFasder       bool
-	getwd           func() (string, error)
-	chdir           func(string) error
-	lookupDirs      func(string) ([]string, error)
+	zombieSessions  map[string]bool    // Sessions whose pane process exited unreaped
+	sessionCpuPct   map[string]float64 // Latest pane CPU sample; see cpuSampleCmd
+	activityHistory map[string][]bool  // Active (true) or idle per tick, oldest first; see recordActivityHistory
+	sessionWarnings map[string]string
+	unseenOutput    map[string]bool
+	// configuredSessionNames caches configuredSessionNameSet. It is a
+	// pointer so the copies Update returns share it; nil disables caching.
+	configuredSessionNames *sessionNameCache
+	showTaskDetails        bool
+	showAllTaskDetails     bool // T: task lines for every session, expanding 10+ summaries; independent of t
+	showIdleSessions       bool
+	taskKillTargets        map[string]taskKillTarget
+	windowWidth            int
+	viewState              viewState
+	mode                   uiMode
+	pickerTool             string
+	pickerEntries          []pickerEntry
+	pickerSortOrder        pickerSort
+	pickerNames            []string // every session the open picker offers, in sort order
+	pickerFilter           string   // fuzzy filter narrowing pickerNames to pickerEntries
+	pickerFiltering        bool     // / was pressed: keys edit pickerFilter
+	renameTarget           string
+	renameInput            string
+	renameCursor           int
+	renameSuggestion       int // index into renameSuggestions(renameInput) shown inline
+	renameForce            bool
+	memoTarget             string
+	memoInput              string
+	memoCursor             int
+	noteTarget             string
+	noteInput              string // May contain newlines; see maxNoteLines
+	noteCursor             int
+	broadcastTarget        string // Tool whose running sessions receive broadcastInput
+	broadcastInput         string
+	broadcastCursor        int
+	sendTarget             string // Session that receives sendInput
+	sendInput              string
+	sendCursor             int
+	tickCount              int // Ticks seen; odd ticks dim the blinking active indicator
+	searchQuery            string
+	searchCursor           int
+	paletteQuery           string
+	paletteCursor          int
+	paletteSelection       int
+	shouldAttach           bool
+	sessionToAttach        string // Name of session to attach to
+	paneToAttach           string // Optional tmux pane target within sessionToAttach
+	homeNotice             string
+	configWarning          string // config load error shown under the dir line until W dismisses it
+	newToolFresh           bool
+	newToolYolo            bool
+	newToolAuto            bool
+	globalYolo             bool // Y: every new session launches in yolo mode
+	dirQuery               string
+	dirCursor              int
+	dirSuggestions         []string
+	// dirQueryHistory holds accepted z queries, oldest first. dirHistoryPos
+	// is how many entries back up has walked; 0 means not browsing.
+	dirQueryHistory []string
+	dirHistoryPos   int
+	// dirPrefetch delivers the startup fasder lookup; the tick handler moves
+	// it into prefetchedDirSuggestions for the first z press.
+	dirPrefetch              chan []string
+	prefetchedDirSuggestions []string
+	dirSelection             int
+	// tooltipSession is the row focused with the arrow keys; its tooltip
+	// shows once tooltipShowAt passes.
+	tooltipSession string
+	tooltipShowAt  time.Time
+	// lastKey and lastKeyTime hold a tool key press waiting out
+	// doubleTapWindow when ui.double_tap_to_kill is on.
+	lastKey     string
+	lastKeyTime time.Time
+	// homePage is the page of session rows shown when they don't fit on one
+	// screen; homePageSessions holds that page's sessions on the copy
+	// viewHome renders from, nil meaning every session.
+	homePage         int
+	homePageSessions map[string]bool
+	// errorLog keeps the most recent error notices (see Update) for the help
+	// overlay; helpShowErrors switches the overlay to the full log.
+	errorLog       []errorEntry
+	helpShowErrors bool
+	// activePreset is the ui.task_filter_presets entry F has selected,
+	// 1-based; 0 is the default filter (task-patterns.yaml alone).
+	activePreset int
+	// configChanged is signalled by the config watcher; the tick handler
+	// turns each signal into a configChangedMsg.
+	configChanged <-chan struct{}
+	// starting holds sessions pb just created, keyed to their creation time,
+	// until tmux reports them and a first pane capture succeeds.
+	starting map[string]time.Time
+	// noAltScreen renders inline instead of on the alternate screen
+	// (--no-alt-screen). teaProgramFactory builds each program; nil means
+	// tea.NewProgram.
+	noAltScreen bool
+	// readonly (--readonly) refuses every create, kill, rename and other
+	// mutation on pocketbot's own server; see blockMutation.
+	readonly          bool
+	teaProgramFactory func(tea.Model, ...tea.ProgramOption) *tea.Program
+	hasFasder         bool
+	getwd             func() (string, error)
+	chdir             func(string) error
+	lookupDirs        func(string) ([]string, error)
 }
 
 func initialModel() model {
@@ -117,26 +354,37 @@ func initialModel() model {
 	}
 
 	// Load configuration
-	cfg, err := config.Load()
+	// The alt screen hides stderr, so a load error is kept for the title area.
+	configWarning := ""
+	cfg, err := loadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		fmt.Fprintf(os.Stderr, "Using default configuration\n")
+		configWarning = fmt.Sprintf("config error: %v (using defaults)", err)
 		cfg = config.DefaultConfig()
 	}
+	applyTaskPatterns(cfg)
 
 	// Create tmux sessions for each configured session
 	sessions := make(map[string]*tmux.Session)
 	for _, sess := range cfg.AllSessions() {
-		sessions[sess.Name] = tmux.NewSession(sess.Name, sess.Command)
+		sessions[sess.Name] = newSessionFor(cfg, sess.Name, toolFromSessionName(cfg, sess.Name), sess.Command)
 	}
 	for _, running := range tmux.ListSessions() {
 		if _, exists := sessions[running]; !exists {
-			sessions[running] = tmux.NewSession(running, "")
+			opts, err := getSessionPBOptionsFn(running)
+			if err != nil {
+				opts = nil
+			}
+			tool := normalizeToolName(cfg, opts["@pb_tool"])
+			if tool == "" {
+				tool = toolFromSessionName(cfg, running)
+			}
+			sessions[running] = newSessionFor(cfg, running, tool, "")
 		}
 	}
 
-	return model{
+	m := model{
 		config:          cfg,
+		configWarning:   configWarning,
 		sessions:        sessions,
 		sessionTools:    make(map[string]string),
 		bindings:        make(map[string]commandBinding),
@@ -144,27 +392,277 @@ func initialModel() model {
 		taskCommands:    make(map[string][]string),
 		taskKillTargets: make(map[string]taskKillTarget),
 		windowWidth:     80,
+		showTaskDetails: cfg.UI.ShowTasksOnStart,
 		viewState:       viewHome,
 		mode:            modeHome,
-		pickerTargets:   make(map[string]string),
 		getwd:           os.Getwd,
 		chdir:           os.Chdir,
 		lookupDirs:      lookupDirectoriesWithFasder,
 		hasFasder:       fasderAvailable(),
+		globalYolo:      getGlobalYoloFn(),
+		dirQueryHistory: loadDirHistory(),
 	}
+	m.configuredSessionNames = &sessionNameCache{}
+	if m.hasFasder {
+		m.dirPrefetch = prefetchDirSuggestions(m.lookupDirs)
+	}
+	m.configChanged = watchConfigFiles(func() []string {
+		return config.WatchPaths(currentDir())
+	}, configPollInterval)
+	if err := m.validateKeyAssignments(); err != nil {
+		m.homeNotice = err.Error()
+	}
+	return m
 }
 
-func normalizeToolName(tool string) string {
-	switch tool {
-	case "claude", "codex", "cursor":
-		return tool
+// watchConfigFiles polls the modification times of the files paths returns
+// every interval for the life of the process, signalling each change on the
+// returned channel. paths is called on every poll, so the project files
+// follow the working directory. Changes that arrive before the last signal
+// is read are coalesced. A file that appears or disappears counts as a
+// change.
+func watchConfigFiles(paths func() []string, interval time.Duration) <-chan struct{} {
+	changed := make(chan struct{}, 1)
+	modTimes := func() map[string]time.Time {
+		times := make(map[string]time.Time)
+		for _, path := range paths() {
+			if info, err := os.Stat(path); err == nil {
+				times[path] = info.ModTime()
+			}
+		}
+		return times
+	}
+	last := modTimes()
+	go func() {
+		for {
+			time.Sleep(interval)
+			if current := modTimes(); !maps.EqualFunc(current, last, time.Time.Equal) {
+				last = current
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return changed
+}
+
+// configChangeCmd returns a command delivering configChangedMsg if the
+// watcher has signalled, without blocking. Polling from the tick (rather
+// than a command blocked on the channel) keeps signals from being lost to
+// the program that ends at each attach.
+func (m model) configChangeCmd() tea.Cmd {
+	if m.configChanged == nil {
+		return nil
+	}
+	select {
+	case <-m.configChanged:
+		return func() tea.Msg { return configChangedMsg{} }
 	default:
+		return nil
+	}
+}
+
+// loadConfig loads every config layer for the current directory: the user
+// config, .pocketbot.yaml files from / down to cwd, then PB_* overrides.
+func loadConfig() (*config.Config, error) {
+	cfg, _, err := config.LoadAll(currentDir())
+	return cfg, err
+}
+
+// loadConfigUnvalidated is loadConfig without Validate, for callers that
+// report or tolerate an invalid config themselves.
+func loadConfigUnvalidated() (*config.Config, error) {
+	cfg, _, err := config.LoadAllUnvalidated(currentDir())
+	return cfg, err
+}
+
+// currentDir returns the working directory, or "" if it can't be read (in
+// which case no project config is loaded).
+func currentDir() string {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	return cwd
+}
+
+// reloadConfig swaps in the config from disk, keeping the current one if
+// it no longer loads or validates.
+func (m model) reloadConfig() model {
+	cfg, err := loadConfigFn()
+	if err != nil {
+		m.homeNotice = fmt.Sprintf("config reload failed: %v", err)
+		return m
+	}
+	m.config = cfg
+	if m.activePreset > len(cfg.UI.TaskFilterPresets) {
+		m.activePreset = 0
+	}
+	applyTaskFilterPreset(cfg, m.activePreset)
+	if m.sessions == nil {
+		m.sessions = make(map[string]*tmux.Session)
+	}
+	// Sessions already wrapped pick up the new idle timeouts; their tool
+	// comes from the last bindings refresh rather than another tmux read.
+	for name, tmuxSess := range m.sessions {
+		if tmuxSess != nil {
+			tool := m.sessionToolFrom(name, m.bindings[name].Tool)
+			tmuxSess.Apply(tmux.WithIdleTimeout(cfg.IdleTimeout(name, tool)))
+		}
+	}
+	for _, sess := range cfg.AllSessions() {
+		if _, exists := m.sessions[sess.Name]; !exists {
+			m.sessions[sess.Name] = newSessionFor(cfg, sess.Name, toolFromSessionName(cfg, sess.Name), sess.Command)
+		}
+	}
+	m.configWarning = ""
+	m.homeNotice = "config reloaded"
+	if err := m.validateKeyAssignments(); err != nil {
+		m.homeNotice = err.Error()
+	}
+	return m
+}
+
+// validateKeyAssignments reports the first home-screen key claimed twice
+// among home action hotkeys, enabled tools and custom sessions. Config
+// validation only compares tools with custom sessions, so this also catches
+// keys that a hotkey shadows (or that shadow a hotkey) at runtime.
+func (m model) validateKeyAssignments() error {
+	if m.config == nil {
+		return nil
+	}
+	owners := make(map[string]string)
+	var conflict error
+	claim := func(key, owner string) {
+		if key == "" || conflict != nil {
+			return
+		}
+		if prev, ok := owners[key]; ok {
+			conflict = fmt.Errorf("key conflict: '%s' used by %s and %s", key, prev, owner)
+			return
+		}
+		owners[key] = owner
+	}
+	for _, action := range homeActions() {
+		claim(action.key, action.name)
+	}
+	claim(":", "command palette")
+	claim("W", "dismiss config warning")
+	if len(m.config.UI.TaskFilterPresets) > 0 {
+		claim("F", "task filter presets")
+	}
+	for _, tool := range allTools(m.config) {
+		if m.toolEnabled(tool) {
+			claim(m.keyForTool(tool), tool)
+		}
+	}
+	for _, sess := range m.config.Sessions {
+		claim(sess.Key, sess.Name)
+	}
+	return conflict
+}
+
+// activePresetName returns the active task filter preset's name, or "" for
+// the default filter.
+func (m model) activePresetName() string {
+	if m.config == nil || m.activePreset <= 0 || m.activePreset > len(m.config.UI.TaskFilterPresets) {
 		return ""
 	}
+	return m.config.UI.TaskFilterPresets[m.activePreset-1].Name
+}
+
+// cycleTaskFilterPreset steps to the next task filter preset, wrapping
+// back to the default, and recounts tasks under it.
+func (m model) cycleTaskFilterPreset() (model, tea.Cmd) {
+	m.activePreset = (m.activePreset + 1) % (len(m.config.UI.TaskFilterPresets) + 1)
+	applyTaskFilterPreset(m.config, m.activePreset)
+	m.taskRefreshAt = time.Time{}
+	m.refreshTaskCounts()
+	name := m.activePresetName()
+	if name == "" {
+		name = "default"
+	}
+	m.homeNotice = "task filter: " + name
+	return m, nil
+}
+
+// dismissConfigWarning hides the config load error from the title area.
+func (m model) dismissConfigWarning() (model, tea.Cmd) {
+	m.configWarning = ""
+	return m, nil
+}
+
+// prefetchDirSuggestions runs the empty-query directory lookup in the
+// background so the first z press doesn't wait on fasder. The channel
+// receives one result (nil on error) and is then closed.
+func prefetchDirSuggestions(lookup func(string) ([]string, error)) chan []string {
+	ch := make(chan []string, 1)
+	go func() {
+		defer close(ch)
+		dirs, err := lookup("")
+		if err != nil {
+			dirs = nil
+		}
+		ch <- dirs
+	}()
+	return ch
+}
+
+// normalizeToolName returns tool when cfg configures it (or it is scratch),
+// else "". A nil cfg has the default tools.
+func normalizeToolName(cfg *config.Config, tool string) string {
+	if tool == scratchTool {
+		return tool
+	}
+	if _, ok := configuredTool(cfg, tool); ok {
+		return tool
+	}
+	return ""
+}
+
+// configuredTools returns cfg's tools, or the default tools when cfg is
+// nil.
+func configuredTools(cfg *config.Config) []config.ToolConfig {
+	if cfg == nil {
+		return config.DefaultTools()
+	}
+	return cfg.Tools
+}
+
+// configuredTool returns cfg's tool named name.
+func configuredTool(cfg *config.Config, name string) (config.ToolConfig, bool) {
+	for _, tool := range configuredTools(cfg) {
+		if tool.Name == name {
+			return tool, true
+		}
+	}
+	return config.ToolConfig{}, false
+}
+
+// allTools lists every tool group in display order: cfg's agents, then
+// scratch shells.
+func allTools(cfg *config.Config) []string {
+	var tools []string
+	for _, tool := range configuredTools(cfg) {
+		tools = append(tools, tool.Name)
+	}
+	return append(tools, scratchTool)
+}
+
+// newSessionFor wraps a tmux session with the idle timeout cfg sets for it
+// by name or for tool. A nil cfg uses the default.
+func newSessionFor(cfg *config.Config, name, tool, command string) *tmux.Session {
+	if cfg == nil {
+		return tmux.NewSession(name, command)
+	}
+	timeout := cfg.IdleTimeout(name, tool)
+	return tmux.NewSessionWithOptions(name, command, tmux.WithIdleTimeout(timeout))
 }
 
 func (m *model) rememberSessionTool(name, tool string) {
-	tool = normalizeToolName(tool)
+	tool = normalizeToolName(m.config, tool)
 	if tool == "" {
 		return
 	}
@@ -174,13 +672,29 @@ func (m *model) rememberSessionTool(name, tool string) {
 	m.sessionTools[name] = tool
 }
 
-func (m *model) configuredSessionNameSet() map[string]bool {
+// sessionNameCache holds the configured session names built for config;
+// version counts rebuilds.
+type sessionNameCache struct {
+	config  *config.Config
+	names   map[string]bool
+	version int
+}
+
+// configuredSessionNameSet returns the configured session names. The result
+// is cached per config pointer and must not be modified by callers.
+func (m model) configuredSessionNameSet() map[string]bool {
+	if c := m.configuredSessionNames; c != nil && c.names != nil && c.config == m.config {
+		return c.names
+	}
 	names := make(map[string]bool)
-	if m.config == nil {
-		return names
+	if m.config != nil {
+		for _, sess := range m.config.AllSessions() {
+			names[sess.Name] = true
+		}
 	}
-	for _, sess := range m.config.AllSessions() {
-		names[sess.Name] = true
+	if c := m.configuredSessionNames; c != nil {
+		c.config, c.names = m.config, names
+		c.version++
 	}
 	return names
 }
@@ -195,14 +709,21 @@ func (m *model) syncSessionsWithTmux() {
 
 	configured := m.configuredSessionNameSet()
 	if m.config != nil {
+		added := false
 		for _, sess := range m.config.AllSessions() {
 			if _, exists := m.sessions[sess.Name]; !exists {
-				m.sessions[sess.Name] = tmux.NewSession(sess.Name, sess.Command)
+				m.sessions[sess.Name] = newSessionFor(m.config, sess.Name, toolFromSessionName(m.config, sess.Name), sess.Command)
+				added = true
 			}
-			if inferred := toolFromSessionName(sess.Name); inferred != "" {
+			if inferred := toolFromSessionName(m.config, sess.Name); inferred != "" {
 				m.rememberSessionTool(sess.Name, inferred)
 			}
 		}
+		if added && m.homeNotice == "" {
+			if err := m.validateKeyAssignments(); err != nil {
+				m.homeNotice = err.Error()
+			}
+		}
 	}
 	live := make(map[string]bool)
 	for _, name := range listSessionsFn() {
@@ -216,17 +737,25 @@ func (m *model) syncSessionsWithTmux() {
 			if tool != "" {
 				command = m.commandForTool(tool)
 			}
-			m.sessions[name] = tmux.NewSession(name, command)
+			m.sessions[name] = newSessionFor(m.config, name, tool, command)
 		}
-		if tool := normalizeToolName(getSessionToolFn(name)); tool != "" {
+		if tool := normalizeToolName(m.config, getSessionToolFn(name)); tool != "" {
 			m.sessionTools[name] = tool
 			continue
 		}
 		if _, ok := m.sessionTools[name]; ok {
 			continue
 		}
-		if inferred := toolFromSessionName(name); inferred != "" {
+		if inferred := toolFromSessionName(m.config, name); inferred != "" {
 			m.sessionTools[name] = inferred
+			continue
+		}
+		if readOnlyFn() {
+			// Foreign sessions carry no @pb_tool and arbitrary names; fall
+			// back to what the panes are running.
+			if inferred := toolFromPanes(name); inferred != "" {
+				m.sessionTools[name] = inferred
+			}
 		}
 	}
 
@@ -234,42 +763,80 @@ func (m *model) syncSessionsWithTmux() {
 		if live[name] || configured[name] {
 			continue
 		}
+		m.releaseDirLock(name)
 		delete(m.sessions, name)
 		delete(m.sessionTools, name)
 	}
 }
 
+// currentDir returns pb's working directory, or "" when it cannot be read;
+// see workingDir.
 func (m *model) currentDir() string {
-	if m.getwd == nil {
-		cwd, _ := os.Getwd()
-		return cwd
+	cwd, _ := m.workingDir()
+	return cwd
+}
+
+// workingDir is like currentDir but reports why the directory is unknown,
+// typically because it was deleted while pb was running.
+func (m *model) workingDir() (string, error) {
+	getwd := m.getwd
+	if getwd == nil {
+		getwd = os.Getwd
 	}
-	cwd, err := m.getwd()
+	cwd, err := getwd()
 	if err != nil {
-		return ""
+		return "", err
 	}
-	return cwd
+	if cwd == "" {
+		return "", errors.New("empty working directory")
+	}
+	return cwd, nil
 }
 
+// missingCwdNotice explains why sessions cannot be created from a deleted
+// working directory.
+const missingCwdNotice = "current directory is gone; press z to jump elsewhere"
+
 func (m *model) refreshBindings() {
 	m.syncSessionsWithTmux()
 	if m.bindings == nil {
 		m.bindings = make(map[string]commandBinding)
 	}
 
+	details := make(map[string]tmux.SessionInfo)
+	if infos, err := listDetailedFn(); err == nil {
+		for _, info := range infos {
+			details[info.Name] = info
+		}
+	}
+
 	live := make(map[string]bool)
 	for name, tmuxSess := range m.sessions {
 		if tmuxSess == nil || !tmuxSess.IsRunning() {
 			continue
 		}
 
+		// One show-options call per session; a failed read leaves the
+		// options empty, as the per-option getters would.
+		opts, err := getSessionPBOptionsFn(name)
+		if err != nil {
+			opts = nil
+		}
 		m.bindings[name] = commandBinding{
-			SessionName: name,
-			Cwd:         tmux.GetSessionCwd(name),
-			Running:     true,
-			Yolo:        tmux.GetSessionYolo(name),
-			Tool:        m.sessionTool(name),
-			LastSeen:    time.Now(),
+			SessionName:  name,
+			Cwd:          opts["@pb_cwd"],
+			Running:      true,
+			Yolo:         tmux.ParseYolo(opts["@pb_yolo"]),
+			Tool:         m.sessionToolFrom(name, opts["@pb_tool"]),
+			Memo:         opts["@pb_memo"],
+			Note:         decodeNote(opts["@pb_note"]),
+			Priority:     parsePriority(opts["@pb_priority"]),
+			AttachCount:  parseAttachCount(opts["@pb_attach_count"]),
+			Command:      tmuxSess.Command(),
+			Created:      details[name].Created,
+			LastAttached: details[name].LastAttached,
+			LastSeen:     time.Now(),
+			SeenHash:     opts["@pb_seen_hash"],
 		}
 		live[name] = true
 	}
@@ -279,16 +846,112 @@ func (m *model) refreshBindings() {
 			delete(m.bindings, sessionName)
 		}
 	}
+	m.settleStartingSessions(time.Now())
+}
+
+// updateActivity polls every session's activity. One batched activity
+// query lets idle sessions skip capture-pane; if it fails, each session is
+// polled the old way.
+func (m *model) updateActivity() {
+	stamps, err := paneActivityFn()
+	for _, sess := range m.sessions {
+		if err != nil {
+			sess.UpdateActivity()
+			continue
+		}
+		sess.UpdateActivityFrom(stamps)
+	}
+}
+
+// activityHistoryLen is how many ticks of activity the row sparkline shows.
+const activityHistoryLen = 10
+
+// recordActivityHistory appends each session's current activity to its
+// history, keeping the last activityHistoryLen ticks. Sessions that are
+// gone lose their history.
+func (m *model) recordActivityHistory() {
+	if m.activityHistory == nil {
+		m.activityHistory = make(map[string][]bool)
+	}
+	for name := range m.activityHistory {
+		if _, ok := m.sessions[name]; !ok {
+			delete(m.activityHistory, name)
+		}
+	}
+	for name, sess := range m.sessions {
+		history := append(m.activityHistory[name], sessionActiveFn(sess))
+		if len(history) > activityHistoryLen {
+			history = history[len(history)-activityHistoryLen:]
+		}
+		m.activityHistory[name] = history
+	}
+}
+
+// renderActivitySparkline draws one cell per tick, oldest first: █ for
+// active and ░ for idle.
+func renderActivitySparkline(history []bool) string {
+	var b strings.Builder
+	for _, active := range history {
+		if active {
+			b.WriteString("█")
+		} else {
+			b.WriteString("░")
+		}
+	}
+	return b.String()
+}
+
+// startingTimeout bounds how long a row may show "…starting" before pb gives
+// up waiting for the session and falls back to its normal status.
+const startingTimeout = 10 * time.Second
+
+// markStarting records that name was just created so its row renders a
+// "…starting" badge instead of flashing "not running" or a blank status.
+func (m *model) markStarting(name string) {
+	if m.starting == nil {
+		m.starting = make(map[string]time.Time)
+	}
+	m.starting[name] = time.Now()
+}
+
+// settleStartingSessions clears the starting state once tmux knows the
+// session and its pane can be captured, or once startingTimeout passes.
+func (m *model) settleStartingSessions(now time.Time) {
+	for name, since := range m.starting {
+		if now.Sub(since) >= startingTimeout {
+			delete(m.starting, name)
+			continue
+		}
+		if !sessionExistsFn(name) {
+			continue
+		}
+		if _, err := capturePaneFn(name); err == nil {
+			delete(m.starting, name)
+		}
+	}
+}
+
+func (m model) isStarting(name string) bool {
+	_, ok := m.starting[name]
+	return ok
 }
 
 func (m model) sessionTool(name string) string {
-	if tool := normalizeToolName(m.sessionTools[name]); tool != "" {
+	if tool := normalizeToolName(m.config, m.sessionTools[name]); tool != "" {
+		return tool
+	}
+	return m.sessionToolFrom(name, getSessionToolFn(name))
+}
+
+// sessionToolFrom is sessionTool with the session's @pb_tool already read.
+func (m model) sessionToolFrom(name, stored string) string {
+	if tool := normalizeToolName(m.config, m.sessionTools[name]); tool != "" {
 		return tool
 	}
-	if tool := normalizeToolName(getSessionToolFn(name)); tool != "" {
+	if tool := normalizeToolName(m.config, stored); tool != "" {
 		return tool
 	}
-	return toolFromSessionName(name)
+	return toolFromSessionName(m.config, name)
 }
 
 func checkDirectoryMismatch() {
@@ -320,17 +983,34 @@ func checkDirectoryMismatch() {
 	}
 }
 
-func toolFromSessionName(name string) string {
-	switch {
-	case name == "claude" || strings.HasPrefix(name, "claude-"):
-		return "claude"
-	case name == "codex" || strings.HasPrefix(name, "codex-"):
-		return "codex"
-	case name == "cursor" || strings.HasPrefix(name, "cursor-"):
-		return "cursor"
-	default:
+func toolFromSessionName(cfg *config.Config, name string) string {
+	if name == scratchTool || strings.HasPrefix(name, scratchTool+"-") {
+		return scratchTool
+	}
+	for _, tool := range configuredTools(cfg) {
+		if name == tool.Name || strings.HasPrefix(name, tool.Name+"-") {
+			return tool.Name
+		}
+	}
+	return ""
+}
+
+// toolFromPanes infers a built-in tool from the commands running in a
+// session's panes.
+func toolFromPanes(sessionName string) string {
+	panes, err := listPanesFn(sessionName)
+	if err != nil {
 		return ""
 	}
+	for _, p := range panes {
+		switch p.Command {
+		case "claude", "codex":
+			return p.Command
+		case "agent", "cursor-agent":
+			return "cursor"
+		}
+	}
+	return ""
 }
 
 func alphaKey(i int) string {
@@ -360,11 +1040,87 @@ func (m model) runningToolSessions(tool string) []string {
 		}
 		out = append(out, name)
 	}
-	sort.Strings(out)
+	for name := range m.starting {
+		if binding, ok := m.bindings[name]; ok && binding.Running {
+			continue
+		}
+		startingTool := normalizeToolName(m.config, m.sessionTools[name])
+		if startingTool == "" {
+			startingTool = toolFromSessionName(m.config, name)
+		}
+		if startingTool == tool {
+			out = append(out, name)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return m.lessByPriority(out[i], out[j])
+	})
 	return out
 }
 
+// minPriority and maxPriority bound the labels `pb label` accepts.
+const (
+	minPriority = 1
+	maxPriority = 9
+)
+
+// parsePriority reads an @pb_priority value, returning 0 for anything that
+// is not a priority in range.
+func parsePriority(value string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < minPriority || n > maxPriority {
+		return 0
+	}
+	return n
+}
+
+// parseAttachCount reads an @pb_attach_count value, returning 0 for anything
+// that is not a non-negative integer.
+func parseAttachCount(value string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// recordAttach bumps name's @pb_attach_count. The count lives on the
+// session, so it survives pb restarts and goes away with the session.
+func recordAttach(name string) error {
+	count := parseAttachCount(getSessionOptionFn(name, "@pb_attach_count")) + 1
+	return setSessionOptionFn(name, "@pb_attach_count", strconv.Itoa(count))
+}
+
+// comparePriority orders sessions by label: lower numbers first, and
+// labelled sessions before unlabelled ones.
+func (m model) comparePriority(a, b string) int {
+	priA, priB := m.bindings[a].Priority, m.bindings[b].Priority
+	switch {
+	case priA == priB:
+		return 0
+	case priA == 0:
+		return 1
+	case priB == 0:
+		return -1
+	case priA < priB:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// lessByPriority sorts by priority label, then by name.
+func (m model) lessByPriority(a, b string) bool {
+	if c := m.comparePriority(a, b); c != 0 {
+		return c < 0
+	}
+	return a < b
+}
+
 func (m model) toolSessionsInDir(tool, cwd string) []string {
+	if cwd == "" {
+		return nil
+	}
 	var out []string
 	for name, binding := range m.bindings {
 		bindingTool := binding.Tool
@@ -387,47 +1143,129 @@ func (m model) toolAlreadyRunningInDir(tool, cwd string) bool {
 	return len(m.toolSessionsInDir(tool, cwd)) > 0
 }
 
-func (m model) commandForTool(tool string) string {
-	switch tool {
-	case "claude":
-		return m.config.Claude.Command
-	case "codex":
-		return m.config.Codex.Command
-	case "cursor":
-		return m.config.Cursor.Command
-	default:
-		return ""
+// dirLockPath is the file recording which session holds tool's launch lock
+// for cwd. The lock outlives pb, so a later pb run finds the session even
+// before its bindings show it in this directory.
+func dirLockPath(cwd, tool string) string {
+	return filepath.Join(cwd, ".pocketbot", tool+".lock")
+}
+
+// writeDirLock records name as tool's session for cwd.
+func writeDirLock(cwd, tool, name string) error {
+	path := dirLockPath(cwd, tool)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
 	}
+	return os.WriteFile(path, []byte(name+"\n"), 0644)
 }
 
-func (m model) keyForTool(tool string) string {
-	switch tool {
-	case "claude":
-		return m.config.Claude.Key
-	case "codex":
-		return m.config.Codex.Key
-	case "cursor":
-		return m.config.Cursor.Key
-	default:
+// readDirLock returns the session holding tool's lock for cwd, or "".
+func readDirLock(cwd, tool string) string {
+	data, err := os.ReadFile(dirLockPath(cwd, tool))
+	if err != nil {
 		return ""
 	}
+	return strings.TrimSpace(string(data))
 }
 
-func (m model) toolEnabled(tool string) bool {
-	switch tool {
-	case "claude":
-		return m.config.Claude.Enabled
-	case "codex":
-		return m.config.Codex.Enabled
-	case "cursor":
-		return m.config.Cursor.Enabled
-	default:
+// lockHeldBy reports whether name is still tool's session for cwd. Session
+// names are reused, so a lock can outlive its session and name an unrelated
+// one started elsewhere or for another tool.
+func lockHeldBy(cfg *config.Config, name, cwd, tool string) bool {
+	if !sessionExistsFn(name) {
+		return false
+	}
+	opts, err := getSessionPBOptionsFn(name)
+	if err != nil {
 		return false
 	}
+	return opts["@pb_cwd"] == cwd && normalizeToolName(cfg, opts["@pb_tool"]) == tool
+}
+
+// removeDirLock deletes tool's lock for cwd if name still holds it, and the
+// .pocketbot directory once it is empty.
+func removeDirLock(cwd, tool, name string) {
+	if cwd == "" || tool == "" || readDirLock(cwd, tool) != name {
+		return
+	}
+	path := dirLockPath(cwd, tool)
+	if err := os.Remove(path); err != nil {
+		log.Debug("remove lock %s: %v", path, err)
+		return
+	}
+	_ = os.Remove(filepath.Dir(path))
+}
+
+// releaseDirLock drops a killed session's launch lock, using the cwd and
+// tool its binding held before the kill.
+func (m model) releaseDirLock(name string) {
+	cwd, tool := m.dirLockOf(name)
+	removeDirLock(cwd, tool, name)
+}
+
+// dirLockOf returns the directory and tool a session's launch lock is
+// keyed by.
+func (m model) dirLockOf(name string) (cwd, tool string) {
+	binding := m.bindings[name]
+	tool = binding.Tool
+	if tool == "" {
+		tool = m.sessionTool(name)
+	}
+	return binding.Cwd, tool
+}
+
+// releaseAllDirLocks drops the launch locks of every session on the server,
+// before the whole server is killed.
+func releaseAllDirLocks(cfg *config.Config) {
+	for _, name := range listSessionsFn() {
+		removeDirLock(getSessionCwdFn(name), normalizeToolName(cfg, getSessionToolFn(name)), name)
+	}
+}
+
+func (m model) commandForTool(tool string) string {
+	if tool == scratchTool {
+		return scratchShell()
+	}
+	if tc := m.config.Tool(tool); tc != nil {
+		return tc.Command
+	}
+	return ""
+}
+
+// scratchShell returns the user's login shell for scratch sessions.
+func scratchShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+func (m model) keyForTool(tool string) string {
+	if tool == scratchTool {
+		// A custom session configured on the same key keeps it.
+		for _, sess := range m.config.Sessions {
+			if sess.Key == scratchKey {
+				return ""
+			}
+		}
+		return scratchKey
+	}
+	if tc := m.config.Tool(tool); tc != nil {
+		return tc.Key
+	}
+	return ""
+}
+
+func (m model) toolEnabled(tool string) bool {
+	if tool == scratchTool {
+		return m.keyForTool(scratchTool) != ""
+	}
+	tc := m.config.Tool(tool)
+	return tc != nil && tc.Enabled
 }
 
 func (m model) toolForKey(key string) string {
-	for _, tool := range []string{"claude", "codex", "cursor"} {
+	for _, tool := range allTools(m.config) {
 		if !m.toolEnabled(tool) {
 			continue
 		}
@@ -439,8 +1277,8 @@ func (m model) toolForKey(key string) string {
 }
 
 func (m model) disabledToolKey(key string) bool {
-	for _, tool := range []string{"claude", "codex", "cursor"} {
-		if m.toolEnabled(tool) {
+	for _, tool := range allTools(m.config) {
+		if tool == scratchTool || m.toolEnabled(tool) {
 			continue
 		}
 		if m.keyForTool(tool) == key {
@@ -451,6 +1289,11 @@ func (m model) disabledToolKey(key string) bool {
 }
 
 func (m model) nextSessionName(tool string) string {
+	if m.config != nil && m.config.NameScheme == config.NameSchemeRepo {
+		if repo := sessionNameSafe(repoFromCwd(m.currentDir())); repo != "" && repo != "-" {
+			return m.nextRepoSessionName(tool + "-" + repo)
+		}
+	}
 	names := m.runningToolSessions(tool)
 	used := make(map[string]bool)
 	for _, n := range names {
@@ -471,6 +1314,37 @@ func (m model) nextSessionName(tool string) string {
 	return fmt.Sprintf("%s-%d", tool, max+1)
 }
 
+// nextRepoSessionName returns base, or base-2, base-3, ... when a session
+// with that name already exists.
+func (m model) nextRepoSessionName(base string) string {
+	used := make(map[string]bool)
+	for name := range m.sessions {
+		used[name] = true
+	}
+	for name := range m.bindings {
+		used[name] = true
+	}
+	if !used[base] {
+		return base
+	}
+	for n := 2; ; n++ {
+		if name := fmt.Sprintf("%s-%d", base, n); !used[name] {
+			return name
+		}
+	}
+}
+
+// sessionNameSafe replaces characters tmux does not allow in session
+// names ("." and ":") and whitespace with "-".
+func sessionNameSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == ':' || unicode.IsSpace(r) {
+			return '-'
+		}
+		return r
+	}, s)
+}
+
 func repoFromCwd(cwd string) string {
 	if cwd == "" {
 		return "-"
@@ -478,6 +1352,40 @@ func repoFromCwd(cwd string) string {
 	return filepath.Base(cwd)
 }
 
+// resolveRepoDisplay returns the on-screen repo label for cwd. A cwd under
+// a ui.cwd_aliases prefix shows as the alias plus the rest of the path,
+// using the longest matching prefix; anything else falls back to
+// repoFromCwd.
+func (m model) resolveRepoDisplay(cwd string) string {
+	if cwd == "" || m.config == nil {
+		return repoFromCwd(cwd)
+	}
+	home, _ := os.UserHomeDir()
+	best, bestAlias := "", ""
+	for prefix, alias := range m.config.UI.CwdAliases {
+		if home != "" && (prefix == "~" || strings.HasPrefix(prefix, "~/")) {
+			prefix = home + prefix[1:]
+		}
+		if prefix != "/" {
+			prefix = strings.TrimRight(prefix, "/")
+		}
+		if cwd != prefix && !strings.HasPrefix(cwd, strings.TrimSuffix(prefix, "/")+"/") {
+			continue
+		}
+		if len(prefix) > len(best) {
+			best, bestAlias = prefix, alias
+		}
+	}
+	if best == "" {
+		return repoFromCwd(cwd)
+	}
+	rest := strings.TrimPrefix(strings.TrimPrefix(cwd, best), "/")
+	if rest == "" {
+		return bestAlias
+	}
+	return bestAlias + "/" + rest
+}
+
 func lookupDirectoryWithFasder(query string) (string, error) {
 	args := []string{"-d"}
 	if strings.TrimSpace(query) != "" {
@@ -558,6 +1466,20 @@ func (m *model) refreshDirSuggestions() {
 	}
 }
 
+// consumeDirPrefetch stores the startup lookup once it has arrived, without
+// blocking.
+func (m *model) consumeDirPrefetch() {
+	if m.dirPrefetch == nil {
+		return
+	}
+	select {
+	case dirs := <-m.dirPrefetch:
+		m.prefetchedDirSuggestions = dirs
+		m.dirPrefetch = nil
+	default:
+	}
+}
+
 func (m *model) applyDirChange(target string) (model, tea.Cmd) {
 	chdir := m.chdir
 	if chdir == nil {
@@ -567,14 +1489,134 @@ func (m *model) applyDirChange(target string) (model, tea.Cmd) {
 		m.homeNotice = fmt.Sprintf("cd failed: %v", err)
 		return *m, nil
 	}
+	m.recordDirQuery(m.dirQuery)
 	m.mode = modeHome
 	m.homeNotice = ""
 	m.dirQuery = ""
 	m.dirSuggestions = nil
 	m.dirSelection = 0
+	m.dirHistoryPos = 0
 	return *m, nil
 }
 
+// maxDirHistory caps how many accepted z queries are kept.
+const maxDirHistory = 20
+
+// dirHistoryPath returns where accepted z queries are persisted.
+func dirHistoryPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "pocketbot", "dir-history.txt"), nil
+}
+
+// loadDirHistory reads the persisted z queries, oldest first. A missing or
+// unreadable file yields an empty history.
+func loadDirHistory() []string {
+	path, err := dirHistoryPath()
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var history []string
+	for _, line := range strings.Split(string(data), "\n") {
+		history = pushDirHistory(history, line)
+	}
+	return history
+}
+
+func saveDirHistory(history []string) error {
+	path, err := dirHistoryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data := strings.Join(history, "\n")
+	if data != "" {
+		data += "\n"
+	}
+	return os.WriteFile(path, []byte(data), 0644)
+}
+
+// pushDirHistory appends query unless it is blank or repeats the newest
+// entry, dropping the oldest entries beyond maxDirHistory.
+func pushDirHistory(history []string, query string) []string {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return history
+	}
+	if n := len(history); n > 0 && history[n-1] == query {
+		return history
+	}
+	history = append(history, query)
+	if len(history) > maxDirHistory {
+		history = append([]string(nil), history[len(history)-maxDirHistory:]...)
+	}
+	return history
+}
+
+// recordDirQuery saves an accepted z query; persisting is best-effort.
+func (m *model) recordDirQuery(query string) {
+	if strings.TrimSpace(query) == "" {
+		return
+	}
+	m.dirQueryHistory = pushDirHistory(m.dirQueryHistory, query)
+	_ = saveDirHistory(m.dirQueryHistory)
+}
+
+// stepDirHistory walks the z query history: delta 1 goes to an older entry,
+// -1 to a newer one, and stepping past the newest clears the query. It
+// reports false when there is nothing to walk, so the key can move the
+// suggestion selection instead.
+func (m *model) stepDirHistory(delta int) bool {
+	if len(m.dirQueryHistory) == 0 {
+		return false
+	}
+	if m.dirHistoryPos == 0 && (delta < 0 || m.dirQuery != "") {
+		return false
+	}
+	pos := m.dirHistoryPos + delta
+	if pos > len(m.dirQueryHistory) {
+		pos = len(m.dirQueryHistory)
+	}
+	m.dirHistoryPos = pos
+	m.dirQuery = ""
+	if pos > 0 {
+		m.dirQuery = m.dirQueryHistory[len(m.dirQueryHistory)-pos]
+	}
+	m.dirCursor = len(m.dirQuery)
+	m.dirSelection = 0
+	m.refreshDirSuggestions()
+	return true
+}
+
+// autoCwdChange moves pb into the session's @pb_cwd after a detach when
+// ui.follow_session_cwd is on, so new sessions start where that one was.
+func (m *model) autoCwdChange(name string) {
+	if m.config == nil || !m.config.UI.FollowSessionCwd {
+		return
+	}
+	target := getSessionCwdFn(name)
+	if target == "" || target == m.currentDir() {
+		return
+	}
+	chdir := m.chdir
+	if chdir == nil {
+		chdir = os.Chdir
+	}
+	if err := chdir(target); err != nil {
+		m.homeNotice = fmt.Sprintf("cd failed: %v", err)
+		return
+	}
+	m.homeNotice = "cwd changed to " + target
+}
+
 func (m model) mismatchCountForCurrentDir() int {
 	cwd := m.currentDir()
 	if cwd == "" {
@@ -590,7 +1632,27 @@ func (m model) mismatchCountForCurrentDir() int {
 	return count
 }
 
-func fallbackCommand(tool, command string) string {
+// buildFallbackCommand chains a fallback that runs when primary exits non-zero.
+func buildFallbackCommand(primary, fallback string) string {
+	return primary + " || " + fallback
+}
+
+// fallbackCommand returns command with a resume-or-start fallback appended.
+// Custom sessions whose primary command matches exactly use their configured
+// fallback_command; tools with a fallback_suffix fall back to the command
+// without it, and claude, codex and cursor otherwise use fixed rules for
+// known commands.
+func fallbackCommand(cfg *config.Config, tool, command string, sessions ...config.SessionConfig) string {
+	for _, sess := range sessions {
+		if sess.FallbackCommand != "" && sess.Command == command {
+			return buildFallbackCommand(command, sess.FallbackCommand)
+		}
+	}
+	if tc, ok := configuredTool(cfg, tool); ok && tc.FallbackSuffix != "" {
+		if fresh, ok := strings.CutSuffix(command, " "+tc.FallbackSuffix); ok {
+			return buildFallbackCommand(command, fresh)
+		}
+	}
 	switch tool {
 	case "claude":
 		if command == "claude --continue --permission-mode acceptEdits" {
@@ -623,7 +1685,13 @@ func fallbackCommand(tool, command string) string {
 // freshCommandForTool returns the command with resume/continue flags stripped
 // so the tool starts a new session without previous context.
 // Claude: removes --continue. Codex: removes "resume --last". Cursor: removes "resume".
-func freshCommandForTool(tool, command string) string {
+// A configured fallback_suffix is dropped first when the command ends with it.
+func freshCommandForTool(cfg *config.Config, tool, command string) string {
+	if tc, ok := configuredTool(cfg, tool); ok && tc.FallbackSuffix != "" {
+		if fresh, ok := strings.CutSuffix(command, " "+tc.FallbackSuffix); ok {
+			return fresh
+		}
+	}
 	switch tool {
 	case "claude":
 		cmd := strings.Replace(command, " --continue", "", 1)
@@ -652,8 +1720,8 @@ func autoCommandForTool(tool, command string) string {
 		}
 		return strings.TrimSpace(cmd)
 	case "codex":
-		if strings.HasPrefix(command, "codex ") {
-			return "codex --full-auto " + command[len("codex "):]
+		if command == "codex" || strings.HasPrefix(command, "codex ") {
+			return "codex --full-auto" + command[len("codex"):]
 		}
 	}
 	return command
@@ -663,7 +1731,17 @@ func autoCommandForTool(tool, command string) string {
 // Claude uses --dangerously-skip-permissions (replaces --permission-mode acceptEdits).
 // Codex uses --yolo (global flag placed before subcommand).
 // Cursor agent has no CLI yolo flag; the command is returned unchanged.
-func yoloCommandForTool(tool, command string) string {
+// A configured yolo_flag takes precedence over all of these.
+func yoloCommandForTool(cfg *config.Config, tool, command string) string {
+	// The flag goes right after the program name, ahead of any fallback
+	// suffix.
+	if tc, ok := configuredTool(cfg, tool); ok && tc.YoloFlag != "" {
+		if strings.Contains(command, tc.YoloFlag) {
+			return command
+		}
+		program, rest, _ := strings.Cut(command, " ")
+		return strings.TrimSpace(program + " " + tc.YoloFlag + " " + rest)
+	}
 	switch tool {
 	case "claude":
 		cmd := strings.ReplaceAll(command, "--permission-mode acceptEdits", "--dangerously-skip-permissions")
@@ -672,33 +1750,62 @@ func yoloCommandForTool(tool, command string) string {
 		}
 		return strings.TrimSpace(cmd)
 	case "codex":
-		if strings.HasPrefix(command, "codex ") {
-			return "codex --yolo " + command[len("codex "):]
+		if command == "codex" || strings.HasPrefix(command, "codex ") {
+			return "codex --yolo" + command[len("codex"):]
 		}
-		return command
 	}
 	return command
 }
 
+// LaunchOptions are per-launch modifiers applied to a tool's configured command.
+type LaunchOptions struct {
+	Fresh bool // Drop resume/continue flags
+	Auto  bool // Low-friction automatic approval mode
+	Yolo  bool // Skip all permission prompts
+}
+
+// buildLaunchCommand is the single place launch commands are rewritten. It
+// applies, in order: fresh (strip resume flags), auto, yolo, then the
+// resume-or-start fallback. Fallback must run last because it only matches
+// exact known commands and turns them into shell "a || b" chains. Auto and
+// yolo are mutually exclusive in the UI; if both are set, yolo wins because
+// it replaces the permission flag auto would have set.
+func buildLaunchCommand(cfg *config.Config, tool, command string, opts LaunchOptions, sessions ...config.SessionConfig) string {
+	if opts.Fresh {
+		command = freshCommandForTool(cfg, tool, command)
+	}
+	if opts.Auto && !opts.Yolo {
+		command = autoCommandForTool(tool, command)
+	}
+	if opts.Yolo {
+		command = yoloCommandForTool(cfg, tool, command)
+	}
+	return fallbackCommand(cfg, tool, command, sessions...)
+}
+
 func (m model) startAndAttachSession(name, command string) (model, tea.Cmd) {
 	sess, exists := m.sessions[name]
 	if !exists {
-		sess = tmux.NewSession(name, command)
+		sess = newSessionFor(m.config, name, m.sessionTool(name), command)
 		m.sessions[name] = sess
 	}
 	if !sess.IsRunning() {
+		if m.blockMutation() {
+			return m, nil
+		}
 		if command == "" {
-			command = m.commandForTool(toolFromSessionName(name))
+			command = m.commandForTool(toolFromSessionName(m.config, name))
 		}
 		if command == "" {
 			m.homeNotice = fmt.Sprintf("session %s is not running", name)
 			return m, nil
 		}
-		launchCommand := fallbackCommand(toolFromSessionName(name), command)
-		if err := tmux.CreateSession(name, launchCommand); err != nil {
+		launchCommand := buildLaunchCommand(m.config, toolFromSessionName(m.config, name), command, LaunchOptions{}, m.config.Sessions...)
+		if err := tmux.CreateSessionInDir(name, launchCommand, m.configuredWorkdir(name)); err != nil {
 			m.homeNotice = fmt.Sprintf("failed to start %s: %v", name, err)
 			return m, nil
 		}
+		m.markStarting(name)
 	}
 	m.refreshBindings()
 	m.shouldAttach = true
@@ -708,6 +1815,41 @@ func (m model) startAndAttachSession(name, command string) (model, tea.Cmd) {
 	return m, tea.Quit
 }
 
+// configuredWorkdir returns the workdir of the configured session name, or
+// "" to launch in pb's current directory.
+func (m model) configuredWorkdir(name string) string {
+	if m.config == nil {
+		return ""
+	}
+	for _, sess := range m.config.Sessions {
+		if sess.Name == name {
+			return sess.WorkdirPath()
+		}
+	}
+	return ""
+}
+
+// resolvePaneTarget maps a configured pane ("window.pane" or a pane index in
+// the first window) to a tmux target. It returns "" when no pane is configured
+// or the pane does not exist, so attach falls back to the whole session.
+func resolvePaneTarget(sessionName, pane string) string {
+	pane = strings.TrimSpace(pane)
+	if pane == "" {
+		return ""
+	}
+	panes, err := listPanesFn(sessionName)
+	if err != nil || len(panes) == 0 {
+		return ""
+	}
+	firstWindow := panes[0].WindowIndex
+	for _, p := range panes {
+		if p.Index() == pane || (p.WindowIndex == firstWindow && fmt.Sprintf("%d", p.PaneIndex) == pane) {
+			return tmux.PaneTarget(sessionName, p.WindowIndex, p.PaneIndex)
+		}
+	}
+	return ""
+}
+
 func (m model) requestAttachSession(name string) (model, tea.Cmd) {
 	m.shouldAttach = true
 	m.sessionToAttach = name
@@ -717,146 +1859,592 @@ func (m model) requestAttachSession(name string) (model, tea.Cmd) {
 }
 
 func (m model) createAndAttachTool(tool string) (model, tea.Cmd) {
-	cwd := m.currentDir()
-	if cwd != "" {
-		inDir := m.toolSessionsInDir(tool, cwd)
-		switch len(inDir) {
-		case 1:
-			return m.requestAttachSession(inDir[0])
-		default:
-			if len(inDir) == 0 {
-				break
-			}
-			m.mode = modePickAttach
-			m.pickerTool = tool
-			m.pickerTargets = make(map[string]string)
-			for i, name := range inDir {
-				m.pickerTargets[pickerKey(i)] = name
+	cwd, err := m.workingDir()
+	if err != nil {
+		// New sessions would start with an empty @pb_cwd and skip the
+		// per-directory dedup, so refuse until the user moves somewhere real.
+		m.mode = modeHome
+		m.homeNotice = missingCwdNotice
+		return m, nil
+	}
+	var inDir []string
+	if tool != scratchTool {
+		// Any number of scratch shells may share a directory.
+		inDir = m.toolSessionsInDir(tool, cwd)
+	}
+	if len(inDir) > 0 && m.reuseSessions() {
+		return m.openToolWindow(m.sortPickerTargets(inDir)[0], tool)
+	}
+	switch len(inDir) {
+	case 0:
+	case 1:
+		return m.requestAttachSession(inDir[0])
+	default:
+		m = m.openPicker(tool, modePickAttach, inDir)
+		m.homeNotice = "session already running in this directory"
+		return m, nil
+	}
+	if tool != scratchTool {
+		if locked := readDirLock(cwd, tool); locked != "" {
+			if lockHeldBy(m.config, locked, cwd, tool) {
+				if m.reuseSessions() {
+					return m.openToolWindow(locked, tool)
+				}
+				return m.requestAttachSession(locked)
 			}
-			m.homeNotice = "session already running in this directory"
-			return m, nil
+			// The session is gone (killed outside pb) or its name now
+			// belongs to another session; the lock is stale.
+			removeDirLock(cwd, tool, locked)
 		}
 	}
 
+	if m.blockMutation() {
+		return m, nil
+	}
 	command := m.commandForTool(tool)
 	if command == "" {
 		m.homeNotice = fmt.Sprintf("%s is not configured", tool)
 		return m, nil
 	}
-	if m.newToolFresh {
-		command = freshCommandForTool(tool, command)
-		m.newToolFresh = false
-	}
-	if m.newToolAuto {
-		command = autoCommandForTool(tool, command)
-		m.newToolAuto = false
-	}
-	yoloEnabled := m.newToolYolo
-	if m.newToolYolo {
-		command = yoloCommandForTool(tool, command)
-		m.newToolYolo = false
+	if limit := m.config.MaxInstancesForTool(tool); limit > 0 && len(m.runningToolSessions(tool)) >= limit {
+		m.homeNotice = fmt.Sprintf("%s instance limit (%d) reached", tool, limit)
+		return m, nil
 	}
+	opts := m.launchOptions(tool)
+	m.newToolFresh = false
+	m.newToolAuto = false
+	m.newToolYolo = false
 	name := m.nextSessionName(tool)
-	launchCommand := fallbackCommand(tool, command)
+	launchCommand := buildLaunchCommand(m.config, tool, command, opts)
 	if err := tmux.CreateSession(name, launchCommand); err != nil {
 		m.homeNotice = fmt.Sprintf("failed to create %s: %v", tool, err)
 		return m, nil
 	}
+	m.markStarting(name)
 	_ = setSessionToolFn(name, tool)
 	m.rememberSessionTool(name, tool)
-	if err := tmux.SetSessionYolo(name, yoloEnabled); err != nil {
+	if tool != scratchTool {
+		if err := writeDirLock(cwd, tool, name); err != nil {
+			// Non-fatal: the lock only guards against duplicate launches.
+			log.Debug("lock %s in %s: %v", name, cwd, err)
+		}
+	}
+	if err := tmux.SetSessionYolo(name, opts.Yolo); err != nil {
 		// Non-fatal: session still starts even if metadata cannot be persisted.
 	}
-	m.sessions[name] = tmux.NewSession(name, command)
-	return m.startAndAttachSession(name, command)
+	m.sessions[name] = newSessionFor(m.config, name, tool, launchCommand)
+	return m.startAndAttachSession(name, launchCommand)
 }
 
-func (m model) preparePicker(tool string, pickMode uiMode) model {
-	targets := m.runningToolSessions(tool)
-	m.mode = pickMode
-	m.pickerTool = tool
-	m.pickerTargets = make(map[string]string)
-	limit := len(targets)
-	maxKeys := len("abcdefghijklmnopqrstuvwxyz")
-	if limit > maxKeys {
-		limit = maxKeys
-		m.homeNotice = "showing first 26 sessions"
-	} else {
-		m.homeNotice = ""
-	}
-	for i := 0; i < limit; i++ {
-		m.pickerTargets[pickerKey(i)] = targets[i]
-	}
-	return m
+// reuseSessions reports whether n opens windows in existing sessions.
+func (m model) reuseSessions() bool {
+	return m.config != nil && m.config.ReuseSessions
 }
 
-func (m model) handleToolAttach(tool string) (model, tea.Cmd) {
-	targets := m.runningToolSessions(tool)
-	switch len(targets) {
-	case 0:
-		return m.createAndAttachTool(tool)
-	case 1:
-		return m.startAndAttachSession(targets[0], "")
-	default:
-		m = m.preparePicker(tool, modePickAttach)
+// openToolWindow starts tool in a new window of its existing session name
+// and attaches to it. tmux makes the new window current, so the attach
+// lands there.
+func (m model) openToolWindow(name, tool string) (model, tea.Cmd) {
+	if m.blockMutation() {
 		return m, nil
 	}
-}
-
-func (m model) handleToolKill(tool string) (model, tea.Cmd) {
-	targets := m.runningToolSessions(tool)
-	switch len(targets) {
-	case 0:
-		m.homeNotice = fmt.Sprintf("no %s sessions running", tool)
-		m.mode = modeHome
-		return m, nil
-	case 1:
-		if err := tmux.KillSession(targets[0]); err != nil {
-			m.homeNotice = fmt.Sprintf("failed to stop %s: %v", targets[0], err)
-		} else {
-			m.homeNotice = fmt.Sprintf("stopped %s", targets[0])
-			delete(m.sessions, targets[0])
-			delete(m.sessionTools, targets[0])
-		}
-		m.refreshBindings()
-		m.mode = modeHome
+	command := m.commandForTool(tool)
+	if command == "" {
+		m.homeNotice = fmt.Sprintf("%s is not configured", tool)
 		return m, nil
-	default:
-		m = m.preparePicker(tool, modePickKill)
+	}
+	opts := m.launchOptions(tool)
+	// The session's first window already resumes the last conversation;
+	// another resume would share it.
+	opts.Fresh = true
+	m.newToolFresh = false
+	m.newToolAuto = false
+	m.newToolYolo = false
+	if err := newWindowFn(name, buildLaunchCommand(m.config, tool, command, opts)); err != nil {
+		m.homeNotice = fmt.Sprintf("failed to open %s window: %v", tool, err)
 		return m, nil
 	}
+	return m.requestAttachSession(name)
 }
 
-func validSessionName(name string) bool {
-	if name == "" {
-		return false
+// launchOptions returns the n-menu toggles for a new session of tool, with
+// global yolo overriding the per-session auto/yolo choice.
+func (m model) launchOptions(tool string) LaunchOptions {
+	if tool == scratchTool {
+		return LaunchOptions{}
 	}
-	for _, r := range name {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
-			continue
-		}
-		switch r {
-		case '-', '_', '.', ' ':
-			continue
-		default:
-			return false
-		}
+	opts := LaunchOptions{Fresh: m.newToolFresh, Auto: m.newToolAuto, Yolo: m.newToolYolo}
+	if m.globalYolo {
+		opts.Auto, opts.Yolo = false, true
 	}
-	return true
+	return opts
 }
 
-func (m model) beginRenameTarget(name string) model {
-	m.mode = modeRenameInput
-	m.renameTarget = name
-	m.renameInput = name
-	m.renameCursor = len(name)
-	m.homeNotice = ""
-	return m
-}
+// sessionImportance scores how likely name is the session the user is
+// reaching for: active sessions and ones with running tasks first, fresh
+// sessions ahead of ones nobody has attached to in a day.
+func (m model) sessionImportance(name string) int {
+	score := 0
+	if sess, ok := m.sessions[name]; ok && sess != nil && sessionActiveFn(sess) {
+		score += 10
+	}
+	if m.taskCounts[name] > 0 {
+		score += 5
+	}
+	binding := m.bindings[name]
+	if !binding.Created.IsZero() && time.Since(binding.Created) < time.Hour {
+		score += 3
+	}
+	if !binding.LastAttached.IsZero() && time.Since(binding.LastAttached) > 24*time.Hour {
+		score -= 5
+	}
+	return score
+}
+
+// pickerOrder lists tool's running sessions in the order picker keys are
+// assigned.
+func (m model) pickerOrder(tool string) []string {
+	return m.sortPickerTargets(m.runningToolSessions(tool))
+}
+
+// sortPickerTargets orders names by the current pickerSortOrder.
+func (m model) sortPickerTargets(names []string) []string {
+	if m.pickerSortOrder != sortAlpha {
+		return m.orderByImportance(names)
+	}
+	ordered := append([]string(nil), names...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return m.lessByPriority(ordered[i], ordered[j])
+	})
+	return ordered
+}
+
+// togglePickerSort switches between importance and alphabetical order and
+// reassigns the open picker's keys in the new order.
+func (m model) togglePickerSort() model {
+	if m.pickerSortOrder == sortAlpha {
+		m.pickerSortOrder = sortImportance
+	} else {
+		m.pickerSortOrder = sortAlpha
+	}
+	m.pickerNames = m.sortPickerTargets(m.pickerNames)
+	m, _ = m.assignPickerKeys()
+	return m
+}
+
+// pickerSortHint is the footer shown under picker rows.
+func (m model) pickerSortHint() string {
+	order := "activity"
+	if m.pickerSortOrder == sortAlpha {
+		order = "name"
+	}
+	if m.pickerFiltering {
+		return fmt.Sprintf("enter done   tab toggle sort (by %s)   esc clear filter", order)
+	}
+	return fmt.Sprintf("/ filter   tab toggle sort (by %s)   esc cancel", order)
+}
+
+// pickerFilterLine renders the picker's filter prompt, or "" when no
+// filter is active.
+func (m model) pickerFilterLine() string {
+	if !m.pickerFiltering && m.pickerFilter == "" {
+		return ""
+	}
+	line := "filter: " + m.pickerFilter
+	if m.pickerFiltering {
+		line += lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF")).Bold(true).Render("▌")
+	}
+	return line
+}
+
+// orderByImportance sorts names by priority label, then by descending
+// sessionImportance, keeping the given order within a score.
+func (m model) orderByImportance(names []string) []string {
+	ordered := append([]string(nil), names...)
+	scores := make(map[string]int, len(ordered))
+	for _, name := range ordered {
+		scores[name] = m.sessionImportance(name)
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if c := m.comparePriority(ordered[i], ordered[j]); c != 0 {
+			return c < 0
+		}
+		return scores[ordered[i]] > scores[ordered[j]]
+	})
+	return ordered
+}
+
+// pickerLetters maps tool's names to the picker key each gets, so row
+// hints match the picker even though rows stay sorted by name. While a
+// picker for tool is open its keys are used as assigned, so the hints hold
+// still as activity changes.
+func (m model) pickerLetters(tool string, names []string) map[string]string {
+	letters := make(map[string]string)
+	if m.pickerOpen() && tool == m.pickerTool {
+		for _, entry := range m.pickerEntries {
+			if _, ok := letters[entry.session]; !ok {
+				letters[entry.session] = entry.key
+			}
+		}
+		return letters
+	}
+	for i, name := range m.sortPickerTargets(names) {
+		if letter := pickerKey(i); letter != "" {
+			letters[name] = letter
+		}
+	}
+	return letters
+}
+
+// pickerOpen reports whether a session picker is taking keys.
+func (m model) pickerOpen() bool {
+	switch m.mode {
+	case modePickAttach, modePickKill, modePickRename, modePickMemo, modePickNote, modePickSend:
+		return true
+	}
+	return false
+}
+
+func (m model) preparePicker(tool string, pickMode uiMode) model {
+	return m.openPicker(tool, pickMode, m.runningToolSessions(tool))
+}
+
+// openPicker enters pickMode offering names, with no filter. The names are
+// sorted once here; keys keep that order until the picker closes or tab
+// toggles the sort.
+func (m model) openPicker(tool string, pickMode uiMode, names []string) model {
+	m.mode = pickMode
+	m.pickerTool = tool
+	m.pickerNames = m.sortPickerTargets(names)
+	m.pickerFiltering = false
+	return m.setPickerFilter("")
+}
+
+// setPickerFilter narrows the open picker to sessions fuzzy-matching
+// filter and reassigns keys to the matches.
+func (m model) setPickerFilter(filter string) model {
+	m.pickerFilter = filter
+	var truncated bool
+	m, truncated = m.assignPickerKeys()
+	if truncated {
+		m.homeNotice = "showing first 26 sessions (/ to filter)"
+	} else {
+		m.homeNotice = ""
+	}
+	return m
+}
+
+// assignPickerKeys gives keys, in pickerNames order, to the sessions
+// matching pickerFilter. truncated reports that the keys ran out.
+func (m model) assignPickerKeys() (model, bool) {
+	shown := make([]string, 0, len(m.pickerNames))
+	for _, name := range m.pickerNames {
+		if _, ok := fuzzyScore(m.pickerFilter, name); ok {
+			shown = append(shown, name)
+		}
+	}
+	var truncated bool
+	m.pickerEntries, truncated = m.pickerEntriesFor(shown)
+	return m, truncated
+}
+
+// updatePickerFilter handles keys in a picker: / starts filtering, tab
+// toggles the sort, and while filtering keys edit the filter. handled is
+// false for keys the picker mode itself should see.
+func (m model) updatePickerFilter(msg tea.KeyMsg) (model, bool) {
+	switch {
+	case msg.Type == tea.KeyTab:
+		return m.togglePickerSort(), true
+	case !m.pickerFiltering:
+		switch {
+		case msg.String() == "/":
+			m.pickerFiltering = true
+			return m, true
+		case msg.Type == tea.KeyEsc && m.pickerFilter != "":
+			// The first esc drops a kept filter; the next leaves the picker.
+			return m.setPickerFilter(""), true
+		}
+		return m, false
+	case msg.Type == tea.KeyEsc:
+		m.pickerFiltering = false
+		return m.setPickerFilter(""), true
+	case msg.Type == tea.KeyEnter:
+		m.pickerFiltering = false
+		return m, true
+	case msg.Type == tea.KeyBackspace:
+		filter := []rune(m.pickerFilter)
+		if len(filter) > 0 {
+			filter = filter[:len(filter)-1]
+		}
+		return m.setPickerFilter(string(filter)), true
+	case msg.Type == tea.KeyRunes, msg.Type == tea.KeySpace:
+		return m.setPickerFilter(m.pickerFilter + string(msg.Runes)), true
+	}
+	return m, true
+}
+
+// pickerEntriesFor assigns picker keys to names in order. In the attach
+// picker with reuse_sessions, a session with several windows gets a key per
+// window. truncated reports that the keys ran out.
+func (m model) pickerEntriesFor(names []string) (entries []pickerEntry, truncated bool) {
+	for _, name := range names {
+		windows := []pickerEntry{{session: name}}
+		if m.mode == modePickAttach && m.reuseSessions() {
+			if w := sessionWindows(name); len(w) > 1 {
+				windows = w
+			}
+		}
+		for _, entry := range windows {
+			entry.key = pickerKey(len(entries))
+			if entry.key == "" {
+				return entries, true
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, false
+}
+
+// sessionWindows returns one picker entry per window of sessionName,
+// targeting each window's active pane.
+func sessionWindows(sessionName string) []pickerEntry {
+	panes, err := listPanesFn(sessionName)
+	if err != nil {
+		return nil
+	}
+	var windows []pickerEntry
+	seen := make(map[int]int)
+	for _, p := range panes {
+		i, ok := seen[p.WindowIndex]
+		if !ok {
+			seen[p.WindowIndex] = len(windows)
+			windows = append(windows, pickerEntry{session: sessionName, window: p.WindowIndex,
+				target: tmux.PaneTarget(sessionName, p.WindowIndex, p.PaneIndex)})
+			continue
+		}
+		if p.Active {
+			windows[i].target = tmux.PaneTarget(sessionName, p.WindowIndex, p.PaneIndex)
+		}
+	}
+	return windows
+}
+
+// pickerEntryFor returns the picker entry selected by key.
+func (m model) pickerEntryFor(key string) (pickerEntry, bool) {
+	for _, entry := range m.pickerEntries {
+		if entry.key == key {
+			return entry, true
+		}
+	}
+	return pickerEntry{}, false
+}
+
+// pickerTarget returns the session selected by a picker key.
+func (m model) pickerTarget(key string) (string, bool) {
+	entry, ok := m.pickerEntryFor(key)
+	return entry.session, ok
+}
+
+func (m model) handleToolAttach(tool string) (model, tea.Cmd) {
+	targets := m.runningToolSessions(tool)
+	switch len(targets) {
+	case 0:
+		return m.createAndAttachTool(tool)
+	case 1:
+		if m.reuseSessions() && len(sessionWindows(targets[0])) > 1 {
+			return m.preparePicker(tool, modePickAttach), nil
+		}
+		return m.startAndAttachSession(targets[0], "")
+	default:
+		m = m.preparePicker(tool, modePickAttach)
+		return m, nil
+	}
+}
+
+// handleToolTap holds a tool key press for doubleTapWindow: a second press
+// of the same key inside it kills the tool's newest session, otherwise
+// finishToolTap attaches once the window closes.
+func (m model) handleToolTap(tool, key string, now time.Time) (model, tea.Cmd) {
+	if m.lastKey == key && now.Sub(m.lastKeyTime) < doubleTapWindow {
+		m.lastKey, m.lastKeyTime = "", time.Time{}
+		return m.doubleTapKill(tool)
+	}
+	m.lastKey, m.lastKeyTime = key, now
+	return m, tea.Tick(doubleTapWindow, func(time.Time) tea.Msg { return doubleTapMsg{key: key, at: now} })
+}
+
+// finishToolTap attaches for a single tool key press once its double-tap
+// window closes. Presses already consumed by a double tap, or overtaken by
+// a later press, are ignored.
+func (m model) finishToolTap(msg doubleTapMsg) (model, tea.Cmd) {
+	if m.lastKey != msg.key || !m.lastKeyTime.Equal(msg.at) {
+		return m, nil
+	}
+	m.lastKey, m.lastKeyTime = "", time.Time{}
+	tool := m.toolForKey(msg.key)
+	if m.mode != modeHome || tool == "" {
+		return m, nil
+	}
+	return m.handleToolAttach(tool)
+}
+
+// doubleTapKill kills tool's most recently created session.
+func (m model) doubleTapKill(tool string) (model, tea.Cmd) {
+	if m.blockMutation() {
+		return m, nil
+	}
+	target := m.newestToolSession(tool)
+	if target == "" {
+		m.homeNotice = fmt.Sprintf("no %s sessions running", tool)
+		return m, nil
+	}
+	m.homeNotice = fmt.Sprintf("stopping %s...", target)
+	return m, m.killSessionCmd(target, fmt.Sprintf("double-tap kill: killed %s", target))
+}
+
+// newestToolSession returns tool's running session with the latest
+// creation time, preferring the later name on ties; "" if none run.
+func (m model) newestToolSession(tool string) string {
+	newest := ""
+	for _, name := range m.runningToolSessions(tool) {
+		if newest == "" {
+			newest = name
+			continue
+		}
+		created, best := m.bindings[name].Created, m.bindings[newest].Created
+		if created.After(best) || (created.Equal(best) && name > newest) {
+			newest = name
+		}
+	}
+	return newest
+}
+
+// killOptions returns the configured pre-kill keys for name: a custom
+// session's own entry, or its tool's for claude/codex/cursor sessions.
+func (m model) killOptions(name string) tmux.KillOptions {
+	if m.config == nil {
+		return tmux.KillOptions{}
+	}
+	sessions := m.config.AllSessions()
+	for _, target := range []string{name, m.sessionTool(name)} {
+		for _, sess := range sessions {
+			if sess.Name == target {
+				return tmux.KillOptions{PreKillKeys: sess.PreKillKeys}
+			}
+		}
+	}
+	return tmux.KillOptions{}
+}
+
+// killSessionCmd kills name off the Update loop, since configured pre-kill
+// keys make the kill wait for the tool to exit. The launch lock is looked
+// up now, while the session's binding is still known.
+func (m model) killSessionCmd(name, notice string) tea.Cmd {
+	opts := m.killOptions(name)
+	cwd, tool := m.dirLockOf(name)
+	return func() tea.Msg {
+		if err := killSessionFn(name, opts); err != nil {
+			return sessionKilledMsg{name: name, err: err}
+		}
+		removeDirLock(cwd, tool, name)
+		return sessionKilledMsg{name: name, notice: notice}
+	}
+}
+
+// finishKill forgets a session killed by killSessionCmd, or reports why
+// the kill failed.
+func (m model) finishKill(msg sessionKilledMsg) model {
+	if msg.err != nil {
+		m.homeNotice = fmt.Sprintf("failed to stop %s: %v", msg.name, msg.err)
+		return m
+	}
+	delete(m.sessions, msg.name)
+	delete(m.sessionTools, msg.name)
+	delete(m.starting, msg.name)
+	m.refreshBindings()
+	m.homeNotice = msg.notice
+	return m
+}
+
+func (m model) handleToolKill(tool string) (model, tea.Cmd) {
+	targets := m.runningToolSessions(tool)
+	switch len(targets) {
+	case 0:
+		m.homeNotice = fmt.Sprintf("no %s sessions running", tool)
+		m.mode = modeHome
+		return m, nil
+	case 1:
+		m.mode = modeHome
+		m.homeNotice = fmt.Sprintf("stopping %s...", targets[0])
+		return m, m.killSessionCmd(targets[0], fmt.Sprintf("stopped %s", targets[0]))
+	default:
+		m = m.preparePicker(tool, modePickKill)
+		return m, nil
+	}
+}
+
+func validSessionName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			continue
+		}
+		switch r {
+		case '-', '_', '.', ' ':
+			continue
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (m model) beginRenameTarget(name string) model {
+	m.mode = modeRenameInput
+	m.renameTarget = name
+	m.renameInput = name
+	m.renameCursor = len(name)
+	m.renameSuggestion = 0
+	m.homeNotice = ""
+	return m
+}
+
+// renameSuggestions lists session names that extend prefix, for completing
+// the rename input.
+func (m model) renameSuggestions(prefix string) []string {
+	if prefix == "" {
+		return nil
+	}
+	var out []string
+	for name := range m.sessions {
+		if name != prefix && strings.HasPrefix(name, prefix) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// renameHint is the completion shown after the rename cursor, or "" when
+// nothing matches or the cursor is not at the end of the input.
+func (m model) renameHint() string {
+	if m.renameCursor != len(m.renameInput) {
+		return ""
+	}
+	suggestions := m.renameSuggestions(m.renameInput)
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return suggestions[m.renameSuggestion%len(suggestions)]
+}
 
 func (m model) applyRenameTarget() model {
 	oldName := strings.TrimSpace(m.renameTarget)
 	newName := strings.TrimSpace(m.renameInput)
+	force := m.renameForce
+	m.renameForce = false
 	if oldName == "" {
 		m.mode = modeHome
 		m.homeNotice = "no rename target selected"
@@ -879,18 +2467,29 @@ func (m model) applyRenameTarget() model {
 		m.homeNotice = fmt.Sprintf("session %s already exists", newName)
 		return m
 	}
-	if err := renameSessionFn(oldName, newName); err != nil {
+	rename := renameSessionFn
+	if force {
+		rename = forceRenameFn
+	}
+	if err := rename(oldName, newName); err != nil {
+		if errors.Is(err, tmux.ErrSessionAttached) {
+			m.homeNotice = fmt.Sprintf("%s is currently attached; detach first or use shift+enter to force", oldName)
+			return m
+		}
 		m.homeNotice = fmt.Sprintf("failed to rename %s: %v", oldName, err)
 		return m
 	}
 	tool := m.sessionTool(oldName)
+	if cwd := m.bindings[oldName].Cwd; cwd != "" && readDirLock(cwd, tool) == oldName {
+		_ = writeDirLock(cwd, tool, newName)
+	}
 
 	if _, ok := m.sessions[oldName]; ok {
 		delete(m.sessions, oldName)
 	}
 	delete(m.sessionTools, oldName)
 	command := m.commandForTool(tool)
-	m.sessions[newName] = tmux.NewSession(newName, command)
+	m.sessions[newName] = newSessionFor(m.config, newName, tool, command)
 	_ = setSessionToolFn(newName, tool)
 	m.rememberSessionTool(newName, tool)
 	delete(m.bindings, oldName)
@@ -900,73 +2499,474 @@ func (m model) applyRenameTarget() model {
 	m.mode = modeHome
 	m.refreshBindings()
 	m.homeNotice = fmt.Sprintf("renamed %s to %s", oldName, newName)
+	if max := m.maxNameDisplay(); lipgloss.Width(newName) > max {
+		m.homeNotice += fmt.Sprintf(" (names over %d chars are shortened on screen)", max)
+	}
 	return m
 }
 
-func (m model) Init() tea.Cmd {
-	return tickCmd
-}
-
-func (m *model) refreshTaskCounts() {
-	if m.taskCounts == nil {
-		m.taskCounts = make(map[string]int)
-	}
-	if m.taskCommands == nil {
-		m.taskCommands = make(map[string][]string)
-	}
-	now := time.Now()
-	if !m.taskRefreshAt.IsZero() && now.Sub(m.taskRefreshAt) < 900*time.Millisecond {
-		return
-	}
-
-	next := make(map[string]int)
-	nextCommands := make(map[string][]string)
-	for name, sess := range m.sessions {
-		if sess == nil || !sess.IsRunning() {
-			continue
-		}
-		tasks, err := sessionUserTasksFn(name)
-		if err != nil {
-			continue
+// editTextInput applies a line-editing key to text with the cursor at byte
+// offset cursor, returning the updated text and cursor. Unhandled keys leave
+// both unchanged.
+func editTextInput(text string, cursor int, msg tea.KeyMsg) (string, int) {
+	key := msg.String()
+	switch {
+	case msg.Type == tea.KeyLeft:
+		if cursor > 0 {
+			cursor--
+		}
+	case msg.Type == tea.KeyRight:
+		if cursor < len(text) {
+			cursor++
+		}
+	case key == "ctrl+a", msg.Type == tea.KeyHome:
+		cursor = 0
+	case key == "ctrl+e", msg.Type == tea.KeyEnd:
+		cursor = len(text)
+	case key == "ctrl+u":
+		text = text[cursor:]
+		cursor = 0
+	case key == "ctrl+k":
+		text = text[:cursor]
+	case key == "ctrl+w":
+		if cursor > 0 {
+			i := cursor - 1
+			for i > 0 && text[i-1] == ' ' {
+				i--
+			}
+			for i > 0 && text[i-1] != ' ' {
+				i--
+			}
+			text = text[:i] + text[cursor:]
+			cursor = i
 		}
-		next[name] = len(tasks)
-		if len(tasks) > 0 {
-			nextCommands[name] = summarizeTaskCommands(tasks, 2)
+	case msg.Type == tea.KeyBackspace, msg.Type == tea.KeyDelete:
+		if cursor > 0 {
+			text = text[:cursor-1] + text[cursor:]
+			cursor--
 		}
+	case msg.Type == tea.KeyRunes:
+		text = text[:cursor] + string(msg.Runes) + text[cursor:]
+		cursor += len(string(msg.Runes))
 	}
-	m.taskCounts = next
-	m.taskCommands = nextCommands
-	m.taskRefreshAt = now
+	return text, cursor
 }
 
-func summarizeTaskCommands(tasks []tmux.Task, max int) []string {
-	if max <= 0 || len(tasks) == 0 {
-		return nil
-	}
-	out := make([]string, 0, max+1)
-	for i, t := range tasks {
-		if i >= max {
-			out = append(out, fmt.Sprintf("+%d more", len(tasks)-max))
-			break
-		}
-		out = append(out, t.Command)
+func (m model) beginMemoTarget(name string) model {
+	memo := ""
+	if binding, ok := m.bindings[name]; ok {
+		memo = binding.Memo
 	}
-	return out
+	m.mode = modeMemoInput
+	m.memoTarget = name
+	m.memoInput = memo
+	m.memoCursor = len(memo)
+	m.homeNotice = ""
+	return m
 }
 
-func (m model) runningSessionNames() []string {
-	var names []string
-	for name, binding := range m.bindings {
-		if !binding.Running {
-			continue
-		}
-		names = append(names, name)
-	}
-	sort.Strings(names)
-	return names
+func (m model) clearMemoInput() model {
+	m.memoTarget = ""
+	m.memoInput = ""
+	m.memoCursor = 0
+	return m
+}
+
+// applyMemoTarget stores the memo on the tmux session. An empty memo clears it.
+func (m model) applyMemoTarget() model {
+	name := m.memoTarget
+	memo := strings.TrimSpace(m.memoInput)
+	if name == "" {
+		m.mode = modeHome
+		m.homeNotice = "no memo target selected"
+		return m
+	}
+	if err := setSessionOptionFn(name, "@pb_memo", memo); err != nil {
+		m.homeNotice = fmt.Sprintf("failed to save memo for %s: %v", name, err)
+		return m
+	}
+	if binding, ok := m.bindings[name]; ok {
+		binding.Memo = memo
+		m.bindings[name] = binding
+	}
+	m = m.clearMemoInput()
+	m.mode = modeHome
+	if memo == "" {
+		m.homeNotice = fmt.Sprintf("cleared memo for %s", name)
+	} else {
+		m.homeNotice = fmt.Sprintf("saved memo for %s", name)
+	}
+	return m
+}
+
+func (m model) beginSendTarget(name string) model {
+	m.mode = modeSendKeys
+	m.sendTarget = name
+	m.sendInput = ""
+	m.sendCursor = 0
+	m.homeNotice = ""
+	return m
+}
+
+func (m model) clearSendInput() model {
+	m.sendTarget = ""
+	m.sendInput = ""
+	m.sendCursor = 0
+	return m
+}
+
+// applySendKeys types sendInput, followed by Enter, into sendTarget. The
+// session may have exited since it was picked; that is reported rather
+// than recreating it.
+func (m model) applySendKeys() model {
+	name := m.sendTarget
+	text := m.sendInput
+	if strings.TrimSpace(text) == "" {
+		m.homeNotice = "nothing to send"
+		return m
+	}
+	m = m.clearSendInput()
+	m.mode = modeHome
+	if !sessionExistsFn(name) {
+		m.homeNotice = fmt.Sprintf("%s is no longer running", name)
+		return m
+	}
+	if err := sendKeysFn(name, text); err != nil {
+		m.homeNotice = fmt.Sprintf("failed to send to %s: %v", name, err)
+		return m
+	}
+	m.homeNotice = fmt.Sprintf("sent to %s", name)
+	return m
+}
+
+// Notes are capped so they fit in the tooltip box.
+const (
+	maxNoteLines = 5
+	maxNoteRunes = 500
+)
+
+// encodeNote stores a note base64-encoded so newlines and quotes survive
+// tmux option parsing.
+func encodeNote(note string) string {
+	note = limitNote(note)
+	if note == "" {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString([]byte(note))
+}
+
+// decodeNote reads an @pb_note value, returning "" if it is not valid
+// base64. The result is capped like any saved note.
+func decodeNote(raw string) string {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	return limitNote(string(data))
+}
+
+// limitNote trims trailing blank space and cuts a note to maxNoteLines
+// lines and maxNoteRunes characters.
+func limitNote(note string) string {
+	lines := strings.Split(strings.TrimRight(note, " \t\n"), "\n")
+	if len(lines) > maxNoteLines {
+		lines = lines[:maxNoteLines]
+	}
+	note = strings.Join(lines, "\n")
+	if runes := []rune(note); len(runes) > maxNoteRunes {
+		note = string(runes[:maxNoteRunes])
+	}
+	return note
+}
+
+// renderNoteBox draws a note in a rounded border for the row tooltip.
+func renderNoteBox(note string) string {
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("#888888")).
+		Padding(0, 1).
+		Render(note)
+}
+
+func (m model) beginNoteTarget(name string) model {
+	note := m.bindings[name].Note
+	m.mode = modeNoteInput
+	m.noteTarget = name
+	m.noteInput = note
+	m.noteCursor = len(note)
+	m.homeNotice = ""
+	return m
+}
+
+func (m model) clearNoteInput() model {
+	m.noteTarget = ""
+	m.noteInput = ""
+	m.noteCursor = 0
+	return m
+}
+
+// applyNoteTarget stores the note on the tmux session. An empty note clears
+// it.
+func (m model) applyNoteTarget() model {
+	name := m.noteTarget
+	note := limitNote(strings.TrimSpace(m.noteInput))
+	if name == "" {
+		m.mode = modeHome
+		m.homeNotice = "no note target selected"
+		return m
+	}
+	if err := setSessionOptionFn(name, "@pb_note", encodeNote(note)); err != nil {
+		m.homeNotice = fmt.Sprintf("failed to save note for %s: %v", name, err)
+		return m
+	}
+	if binding, ok := m.bindings[name]; ok {
+		binding.Note = note
+		m.bindings[name] = binding
+	}
+	m = m.clearNoteInput()
+	m.mode = modeHome
+	if note == "" {
+		m.homeNotice = fmt.Sprintf("cleared note for %s", name)
+	} else {
+		m.homeNotice = fmt.Sprintf("saved note for %s", name)
+	}
+	return m
+}
+
+func (m model) clearSearch() model {
+	m.searchQuery = ""
+	m.searchCursor = 0
+	return m
+}
+
+// searchMatches returns running tool sessions whose name contains the search
+// query (case-insensitive), in home screen order.
+func (m model) searchMatches() []string {
+	query := strings.ToLower(strings.TrimSpace(m.searchQuery))
+	var out []string
+	for _, tool := range allTools(m.config) {
+		for _, name := range m.runningToolSessions(tool) {
+			if query == "" || strings.Contains(strings.ToLower(name), query) {
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
+
+func (m model) Init() tea.Cmd {
+	return tea.Batch(tickCmd, m.cpuSampleCmd(), m.titleCmd())
+}
+
+// cpuSampleCmd samples the CPU of the sessions bound now, after
+// cpuSampleInterval, off the UI goroutine. The cpuUpdateMsg handler
+// schedules the next sample.
+func (m model) cpuSampleCmd() tea.Cmd {
+	names := make([]string, 0, len(m.bindings))
+	for name := range m.bindings {
+		names = append(names, name)
+	}
+	return tea.Tick(cpuSampleInterval, func(time.Time) tea.Msg {
+		pct := make(map[string]float64, len(names))
+		for _, name := range names {
+			if v, err := sessionCPUFn(name); err == nil {
+				pct[name] = v
+			}
+		}
+		return cpuUpdateMsg{pct: pct}
+	})
+}
+
+// cpuWarnThreshold returns the CPU percentage above which a row is flagged.
+func (m model) cpuWarnThreshold() float64 {
+	if m.config == nil || m.config.UI.CPUWarnThreshold <= 0 {
+		return config.DefaultCPUWarnThreshold
+	}
+	return m.config.UI.CPUWarnThreshold
+}
+
+// cpuBadge returns the cpu:N% badge text for name, or "" when its last
+// sample is not above the threshold.
+func (m model) cpuBadge(name string) string {
+	pct, ok := m.sessionCpuPct[name]
+	if !ok || pct <= m.cpuWarnThreshold() {
+		return ""
+	}
+	return fmt.Sprintf("cpu:%.0f%%", pct)
+}
+
+func (m *model) refreshTaskCounts() {
+	if m.taskCounts == nil {
+		m.taskCounts = make(map[string]int)
+	}
+	if m.taskCommands == nil {
+		m.taskCommands = make(map[string][]string)
+	}
+	now := time.Now()
+	if !m.taskRefreshAt.IsZero() && now.Sub(m.taskRefreshAt) < 900*time.Millisecond {
+		return
+	}
+
+	next := make(map[string]int)
+	nextCommands := make(map[string][]string)
+	var running []string
+	for name, sess := range m.sessions {
+		if sess == nil || !sess.IsRunning() {
+			continue
+		}
+		running = append(running, name)
+		tasks, err := sessionUserTasksFn(name)
+		if err != nil {
+			continue
+		}
+		next[name] = len(tasks)
+		if len(tasks) > 0 {
+			nextCommands[name] = summarizeTaskCommands(tasks, 2)
+		}
+	}
+	m.taskCounts = next
+	m.taskCommands = nextCommands
+	// Keep the last result if ps fails rather than hiding a known zombie.
+	if zombies, err := zombieSessionsFn(running); err == nil {
+		m.zombieSessions = zombies
+	}
+	m.taskRefreshAt = now
+}
+
+// hasZombieSessions reports whether any session's pane process is a zombie.
+func (m model) hasZombieSessions() bool {
+	for _, zombie := range m.zombieSessions {
+		if zombie {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *model) refreshWarnings() {
+	captures := make(map[string]string, len(m.sessions))
+	for name, sess := range m.sessions {
+		if sess == nil {
+			continue
+		}
+		captures[name] = sess.LastCapture()
+	}
+	m.applyWarnings(captures)
+}
+
+// applyWarnings classifies pane captures against the configured warning
+// patterns and, when enabled, raises a notice for newly warned sessions.
+func (m *model) applyWarnings(captures map[string]string) {
+	if m.config == nil {
+		return
+	}
+	next := make(map[string]string)
+	var raised []string
+	for name, capture := range captures {
+		warning := tmux.MatchWarning(capture, m.config.Warnings.Patterns)
+		if warning == "" {
+			continue
+		}
+		next[name] = warning
+		if m.sessionWarnings[name] != warning {
+			raised = append(raised, fmt.Sprintf("%s: %s", name, warning))
+		}
+	}
+	m.sessionWarnings = next
+	if m.config.Warnings.Notify && len(raised) > 0 {
+		sort.Strings(raised)
+		m.homeNotice = "warning " + strings.Join(raised, ", ")
+	}
+}
+
+// refreshUnseenOutput marks sessions whose last activity capture no longer
+// matches the hash recorded at their last attach. It reuses the captures
+// updateActivity already took and the hashes refreshBindings already read,
+// so it costs no tmux calls. Sessions that were never attached have no
+// recorded hash and are not marked.
+func (m *model) refreshUnseenOutput() {
+	next := make(map[string]bool)
+	for name, binding := range m.bindings {
+		sess := m.sessions[name]
+		if !binding.Running || binding.SeenHash == "" || sess == nil {
+			continue
+		}
+		content := sessionCaptureFn(sess)
+		if content == "" {
+			continue
+		}
+		if tmux.PaneHash(content) != binding.SeenHash {
+			next[name] = true
+		}
+	}
+	m.unseenOutput = next
+}
+
+// markSessionSeen records the current pane as seen so the "new" badge clears.
+// The session's activity snapshot is reset to the same capture, so output
+// that was on screen at attach is not later reported as unseen.
+func (m *model) markSessionSeen(name string) {
+	delete(m.unseenOutput, name)
+	hash := ""
+	if sess := m.sessions[name]; sess != nil {
+		if content, err := resetBaselineFn(sess); err == nil {
+			hash = tmux.PaneHash(content)
+			_ = setSeenHashFn(name, hash)
+		}
+	}
+	if hash == "" {
+		hash = recordSessionSeen(name)
+	}
+	if binding, ok := m.bindings[name]; ok && hash != "" {
+		binding.SeenHash = hash
+		m.bindings[name] = binding
+	}
+}
+
+// recordSessionSeen stores a hash of the session's current pane, which later
+// activity captures are compared against to decide whether there is unseen
+// output. It returns the hash, or "" if the pane could not be captured.
+func recordSessionSeen(name string) string {
+	content, err := captureRecentFn(name)
+	if err != nil {
+		return ""
+	}
+	hash := tmux.PaneHash(content)
+	_ = setSeenHashFn(name, hash)
+	return hash
+}
+
+func summarizeTaskCommands(tasks []tmux.Task, max int) []string {
+	if max <= 0 || len(tasks) == 0 {
+		return nil
+	}
+	out := make([]string, 0, max+1)
+	for i, t := range tasks {
+		if i >= max {
+			out = append(out, fmt.Sprintf("+%d more", len(tasks)-max))
+			break
+		}
+		out = append(out, t.Command)
+	}
+	return out
+}
+
+func (m model) runningSessionNames() []string {
+	var names []string
+	for name, binding := range m.bindings {
+		if !binding.Running {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func (m model) enterTaskKillPicker() (model, tea.Cmd) {
+	if m.blockMutation() {
+		return m, nil
+	}
 	targets := make([]taskKillTarget, 0)
 	for _, name := range m.runningSessionNames() {
 		tasks, err := sessionUserTasksFn(name)
@@ -1004,7 +3004,143 @@ func (m model) enterTaskKillPicker() (model, tea.Cmd) {
 	return m, nil
 }
 
+// killAllTasks sends SIGTERM (via killTaskPIDFn) to every user task in
+// every running session concurrently and counts successes and failures.
+func (m model) killAllTasks() (killed, errs int) {
+	return killSessionTasks(m.runningSessionNames())
+}
+
+// killSessionTasks sends SIGTERM to every user task in the named sessions
+// concurrently, counting each pid once.
+func killSessionTasks(names []string) (killed, errs int) {
+	seen := make(map[int]bool)
+	var pids []int
+	for _, name := range names {
+		tasks, err := sessionUserTasksFn(name)
+		if err != nil {
+			continue
+		}
+		for _, task := range tasks {
+			if !seen[task.PID] {
+				seen[task.PID] = true
+				pids = append(pids, task.PID)
+			}
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, pid := range pids {
+		wg.Add(1)
+		go func(pid int) {
+			defer wg.Done()
+			err := killTaskPIDFn(pid)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs++
+			} else {
+				killed++
+			}
+		}(pid)
+	}
+	wg.Wait()
+	return killed, errs
+}
+
+// enterTaskKillAllMode asks to confirm killing every task in every session
+// (T in kill mode).
+func (m model) enterTaskKillAllMode() (model, tea.Cmd) {
+	if m.blockMutation() {
+		return m, nil
+	}
+	m.mode = modeConfirmKillAllTasks
+	m.homeNotice = ""
+	return m, nil
+}
+
+// killAllTasksCmd kills every task off the Update loop, since listing the
+// tasks of each session shells out to ps. The session names are taken now.
+func (m model) killAllTasksCmd() tea.Cmd {
+	names := m.runningSessionNames()
+	return func() tea.Msg {
+		killed, errs := killSessionTasks(names)
+		return tasksKilledMsg{killed: killed, errs: errs}
+	}
+}
+
+// finishKillAllTasks reports the result of a killAllTasksCmd.
+func (m model) finishKillAllTasks(msg tasksKilledMsg) model {
+	switch {
+	case msg.killed == 0 && msg.errs == 0:
+		m.homeNotice = "no tasks to kill"
+	case msg.errs == 0:
+		m.homeNotice = fmt.Sprintf("killed %d tasks", msg.killed)
+	default:
+		m.homeNotice = fmt.Sprintf("killed %d tasks, %d errors", msg.killed, msg.errs)
+	}
+	m.refreshTaskCounts()
+	return m
+}
+
+// errorEntry is one error notice kept in the model's errorLog.
+type errorEntry struct {
+	msg string
+	ts  time.Time
+}
+
+// maxErrorLog is how many recent errors the error log keeps.
+const maxErrorLog = 20
+
+// isErrorNotice reports whether a home notice describes a failure.
+func isErrorNotice(notice string) bool {
+	for _, prefix := range []string{"failed", "error", "Error"} {
+		if strings.HasPrefix(notice, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// logError appends msg to the error log, dropping the oldest entry once it
+// holds maxErrorLog.
+func (m model) logError(msg string, ts time.Time) model {
+	entries := make([]errorEntry, 0, maxErrorLog)
+	if len(m.errorLog) >= maxErrorLog {
+		entries = append(entries, m.errorLog[len(m.errorLog)-maxErrorLog+1:]...)
+	} else {
+		entries = append(entries, m.errorLog...)
+	}
+	m.errorLog = append(entries, errorEntry{msg: msg, ts: ts})
+	return m
+}
+
+func (m model) clearErrorLog() model {
+	m.errorLog = nil
+	return m
+}
+
+// Update records any new error notice in the error log, since the next
+// action usually overwrites homeNotice.
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	prev := m.homeNotice
+	next, cmd := m.update(msg)
+	nm, ok := next.(model)
+	if !ok {
+		return next, cmd
+	}
+	if nm.homeNotice != prev && isErrorNotice(nm.homeNotice) {
+		nm = nm.logError(nm.homeNotice, time.Now())
+	}
+	// Retitle before cmd runs, so an attach's tea.Quit leaves the session
+	// name in the title.
+	if title := nm.terminalTitle(); title != m.terminalTitle() {
+		cmd = tea.Sequence(nm.titleCmd(), cmd)
+	}
+	return nm, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		// Handle keys based on current view state
@@ -1015,13 +3151,32 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateAttached(msg)
 		}
 	case tickMsg:
+		m.tickCount++
 		m.refreshBindings()
-		// Periodic update to refresh activity status
-		for _, sess := range m.sessions {
-			sess.UpdateActivity()
-		}
+		m.updateActivity()
+		m.recordActivityHistory()
+		m.refreshWarnings()
 		m.refreshTaskCounts()
+		m.refreshUnseenOutput()
+		m.consumeDirPrefetch()
+		if reload := m.configChangeCmd(); reload != nil {
+			return m, tea.Batch(tickCmd, reload)
+		}
 		return m, tickCmd
+	case configChangedMsg:
+		return m.reloadConfig(), nil
+	case tooltipMsg:
+		// Nothing to update; re-rendering shows the tooltip once it is due.
+		return m, nil
+	case doubleTapMsg:
+		return m.finishToolTap(msg)
+	case sessionKilledMsg:
+		return m.finishKill(msg), nil
+	case tasksKilledMsg:
+		return m.finishKillAllTasks(msg), nil
+	case cpuUpdateMsg:
+		m.sessionCpuPct = msg.pct
+		return m, m.cpuSampleCmd()
 	case tea.WindowSizeMsg:
 		m.windowWidth = msg.Width
 		return m, nil
@@ -1034,7 +3189,15 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	// ctrl+c always works regardless of mode
 	if key == "ctrl+c" {
-		tmux.KillServer()
+		// --readonly refuses the kill-all; d still quits.
+		if m.readonly {
+			m.blockMutation()
+			return m, nil
+		}
+		if !readOnlyFn() {
+			releaseAllDirLocks(m.config)
+			tmux.KillServer()
+		}
 		return m, tea.Quit
 	}
 
@@ -1051,59 +3214,166 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.renameInput = ""
 			m.renameCursor = 0
 			return m, nil
+		case key == "shift+enter", msg.Type == tea.KeyEnter && msg.Alt:
+			// Most terminals report shift+enter as alt+enter (or not at all).
+			m.renameForce = true
+			m = m.applyRenameTarget()
+			return m, nil
 		case msg.Type == tea.KeyEnter:
 			m = m.applyRenameTarget()
 			return m, nil
-		case msg.Type == tea.KeyLeft:
-			if m.renameCursor > 0 {
-				m.renameCursor--
+		case msg.Type == tea.KeyTab:
+			if suggestion := m.renameHint(); suggestion != "" {
+				m.renameInput = suggestion
+				m.renameCursor = len(suggestion)
+				m.renameSuggestion = 0
 			}
 			return m, nil
-		case msg.Type == tea.KeyRight:
-			if m.renameCursor < len(m.renameInput) {
-				m.renameCursor++
+		case msg.Type == tea.KeyUp, msg.Type == tea.KeyDown:
+			if n := len(m.renameSuggestions(m.renameInput)); n > 0 {
+				step := 1
+				if msg.Type == tea.KeyUp {
+					step = n - 1
+				}
+				m.renameSuggestion = (m.renameSuggestion + step) % n
 			}
 			return m, nil
-		case key == "ctrl+a", msg.Type == tea.KeyHome:
-			m.renameCursor = 0
+		default:
+			m.renameInput, m.renameCursor = editTextInput(m.renameInput, m.renameCursor, msg)
+			m.renameSuggestion = 0
 			return m, nil
-		case key == "ctrl+e", msg.Type == tea.KeyEnd:
-			m.renameCursor = len(m.renameInput)
+		}
+	case modeMemoInput:
+		switch {
+		case msg.Type == tea.KeyEsc:
+			m = m.clearMemoInput()
+			m.mode = modeHome
+			m.homeNotice = ""
 			return m, nil
-		case key == "ctrl+u":
-			m.renameInput = m.renameInput[m.renameCursor:]
-			m.renameCursor = 0
+		case msg.Type == tea.KeyEnter:
+			m = m.applyMemoTarget()
 			return m, nil
-		case key == "ctrl+k":
-			m.renameInput = m.renameInput[:m.renameCursor]
+		default:
+			m.memoInput, m.memoCursor = editTextInput(m.memoInput, m.memoCursor, msg)
 			return m, nil
-		case key == "ctrl+w":
-			if m.renameCursor > 0 {
-				i := m.renameCursor - 1
-				for i > 0 && m.renameInput[i-1] == ' ' {
-					i--
-				}
-				for i > 0 && m.renameInput[i-1] != ' ' {
-					i--
-				}
-				m.renameInput = m.renameInput[:i] + m.renameInput[m.renameCursor:]
-				m.renameCursor = i
+		}
+	case modeNoteInput:
+		switch {
+		case msg.Type == tea.KeyEsc:
+			m = m.clearNoteInput()
+			m.mode = modeHome
+			m.homeNotice = ""
+			return m, nil
+		case msg.Type == tea.KeyEnter && msg.Alt, msg.Type == tea.KeyCtrlJ:
+			// Terminals report shift+enter as plain enter, so alt+enter (or
+			// ctrl+j) starts a new line.
+			if strings.Count(m.noteInput, "\n") < maxNoteLines-1 {
+				m.noteInput = m.noteInput[:m.noteCursor] + "\n" + m.noteInput[m.noteCursor:]
+				m.noteCursor++
 			}
 			return m, nil
-		case msg.Type == tea.KeyBackspace, msg.Type == tea.KeyDelete:
-			if m.renameCursor > 0 {
-				m.renameInput = m.renameInput[:m.renameCursor-1] + m.renameInput[m.renameCursor:]
-				m.renameCursor--
+		case msg.Type == tea.KeyEnter:
+			m = m.applyNoteTarget()
+			return m, nil
+		default:
+			m.noteInput, m.noteCursor = editTextInput(m.noteInput, m.noteCursor, msg)
+			return m, nil
+		}
+	case modeSendKeys:
+		switch {
+		case msg.Type == tea.KeyEsc:
+			m = m.clearSendInput()
+			m.mode = modeHome
+			m.homeNotice = ""
+			return m, nil
+		case msg.Type == tea.KeyEnter:
+			m = m.applySendKeys()
+			return m, nil
+		default:
+			m.sendInput, m.sendCursor = editTextInput(m.sendInput, m.sendCursor, msg)
+			return m, nil
+		}
+	case modeBroadcastInput:
+		switch {
+		case msg.Type == tea.KeyEsc:
+			m = m.clearBroadcastInput()
+			m.mode = modeHome
+			m.homeNotice = ""
+			return m, nil
+		case msg.Type == tea.KeyEnter:
+			m = m.applyBroadcast()
+			return m, nil
+		default:
+			m.broadcastInput, m.broadcastCursor = editTextInput(m.broadcastInput, m.broadcastCursor, msg)
+			return m, nil
+		}
+	case modeHelp:
+		// E switches to the error log; there, E (shift+e) clears it. Any
+		// other key closes the overlay.
+		if key == "E" {
+			if m.helpShowErrors {
+				m = m.clearErrorLog()
+			} else {
+				m.helpShowErrors = true
 			}
 			return m, nil
-		case msg.Type == tea.KeyRunes:
-			m.renameInput = m.renameInput[:m.renameCursor] + string(msg.Runes) + m.renameInput[m.renameCursor:]
-			m.renameCursor += len(string(msg.Runes))
+		}
+		m.helpShowErrors = false
+		m.mode = modeHome
+		return m, nil
+	case modeCommand:
+		switch {
+		case msg.Type == tea.KeyEsc:
+			m = m.clearPalette()
+			m.mode = modeHome
+			m.homeNotice = ""
+			return m, nil
+		case msg.Type == tea.KeyEnter:
+			m.refreshBindings()
+			return m.runPaletteSelection()
+		case msg.Type == tea.KeyUp:
+			if n := len(m.paletteMatches()); n > 0 {
+				m.paletteSelection = (m.paletteSelection + n - 1) % n
+			}
+			return m, nil
+		case msg.Type == tea.KeyDown:
+			if n := len(m.paletteMatches()); n > 0 {
+				m.paletteSelection = (m.paletteSelection + 1) % n
+			}
+			return m, nil
+		default:
+			m.paletteQuery, m.paletteCursor = editTextInput(m.paletteQuery, m.paletteCursor, msg)
+			m.paletteSelection = 0
+			return m, nil
+		}
+	case modePickAttach, modePickKill, modePickRename, modePickMemo, modePickNote, modePickSend:
+		if next, handled := m.updatePickerFilter(msg); handled {
+			return next, nil
+		}
+	case modeSearch:
+		switch {
+		case msg.Type == tea.KeyEsc:
+			m = m.clearSearch()
+			m.mode = modeHome
+			m.homeNotice = ""
 			return m, nil
+		case msg.Type == tea.KeyEnter:
+			matches := m.searchMatches()
+			if len(matches) == 0 {
+				m.homeNotice = fmt.Sprintf("no sessions match %q", m.searchQuery)
+				return m, nil
+			}
+			m = m.clearSearch()
+			return m.startAndAttachSession(matches[0], "")
 		default:
+			m.searchQuery, m.searchCursor = editTextInput(m.searchQuery, m.searchCursor, msg)
 			return m, nil
 		}
 	case modeDirJump:
+		if msg.Type != tea.KeyUp && msg.Type != tea.KeyDown {
+			// Any edit or selection ends history browsing.
+			m.dirHistoryPos = 0
+		}
 		switch {
 		case msg.Type == tea.KeyEsc:
 			m.mode = modeHome
@@ -1126,6 +3396,9 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 			return m.applyDirChange(m.dirSuggestions[m.dirSelection])
 		case msg.Type == tea.KeyUp:
+			if m.stepDirHistory(1) {
+				return m, nil
+			}
 			if len(m.dirSuggestions) > 0 {
 				if m.dirSelection <= 0 {
 					m.dirSelection = len(m.dirSuggestions) - 1
@@ -1135,6 +3408,9 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 			return m, nil
 		case msg.Type == tea.KeyDown:
+			if m.stepDirHistory(-1) {
+				return m, nil
+			}
 			if len(m.dirSuggestions) > 0 {
 				m.dirSelection = (m.dirSelection + 1) % len(m.dirSuggestions)
 			}
@@ -1211,7 +3487,7 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			// Quit without killing sessions
 			return m, tea.Quit
 		}
-		if m.mode == modeNewTool || m.mode == modeKillTool || m.mode == modeRenameTool {
+		if m.mode == modeNewTool || m.mode == modeKillTool || m.mode == modeRenameTool || m.mode == modeMemoTool || m.mode == modeNoteTool || m.mode == modeSendTool || m.mode == modeBroadcastTool {
 			m.mode = modeHome
 			m.homeNotice = ""
 			m.newToolFresh = false
@@ -1223,6 +3499,10 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 	case "esc":
+		if m.mode == modeHome && m.tooltipSession != "" {
+			m.tooltipSession = ""
+			return m, nil
+		}
 		if m.mode != modeHome {
 			m.mode = modeHome
 			m.homeNotice = ""
@@ -1265,19 +3545,13 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.homeNotice = fmt.Sprintf("Unknown new target %q.", key)
 			return m, nil
 		}
-		if m.toolAlreadyRunningInDir(tool, cwd) {
+		if tool != scratchTool && !m.reuseSessions() && m.toolAlreadyRunningInDir(tool, cwd) {
 			m.homeNotice = fmt.Sprintf("%s already running in this directory", tool)
 			return m, nil
 		}
 		return m.createAndAttachTool(tool)
 	case modeKillTool:
-		claudeTargets := m.runningToolSessions("claude")
-		codexTargets := m.runningToolSessions("codex")
-		cursorTargets := m.runningToolSessions("cursor")
-		runningClaude := len(claudeTargets) > 0
-		runningCodex := len(codexTargets) > 0
-		runningCursor := len(cursorTargets) > 0
-		if !runningClaude && !runningCodex && !runningCursor {
+		if len(m.toolsWithRunningSessions(allTools(m.config))) == 0 {
 			m.mode = modeHome
 			m.homeNotice = "no kill targets are running"
 			return m, nil
@@ -1285,6 +3559,8 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		switch key {
 		case "t":
 			return m.enterTaskKillPicker()
+		case "T":
+			return m.enterTaskKillAllMode()
 		default:
 			tool := m.toolForKey(key)
 			if tool == "" {
@@ -1294,15 +3570,7 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.homeNotice = fmt.Sprintf("Unknown kill target %q.", key)
 				return m, nil
 			}
-			var targets []string
-			switch tool {
-			case "claude":
-				targets = claudeTargets
-			case "codex":
-				targets = codexTargets
-			case "cursor":
-				targets = cursorTargets
-			}
+			targets := m.runningToolSessions(tool)
 			if len(targets) == 0 {
 				m.homeNotice = fmt.Sprintf("%s is not running", tool)
 				return m, nil
@@ -1313,8 +3581,17 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 			return m.handleToolKill(tool)
 		}
-	case modeRenameTool:
-		tools := []string{"claude", "codex", "cursor"}
+	case modeRenameTool, modeMemoTool, modeNoteTool, modeSendTool:
+		action, pickMode, begin := "rename", modePickRename, model.beginRenameTarget
+		switch m.mode {
+		case modeMemoTool:
+			action, pickMode, begin = "memo", modePickMemo, model.beginMemoTarget
+		case modeNoteTool:
+			action, pickMode, begin = "note", modePickNote, model.beginNoteTarget
+		case modeSendTool:
+			action, pickMode, begin = "send", modePickSend, model.beginSendTarget
+		}
+		tools := allTools(m.config)
 		targetsByTool := make(map[string][]string, len(tools))
 		runningAny := false
 		for _, tool := range tools {
@@ -1325,7 +3602,7 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		if !runningAny {
 			m.mode = modeHome
-			m.homeNotice = "no rename targets are running"
+			m.homeNotice = fmt.Sprintf("no %s targets are running", action)
 			return m, nil
 		}
 		tool := m.toolForKey(key)
@@ -1333,7 +3610,7 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if m.disabledToolKey(key) {
 				return m, nil
 			}
-			m.homeNotice = fmt.Sprintf("Unknown rename target %q.", key)
+			m.homeNotice = fmt.Sprintf("Unknown %s target %q.", action, key)
 			return m, nil
 		}
 		targets := targetsByTool[tool]
@@ -1342,41 +3619,66 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		if len(targets) > 1 {
-			m = m.preparePicker(tool, modePickRename)
+			m = m.preparePicker(tool, pickMode)
+			return m, nil
+		}
+		m = begin(m, targets[0])
+		return m, nil
+	case modeBroadcastTool:
+		tool := m.toolForKey(key)
+		if tool == "" {
+			if m.disabledToolKey(key) {
+				return m, nil
+			}
+			m.homeNotice = fmt.Sprintf("Unknown broadcast target %q.", key)
+			return m, nil
+		}
+		if len(m.runningToolSessions(tool)) == 0 {
+			m.homeNotice = fmt.Sprintf("%s is not running", tool)
 			return m, nil
 		}
-		m = m.beginRenameTarget(targets[0])
+		m.mode = modeBroadcastInput
+		m.broadcastTarget = tool
+		m.broadcastInput = ""
+		m.broadcastCursor = 0
+		m.homeNotice = ""
 		return m, nil
 	case modePickAttach:
-		target, ok := m.pickerTargets[key]
+		entry, ok := m.pickerEntryFor(key)
 		if !ok {
 			m.homeNotice = fmt.Sprintf("Unknown target %q.", key)
 			return m, nil
 		}
-		return m.startAndAttachSession(target, "")
+		next, cmd := m.startAndAttachSession(entry.session, "")
+		if next.shouldAttach {
+			next.paneToAttach = entry.target
+		}
+		return next, cmd
 	case modePickKill:
-		target, ok := m.pickerTargets[key]
+		target, ok := m.pickerTarget(key)
 		if !ok {
 			m.homeNotice = fmt.Sprintf("Unknown target %q.", key)
 			return m, nil
 		}
-		if err := tmux.KillSession(target); err != nil {
-			m.homeNotice = fmt.Sprintf("failed to stop %s: %v", target, err)
-		} else {
-			m.homeNotice = fmt.Sprintf("stopped %s", target)
-			delete(m.sessions, target)
-			delete(m.sessionTools, target)
-		}
 		m.mode = modeHome
-		m.refreshBindings()
-		return m, nil
-	case modePickRename:
-		target, ok := m.pickerTargets[key]
+		m.homeNotice = fmt.Sprintf("stopping %s...", target)
+		return m, m.killSessionCmd(target, fmt.Sprintf("stopped %s", target))
+	case modePickRename, modePickMemo, modePickNote, modePickSend:
+		target, ok := m.pickerTarget(key)
 		if !ok {
 			m.homeNotice = fmt.Sprintf("Unknown target %q.", key)
 			return m, nil
 		}
-		m = m.beginRenameTarget(target)
+		switch m.mode {
+		case modePickMemo:
+			m = m.beginMemoTarget(target)
+		case modePickNote:
+			m = m.beginNoteTarget(target)
+		case modePickSend:
+			m = m.beginSendTarget(target)
+		default:
+			m = m.beginRenameTarget(target)
+		}
 		return m, nil
 	case modePickKillTask:
 		target, ok := m.taskKillTargets[key]
@@ -1392,45 +3694,63 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.mode = modeHome
 		m.refreshTaskCounts()
 		return m, nil
+	case modeConfirmKillAllTasks:
+		m.mode = modeHome
+		if key != "y" {
+			m.homeNotice = "kill cancelled"
+			return m, nil
+		}
+		m.homeNotice = "killing all tasks..."
+		return m, m.killAllTasksCmd()
+	}
+
+	if m.mode == modeHome && (msg.Type == tea.KeyUp || msg.Type == tea.KeyDown) {
+		delta := 1
+		if msg.Type == tea.KeyUp {
+			delta = -1
+		}
+		return m.moveTooltipFocus(delta)
 	}
 
 	switch key {
+	case "/":
+		return m.beginSearch()
 	case "z":
-		if !m.hasFasder {
-			m.homeNotice = "fasder not found; install fasder to use z"
-			return m, nil
-		}
-		m.mode = modeDirJump
-		m.homeNotice = ""
-		m.dirQuery = ""
-		m.dirCursor = 0
-		m.dirSuggestions = nil
-		m.dirSelection = 0
-		m.refreshDirSuggestions()
-		return m, nil
+		return m.beginDirJump()
 	case "n":
-		m.mode = modeNewTool
-		m.homeNotice = ""
-		return m, nil
+		return m.beginNewTool()
 	case "k":
-		if !m.hasAnyRunningSessions() {
-			m.homeNotice = "no running sessions to kill"
-			return m, nil
-		}
-		m.mode = modeKillTool
-		m.homeNotice = ""
-		return m, nil
+		return m.beginKillTool()
 	case "r":
-		if !m.hasAnyRunningSessions() {
-			m.homeNotice = "no running sessions to rename"
-			return m, nil
+		return m.beginRenameTool()
+	case "m":
+		return m.beginMemoTool()
+	case "N":
+		return m.beginNoteTool()
+	case "S":
+		return m.beginSendTool()
+	case "B":
+		return m.beginBroadcastTool()
+	case ":":
+		return m.beginCommandPalette()
+	case "?":
+		return m.showHelp()
+	case "Y":
+		return m.toggleGlobalYolo()
+	case "W":
+		if m.configWarning != "" {
+			return m.dismissConfigWarning()
+		}
+	case "F":
+		if m.config != nil && len(m.config.UI.TaskFilterPresets) > 0 {
+			return m.cycleTaskFilterPreset()
 		}
-		m.mode = modeRenameTool
-		m.homeNotice = ""
-		return m, nil
 	}
 
 	if tool := m.toolForKey(key); tool != "" {
+		if m.config != nil && m.config.UI.DoubleTapToKill {
+			return m.handleToolTap(tool, key, time.Now())
+		}
 		return m.handleToolAttach(tool)
 	}
 
@@ -1439,607 +3759,2833 @@ func (m model) updateHome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if sess.Key != key {
 			continue
 		}
-		return m.startAndAttachSession(sess.Name, sess.Command)
+		next, cmd := m.startAndAttachSession(sess.Name, sess.Command)
+		if next.shouldAttach {
+			next.paneToAttach = resolvePaneTarget(sess.Name, sess.Pane)
+		}
+		return next, cmd
 	}
 
 	if key == "t" && m.mode == modeHome {
-		m.showTaskDetails = !m.showTaskDetails
-		return m, nil
+		return m.toggleTaskDetails()
+	}
+	if key == "T" && m.mode == modeHome {
+		return m.toggleAllTaskDetails()
+	}
+
+	if key == "i" && m.mode == modeHome && m.hideIdleAfter() > 0 {
+		return m.toggleIdleSessions()
 	}
 
 	return m, nil
 }
 
-func (m model) stopSession(name string) model {
-	tmuxSess, exists := m.sessions[name]
-	if !exists {
-		m.homeNotice = fmt.Sprintf("%s session is not configured", name)
-		return m
+// moveTooltipFocus steps the arrow-key row focus through running sessions
+// in home screen order and schedules that row's tooltip. When the rows are
+// paged, focus stops at the first and last session instead of wrapping, and
+// the page follows it.
+func (m model) moveTooltipFocus(delta int) (model, tea.Cmd) {
+	names := m.searchMatches()
+	if len(names) == 0 {
+		m.tooltipSession = ""
+		return m, nil
 	}
-
-	if !tmuxSess.IsRunning() {
-		m.homeNotice = fmt.Sprintf("%s session is not running", name)
-		return m
+	i := -1
+	for j, name := range names {
+		if name == m.tooltipSession {
+			i = j
+			break
+		}
 	}
-
-	if err := tmuxSess.Stop(); err != nil {
-		m.homeNotice = fmt.Sprintf("failed to stop %s: %v", name, err)
-		return m
+	paged := m.homePageCount() > 1
+	switch {
+	case i < 0 && delta < 0:
+		i = len(names) - 1
+	case i < 0:
+		i = 0
+	case paged:
+		i = min(max(i+delta, 0), len(names)-1)
+	default:
+		i = (i + delta + len(names)) % len(names)
+	}
+	m.tooltipSession = names[i]
+	if paged {
+		if page, ok := m.homePageOf(names[i]); ok {
+			m.homePage = page
+		}
 	}
+	m.tooltipShowAt = time.Now().Add(tooltipKeyDelay)
+	return m, tea.Tick(tooltipKeyDelay, func(time.Time) tea.Msg { return tooltipMsg{} })
+}
 
-	delete(m.sessions, name)
-	delete(m.sessionTools, name)
-	m.refreshBindings()
-	m.homeNotice = fmt.Sprintf("stopped %s session", name)
-	return m
+// homeMaxLines caps the home view height.
+const homeMaxLines = 20
+
+func (m model) effectiveMaxLines() int {
+	return homeMaxLines
 }
 
-func (m model) updateAttached(_ tea.KeyMsg) (tea.Model, tea.Cmd) {
-	// This view state is no longer used
-	// Attach happens outside of Bubble Tea
-	return m, nil
+// homePages splits the home rows into pages. Each page takes rows until
+// the rendered home screen (task lines, memos, tooltips, tool group
+// separators and the page indicator included) would overflow
+// effectiveMaxLines.
+func (m model) homePages() [][]string {
+	budget := m.effectiveMaxLines()
+	var pages [][]string
+	var page []string
+	for _, name := range m.homeRowSessions() {
+		next := append(slices.Clone(page), name)
+		if len(page) > 0 && len(m.onHomePage(next).homeSectionLines()) > budget {
+			pages = append(pages, page)
+			next = []string{name}
+		}
+		page = next
+	}
+	return append(pages, page)
 }
 
-func (m model) View() string {
-	switch m.viewState {
-	case viewHome:
-		return m.viewHome()
-	case viewAttached:
-		return m.viewAttached()
-	default:
-		return ""
+// onHomePage returns a copy of m that renders only names' rows, as one
+// page of several.
+func (m model) onHomePage(names []string) model {
+	m.homePageSessions = make(map[string]bool, len(names))
+	for _, name := range names {
+		m.homePageSessions[name] = true
 	}
+	return m
 }
 
-func (m model) viewHome() string {
-	m.refreshBindings()
-
-	titleStyle := lipgloss.NewStyle().
-		Bold(true).
-		Foreground(lipgloss.Color("#7D56F4"))
+// homeRowLines renders the detailed home rows, ruling off each tool's rows
+// once more than one tool is running.
+func (m model) homeRowLines() []string {
 	metaStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("#888888"))
-	keyStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#4DA3FF"))
-	activeStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#04B575")).
-		Bold(true)
-	idleStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#999999"))
-	repoNameStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#7D56F4")).
-		Bold(true)
-	alertStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#4DA3FF"))
-	title := "Welcome to PocketBot"
-	if level := os.Getenv("PB_LEVEL"); level != "" {
-		title = fmt.Sprintf("Welcome to PocketBot (level %s)", level)
+	separate := len(m.toolsWithRunningSessions(allTools(m.config))) > 1
+	var lines []string
+	rendered := 0
+	for _, tool := range allTools(m.config) {
+		names := m.runningToolSessions(tool)
+		if tool == scratchTool && len(names) == 0 {
+			continue
+		}
+		group := m.detailedRows(tool, names)
+		if len(group) == 0 {
+			continue
+		}
+		if separate && rendered > 0 {
+			lines = append(lines, m.groupSeparator(metaStyle))
+		}
+		lines = append(lines, group...)
+		rendered++
 	}
-	lines := []string{
-		titleStyle.Render("🤖 " + title),
-		metaStyle.Render(fmt.Sprintf("dir: %s", m.currentDir())),
+	return lines
+}
+
+// toolsWithRunningSessions returns the tools that have at least one running
+// session, in the given order.
+func (m model) toolsWithRunningSessions(tools []string) []string {
+	var out []string
+	for _, tool := range tools {
+		if len(m.runningToolSessions(tool)) > 0 {
+			out = append(out, tool)
+		}
 	}
+	return out
+}
 
-	if m.homeNotice != "" {
-		lines = append(lines, alertStyle.Render(m.homeNotice))
+// effectiveWidth is the width the home screen renders at: the terminal
+// width capped at ui.max_render_width, or 0 before the size is known.
+func (m model) effectiveWidth() int {
+	limit := config.DefaultMaxRenderWidth
+	if m.config != nil && m.config.UI.MaxRenderWidth > 0 {
+		limit = m.config.UI.MaxRenderWidth
 	}
+	return min(m.windowWidth, limit)
+}
 
-	switch m.mode {
-	case modeDirJump:
-		jumpTitleStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#7D56F4")).
-			Bold(true)
-		searchLabelStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#4DA3FF"))
-		hintStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#AAAAAA"))
-		cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF")).Bold(true)
-		selectedStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#04B575")).
-			Bold(true)
-		suggestionStyle := lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#BBBBBB"))
+// groupSeparator is the horizontal rule drawn between tool groups.
+func (m model) groupSeparator(style lipgloss.Style) string {
+	width := m.effectiveWidth()
+	if width <= 0 {
+		width = 80
+	}
+	return style.Render(strings.Repeat("─", width))
+}
 
-		lines = append(lines,
-			jumpTitleStyle.Render("z fasder jump"),
-			fmt.Sprintf("%s%s%s%s", searchLabelStyle.Render("search: "), m.dirQuery[:m.dirCursor], cursorStyle.Render("▌"), m.dirQuery[m.dirCursor:]),
-			hintStyle.Render("up/down move   enter select   esc cancel"),
-		)
-		for i, suggestion := range m.dirSuggestions {
-			row := fmt.Sprintf("  %s", suggestion)
-			if i == m.dirSelection {
-				row = fmt.Sprintf("> %s", suggestion)
-				lines = append(lines, selectedStyle.Render(row))
-				continue
-			}
-			lines = append(lines, suggestionStyle.Render(row))
-		}
-	case modeNewTool:
-		yoloStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF8A00")).Bold(true)
-		cwd := m.currentDir()
-		lines = append(lines, "")
-		if m.toolEnabled("claude") {
-			if m.toolAlreadyRunningInDir("claude", cwd) {
-				lines = append(lines, metaStyle.Render("claude already running"))
-			} else {
-				lines = append(lines, fmt.Sprintf("%s new claude", keyStyle.Render(m.keyForTool("claude"))))
-			}
-		}
-		if m.toolEnabled("codex") {
-			if m.toolAlreadyRunningInDir("codex", cwd) {
-				lines = append(lines, metaStyle.Render("codex already running"))
-			} else {
-				lines = append(lines, fmt.Sprintf("%s new codex", keyStyle.Render(m.keyForTool("codex"))))
-			}
+// homeRowSessions lists the sessions that get a detailed home row, in
+// screen order. Idle sessions collapsed by hide_idle_after are left out.
+// It is nil when the home screen shows per-tool summary rows instead.
+func (m model) homeRowSessions() []string {
+	var names []string
+	for _, tool := range allTools(m.config) {
+		names = append(names, m.runningToolSessions(tool)...)
+	}
+	if len(names) >= 10 && !m.showAllTaskDetails {
+		return nil
+	}
+	visible := names[:0]
+	for _, name := range names {
+		if !m.idleHidden(name) {
+			visible = append(visible, name)
 		}
-		if m.toolEnabled("cursor") {
-			if m.toolAlreadyRunningInDir("cursor", cwd) {
-				lines = append(lines, metaStyle.Render("cursor already running"))
+	}
+	return visible
+}
+
+// homePageCount returns how many pages the home rows span (at least 1).
+func (m model) homePageCount() int {
+	return len(m.homePages())
+}
+
+// homePageOf returns the page holding name's row.
+func (m model) homePageOf(name string) (int, bool) {
+	for i, page := range m.homePages() {
+		if slices.Contains(page, name) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// tooltipVisible reports whether name's tooltip is due to be drawn.
+func (m model) tooltipVisible(name string) bool {
+	return m.tooltipSession != "" && m.tooltipSession == name && time.Now().After(m.tooltipShowAt)
+}
+
+// buildTooltip describes a session for its hover tooltip: launch command,
+// cwd, uptime, task summary and attach count, one per line.
+func (m model) buildTooltip(name string) string {
+	binding := m.bindings[name]
+	command := binding.Command
+	if command == "" {
+		command = "-"
+	}
+	cwd := binding.Cwd
+	if cwd == "" {
+		cwd = "-"
+	}
+	uptime := "-"
+	if !binding.Created.IsZero() {
+		uptime = formatUptime(time.Since(binding.Created))
+	}
+	tasks := fmt.Sprintf("%d", m.taskCounts[name])
+	if cmds := m.taskCommands[name]; len(cmds) > 0 {
+		tasks += " (" + strings.Join(cmds, ", ") + ")"
+	}
+	lines := []string{
+		"cmd: " + command,
+		"cwd: " + cwd,
+		"up: " + uptime,
+		"tasks: " + tasks,
+		fmt.Sprintf("visits: %d", binding.AttachCount),
+	}
+	if m.zombieSessions[name] {
+		lines = append(lines, "zombie: the pane process exited; kill (k) and start it again")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m model) beginSearch() (model, tea.Cmd) {
+	if !m.hasAnyRunningSessions() {
+		m.homeNotice = "no running sessions to search"
+		return m, nil
+	}
+	m = m.clearSearch()
+	m.mode = modeSearch
+	m.homeNotice = ""
+	m.homePage = 0
+	return m, nil
+}
+
+func (m model) beginDirJump() (model, tea.Cmd) {
+	if !m.hasFasder {
+		m.homeNotice = "fasder not found; install fasder to use z"
+		return m, nil
+	}
+	m.mode = modeDirJump
+	m.homeNotice = ""
+	m.dirQuery = ""
+	m.dirCursor = 0
+	m.dirHistoryPos = 0
+	m.dirSuggestions = nil
+	m.dirSelection = 0
+	m.consumeDirPrefetch()
+	if m.prefetchedDirSuggestions != nil {
+		// The prefetch is only fresh enough for the first jump.
+		m.dirSuggestions = m.prefetchedDirSuggestions
+		if len(m.dirSuggestions) > 9 {
+			m.dirSuggestions = m.dirSuggestions[:9]
+		}
+		m.prefetchedDirSuggestions = nil
+		return m, nil
+	}
+	m.refreshDirSuggestions()
+	return m, nil
+}
+
+// readonlyNotice is shown when --readonly refuses an action.
+const readonlyNotice = "readonly mode: mutations disabled"
+
+// blockMutation reports whether the current action must be refused because
+// pb runs with --readonly or is observing a foreign server read-only, and
+// says so in the notice.
+func (m *model) blockMutation() bool {
+	switch {
+	case m.readonly:
+		m.homeNotice = readonlyNotice
+	case readOnlyFn():
+		m.homeNotice = fmt.Sprintf("read-only: observing %s", tmux.SocketName())
+	default:
+		return false
+	}
+	m.mode = modeHome
+	return true
+}
+
+func (m model) beginNewTool() (model, tea.Cmd) {
+	if m.blockMutation() {
+		return m, nil
+	}
+	m.mode = modeNewTool
+	m.homeNotice = ""
+	return m, nil
+}
+
+func (m model) beginKillTool() (model, tea.Cmd) {
+	if m.blockMutation() {
+		return m, nil
+	}
+	if !m.hasAnyRunningSessions() {
+		m.homeNotice = "no running sessions to kill"
+		return m, nil
+	}
+	m.mode = modeKillTool
+	m.homeNotice = ""
+	return m, nil
+}
+
+func (m model) beginRenameTool() (model, tea.Cmd) {
+	if m.blockMutation() {
+		return m, nil
+	}
+	if !m.hasAnyRunningSessions() {
+		m.homeNotice = "no running sessions to rename"
+		return m, nil
+	}
+	m.mode = modeRenameTool
+	m.homeNotice = ""
+	return m, nil
+}
+
+func (m model) beginNoteTool() (model, tea.Cmd) {
+	if m.blockMutation() {
+		return m, nil
+	}
+	if !m.hasAnyRunningSessions() {
+		m.homeNotice = "no running sessions to annotate"
+		return m, nil
+	}
+	m.mode = modeNoteTool
+	m.homeNotice = ""
+	return m, nil
+}
+
+func (m model) beginSendTool() (model, tea.Cmd) {
+	if m.blockMutation() {
+		return m, nil
+	}
+	if !m.hasAnyRunningSessions() {
+		m.homeNotice = "no running sessions to send to"
+		return m, nil
+	}
+	m.mode = modeSendTool
+	m.homeNotice = ""
+	return m, nil
+}
+
+func (m model) beginMemoTool() (model, tea.Cmd) {
+	if m.blockMutation() {
+		return m, nil
+	}
+	if !m.hasAnyRunningSessions() {
+		m.homeNotice = "no running sessions to annotate"
+		return m, nil
+	}
+	m.mode = modeMemoTool
+	m.homeNotice = ""
+	return m, nil
+}
+
+func (m model) toggleTaskDetails() (model, tea.Cmd) {
+	m.showTaskDetails = !m.showTaskDetails
+	return m, nil
+}
+
+func (m model) toggleAllTaskDetails() (model, tea.Cmd) {
+	m.showAllTaskDetails = !m.showAllTaskDetails
+	return m, nil
+}
+
+// showTaskLines reports whether rows list task commands instead of counts.
+func (m model) showTaskLines() bool {
+	return m.showTaskDetails || m.showAllTaskDetails
+}
+
+func (m model) toggleIdleSessions() (model, tea.Cmd) {
+	if m.hideIdleAfter() <= 0 {
+		m.homeNotice = "hide_idle_after is not set"
+		return m, nil
+	}
+	m.showIdleSessions = !m.showIdleSessions
+	return m, nil
+}
+
+func (m model) toggleGlobalYolo() (model, tea.Cmd) {
+	if m.blockMutation() {
+		return m, nil
+	}
+	if err := setGlobalYoloFn(!m.globalYolo); err != nil {
+		m.homeNotice = fmt.Sprintf("failed to save global yolo: %v", err)
+		return m, nil
+	}
+	m.globalYolo = !m.globalYolo
+	if m.globalYolo {
+		m.homeNotice = "global yolo on: new sessions skip all permissions"
+	} else {
+		m.homeNotice = "global yolo off"
+	}
+	return m, nil
+}
+
+func (m model) beginBroadcastTool() (model, tea.Cmd) {
+	if m.blockMutation() {
+		return m, nil
+	}
+	if !m.hasAnyRunningSessions() {
+		m.homeNotice = "no running sessions to broadcast to"
+		return m, nil
+	}
+	m.mode = modeBroadcastTool
+	m.homeNotice = ""
+	return m, nil
+}
+
+func (m model) clearBroadcastInput() model {
+	m.broadcastTarget = ""
+	m.broadcastInput = ""
+	m.broadcastCursor = 0
+	return m
+}
+
+// applyBroadcast types broadcastInput, followed by Enter, into every running
+// session of broadcastTarget. Sessions that exited since the bindings were
+// read are skipped rather than counted as failures.
+func (m model) applyBroadcast() model {
+	tool := m.broadcastTarget
+	text := m.broadcastInput
+	if strings.TrimSpace(text) == "" {
+		m.homeNotice = "nothing to broadcast"
+		return m
+	}
+	var targets []string
+	skipped := 0
+	for _, name := range m.runningToolSessions(tool) {
+		if !sessionExistsFn(name) {
+			skipped++
+			continue
+		}
+		targets = append(targets, name)
+	}
+	m = m.clearBroadcastInput()
+	m.mode = modeHome
+	if len(targets) == 0 {
+		m.homeNotice = fmt.Sprintf("%s is not running", tool)
+		return m
+	}
+	errs := broadcastKeysFn(targets, text)
+	if len(errs) == 0 {
+		m.homeNotice = fmt.Sprintf("sent to %d %s sessions", len(targets), tool)
+	} else {
+		m.homeNotice = fmt.Sprintf("sent to %d/%d %s sessions; %v", len(targets)-len(errs), len(targets), tool, errs[0])
+	}
+	if skipped > 0 {
+		m.homeNotice += fmt.Sprintf(" (skipped %d that exited)", skipped)
+	}
+	return m
+}
+
+func (m model) showHelp() (model, tea.Cmd) {
+	m.mode = modeHelp
+	m.homeNotice = ""
+	return m, nil
+}
+
+func (m model) quitHome() (model, tea.Cmd) {
+	return m, tea.Quit
+}
+
+// homeAction is a named home-screen action. The command palette and the
+// help overlay are both built from homeActions, so every action is reachable
+// by name even when it has no hotkey.
+type homeAction struct {
+	name string
+	key  string // hotkey on the home screen, or "" if palette-only
+	desc string
+	run  func(model) (model, tea.Cmd)
+}
+
+func homeActions() []homeAction {
+	return []homeAction{
+		{name: "new", key: "n", desc: "new instance (then c/x/u)", run: model.beginNewTool},
+		{name: "kill", key: "k", desc: "kill one instance", run: model.beginKillTool},
+		{name: "kill-task", desc: "kill a task process inside a session", run: model.enterTaskKillPicker},
+		{name: "rename", key: "r", desc: "rename one instance", run: model.beginRenameTool},
+		{name: "memo", key: "m", desc: "add or edit a one-line memo", run: model.beginMemoTool},
+		{name: "note", key: "N", desc: "add or edit a multi-line note (shown with the row tooltip)", run: model.beginNoteTool},
+		{name: "send", key: "S", desc: "send one line to a session without attaching", run: model.beginSendTool},
+		{name: "broadcast", key: "B", desc: "send one line to every session of a tool", run: model.beginBroadcastTool},
+		{name: "search", key: "/", desc: "search running sessions by name", run: model.beginSearch},
+		{name: "jump-dir", key: "z", desc: "jump directory with fasder", run: model.beginDirJump},
+		{name: "toggle-tasks", key: "t", desc: "show or hide task lines", run: model.toggleTaskDetails},
+		{name: "toggle-all-tasks", key: "T", desc: "expand every session with its task lines", run: model.toggleAllTaskDetails},
+		{name: "toggle-idle", key: "i", desc: "expand or collapse idle sessions", run: model.toggleIdleSessions},
+		{name: "global-yolo", key: "Y", desc: "launch every new session in yolo mode", run: model.toggleGlobalYolo},
+		{name: "help", key: "?", desc: "list all actions", run: model.showHelp},
+		{name: "quit", key: "d", desc: "quit pb (sessions keep running)", run: model.quitHome},
+	}
+}
+
+// fuzzyScore reports whether the characters of query appear in order in
+// target, ignoring case. Lower scores are better: matches that start early
+// and stay contiguous rank first.
+func fuzzyScore(query, target string) (int, bool) {
+	q := []rune(strings.ToLower(query))
+	if len(q) == 0 {
+		return 0, true
+	}
+	score, qi, last := 0, 0, -1
+	for i, r := range []rune(strings.ToLower(target)) {
+		if r != q[qi] {
+			continue
+		}
+		if last < 0 {
+			score += i
+		} else {
+			score += i - last - 1
+		}
+		last = i
+		qi++
+		if qi == len(q) {
+			return score, true
+		}
+	}
+	return 0, false
+}
+
+// paletteMatches returns the actions matching the palette query, best first.
+// An empty query lists every action in registry order.
+func (m model) paletteMatches() []homeAction {
+	type scored struct {
+		action homeAction
+		score  int
+	}
+	var matches []scored
+	for _, action := range homeActions() {
+		if score, ok := fuzzyScore(strings.TrimSpace(m.paletteQuery), action.name); ok {
+			matches = append(matches, scored{action, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score < matches[j].score })
+	out := make([]homeAction, len(matches))
+	for i, match := range matches {
+		out[i] = match.action
+	}
+	return out
+}
+
+func (m model) beginCommandPalette() (model, tea.Cmd) {
+	m = m.clearPalette()
+	m.mode = modeCommand
+	m.homeNotice = ""
+	return m, nil
+}
+
+func (m model) clearPalette() model {
+	m.paletteQuery = ""
+	m.paletteCursor = 0
+	m.paletteSelection = 0
+	return m
+}
+
+// runPaletteSelection dispatches the selected palette action from the home
+// mode, as if its hotkey had been pressed.
+func (m model) runPaletteSelection() (model, tea.Cmd) {
+	matches := m.paletteMatches()
+	if len(matches) == 0 {
+		m.homeNotice = fmt.Sprintf("no command matches %q", m.paletteQuery)
+		return m, nil
+	}
+	if m.paletteSelection < 0 || m.paletteSelection >= len(matches) {
+		m.paletteSelection = 0
+	}
+	action := matches[m.paletteSelection]
+	m = m.clearPalette()
+	m.mode = modeHome
+	m.homeNotice = ""
+	return action.run(m)
+}
+
+func (m model) stopSession(name string) model {
+	tmuxSess, exists := m.sessions[name]
+	if !exists {
+		m.homeNotice = fmt.Sprintf("%s session is not configured", name)
+		return m
+	}
+
+	if !tmuxSess.IsRunning() {
+		m.homeNotice = fmt.Sprintf("%s session is not running", name)
+		return m
+	}
+
+	if err := killSessionFn(name, m.killOptions(name)); err != nil {
+		m.homeNotice = fmt.Sprintf("failed to stop %s: %v", name, err)
+		return m
+	}
+
+	m.releaseDirLock(name)
+	delete(m.sessions, name)
+	delete(m.sessionTools, name)
+	delete(m.starting, name)
+	m.refreshBindings()
+	m.homeNotice = fmt.Sprintf("stopped %s session", name)
+	return m
+}
+
+func (m model) updateAttached(_ tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// This view state is no longer used
+	// Attach happens outside of Bubble Tea
+	return m, nil
+}
+
+func (m model) View() string {
+	switch m.viewState {
+	case viewHome:
+		return m.viewHome()
+	case viewAttached:
+		return m.viewAttached()
+	default:
+		return ""
+	}
+}
+
+// viewHome renders purely from model state; tmux is only queried in
+// Update (ticks and key handling) so rendering never blocks or flickers.
+func (m model) viewHome() string {
+	metaStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#888888"))
+	keyStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#4DA3FF"))
+	activeStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#04B575")).
+		Bold(true)
+	idleStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#999999"))
+	repoNameStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#7D56F4")).
+		Bold(true)
+	alertStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#4DA3FF"))
+	lines := m.homeSectionLines()
+
+	switch m.mode {
+	case modeDirJump:
+		jumpTitleStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#7D56F4")).
+			Bold(true)
+		searchLabelStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#4DA3FF"))
+		hintStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#AAAAAA"))
+		cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF")).Bold(true)
+		selectedStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#04B575")).
+			Bold(true)
+		suggestionStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#BBBBBB"))
+
+		lines = append(lines,
+			jumpTitleStyle.Render("z fasder jump"),
+			fmt.Sprintf("%s%s%s%s", searchLabelStyle.Render("search: "), m.dirQuery[:m.dirCursor], cursorStyle.Render("▌"), m.dirQuery[m.dirCursor:]),
+			hintStyle.Render("up/down move (up on empty: history)   enter select   esc cancel"),
+		)
+		for i, suggestion := range m.dirSuggestions {
+			row := fmt.Sprintf("  %s", suggestion)
+			if i == m.dirSelection {
+				row = fmt.Sprintf("> %s", suggestion)
+				lines = append(lines, selectedStyle.Render(row))
+				continue
+			}
+			lines = append(lines, suggestionStyle.Render(row))
+		}
+	case modeNewTool:
+		yoloStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF8A00")).Bold(true)
+		cwd := m.currentDir()
+		lines = append(lines, "")
+		anyEnabled := false
+		for _, tc := range m.config.Tools {
+			if !tc.Enabled {
+				continue
+			}
+			anyEnabled = true
+			switch {
+			case !m.toolAlreadyRunningInDir(tc.Name, cwd):
+				lines = append(lines, fmt.Sprintf("%s new %s", keyStyle.Render(tc.Key), tc.Name))
+			case m.reuseSessions():
+				lines = append(lines, fmt.Sprintf("%s new %s window", keyStyle.Render(tc.Key), tc.Name))
+			default:
+				lines = append(lines, metaStyle.Render(tc.Name+" already running"))
+			}
+		}
+		if !anyEnabled {
+			lines = append(lines, metaStyle.Render("all tools are disabled"))
+		}
+		if m.toolEnabled(scratchTool) {
+			lines = append(lines, fmt.Sprintf("%s new scratch shell", keyStyle.Render(m.keyForTool(scratchTool))))
+		}
+		lines = append(lines, "")
+		if m.newToolFresh {
+			lines = append(lines, fmt.Sprintf("%s fresh: %s", keyStyle.Render("f"), yoloStyle.Render("ON (no previous context)")))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s fresh: off", keyStyle.Render("f")))
+		}
+		if m.newToolAuto {
+			lines = append(lines, fmt.Sprintf("%s auto: %s", keyStyle.Render("a"), yoloStyle.Render("ON (claude auto/codex full-auto)")))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s auto: off", keyStyle.Render("a")))
+		}
+		if m.globalYolo {
+			lines = append(lines, fmt.Sprintf("%s yolo: %s", keyStyle.Render("y"), yoloStyle.Render("ON (global yolo, Y to turn off)")))
+		} else if m.newToolYolo {
+			lines = append(lines, fmt.Sprintf("%s yolo: %s", keyStyle.Render("y"), yoloStyle.Render("ON (skip all permissions)")))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s yolo: off", keyStyle.Render("y")))
+		}
+		lines = append(lines, "esc cancel")
+	case modeKillTool:
+		renderKillRows := func(tool, key string) {
+			names := m.pickerOrder(tool)
+			if len(names) == 0 {
+				return
+			}
+			if len(names) == 1 {
+				lines = append(lines, fmt.Sprintf("%s kill %s", keyStyle.Render(key), m.withToolIcon(tool, m.displayName(names[0]))))
+				return
+			}
+			for i, name := range names {
+				letter := alphaKey(i)
+				if letter == "" {
+					break
+				}
+				repo := "-"
+				if binding, ok := m.bindings[name]; ok {
+					repo = m.resolveRepoDisplay(binding.Cwd)
+				}
+				lines = append(lines, fmt.Sprintf("%s %s repo:%s", keyStyle.Render("("+key+" "+letter+")"), m.withToolIcon(tool, m.displayName(name)), repoNameStyle.Render(repo)))
+			}
+		}
+		for _, tc := range m.config.Tools {
+			if tc.Enabled {
+				renderKillRows(tc.Name, tc.Key)
+			}
+		}
+		if m.toolEnabled(scratchTool) {
+			renderKillRows(scratchTool, m.keyForTool(scratchTool))
+		}
+		lines = append(lines, fmt.Sprintf("%s kill task", keyStyle.Render("t")))
+		lines = append(lines, fmt.Sprintf("%s kill all tasks in every session", keyStyle.Render("T")))
+		lines = append(lines, "esc cancel")
+	case modeConfirmKillAllTasks:
+		lines = append(lines, alertStyle.Render("kill all tasks in every session?"))
+		lines = append(lines, fmt.Sprintf("%s yes   %s no", keyStyle.Render("y"), keyStyle.Render("n")))
+	case modeRenameTool, modeMemoTool, modeNoteTool, modeSendTool:
+		verb := "rename"
+		switch m.mode {
+		case modeMemoTool:
+			verb = "memo"
+		case modeNoteTool:
+			verb = "note"
+		case modeSendTool:
+			verb = "send to"
+		}
+		renderRenameRows := func(tool, key string) {
+			names := m.pickerOrder(tool)
+			if len(names) == 0 {
+				return
+			}
+			if len(names) == 1 {
+				lines = append(lines, fmt.Sprintf("%s %s %s", keyStyle.Render(key), verb, m.withToolIcon(tool, m.displayName(names[0]))))
+				return
+			}
+			for i, name := range names {
+				letter := alphaKey(i)
+				if letter == "" {
+					break
+				}
+				repo := "-"
+				if binding, ok := m.bindings[name]; ok {
+					repo = m.resolveRepoDisplay(binding.Cwd)
+				}
+				lines = append(lines, fmt.Sprintf("%s %s repo:%s", keyStyle.Render("("+key+" "+letter+")"), m.withToolIcon(tool, m.displayName(name)), repoNameStyle.Render(repo)))
+			}
+		}
+		for _, tc := range m.config.Tools {
+			if tc.Enabled {
+				renderRenameRows(tc.Name, tc.Key)
+			}
+		}
+		if m.toolEnabled(scratchTool) {
+			renderRenameRows(scratchTool, m.keyForTool(scratchTool))
+		}
+		lines = append(lines, "esc cancel")
+	case modePickAttach, modePickKill:
+		action := "attach"
+		if m.mode == modePickKill {
+			action = "kill"
+		}
+		lines = append(lines, metaStyle.Render(fmt.Sprintf("%s %s", action, m.pickerTool)))
+		if m.mode == modePickKill {
+			lines = append(lines, alertStyle.Render("pick one key to kill"))
+		} else {
+			lines = append(lines, metaStyle.Render("pick one key to attach"))
+		}
+		if filter := m.pickerFilterLine(); filter != "" {
+			lines = append(lines, filter)
+		}
+		if len(m.pickerEntries) == 0 {
+			lines = append(lines, metaStyle.Render("no sessions match"))
+		}
+		for _, entry := range m.pickerEntries {
+			k, name := entry.key, entry.session
+			status := ""
+			if sess, ok := m.sessions[name]; ok && sess.ActivityKnown() {
+				status = idleStyle.Render("○")
+				if sess.RecentlyActive() {
+					status = m.activeIndicator("●", activeStyle)
+				}
+			}
+			repo := "-"
+			if binding, ok := m.bindings[name]; ok {
+				repo = m.resolveRepoDisplay(binding.Cwd)
+			}
+			rowParts := []string{keyStyle.Render("(" + k + ")"), m.withToolIcon(m.pickerTool, m.displayName(name))}
+			if entry.target != "" {
+				rowParts = append(rowParts, metaStyle.Render(fmt.Sprintf("window %d", entry.window)))
+			}
+			if status != "" {
+				rowParts = append(rowParts, status)
+			}
+			rowParts = append(rowParts, repoNameStyle.Render(repo))
+			lines = append(lines, strings.Join(rowParts, " "))
+		}
+		lines = append(lines, metaStyle.Render(m.pickerSortHint()))
+	case modePickRename, modePickMemo, modePickNote, modePickSend:
+		verb := "rename"
+		switch m.mode {
+		case modePickMemo:
+			verb = "memo"
+		case modePickNote:
+			verb = "note"
+		case modePickSend:
+			verb = "send to"
+		}
+		lines = append(lines, metaStyle.Render(verb+" "+m.pickerTool))
+		lines = append(lines, alertStyle.Render("pick one key"))
+		if filter := m.pickerFilterLine(); filter != "" {
+			lines = append(lines, filter)
+		}
+		if len(m.pickerEntries) == 0 {
+			lines = append(lines, metaStyle.Render("no sessions match"))
+		}
+		for _, entry := range m.pickerEntries {
+			k, name := entry.key, entry.session
+			repo := "-"
+			if binding, ok := m.bindings[name]; ok {
+				repo = m.resolveRepoDisplay(binding.Cwd)
+			}
+			lines = append(lines, fmt.Sprintf("%s %s %s",
+				keyStyle.Render("("+k+")"),
+				m.withToolIcon(m.pickerTool, m.displayName(name)),
+				repoNameStyle.Render(repo),
+			))
+		}
+		lines = append(lines, metaStyle.Render(m.pickerSortHint()))
+	case modePickKillTask:
+		lines = append(lines, metaStyle.Render("kill task"))
+		keys := make([]string, 0, len(m.taskKillTargets))
+		for k := range m.taskKillTargets {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		lines = append(lines, alertStyle.Render("pick one key to kill task"))
+		for _, k := range keys {
+			target := m.taskKillTargets[k]
+			prefix := fmt.Sprintf("%s %s pid:%d ",
+				keyStyle.Render("("+k+")"),
+				m.displayName(target.Session),
+				target.PID,
+			)
+			lines = append(lines, prefix+m.truncateCommand(target.Command, m.fitWidth(lipgloss.Width(prefix), m.taskCommandWidth())))
+		}
+		lines = append(lines, "esc cancel")
+	case modeRenameInput:
+		lines = append(lines, metaStyle.Render(fmt.Sprintf("rename %s", m.displayName(m.renameTarget))))
+		cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF")).Bold(true)
+		hintStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#666666"))
+		completion := ""
+		help := "enter confirm   esc cancel"
+		if hint := m.renameHint(); hint != "" {
+			completion = hintStyle.Render(strings.TrimPrefix(hint, m.renameInput))
+			help = "tab complete   " + help
+			if n := len(m.renameSuggestions(m.renameInput)); n > 1 {
+				help = fmt.Sprintf("up/down %d matches   %s", n, help)
+			}
+		}
+		lines = append(lines, fmt.Sprintf("new name: %s%s%s%s", m.renameInput[:m.renameCursor], cursorStyle.Render("▌"), completion, m.renameInput[m.renameCursor:]))
+		lines = append(lines, help)
+	case modeCommand:
+		cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF")).Bold(true)
+		selectedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Bold(true)
+		lines = append(lines, fmt.Sprintf(":%s%s%s", m.paletteQuery[:m.paletteCursor], cursorStyle.Render("▌"), m.paletteQuery[m.paletteCursor:]))
+		lines = append(lines, metaStyle.Render("up/down move   enter run   esc cancel"))
+		for i, action := range m.paletteMatches() {
+			row := fmt.Sprintf("  %s  %s", action.name, metaStyle.Render(action.desc))
+			if i == m.paletteSelection {
+				row = selectedStyle.Render("> "+action.name) + "  " + metaStyle.Render(action.desc)
+			}
+			lines = append(lines, row)
+		}
+	case modeHelp:
+		if m.helpShowErrors {
+			lines = append(lines, metaStyle.Render("recent errors (newest first)"))
+			if len(m.errorLog) == 0 {
+				lines = append(lines, metaStyle.Render("(none)"))
+			}
+			room := m.effectiveMaxLines() - len(lines) - 1
+			for i := len(m.errorLog) - 1; i >= 0 && room > 0; i-- {
+				entry := m.errorLog[i]
+				lines = append(lines, alertStyle.Render(entry.ts.Format("15:04:05")+" "+entry.msg))
+				room--
+			}
+			lines = append(lines, fmt.Sprintf("%s clear   any other key closes", keyStyle.Render("E")))
+			break
+		}
+		lines = append(lines, metaStyle.Render("actions (: to run by name)"))
+		for _, action := range homeActions() {
+			key := action.key
+			if key == "" {
+				key = ":"
+			}
+			lines = append(lines, fmt.Sprintf("%s %s  %s", keyStyle.Render(fmt.Sprintf("%-2s", key)), action.name, metaStyle.Render(action.desc)))
+		}
+		// The action list already fills the overlay, so the error summary
+		// shares the closing line.
+		if n := len(m.errorLog); n > 0 {
+			latest := m.errorLog[n-1]
+			lines = append(lines, fmt.Sprintf("%s   %s show   any other key closes",
+				alertStyle.Render(fmt.Sprintf("recent errors: %d, latest %s", n, latest.ts.Format("15:04:05"))),
+				keyStyle.Render("E")))
+			break
+		}
+		lines = append(lines, "any key closes")
+	case modeSearch:
+		cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF")).Bold(true)
+		lines = append(lines, fmt.Sprintf("search: %s%s%s", m.searchQuery[:m.searchCursor], cursorStyle.Render("▌"), m.searchQuery[m.searchCursor:]))
+		lines = append(lines, "enter attach first match   esc cancel")
+		lines = append(lines, "")
+		for _, tool := range allTools(m.config) {
+			names := m.runningToolSessions(tool)
+			if tool == scratchTool && len(names) == 0 {
+				continue
+			}
+			lines = append(lines, m.detailedRows(tool, names)...)
+		}
+	case modeBroadcastTool:
+		lines = append(lines, metaStyle.Render("broadcast to every session of"))
+		for _, tool := range allTools(m.config) {
+			n := len(m.runningToolSessions(tool))
+			if n == 0 || !m.toolEnabled(tool) {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s %s (%d)", keyStyle.Render(m.keyForTool(tool)), m.withToolIcon(tool, tool), n))
+		}
+		lines = append(lines, "esc cancel")
+	case modeBroadcastInput:
+		n := len(m.runningToolSessions(m.broadcastTarget))
+		lines = append(lines, metaStyle.Render(fmt.Sprintf("broadcast to %d %s sessions", n, m.broadcastTarget)))
+		cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF")).Bold(true)
+		lines = append(lines, fmt.Sprintf("send: %s%s%s", m.broadcastInput[:m.broadcastCursor], cursorStyle.Render("▌"), m.broadcastInput[m.broadcastCursor:]))
+		lines = append(lines, "enter send (types the line, then Enter)   esc cancel")
+	case modeSendKeys:
+		lines = append(lines, metaStyle.Render(fmt.Sprintf("send to %s", m.displayName(m.sendTarget))))
+		cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF")).Bold(true)
+		lines = append(lines, fmt.Sprintf("send: %s%s%s", m.sendInput[:m.sendCursor], cursorStyle.Render("▌"), m.sendInput[m.sendCursor:]))
+		lines = append(lines, "enter send (types the line, then Enter)   esc cancel")
+	case modeNoteInput:
+		lines = append(lines, metaStyle.Render(fmt.Sprintf("note for %s", m.displayName(m.noteTarget))))
+		cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF")).Bold(true)
+		text := m.noteInput[:m.noteCursor] + cursorStyle.Render("▌") + m.noteInput[m.noteCursor:]
+		for i, line := range strings.Split(text, "\n") {
+			prefix := "note: "
+			if i > 0 {
+				prefix = "      "
+			}
+			lines = append(lines, prefix+line)
+		}
+		lines = append(lines, fmt.Sprintf("enter save (empty clears)   alt+enter new line (max %d)   esc cancel", maxNoteLines))
+	case modeMemoInput:
+		lines = append(lines, metaStyle.Render(fmt.Sprintf("memo for %s", m.displayName(m.memoTarget))))
+		cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF")).Bold(true)
+		lines = append(lines, fmt.Sprintf("memo: %s%s%s", m.memoInput[:m.memoCursor], cursorStyle.Render("▌"), m.memoInput[m.memoCursor:]))
+		lines = append(lines, "enter save (empty clears)   esc cancel")
+	}
+
+	lines = capLines(lines, m.effectiveMaxLines())
+	if width := m.effectiveWidth(); width > 0 {
+		clip := lipgloss.NewStyle().MaxWidth(width)
+		for i, line := range lines {
+			lines[i] = clip.Render(line)
+		}
+	}
+	view := strings.Join(lines, "\n") + "\n"
+	if m.noAltScreen {
+		// Inline frames sit below earlier terminal output; keep a gap.
+		view = "\n\n" + view
+	}
+	return view
+}
+
+// homeSections returns the home screen sections to draw, in order.
+func (m model) homeSections() []string {
+	if m.config == nil || m.config.UI.Sections == nil {
+		return config.DefaultSections()
+	}
+	return m.config.UI.Sections
+}
+
+// homeSectionLines renders the configured home sections. The session rows
+// and key hints only show on the plain home screen, each set off from its
+// neighbours by a blank line; other modes draw below the header sections.
+func (m model) homeSectionLines() []string {
+	var lines []string
+	afterBlock := false
+	for _, name := range m.homeSections() {
+		block := name == "sessions" || name == "hotkeys"
+		if block && m.mode != modeHome {
+			continue
+		}
+		section := m.renderSection(name)
+		if len(section) == 0 && !block {
+			continue
+		}
+		if len(lines) > 0 && (block || afterBlock) {
+			lines = append(lines, "")
+		}
+		lines = append(lines, section...)
+		afterBlock = block
+	}
+	return lines
+}
+
+// renderSection renders one home screen section by name. Unknown names
+// render nothing.
+func (m model) renderSection(name string) []string {
+	metaStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#888888"))
+	keyStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#4DA3FF"))
+	alertStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#4DA3FF"))
+	var lines []string
+	switch name {
+	case "title":
+		titleStyle := lipgloss.NewStyle().
+			Bold(true).
+			Foreground(lipgloss.Color("#7D56F4"))
+		activeStyle := lipgloss.NewStyle().
+			Foreground(lipgloss.Color("#04B575")).
+			Bold(true)
+		title := "Welcome to PocketBot"
+		if level := os.Getenv("PB_LEVEL"); level != "" {
+			title = fmt.Sprintf("Welcome to PocketBot (level %s)", level)
+		}
+		if readOnlyFn() {
+			title = fmt.Sprintf("PocketBot observing %s (read-only)", tmux.SocketName())
+		} else if m.readonly {
+			title += " (readonly)"
+		}
+		titleLine := titleStyle.Render("🤖 " + title)
+		if active, total := m.activitySummary(); total > 0 {
+			titleLine += " " + metaStyle.Render("[") + activeStyle.Render(fmt.Sprintf("%d", active)) +
+				metaStyle.Render(fmt.Sprintf("/%d active]", total))
+		}
+		if m.hasZombieSessions() {
+			titleLine += " " + alertStyle.Render("⚠ zombie")
+		}
+		if m.globalYolo {
+			yoloStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF8A00")).Bold(true)
+			titleLine += " " + yoloStyle.Render("(global yolo)")
+		}
+		if preset := m.activePresetName(); preset != "" {
+			titleLine += " " + metaStyle.Render("filter:"+preset)
+		}
+		lines = append(lines, titleLine)
+	case "dir":
+		if cwd, err := m.workingDir(); err != nil {
+			lines = append(lines, alertStyle.Render("dir: (missing)"))
+			if m.homeNotice == "" {
+				lines = append(lines, alertStyle.Render(missingCwdNotice))
+			}
+		} else {
+			lines = append(lines, metaStyle.Render(fmt.Sprintf("dir: %s", cwd)))
+		}
+	case "notice":
+		if m.configWarning != "" {
+			lines = append(lines, alertStyle.Render(m.configWarning)+" "+metaStyle.Render("(W dismiss)"))
+		}
+		if m.homeNotice != "" {
+			lines = append(lines, alertStyle.Render(m.homeNotice))
+		}
+	case "sessions":
+		total := 0
+		for _, tool := range allTools(m.config) {
+			total += len(m.runningToolSessions(tool))
+		}
+		// T expands every session even past the summary threshold.
+		if total < 10 || m.showAllTaskDetails {
+			rowsView := m
+			if m.homePageSessions == nil {
+				if pages := m.homePages(); len(pages) > 1 {
+					rowsView = m.onHomePage(pages[min(max(m.homePage, 0), len(pages)-1)])
+				}
+			}
+			lines = append(lines, rowsView.homeRowLines()...)
+		} else {
+			for _, tool := range allTools(m.config) {
+				names := m.runningToolSessions(tool)
+				// Scratch shells and configured tools only get a summary
+				// while one is running.
+				if len(names) == 0 && tool != "claude" && tool != "codex" && tool != "cursor" {
+					continue
+				}
+				lines = append(lines, m.summaryRow(tool, names))
+			}
+		}
+	case "hotkeys":
+		lines = append(lines,
+			fmt.Sprintf("%s jump-dir   %s new   %s kill   %s search", keyStyle.Render("z"), keyStyle.Render("n"), keyStyle.Render("k"), keyStyle.Render("/")),
+			fmt.Sprintf("%s %s   %s rename   %s memo", keyStyle.Render("t"), map[bool]string{true: "hide tasks", false: "show tasks"}[m.showTaskLines()], keyStyle.Render("r"), keyStyle.Render("m")),
+		)
+		if m.hideIdleAfter() > 0 {
+			lines = append(lines, fmt.Sprintf("%s %s", keyStyle.Render("i"), map[bool]string{true: "collapse idle", false: "show idle"}[m.showIdleSessions]))
+		}
+		if m.hasAnyRunningSessions() {
+			lines = append(lines, fmt.Sprintf("%s quit   %s kill-all   %s commands   %s help", keyStyle.Render("d"), keyStyle.Render("^c"), keyStyle.Render(":"), keyStyle.Render("?")))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s quit    %s kill-all   %s commands   %s help", keyStyle.Render("d"), keyStyle.Render("^c"), keyStyle.Render(":"), keyStyle.Render("?")))
+		}
+		if m.homePageSessions != nil {
+			// A page being measured by homePages keeps a line for the
+			// indicator it will get.
+			lines = append(lines, "")
+		} else if pages := m.homePageCount(); pages > 1 {
+			page := min(max(m.homePage, 0), pages-1)
+			lines = append(lines, metaStyle.Render(fmt.Sprintf("page %d/%d (up/down to scroll)", page+1, pages)))
+		}
+	}
+	return lines
+}
+
+// terminalTitle returns the terminal window title for the current state:
+// the attach target once one is chosen, else the running session count. It
+// is "" when ui.terminal_title is off. Control characters are dropped so a
+// session name cannot end the title sequence early.
+func (m model) terminalTitle() string {
+	if m.config == nil || !m.config.UI.TerminalTitle {
+		return ""
+	}
+	running := 0
+	for _, binding := range m.bindings {
+		if binding.Running {
+			running++
+		}
+	}
+	title := fmt.Sprintf("pb: %d sessions active", running)
+	if m.shouldAttach && m.sessionToAttach != "" {
+		title = "pb → " + m.sessionToAttach
+	}
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, title)
+}
+
+// titleCmd sets the terminal window title, or is nil when there is none.
+func (m model) titleCmd() tea.Cmd {
+	if title := m.terminalTitle(); title != "" {
+		return tea.SetWindowTitle(title)
+	}
+	return nil
+}
+
+// The xterm title stack: runTUI pushes the terminal's own title before pb
+// sets one and pops it on exit, restoring it.
+const (
+	pushTitleSeq = "\033[22;0t"
+	popTitleSeq  = "\033[23;0t"
+)
+
+// detailColumns returns the configured session row columns in order.
+// ui.show_visit_count adds visits at the end unless it is already listed.
+func (m model) detailColumns() []string {
+	if m.config == nil {
+		return config.DefaultColumns()
+	}
+	cols := m.config.UI.Columns
+	if cols == nil {
+		cols = config.DefaultColumns()
+	}
+	if m.config.UI.ShowVisitCount && !slices.Contains(cols, "visits") {
+		cols = append(slices.Clone(cols), "visits")
+	}
+	return cols
+}
+
+func (m model) detailedRows(tool string, names []string) []string {
+	if m.windowWidth > 0 && m.windowWidth < compactWidth {
+		return m.detailedRowsCompact(tool, names)
+	}
+	var rows []string
+	keyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF"))
+	activeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Bold(true)
+	idleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#999999"))
+	repoLabelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	repoNameStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4")).Bold(true)
+	yoloStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF8A00")).Bold(true)
+	taskStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF"))
+	taskDetailStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#AAAAAA"))
+	warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F5F")).Bold(true)
+	newStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD700")).Bold(true)
+	metaStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	highlightStyle := lipgloss.NewStyle().Bold(true).Underline(true)
+	startingStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FFD700")).Italic(true)
+	alertStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF"))
+	searchQuery := ""
+	if m.mode == modeSearch {
+		searchQuery = strings.TrimSpace(m.searchQuery)
+	}
+	key := m.keyForTool(tool)
+	if len(names) == 0 {
+		if !m.toolEnabled(tool) || key == "" {
+			return nil
+		}
+		repoText := repoLabelStyle.Render("repo:") + repoNameStyle.Render("-")
+		rows = append(rows, fmt.Sprintf("%s %s %s %s",
+			keyStyle.Render("("+key+")"),
+			m.withToolIcon(tool, tool),
+			repoText,
+			idleStyle.Render("○ not running"),
+		))
+		return rows
+	}
+	// join, status and repoWidth are set per row before the renderers run.
+	var join, status string
+	var repoWidth int
+	renderers := map[string]func(name string, binding commandBinding) string{
+		"key": func(name string, binding commandBinding) string {
+			hint := keyStyle.Render("(" + join + ")")
+			if binding.Priority > 0 {
+				hint += " " + alertStyle.Render(fmt.Sprintf("[%d]", binding.Priority))
+			}
+			return hint
+		},
+		"name": func(name string, binding commandBinding) string {
+			return m.withToolIcon(tool, m.displayName(name))
+		},
+		"repo": func(name string, binding commandBinding) string {
+			return repoLabelStyle.Render("repo:") + repoNameStyle.Render(truncateName(m.resolveRepoDisplay(binding.Cwd), repoWidth))
+		},
+		"yolo": func(name string, binding commandBinding) string {
+			if !binding.Yolo {
+				return ""
+			}
+			return yoloStyle.Render("(yolo)")
+		},
+		"status": func(name string, binding commandBinding) string {
+			var parts []string
+			if m.unseenOutput[name] {
+				parts = append(parts, newStyle.Render("●new"))
+			}
+			if m.zombieSessions[name] {
+				parts = append(parts, alertStyle.Render("⚠ zombie"))
+			}
+			if badge := m.cpuBadge(name); badge != "" {
+				parts = append(parts, alertStyle.Render(badge))
+			}
+			if warning := m.sessionWarnings[name]; warning != "" {
+				parts = append(parts, warnStyle.Render("⚠ "+warning))
+			}
+			if status != "" {
+				parts = append(parts, status)
+			}
+			if m.showTaskLines() {
+				if spark := renderActivitySparkline(m.activityHistory[name]); spark != "" {
+					parts = append(parts, idleStyle.Render(spark))
+				}
+			}
+			return strings.Join(parts, " ")
+		},
+		"tasks": func(name string, binding commandBinding) string {
+			if n := m.taskCounts[name]; n > 0 && !m.showTaskLines() {
+				return taskStyle.Render(fmt.Sprintf("tasks:%d", n))
+			}
+			return ""
+		},
+		"uptime": func(name string, binding commandBinding) string {
+			if binding.Created.IsZero() {
+				return ""
+			}
+			return repoLabelStyle.Render("up:" + formatUptime(time.Since(binding.Created)))
+		},
+		"visits": func(name string, binding commandBinding) string {
+			if binding.AttachCount <= 1 {
+				return ""
+			}
+			return repoLabelStyle.Render(fmt.Sprintf("visits:%d", binding.AttachCount))
+		},
+	}
+	letters := m.pickerLetters(tool, names)
+	hidden := 0
+	for _, name := range names {
+		join = key
+		if len(names) > 1 {
+			letter := letters[name]
+			if letter == "" {
+				continue
+			}
+			join = key + " " + letter
+		}
+		if m.idleHidden(name) {
+			hidden++
+			continue
+		}
+		if m.homePageSessions != nil && !m.homePageSessions[name] {
+			continue
+		}
+		status = ""
+		if m.isStarting(name) {
+			status = startingStyle.Render("…starting")
+		} else if sess, ok := m.sessions[name]; ok && sess.ActivityKnown() {
+			status = lipgloss.NewStyle().Foreground(idleColor(sess.IdleFor())).Render("○ idle")
+			if sess.RecentlyActive() {
+				status = m.activeIndicator("● active", activeStyle)
+			}
+		}
+		binding := m.bindings[name]
+		renderRow := func() string {
+			var rowParts []string
+			for _, col := range m.detailColumns() {
+				render, ok := renderers[col]
+				if !ok {
+					continue
+				}
+				part := render(name, binding)
+				if part == "" {
+					continue
+				}
+				if col == "name" && searchQuery != "" && strings.Contains(strings.ToLower(name), strings.ToLower(searchQuery)) {
+					// Only the name is highlighted so the key hint never matches.
+					part = highlightMatch(part, searchQuery, highlightStyle)
+				}
+				rowParts = append(rowParts, part)
+			}
+			return strings.Join(rowParts, " ")
+		}
+		repoWidth = 0
+		row := renderRow()
+		if width := m.effectiveWidth(); width > 0 && lipgloss.Width(row) > width {
+			// Shorten the repo before the line clip so the status badges
+			// at the end of the row stay visible.
+			full := lipgloss.Width(m.resolveRepoDisplay(binding.Cwd))
+			repoWidth = m.fitWidth(lipgloss.Width(row)-full, full)
+			row = renderRow()
+		}
+		rows = append(rows, row)
+		if binding.Memo != "" {
+			rows = append(rows, metaStyle.Italic(true).Render("  "+binding.Memo))
+		}
+		if m.showTaskLines() {
+			for _, cmd := range m.taskCommands[name] {
+				rows = append(rows, taskDetailStyle.Render("  task: "+m.truncateCommand(cmd, m.taskCommandWidth())))
+			}
+		}
+		if m.tooltipVisible(name) {
+			for _, line := range strings.Split(m.buildTooltip(name), "\n") {
+				rows = append(rows, taskDetailStyle.Render("  │ "+line))
+			}
+			if note := m.bindings[name].Note; note != "" {
+				for _, line := range strings.Split(renderNoteBox(note), "\n") {
+					rows = append(rows, "  "+line)
+				}
+			}
+		}
+	}
+	if hidden > 0 {
+		rows = append(rows, fmt.Sprintf("%s %s %s",
+			keyStyle.Render("("+key+")"),
+			m.withToolIcon(tool, tool),
+			idleStyle.Render(fmt.Sprintf("+%d idle", hidden)),
+		))
+	}
+	return rows
+}
+
+func (m model) maxNameDisplay() int {
+	if m.config == nil || m.config.MaxNameDisplay <= 0 {
+		return config.DefaultMaxNameDisplay
+	}
+	return m.config.MaxNameDisplay
+}
+
+// displayName shortens a session name to max_name_display for rendering.
+// Only use it for display; tmux targets always need the full name.
+func (m model) displayName(name string) string {
+	return truncateName(name, m.maxNameDisplay())
+}
+
+// truncateName cuts name to at most max terminal cells, ending in an
+// ellipsis when shortened. Wide runes such as emoji and CJK count as two.
+func truncateName(name string, max int) string {
+	if max <= 0 || lipgloss.Width(name) <= max {
+		return name
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range name {
+		w := lipgloss.Width(string(r))
+		if width+w > max-1 {
+			break
+		}
+		b.WriteRune(r)
+		width += w
+	}
+	return b.String() + "…"
+}
+
+// truncateMiddle shortens s to max display columns by replacing its middle
+// with "…", keeping both the command name and its final arguments visible.
+func truncateMiddle(s string, max int) string {
+	if max <= 0 || lipgloss.Width(s) <= max {
+		return s
+	}
+	if max == 1 {
+		return "…"
+	}
+	runes := []rune(s)
+	headWidth := max / 2
+	tailWidth := max - 1 - headWidth
+	var head strings.Builder
+	width := 0
+	for _, r := range runes {
+		w := lipgloss.Width(string(r))
+		if width+w > headWidth {
+			break
+		}
+		head.WriteRune(r)
+		width += w
+	}
+	start := len(runes)
+	width = 0
+	for start > 0 {
+		w := lipgloss.Width(string(runes[start-1]))
+		if width+w > tailWidth {
+			break
+		}
+		start--
+		width += w
+	}
+	return head.String() + "…" + string(runes[start:])
+}
+
+// stripCommandPaths shortens each word of a task command: anything under
+// node_modules keeps only the package path after it (minus .bin/), and
+// words starting with one of prefixes lose that prefix.
+func stripCommandPaths(command string, prefixes []string) string {
+	words := strings.Fields(command)
+	for i, word := range words {
+		if idx := strings.LastIndex(word, "/node_modules/"); idx >= 0 {
+			word = strings.TrimPrefix(word[idx+len("/node_modules/"):], ".bin/")
+		}
+		for _, prefix := range prefixes {
+			if prefix != "" && strings.HasPrefix(word, prefix) && len(word) > len(prefix) {
+				word = word[len(prefix):]
+				break
+			}
+		}
+		words[i] = word
+	}
+	return strings.Join(words, " ")
+}
+
+// truncateCommand fits a task command into maxLen columns, stripping
+// ui.command_strip_prefixes and node_modules paths before cutting the middle.
+func (m model) truncateCommand(command string, maxLen int) string {
+	var prefixes []string
+	if m.config != nil {
+		prefixes = m.config.UI.CommandStripPrefixes
+	}
+	return truncateMiddle(stripCommandPaths(command, prefixes), maxLen)
+}
+
+// taskCommandWidth is how many columns task commands get: 60, or less on
+// narrow terminals.
+func (m model) taskCommandWidth() int {
+	width := 60
+	if w := m.effectiveWidth(); w > 0 && w-10 < width {
+		width = w - 10
+	}
+	if width < 10 {
+		width = 10
+	}
+	return width
+}
+
+// minCellWidth is the narrowest fitWidth shrinks a cell to.
+const minCellWidth = 8
+
+// fitWidth is how many columns a cell may take on a line whose other parts
+// use used columns: want, or less when the line would run past
+// effectiveWidth, but never under minCellWidth.
+func (m model) fitWidth(used, want int) int {
+	width := m.effectiveWidth()
+	if width <= 0 || used+want <= width {
+		return want
+	}
+	return max(width-used, min(want, minCellWidth))
+}
+
+// toolIcon returns the configured icon for a tool, or "" when icons
+// are disabled or the tool has none.
+func (m model) toolIcon(tool string) string {
+	if m.config == nil || m.config.DisableIcons {
+		return ""
+	}
+	if tool == scratchTool {
+		return "🐚"
+	}
+	if tc := m.config.Tool(tool); tc != nil {
+		return tc.Icon
+	}
+	return ""
+}
+
+// withToolIcon prefixes text with the tool's icon when one is shown.
+func (m model) withToolIcon(tool, text string) string {
+	if icon := m.toolIcon(tool); icon != "" {
+		return icon + " " + text
+	}
+	return text
+}
+
+// detailedRowsCompact renders home rows for narrow terminals: no "repo:"
+// label, a bare status glyph, and no task count or detail lines.
+func (m model) detailedRowsCompact(tool string, names []string) []string {
+	var rows []string
+	keyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF"))
+	activeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Bold(true)
+	idleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#999999"))
+	repoNameStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4")).Bold(true)
+	warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5F5F")).Bold(true)
+	nameWidth := m.maxNameDisplay()
+	if nameWidth > compactNameWidth {
+		nameWidth = compactNameWidth
+	}
+	key := m.keyForTool(tool)
+	if len(names) == 0 {
+		if !m.toolEnabled(tool) || key == "" {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s %s %s",
+			keyStyle.Render("("+key+")"),
+			m.withToolIcon(tool, tool),
+			idleStyle.Render("○"),
+		)}
+	}
+	letters := m.pickerLetters(tool, names)
+	hidden := 0
+	for _, name := range names {
+		join := key
+		if len(names) > 1 {
+			letter := letters[name]
+			if letter == "" {
+				continue
+			}
+			join = key + " " + letter
+		}
+		if m.idleHidden(name) {
+			hidden++
+			continue
+		}
+		if m.homePageSessions != nil && !m.homePageSessions[name] {
+			continue
+		}
+		repo := "-"
+		if binding, ok := m.bindings[name]; ok {
+			repo = m.resolveRepoDisplay(binding.Cwd)
+		}
+		rowParts := []string{
+			keyStyle.Render("(" + join + ")"),
+			m.withToolIcon(tool, truncateName(name, nameWidth)),
+			repoNameStyle.Render(truncateName(repo, compactRepoWidth)),
+		}
+		if m.sessionWarnings[name] != "" || m.zombieSessions[name] {
+			rowParts = append(rowParts, warnStyle.Render("⚠"))
+		}
+		if m.isStarting(name) {
+			rowParts = append(rowParts, idleStyle.Render("…"))
+		} else if sess, ok := m.sessions[name]; ok && sess.ActivityKnown() {
+			if sess.RecentlyActive() {
+				rowParts = append(rowParts, m.activeIndicator("●", activeStyle))
 			} else {
-				lines = append(lines, fmt.Sprintf("%s new cursor", keyStyle.Render(m.keyForTool("cursor"))))
+				rowParts = append(rowParts, lipgloss.NewStyle().Foreground(idleColor(sess.IdleFor())).Render("○"))
 			}
 		}
-		if !m.toolEnabled("claude") && !m.toolEnabled("codex") && !m.toolEnabled("cursor") {
-			lines = append(lines, metaStyle.Render("all built-in tools are disabled"))
+		rows = append(rows, strings.Join(rowParts, " "))
+	}
+	if hidden > 0 {
+		rows = append(rows, fmt.Sprintf("%s %s %s",
+			keyStyle.Render("("+key+")"),
+			m.withToolIcon(tool, tool),
+			idleStyle.Render(fmt.Sprintf("+%d idle", hidden)),
+		))
+	}
+	return rows
+}
+
+func (m model) hideIdleAfter() time.Duration {
+	if m.config == nil {
+		return 0
+	}
+	return m.config.HideIdleAfter
+}
+
+// idleHidden reports whether name should collapse into its tool's "+N idle"
+// line because it has been idle longer than hide_idle_after.
+func (m model) idleHidden(name string) bool {
+	threshold := m.hideIdleAfter()
+	if threshold <= 0 || m.showIdleSessions {
+		return false
+	}
+	sess, ok := m.sessions[name]
+	if !ok {
+		return false
+	}
+	idleFor, ok := sessionIdleFn(sess)
+	return ok && idleFor > threshold
+}
+
+// activeIndicator renders an active session's marker in style, dimmed on odd
+// ticks so it blinks once a second, unless ui.disable_animations is set.
+func (m model) activeIndicator(text string, style lipgloss.Style) string {
+	if m.tickCount%2 == 1 && (m.config == nil || !m.config.UI.DisableAnimations) {
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")).Render(text)
+	}
+	return style.Render(text)
+}
+
+// idleColor maps how long a session has been idle to a status color: amber
+// while recently idle, fading to grey as it goes stale. Tiers follow the
+// activity poll backoff in the tmux package.
+func idleColor(idleFor time.Duration) lipgloss.Color {
+	switch {
+	case idleFor < 30*time.Second:
+		return lipgloss.Color("#FFB000")
+	case idleFor < 2*time.Minute:
+		return lipgloss.Color("#C8A96A")
+	default:
+		return lipgloss.Color("#999999")
+	}
+}
+
+func (m model) summaryRow(tool string, names []string) string {
+	active := 0
+	taskTotal := 0
+	for _, name := range names {
+		if sess, ok := m.sessions[name]; ok && sess.RecentlyActive() {
+			active++
 		}
-		lines = append(lines, "")
-		if m.newToolFresh {
-			lines = append(lines, fmt.Sprintf("%s fresh: %s", keyStyle.Render("f"), yoloStyle.Render("ON (no previous context)")))
-		} else {
-			lines = append(lines, fmt.Sprintf("%s fresh: off", keyStyle.Render("f")))
+		taskTotal += m.taskCounts[name]
+	}
+	metaStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	activeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Bold(true)
+	parts := []string{
+		m.withToolIcon(tool, tool),
+		fmt.Sprintf("%d", len(names)),
+		activeStyle.Render(fmt.Sprintf("active:%d", active)),
+		metaStyle.Render(fmt.Sprintf("idle:%d", len(names)-active)),
+	}
+	if taskTotal > 0 {
+		parts = append(parts, metaStyle.Render(fmt.Sprintf("tasks:%d", taskTotal)))
+	}
+	return strings.Join(parts, " ")
+}
+
+// activitySummary counts the running sessions from the last refreshBindings
+// and how many of them are currently active, for the title bar badge.
+func (m model) activitySummary() (active, total int) {
+	for name, binding := range m.bindings {
+		if !binding.Running {
+			continue
 		}
-		if m.newToolAuto {
-			lines = append(lines, fmt.Sprintf("%s auto: %s", keyStyle.Render("a"), yoloStyle.Render("ON (claude auto/codex full-auto)")))
-		} else {
-			lines = append(lines, fmt.Sprintf("%s auto: off", keyStyle.Render("a")))
+		total++
+		if sess, ok := m.sessions[name]; ok && sessionActiveFn(sess) {
+			active++
 		}
-		if m.newToolYolo {
-			lines = append(lines, fmt.Sprintf("%s yolo: %s", keyStyle.Render("y"), yoloStyle.Render("ON (skip all permissions)")))
-		} else {
-			lines = append(lines, fmt.Sprintf("%s yolo: off", keyStyle.Render("y")))
+	}
+	return active, total
+}
+
+// hasAnyRunningSessions reports whether the last refreshBindings saw a
+// running session.
+func (m model) hasAnyRunningSessions() bool {
+	for _, binding := range m.bindings {
+		if binding.Running {
+			return true
+		}
+	}
+	return false
+}
+
+var ansiPattern = regexp.MustCompile("\x1b\\[[0-9;]*[A-Za-z]")
+
+// stripANSI removes terminal escape sequences, leaving the visible text.
+func stripANSI(s string) string {
+	return ansiPattern.ReplaceAllString(s, "")
+}
+
+// highlightMatch renders the first case-insensitive occurrence of query in
+// the visible text of s with style. s may already contain ANSI styling: the
+// match is located in the stripped text, escapes inside the match are dropped,
+// and the style that was in effect is re-applied after it.
+func highlightMatch(s, query string, style lipgloss.Style) string {
+	if query == "" {
+		return s
+	}
+	plain := stripANSI(s)
+	start := strings.Index(strings.ToLower(plain), strings.ToLower(query))
+	if start < 0 {
+		return s
+	}
+	end := start + len(query)
+
+	var b strings.Builder
+	active := ""
+	pos := 0 // byte offset into the visible text
+	for i := 0; i < len(s); {
+		if loc := ansiPattern.FindStringIndex(s[i:]); loc != nil && loc[0] == 0 {
+			seq := s[i : i+loc[1]]
+			if seq == "\x1b[0m" || seq == "\x1b[m" {
+				active = ""
+			} else {
+				active += seq
+			}
+			if pos <= start || pos >= end {
+				b.WriteString(seq)
+			}
+			i += loc[1]
+			continue
+		}
+		if pos == start {
+			b.WriteString(style.Render(plain[start:end]))
+		}
+		if pos >= start && pos < end {
+			pos++
+			i++
+			if pos == end && active != "" {
+				b.WriteString(active)
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+		pos++
+		i++
+	}
+	return b.String()
+}
+
+func capLines(lines []string, max int) []string {
+	if len(lines) <= max {
+		return lines
+	}
+	if max <= 0 {
+		return []string{}
+	}
+	out := append([]string{}, lines[:max]...)
+	out[max-1] = "..."
+	return out
+}
+
+func (m model) viewAttached() string {
+	helpStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("#888888")).
+		Italic(true)
+
+	help := helpStyle.Render("[Press Ctrl+D to detach]")
+
+	// This view is not actually used - attach happens outside Bubble Tea
+	return fmt.Sprintf("%s\n\n[Attached to Claude]\n", help)
+}
+
+func main() {
+	flags, args, err := extractGlobalFlags(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Usage: pb [--readonly] [--no-alt-screen] [--log-file <path>] [command]\n")
+		os.Exit(1)
+	}
+	readonly, noAltScreen, logPath := flags.readonly, flags.noAltScreen, flags.logPath
+	if logPath != "" {
+		if err := log.Open(logPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer log.Close()
+		log.Debug("pb started: %s", strings.Join(os.Args, " "))
+	}
+
+	// The socket must be chosen before the first tmux command, and
+	// subcommands like `pb new <tool>` need configured tool names before
+	// any of them loads the config itself. Invalid configs are reported
+	// later by whoever loads them properly; until then a nil cfg means the
+	// default tools.
+	var cfg *config.Config
+	if loaded, err := loadConfigUnvalidated(); err == nil {
+		if loaded.Validate() == nil {
+			tmux.SetSocketName(loaded.Socket)
+		}
+		cfg = loaded
+	}
+
+	// Only reads the environment and config, so it works without tmux
+	// installed.
+	if len(args) > 0 && args[0] == "--print-socket" {
+		printSocket(os.Stdout)
+		return
+	}
+
+	// Old tmux servers cannot hold @pb_* options; pick the store once.
+	tmux.DetectOptionSupport()
+
+	// --readonly refuses writes where they happen, so attach bookkeeping
+	// (last attached, attach count, seen hash) is skipped too.
+	if readonly {
+		tmux.RefuseWrites()
+	}
+
+	// Handle subcommands
+	if len(args) > 0 {
+		handleSubcommand(args[0], args[1:], cfg, noAltScreen, readonly)
+		return
+	}
+
+	runTUI(noAltScreen, readonly)
+}
+
+// printSocket prints the tmux socket pocketbot uses, for `tmux -L` (or `-S`
+// when it is a path) in scripts.
+func printSocket(w io.Writer) {
+	fmt.Fprintln(w, tmux.SocketName())
+}
+
+// readonlyRefusedSubcommands are the subcommands that change sessions, so
+// --readonly refuses them.
+var readonlyRefusedSubcommands = map[string]bool{
+	"new":        true,
+	"kill-all":   true,
+	"label":      true,
+	"clean":      true,
+	"detach-all": true,
+}
+
+// globalFlags are the flags given before any subcommand.
+type globalFlags struct {
+	readonly    bool
+	noAltScreen bool
+	logPath     string
+}
+
+// extractGlobalFlags strips leading --readonly, --no-alt-screen and
+// --log-file <path> (or --log-file=<path>) from args, in any order, and
+// returns the rest for subcommand dispatch.
+func extractGlobalFlags(args []string) (globalFlags, []string, error) {
+	var flags globalFlags
+	for len(args) > 0 {
+		switch arg := args[0]; {
+		case arg == "--readonly":
+			flags.readonly = true
+			args = args[1:]
+		case arg == "--no-alt-screen":
+			flags.noAltScreen = true
+			args = args[1:]
+		case arg == "--log-file":
+			if len(args) < 2 || args[1] == "" {
+				return flags, nil, fmt.Errorf("--log-file needs a path")
+			}
+			flags.logPath = args[1]
+			args = args[2:]
+		case strings.HasPrefix(arg, "--log-file="):
+			flags.logPath = strings.TrimPrefix(arg, "--log-file=")
+			if flags.logPath == "" {
+				return flags, nil, fmt.Errorf("--log-file needs a path")
+			}
+			args = args[1:]
+		default:
+			return flags, args, nil
+		}
+	}
+	return flags, args, nil
+}
+
+// newProgram builds the Bubble Tea program for one run of the home screen,
+// on the alternate screen buffer unless --no-alt-screen was given.
+func (m model) newProgram() *tea.Program {
+	var opts []tea.ProgramOption
+	if !m.noAltScreen {
+		opts = append(opts, tea.WithAltScreen())
+	}
+	factory := m.teaProgramFactory
+	if factory == nil {
+		factory = tea.NewProgram
+	}
+	return factory(m, opts...)
+}
+
+// runTUI runs the home screen, attaching and returning to it until the user
+// quits.
+func runTUI(noAltScreen, readonly bool) {
+	m := initialModel()
+	m.noAltScreen = noAltScreen
+	m.readonly = readonly
+	if m.terminalTitle() != "" {
+		fmt.Print(pushTitleSeq)
+		defer fmt.Print(popTitleSeq)
+	}
+
+	// Note: We don't kill tmux sessions on exit - they persist in background
+	// User can manually kill with: tmux -L pocketbot kill-server
+
+	// Main loop: run UI, attach when requested, repeat
+	for {
+		m.shouldAttach = false
+		m.sessionToAttach = ""
+		m.paneToAttach = ""
+		m.viewState = viewHome
+		// View only renders model state, so load it before the first frame.
+		m.refreshBindings()
+		m.refreshTaskCounts()
+
+		p := m.newProgram()
+		finalModel, err := p.Run()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Get the final model state
+		m = finalModel.(model)
+
+		// Check if we should attach
+		if !m.shouldAttach || m.sessionToAttach == "" {
+			// User quit normally
+			break
+		}
+
+		// Attach to requested tmux session
+		tmuxSess, exists := m.sessions[m.sessionToAttach]
+		if !exists || tmuxSess == nil {
+			tmuxSess = newSessionFor(m.config, m.sessionToAttach, m.sessionTool(m.sessionToAttach), "")
+			m.sessions[m.sessionToAttach] = tmuxSess
+		}
+		if !tmuxSess.IsRunning() {
+			// The home screen is about to redraw over stderr, so say it there.
+			log.Error("attach %s: session is not running", m.sessionToAttach)
+			m.homeNotice = fmt.Sprintf("session %s is not running", m.sessionToAttach)
+			continue
+		}
+
+		// Note: No delay needed. The original bug was an invalid claude flag,
+		// not a race condition. See TestClaudeCommandFlag for regression test.
+
+		_ = setLastAttachedFn(m.sessionToAttach)
+		if err := recordAttach(m.sessionToAttach); err != nil {
+			log.Debug("attach count %s: %v", m.sessionToAttach, err)
+		}
+		// Whatever is on screen as the user attaches counts as seen.
+		m.markSessionSeen(m.sessionToAttach)
+
+		// tmux attach - returns when user detaches (prefix+d)
+		attach := tmuxSess.Attach
+		if m.paneToAttach != "" {
+			target := m.paneToAttach
+			attach = func() error { return tmux.AttachSessionTarget(target) }
+		}
+		log.Debug("attaching %s", m.sessionToAttach)
+		if err := attach(); err != nil {
+			log.Error("attach %s: %v", m.sessionToAttach, err)
+			m.homeNotice = fmt.Sprintf("attach error: %v", err)
+			// Check if session died
+			if !tmuxSess.IsRunning() {
+				m.homeNotice = fmt.Sprintf("session %s exited", m.sessionToAttach)
+			}
+		}
+		log.Debug("detached from %s", m.sessionToAttach)
+
+		m.autoCwdChange(m.sessionToAttach)
+
+		// Always return to home screen after detach
+	}
+}
+
+func handleSubcommand(cmd string, args []string, cfg *config.Config, noAltScreen, readonly bool) {
+	if readonly && readonlyRefusedSubcommands[cmd] {
+		fmt.Fprintf(os.Stderr, "Error: pb %s is disabled in --readonly mode\n", cmd)
+		os.Exit(1)
+	}
+	switch cmd {
+	case "test":
+		runCommand("go", "test", "./...")
+	case "build":
+		runCommand("go", "build", "-o", "pb", "./cmd/pb")
+	case "install":
+		runCommand("go", "install", "./cmd/pb")
+	case "run":
+		runCommand("go", "run", "./cmd/pb")
+	case "demo":
+		// Run a simple demo session for testing
+		runDemoSession()
+	case "sessions":
+		opts, err := parseSessionsArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Usage: pb sessions [--raw]\n")
+			os.Exit(1)
+		}
+		if opts.Raw {
+			// Show sessions for current nesting level
+			runCommand("tmux", append(tmux.SocketArgs(), "list-sessions")...)
+			return
+		}
+		if err := printSessionsTable(os.Stdout, cfg, time.Now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing sessions: %v\n", err)
+			os.Exit(1)
+		}
+	case "tasks":
+		opts, err := parseTaskListArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Usage: pb tasks [--session <name>] [--all] [--json] [--list-patterns]\n")
+			os.Exit(1)
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			cfg = config.DefaultConfig()
+		}
+		applyTaskPatterns(cfg)
+		if opts.ListPatterns {
+			printTaskPatterns(os.Stdout, cfg.TaskPatterns)
+			return
 		}
-		lines = append(lines, "esc cancel")
-	case modeKillTool:
-		runningClaude := len(m.runningToolSessions("claude")) > 0
-		runningCodex := len(m.runningToolSessions("codex")) > 0
-		runningCursor := len(m.runningToolSessions("cursor")) > 0
-		renderKillRows := func(tool, key string) {
-			names := m.runningToolSessions(tool)
-			if len(names) == 0 {
-				return
-			}
-			if len(names) == 1 {
-				lines = append(lines, fmt.Sprintf("%s kill %s", keyStyle.Render(key), names[0]))
-				return
-			}
-			for i, name := range names {
-				letter := alphaKey(i)
-				if letter == "" {
-					break
-				}
-				repo := "-"
-				if binding, ok := m.bindings[name]; ok {
-					repo = repoFromCwd(binding.Cwd)
-				}
-				lines = append(lines, fmt.Sprintf("%s %s repo:%s", keyStyle.Render("("+key+" "+letter+")"), name, repoNameStyle.Render(repo)))
-			}
+		printToolTasks(cfg, opts)
+	case "new":
+		opts, err := parseNewArgs(cfg, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Usage: pb new <claude|codex|cursor|scratch> [--fresh]\n")
+			os.Exit(1)
 		}
-		if runningClaude && m.toolEnabled("claude") {
-			renderKillRows("claude", m.keyForTool("claude"))
+		runNewSession(opts)
+	case "label":
+		opts, err := parseLabelArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Usage: pb label <session> <1-9|none>\n")
+			os.Exit(1)
 		}
-		if runningCodex && m.toolEnabled("codex") {
-			renderKillRows("codex", m.keyForTool("codex"))
+		if err := applyLabel(opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-		if runningCursor && m.toolEnabled("cursor") {
-			renderKillRows("cursor", m.keyForTool("cursor"))
+	case "list":
+		opts, err := parseListArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Usage: pb list [--json[=false]]\n")
+			os.Exit(1)
 		}
-		lines = append(lines, fmt.Sprintf("%s kill task", keyStyle.Render("t")))
-		lines = append(lines, "esc cancel")
-	case modeRenameTool:
-		runningClaude := len(m.runningToolSessions("claude")) > 0
-		runningCodex := len(m.runningToolSessions("codex")) > 0
-		runningCursor := len(m.runningToolSessions("cursor")) > 0
-		renderRenameRows := func(tool, key string) {
-			names := m.runningToolSessions(tool)
-			if len(names) == 0 {
-				return
-			}
-			if len(names) == 1 {
-				lines = append(lines, fmt.Sprintf("%s rename %s", keyStyle.Render(key), names[0]))
-				return
-			}
-			for i, name := range names {
-				letter := alphaKey(i)
-				if letter == "" {
-					break
-				}
-				repo := "-"
-				if binding, ok := m.bindings[name]; ok {
-					repo = repoFromCwd(binding.Cwd)
-				}
-				lines = append(lines, fmt.Sprintf("%s %s repo:%s", keyStyle.Render("("+key+" "+letter+")"), name, repoNameStyle.Render(repo)))
-			}
+		list := listSessionsJSON
+		if !opts.JSON {
+			list = listSessionsTable
 		}
-		if runningClaude && m.toolEnabled("claude") {
-			renderRenameRows("claude", m.keyForTool("claude"))
+		if err := list(os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error listing sessions: %v\n", err)
+			os.Exit(1)
 		}
-		if runningCodex && m.toolEnabled("codex") {
-			renderRenameRows("codex", m.keyForTool("codex"))
+	case "attach":
+		opts, err := parseAttachArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Usage: pb attach (<session> | --last)\n")
+			os.Exit(1)
 		}
-		if runningCursor && m.toolEnabled("cursor") {
-			renderRenameRows("cursor", m.keyForTool("cursor"))
+		runAttach(opts)
+	case "detach-all":
+		if err := tmux.DetachAllSessionClients(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error detaching clients: %v\n", err)
+			os.Exit(1)
 		}
-		lines = append(lines, "esc cancel")
-	case modePickAttach, modePickKill:
-		action := "attach"
-		if m.mode == modePickKill {
-			action = "kill"
+	case "clean":
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: unexpected argument %q\n", args[0])
+			fmt.Fprintf(os.Stderr, "Usage: pb clean\n")
+			os.Exit(1)
 		}
-		lines = append(lines, metaStyle.Render(fmt.Sprintf("%s %s", action, m.pickerTool)))
-		keys := make([]string, 0, len(m.pickerTargets))
-		for k := range m.pickerTargets {
-			keys = append(keys, k)
+		if _, err := cleanDeadSessions(os.Stdout, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-		sort.Strings(keys)
-		if m.mode == modePickKill {
-			lines = append(lines, alertStyle.Render("pick one key to kill"))
-		} else {
-			lines = append(lines, metaStyle.Render("pick one key to attach"))
+	case "kill-all":
+		// Kill sessions for current nesting level
+		releaseAllDirLocks(cfg)
+		runCommand("tmux", append(tmux.SocketArgs(), "kill-server")...)
+	case "--test-config":
+		runTestConfig()
+	case "--observe":
+		opts, err := parseObserveArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Usage: pb --observe (-L <socket-name> | -S <socket-path>)\n")
+			os.Exit(1)
 		}
-		for _, k := range keys {
-			name := m.pickerTargets[k]
-			status := ""
-			if sess, ok := m.sessions[name]; ok && sess.ActivityKnown() {
-				status = idleStyle.Render("○")
-				if sess.IsActive() {
-					status = activeStyle.Render("●")
-				}
-			}
-			repo := "-"
-			if binding, ok := m.bindings[name]; ok {
-				repo = repoFromCwd(binding.Cwd)
-			}
-			rowParts := []string{keyStyle.Render("(" + k + ")"), name}
-			if status != "" {
-				rowParts = append(rowParts, status)
-			}
-			rowParts = append(rowParts, repoNameStyle.Render(repo))
-			lines = append(lines, strings.Join(rowParts, " "))
+		tmux.Observe(opts.SocketName, opts.SocketPath)
+		runTUI(noAltScreen, readonly)
+	case "config":
+		opts, err := parseConfigArgs(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Usage: pb config (init [--force] | validate | show)\n")
+			os.Exit(1)
 		}
-		lines = append(lines, "esc cancel")
-	case modePickRename:
-		lines = append(lines, metaStyle.Render("rename "+m.pickerTool))
-		keys := make([]string, 0, len(m.pickerTargets))
-		for k := range m.pickerTargets {
-			keys = append(keys, k)
+		if opts.Show {
+			if err := showConfig(os.Stdout); err != nil {
+				fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+				os.Exit(1)
+			}
+			return
 		}
-		sort.Strings(keys)
-		lines = append(lines, alertStyle.Render("pick one key"))
-		for _, k := range keys {
-			name := m.pickerTargets[k]
-			repo := "-"
-			if binding, ok := m.bindings[name]; ok {
-				repo = repoFromCwd(binding.Cwd)
+		if opts.Validate {
+			summary, err := validateConfig()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "config error: %v\n", err)
+				os.Exit(1)
 			}
-			lines = append(lines, fmt.Sprintf("%s %s %s",
-				keyStyle.Render("("+k+")"),
-				name,
-				repoNameStyle.Render(repo),
-			))
+			fmt.Print(summary)
+			return
 		}
-		lines = append(lines, "esc cancel")
-	case modePickKillTask:
-		lines = append(lines, metaStyle.Render("kill task"))
-		keys := make([]string, 0, len(m.taskKillTargets))
-		for k := range m.taskKillTargets {
-			keys = append(keys, k)
+		path, err := initConfigFile(opts.Force)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
 		}
-		sort.Strings(keys)
-		lines = append(lines, alertStyle.Render("pick one key to kill task"))
-		for _, k := range keys {
-			target := m.taskKillTargets[k]
-			lines = append(lines, fmt.Sprintf("%s %s pid:%d %s",
-				keyStyle.Render("("+k+")"),
-				target.Session,
-				target.PID,
-				target.Command,
-			))
+		fmt.Printf("Wrote %s\n", path)
+	case "doctor":
+		if !printDoctor(os.Stdout) {
+			os.Exit(1)
 		}
-		lines = append(lines, "esc cancel")
-	case modeRenameInput:
-		lines = append(lines, metaStyle.Render(fmt.Sprintf("rename %s", m.renameTarget)))
-		cursorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF")).Bold(true)
-		lines = append(lines, fmt.Sprintf("new name: %s%s%s", m.renameInput[:m.renameCursor], cursorStyle.Render("▌"), m.renameInput[m.renameCursor:]))
-		lines = append(lines, "enter confirm   esc cancel")
+	case "help", "-h", "--help":
+		printHelp()
 	default:
-		claude := m.runningToolSessions("claude")
-		codex := m.runningToolSessions("codex")
-		cursor := m.runningToolSessions("cursor")
-		total := len(claude) + len(codex) + len(cursor)
-		lines = append(lines, "")
-		if total < 10 {
-			lines = append(lines, m.detailedRows("claude", claude)...)
-			lines = append(lines, m.detailedRows("codex", codex)...)
-			lines = append(lines, m.detailedRows("cursor", cursor)...)
-		} else {
-			lines = append(lines, m.summaryRow("claude", claude))
-			lines = append(lines, m.summaryRow("codex", codex))
-			lines = append(lines, m.summaryRow("cursor", cursor))
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", cmd)
+		fmt.Fprintf(os.Stderr, "Run 'pb help' for usage\n")
+		os.Exit(1)
+	}
+}
+
+// taskListOptions narrows `pb tasks` output.
+type taskListOptions struct {
+	Session string // Only show this session (any session, not just tools)
+	All     bool   // Show every descendant process instead of filtered user tasks
+	// ListPatterns prints the effective noise/highlight patterns instead of tasks.
+	ListPatterns bool
+	JSON         bool // Print a JSON array instead of text
+}
+
+func parseTaskListArgs(args []string) (taskListOptions, error) {
+	var opts taskListOptions
+	fs := flag.NewFlagSet("tasks", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.Session, "session", "", "only show tasks for this session")
+	fs.BoolVar(&opts.All, "all", false, "show all descendant processes")
+	fs.BoolVar(&opts.ListPatterns, "list-patterns", false, "print the task filter patterns")
+	fs.BoolVar(&opts.JSON, "json", false, "print tasks as JSON")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	sessionSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "session" {
+			sessionSet = true
+		}
+	})
+	if sessionSet && strings.TrimSpace(opts.Session) == "" {
+		return opts, fmt.Errorf("--session requires a session name")
+	}
+	return opts, nil
+}
+
+// configOptions selects the `pb config` action.
+type configOptions struct {
+	Validate bool // `pb config validate` instead of init
+	Show     bool // `pb config show` instead of init
+	Force    bool // Overwrite an existing config file on init
+}
+
+func parseConfigArgs(args []string) (configOptions, error) {
+	var opts configOptions
+	if len(args) == 0 || (args[0] != "init" && args[0] != "validate" && args[0] != "show") {
+		return opts, fmt.Errorf("expected init, validate or show")
+	}
+	opts.Validate = args[0] == "validate"
+	opts.Show = args[0] == "show"
+	fs := flag.NewFlagSet("config "+args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if args[0] == "init" {
+		fs.BoolVar(&opts.Force, "force", false, "overwrite an existing config file")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return opts, nil
+}
+
+// validateConfig loads the config as pb would and summarizes the enabled
+// tools and custom sessions. Parse errors carry the file and YAML line.
+func validateConfig() (string, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return "", err
+	}
+	var tools, sessions []string
+	for _, tool := range cfg.Tools {
+		if tool.Enabled {
+			tools = append(tools, fmt.Sprintf("%s (%s)", tool.Name, tool.Key))
 		}
-		lines = append(lines, "")
-		lines = append(lines,
-			fmt.Sprintf("%s jump-dir   %s new   %s kill", keyStyle.Render("z"), keyStyle.Render("n"), keyStyle.Render("k")),
-			fmt.Sprintf("%s %s   %s rename", keyStyle.Render("t"), map[bool]string{true: "hide tasks", false: "show tasks"}[m.showTaskDetails], keyStyle.Render("r")),
-		)
-		if m.hasAnyRunningSessions() {
-			lines = append(lines, fmt.Sprintf("%s quit   %s kill-all", keyStyle.Render("d"), keyStyle.Render("^c")))
-		} else {
-			lines = append(lines, fmt.Sprintf("%s quit    %s kill-all", keyStyle.Render("d"), keyStyle.Render("^c")))
+	}
+	for _, sess := range cfg.Sessions {
+		sessions = append(sessions, fmt.Sprintf("%s (%s)", sess.Name, sess.Key))
+	}
+	list := func(items []string) string {
+		if len(items) == 0 {
+			return "none"
 		}
+		return strings.Join(items, ", ")
 	}
+	return fmt.Sprintf("config OK\ntools: %s\nsessions: %s\n", list(tools), list(sessions)), nil
+}
 
-	return strings.Join(capLines(lines, 20), "\n") + "\n"
+// showConfig prints which config file or environment variable set each
+// non-default field, in the order the layers were applied.
+func showConfig(w io.Writer) error {
+	_, sources, err := config.LoadAll(currentDir())
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		fmt.Fprintln(w, "all settings are defaults")
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "FIELD\tSOURCE")
+	for _, src := range sources {
+		fmt.Fprintf(tw, "%s\t%s\n", src.Field, src.Source)
+	}
+	return tw.Flush()
 }
 
-func (m model) detailedRows(tool string, names []string) []string {
-	var rows []string
-	keyStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF"))
-	activeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Bold(true)
-	idleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#999999"))
-	repoLabelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
-	repoNameStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#7D56F4")).Bold(true)
-	yoloStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#FF8A00")).Bold(true)
-	taskStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF"))
-	taskDetailStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#AAAAAA"))
-	key := m.keyForTool(tool)
-	if len(names) == 0 {
-		if !m.toolEnabled(tool) || key == "" {
-			return nil
+// initConfigFile writes the default config to the config path and returns
+// the path.
+func initConfigFile(force bool) (string, error) {
+	path, err := config.ConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return path, config.WriteDefault(path, force)
+}
+
+// sessionsOptions controls `pb sessions` output.
+type sessionsOptions struct {
+	Raw bool // Pass through to plain `tmux list-sessions`
+}
+
+func parseSessionsArgs(args []string) (sessionsOptions, error) {
+	var opts sessionsOptions
+	fs := flag.NewFlagSet("sessions", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.Raw, "raw", false, "print raw tmux list-sessions output")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return opts, nil
+}
+
+// observeOptions selects the foreign tmux server for `pb --observe`.
+type observeOptions struct {
+	SocketName string // -L
+	SocketPath string // -S
+}
+
+func parseObserveArgs(args []string) (observeOptions, error) {
+	var opts observeOptions
+	fs := flag.NewFlagSet("observe", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.SocketName, "L", "", "tmux socket name")
+	fs.StringVar(&opts.SocketPath, "S", "", "tmux socket path")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if (opts.SocketName == "") == (opts.SocketPath == "") {
+		return opts, fmt.Errorf("exactly one of -L or -S is required")
+	}
+	return opts, nil
+}
+
+// newSessionOptions describes a `pb new` launch.
+type newSessionOptions struct {
+	Tool  string
+	Fresh bool // Strip resume/continue flags so the tool starts without prior context
+}
+
+func parseNewArgs(cfg *config.Config, args []string) (newSessionOptions, error) {
+	var opts newSessionOptions
+	fs := flag.NewFlagSet("new", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.Fresh, "fresh", false, "start without previous context")
+
+	// Allow the tool before or after flags (`pb new claude --fresh`).
+	var positional []string
+	for len(args) > 0 {
+		if err := fs.Parse(args); err != nil {
+			return opts, err
+		}
+		args = fs.Args()
+		if len(args) > 0 {
+			positional = append(positional, args[0])
+			args = args[1:]
 		}
-		repoText := repoLabelStyle.Render("repo:") + repoNameStyle.Render("-")
-		rows = append(rows, fmt.Sprintf("%s %s %s %s",
-			keyStyle.Render("("+key+")"),
-			tool,
-			repoText,
-			idleStyle.Render("○ not running"),
-		))
-		return rows
 	}
-	for i, name := range names {
-		join := key
-		if len(names) > 1 {
-			letter := alphaKey(i)
-			if letter == "" {
+	if len(positional) != 1 {
+		return opts, fmt.Errorf("expected exactly one tool")
+	}
+	opts.Tool = normalizeToolName(cfg, positional[0])
+	if opts.Tool == "" {
+		return opts, fmt.Errorf("unknown tool %q", positional[0])
+	}
+	return opts, nil
+}
+
+// labelOptions sets or clears a session's priority label.
+type labelOptions struct {
+	Session  string
+	Priority int // 0 clears the label
+}
+
+func parseLabelArgs(args []string) (labelOptions, error) {
+	var opts labelOptions
+	if len(args) != 2 {
+		return opts, fmt.Errorf("expected a session and a priority")
+	}
+	opts.Session = args[0]
+	if args[1] == "none" {
+		return opts, nil
+	}
+	opts.Priority = parsePriority(args[1])
+	if opts.Priority == 0 {
+		return opts, fmt.Errorf("priority must be %d-%d or none, got %q", minPriority, maxPriority, args[1])
+	}
+	return opts, nil
+}
+
+// applyLabel stores opts.Priority as @pb_priority on the session.
+func applyLabel(opts labelOptions) error {
+	if !sessionExistsFn(opts.Session) {
+		return fmt.Errorf("session %q not found", opts.Session)
+	}
+	value := ""
+	if opts.Priority > 0 {
+		value = strconv.Itoa(opts.Priority)
+	}
+	return setSessionOptionFn(opts.Session, "@pb_priority", value)
+}
+
+type attachOptions struct {
+	Last    bool
+	Session string // Attach this session by name instead of the last one
+}
+
+func parseAttachArgs(args []string) (attachOptions, error) {
+	var opts attachOptions
+	fs := flag.NewFlagSet("attach", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.Last, "last", false, "reattach to the most recently attached session")
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 1 {
+		return opts, fmt.Errorf("unexpected argument %q", fs.Arg(1))
+	}
+	opts.Session = strings.TrimSpace(fs.Arg(0))
+	switch {
+	case opts.Last && fs.NArg() > 0:
+		return opts, fmt.Errorf("unexpected argument %q with --last", fs.Arg(0))
+	case !opts.Last && opts.Session == "":
+		return opts, fmt.Errorf("expected a session name or --last")
+	}
+	return opts, nil
+}
+
+// attachTarget resolves the session `pb attach` should attach to, with an
+// error naming the running sessions when the requested one does not exist.
+func attachTarget(opts attachOptions) (string, error) {
+	if opts.Last {
+		return lastAttachedSession()
+	}
+	if sessionExistsFn(opts.Session) {
+		return opts.Session, nil
+	}
+	running := listSessionsFn()
+	if len(running) == 0 {
+		return "", fmt.Errorf("no session named %q (no sessions are running)", opts.Session)
+	}
+	sort.Strings(running)
+	return "", fmt.Errorf("no session named %q (running: %s)", opts.Session, strings.Join(running, ", "))
+}
+
+// lastAttachedSession returns the session recorded by the last attach, or an
+// error if none was recorded or it no longer exists.
+func lastAttachedSession() (string, error) {
+	name := getLastAttachedFn()
+	if name == "" {
+		return "", fmt.Errorf("no session has been attached yet")
+	}
+	if !sessionExistsFn(name) {
+		return "", fmt.Errorf("last attached session %q is gone", name)
+	}
+	return name, nil
+}
+
+// runAttach attaches straight to a session without the home screen,
+// recording the attach like the TUI does.
+func runAttach(opts attachOptions) {
+	name, err := attachTarget(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if !opts.Last {
+		_ = setLastAttachedFn(name)
+	}
+	if err := recordAttach(name); err != nil {
+		log.Debug("attach count %s: %v", name, err)
+	}
+	recordSessionSeen(name)
+	if err := tmux.AttachSession(name); err != nil {
+		fmt.Fprintf(os.Stderr, "Attach error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runNewSession creates a new tool session in the current directory and
+// attaches to it, mirroring the `n` flow in the TUI.
+func runNewSession(opts newSessionOptions) {
+	m := initialModel()
+	m.refreshBindings()
+	if !m.toolEnabled(opts.Tool) {
+		fmt.Fprintf(os.Stderr, "%s is disabled in config\n", opts.Tool)
+		os.Exit(1)
+	}
+	if opts.Tool != scratchTool && !m.reuseSessions() && m.toolAlreadyRunningInDir(opts.Tool, m.currentDir()) {
+		fmt.Fprintf(os.Stderr, "%s already running in this directory\n", opts.Tool)
+		os.Exit(1)
+	}
+	m.newToolFresh = opts.Fresh
+	m, _ = m.createAndAttachTool(opts.Tool)
+	if !m.shouldAttach || m.sessionToAttach == "" {
+		fmt.Fprintf(os.Stderr, "%s\n", m.homeNotice)
+		os.Exit(1)
+	}
+	_ = setLastAttachedFn(m.sessionToAttach)
+	if err := tmux.AttachSession(m.sessionToAttach); err != nil {
+		fmt.Fprintf(os.Stderr, "Attach error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printToolTasksForSocket(w io.Writer, cfg *config.Config, opts taskListOptions) bool {
+	names := listSessionsFn()
+	sort.Strings(names)
+
+	listTasks := sessionUserTasksFn
+	if opts.All {
+		listTasks = sessionTasksFn
+	}
+
+	seen := false
+	var entries []taskListEntry
+	for _, name := range names {
+		if opts.Session != "" {
+			if name != opts.Session {
 				continue
 			}
-			join = key + " " + letter
-		}
-		status := ""
-		if sess, ok := m.sessions[name]; ok && sess.ActivityKnown() {
-			status = idleStyle.Render("○ idle")
-			if sess.IsActive() {
-				status = activeStyle.Render("● active")
+		} else {
+			tool := toolFromSessionName(cfg, name)
+			if tool != "claude" && tool != "codex" && tool != "cursor" {
+				continue
 			}
 		}
-		repo := "-"
-		if binding, ok := m.bindings[name]; ok {
-			repo = repoFromCwd(binding.Cwd)
+		seen = true
+		tasks, err := listTasks(name)
+		if opts.JSON {
+			entries = append(entries, newTaskListEntry(name, tasks, err))
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(w, "%s: error reading tasks: %v\n", name, err)
+			continue
 		}
-		repoText := repoLabelStyle.Render("repo:") + repoNameStyle.Render(repo)
-		rowParts := []string{keyStyle.Render("(" + join + ")"), name, repoText}
-		if binding, ok := m.bindings[name]; ok && binding.Yolo {
-			rowParts = append(rowParts, yoloStyle.Render("(yolo)"))
+		fmt.Fprintf(w, "%s: %d task process(es)\n", name, len(tasks))
+		if len(tasks) == 0 {
+			fmt.Fprintln(w, "  (none)")
+			continue
 		}
-		if !m.showTaskDetails {
-			if n := m.taskCounts[name]; n > 0 {
-				rowParts = append(rowParts, taskStyle.Render(fmt.Sprintf("tasks:%d", n)))
-			}
+		limit := len(tasks)
+		if limit > maxTasksShownPerAgent {
+			limit = maxTasksShownPerAgent
 		}
-		if status != "" {
-			rowParts = append(rowParts, status)
+		for _, task := range tasks[:limit] {
+			fmt.Fprintf(w, "  pid=%d ppid=%d state=%s cmd=%s\n", task.PID, task.PPID, task.State, task.Command)
 		}
-		rows = append(rows, strings.Join(rowParts, " "))
-		if m.showTaskDetails {
-			for _, cmd := range m.taskCommands[name] {
-				rows = append(rows, taskDetailStyle.Render("  task: "+cmd))
-			}
+		if len(tasks) > limit {
+			fmt.Fprintf(w, "  +%d more\n", len(tasks)-limit)
 		}
 	}
-	return rows
+	if opts.JSON && seen {
+		writeTaskListJSON(w, entries)
+	}
+	return seen
 }
 
-func (m model) summaryRow(tool string, names []string) string {
-	active := 0
-	taskTotal := 0
-	for _, name := range names {
-		if sess, ok := m.sessions[name]; ok && sess.IsActive() {
-			active++
-		}
-		taskTotal += m.taskCounts[name]
-	}
-	metaStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
-	activeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Bold(true)
-	parts := []string{
-		tool,
-		fmt.Sprintf("%d", len(names)),
-		activeStyle.Render(fmt.Sprintf("active:%d", active)),
-		metaStyle.Render(fmt.Sprintf("idle:%d", len(names)-active)),
+// taskListEntry is one session in `pb tasks --json` output.
+type taskListEntry struct {
+	Session string         `json:"session"`
+	Tasks   []taskJSONItem `json:"tasks"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type taskJSONItem struct {
+	PID     int    `json:"pid"`
+	PPID    int    `json:"ppid"`
+	State   string `json:"state"`
+	Command string `json:"command"`
+}
+
+func newTaskListEntry(name string, tasks []tmux.Task, err error) taskListEntry {
+	entry := taskListEntry{Session: name, Tasks: []taskJSONItem{}}
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
 	}
-	if taskTotal > 0 {
-		parts = append(parts, metaStyle.Render(fmt.Sprintf("tasks:%d", taskTotal)))
+	for _, task := range tasks {
+		entry.Tasks = append(entry.Tasks, taskJSONItem{PID: task.PID, PPID: task.PPID, State: task.State, Command: task.Command})
 	}
-	return strings.Join(parts, " ")
+	return entry
 }
 
-func (m model) hasAnyRunningSessions() bool {
-	for _, sess := range m.sessions {
-		if sess != nil && sess.IsRunning() {
-			return true
-		}
+// writeTaskListJSON prints entries as an indented JSON array; nil prints [].
+func writeTaskListJSON(w io.Writer, entries []taskListEntry) {
+	if entries == nil {
+		entries = []taskListEntry{}
 	}
-	return false
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(entries)
 }
 
-func capLines(lines []string, max int) []string {
-	if len(lines) <= max {
-		return lines
+// parseListArgs accepts `pb list` options. Output is always JSON; --json
+// is allowed so scripts can say so.
+func parseListArgs(args []string) (listOptions, error) {
+	opts := listOptions{}
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.BoolVar(&opts.JSON, "json", true, "print sessions as JSON")
+	if err := fs.Parse(args); err != nil {
+		return listOptions{}, err
 	}
-	if max <= 0 {
-		return []string{}
+	if fs.NArg() > 0 {
+		return listOptions{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
 	}
-	out := append([]string{}, lines[:max]...)
-	out[max-1] = "..."
-	return out
+	return opts, nil
 }
 
-func (m model) viewAttached() string {
-	helpStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("#888888")).
-		Italic(true)
-
-	help := helpStyle.Render("[Press Ctrl+D to detach]")
+// listOptions are the flags of `pb list`. JSON is the default; --json=false
+// prints a table instead.
+type listOptions struct {
+	JSON bool
+}
 
-	// This view is not actually used - attach happens outside Bubble Tea
-	return fmt.Sprintf("%s\n\n[Attached to Claude]\n", help)
+// sessionListItem is one session in `pb list` output.
+type sessionListItem struct {
+	Name      string `json:"name"`
+	Tool      string `json:"tool"`
+	Cwd       string `json:"cwd"`
+	Yolo      bool   `json:"yolo"`
+	Running   bool   `json:"running"`
+	TaskCount int    `json:"task_count"`
 }
 
-func main() {
-	// Handle subcommands
-	if len(os.Args) > 1 {
-		handleSubcommand(os.Args[1])
-		return
+// cleanDeadSessions kills sessions that no longer run anything: every pane
+// exited (tmux keeps them when remain-on-exit is set) or the pane process
+// is a zombie. It prints each killed session and a summary, and returns how
+// many were killed.
+func cleanDeadSessions(w io.Writer, cfg *config.Config) (int, error) {
+	names := listSessionsFn()
+	sort.Strings(names)
+	zombies, err := zombieSessionsFn(names)
+	if err != nil {
+		// Dead panes can still be found without ps.
+		log.Debug("zombie check: %v", err)
 	}
-
-	m := initialModel()
-
-	// Note: We don't kill tmux sessions on exit - they persist in background
-	// User can manually kill with: tmux -L pocketbot kill-server
-
-	// Main loop: run UI, attach when requested, repeat
-	for {
-		m.shouldAttach = false
-		m.sessionToAttach = ""
-		m.viewState = viewHome
-
-		// Run Bubble Tea UI with alternate screen buffer
-		p := tea.NewProgram(m, tea.WithAltScreen())
-		finalModel, err := p.Run()
-		if err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+	killed := 0
+	var errs []string
+	for _, name := range names {
+		if sessionAliveFn(name) && !zombies[name] {
+			continue
 		}
-
-		// Get the final model state
-		m = finalModel.(model)
-
-		// Check if we should attach
-		if !m.shouldAttach || m.sessionToAttach == "" {
-			// User quit normally
-			break
+		cwd, tool := getSessionCwdFn(name), normalizeToolName(cfg, getSessionToolFn(name))
+		if err := killSessionFn(name, tmux.KillOptions{}); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
 		}
+		removeDirLock(cwd, tool, name)
+		fmt.Fprintf(w, "killed %s\n", name)
+		killed++
+	}
+	fmt.Fprintf(w, "cleaned %d of %d sessions\n", killed, len(names))
+	if len(errs) > 0 {
+		return killed, fmt.Errorf("failed to kill %s", strings.Join(errs, "; "))
+	}
+	return killed, nil
+}
 
-		// Attach to requested tmux session
-		tmuxSess, exists := m.sessions[m.sessionToAttach]
-		if !exists || tmuxSess == nil {
-			tmuxSess = tmux.NewSession(m.sessionToAttach, "")
-			m.sessions[m.sessionToAttach] = tmuxSess
+// sessionListItems lists every tmux session, sorted by name, then the
+// configured custom sessions that are not running. Each session's options
+// come from one batched read.
+func sessionListItems() []sessionListItem {
+	cfg, err := loadConfigFn()
+	if err != nil {
+		cfg = nil
+	}
+	names := slices.Sorted(slices.Values(listSessionsFn()))
+	items := []sessionListItem{}
+	for _, name := range names {
+		opts, err := getSessionPBOptionsFn(name)
+		if err != nil {
+			opts = nil
 		}
-		if !tmuxSess.IsRunning() {
-			fmt.Fprintf(os.Stderr, "Session %q is not running\n", m.sessionToAttach)
-			continue
+		item := sessionListItem{
+			Name:    name,
+			Tool:    listedTool(cfg, name, opts["@pb_tool"]),
+			Cwd:     opts["@pb_cwd"],
+			Yolo:    tmux.ParseYolo(opts["@pb_yolo"]),
+			Running: sessionAliveFn(name),
 		}
-
-		// Note: No delay needed. The original bug was an invalid claude flag,
-		// not a race condition. See TestClaudeCommandFlag for regression test.
-
-		// tmux attach - returns when user detaches (prefix+d)
-		if err := tmuxSess.Attach(); err != nil {
-			fmt.Fprintf(os.Stderr, "Attach error: %v\n", err)
-			// Check if session died
-			if !tmuxSess.IsRunning() {
-				fmt.Fprintf(os.Stderr, "Session exited. Check: tmux -L pocketbot list-sessions\n")
+		if tasks, err := sessionUserTasksFn(name); err == nil {
+			item.TaskCount = len(tasks)
+		}
+		items = append(items, item)
+	}
+	if cfg != nil {
+		for _, sess := range cfg.Sessions {
+			if !slices.Contains(names, sess.Name) {
+				items = append(items, sessionListItem{Name: sess.Name, Tool: listedTool(cfg, sess.Name, "")})
 			}
 		}
+	}
+	return items
+}
 
-		// Always return to home screen after detach
+// listedTool is the tool `pb list` reports: the stored @pb_tool, else the
+// one the session name implies.
+func listedTool(cfg *config.Config, name, stored string) string {
+	if tool := normalizeToolName(cfg, stored); tool != "" {
+		return tool
 	}
+	return toolFromSessionName(cfg, name)
 }
 
-func handleSubcommand(cmd string) {
-	switch cmd {
-	case "test":
-		runCommand("go", "test", "./...")
-	case "build":
-		runCommand("go", "build", "-o", "pb", "./cmd/pb")
-	case "install":
-		runCommand("go", "install", "./cmd/pb")
-	case "run":
-		runCommand("go", "run", "./cmd/pb")
-	case "demo":
-		// Run a simple demo session for testing
-		runDemoSession()
-	case "sessions":
-		// Show sessions for current nesting level
-		socket := "pocketbot"
-		if level := os.Getenv("PB_LEVEL"); level != "" {
-			socket = "pocketbot-" + level
+// listSessionsJSON prints sessionListItems as a JSON array.
+func listSessionsJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sessionListItems())
+}
+
+// listSessionsTable prints sessionListItems as a table, for
+// `pb list --json=false`.
+func listSessionsTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTOOL\tCWD\tYOLO\tRUNNING\tTASKS")
+	for _, item := range sessionListItems() {
+		tool, cwd := item.Tool, item.Cwd
+		if tool == "" {
+			tool = "-"
 		}
-		runCommand("tmux", "-L", socket, "list-sessions")
-	case "tasks":
-		printToolTasks()
-	case "kill-all":
-		// Kill sessions for current nesting level
-		socket := "pocketbot"
-		if level := os.Getenv("PB_LEVEL"); level != "" {
-			socket = "pocketbot-" + level
+		if cwd == "" {
+			cwd = "-"
 		}
-		runCommand("tmux", "-L", socket, "kill-server")
-	case "help", "-h", "--help":
-		printHelp()
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", cmd)
-		fmt.Fprintf(os.Stderr, "Run 'pb help' for usage\n")
-		os.Exit(1)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%t\t%t\t%d\n", item.Name, tool, cwd, item.Yolo, item.Running, item.TaskCount)
 	}
+	return tw.Flush()
 }
 
-func printToolTasksForSocket(w io.Writer) bool {
-	names := listSessionsFn()
-	sort.Strings(names)
+// printSessionsTable writes one row per running session with its tool,
+// repo, uptime, activity and user task count.
+func printSessionsTable(w io.Writer, cfg *config.Config, now time.Time) error {
+	infos, err := listDetailedFn()
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		fmt.Fprintln(w, "No sessions are running.")
+		return nil
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
 
-	seen := false
-	for _, name := range names {
-		tool := toolFromSessionName(name)
-		if tool != "claude" && tool != "codex" && tool != "cursor" {
-			continue
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tTOOL\tREPO\tUPTIME\tSTATUS\tTASKS")
+	for _, info := range infos {
+		tool := normalizeToolName(cfg, getSessionToolFn(info.Name))
+		if tool == "" {
+			tool = toolFromSessionName(cfg, info.Name)
 		}
-		seen = true
-		tasks, err := sessionUserTasksFn(name)
-		if err != nil {
-			fmt.Fprintf(w, "%s: error reading tasks: %v\n", name, err)
-			continue
+		if tool == "" {
+			tool = "-"
 		}
-		fmt.Fprintf(w, "%s: %d task process(es)\n", name, len(tasks))
-		if len(tasks) == 0 {
-			fmt.Fprintln(w, "  (none)")
-			continue
+		status := "idle"
+		if now.Sub(info.LastActivity) < tmux.IdleTimeout {
+			status = "active"
 		}
-		limit := len(tasks)
-		if limit > maxTasksShownPerAgent {
-			limit = maxTasksShownPerAgent
+		if info.Attached > 0 {
+			status += ", attached"
 		}
-		for _, task := range tasks[:limit] {
-			fmt.Fprintf(w, "  pid=%d ppid=%d state=%s cmd=%s\n", task.PID, task.PPID, task.State, task.Command)
+		tasks := "-"
+		if userTasks, err := sessionUserTasksFn(info.Name); err == nil {
+			tasks = strconv.Itoa(len(userTasks))
 		}
-		if len(tasks) > limit {
-			fmt.Fprintf(w, "  +%d more\n", len(tasks)-limit)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", info.Name, tool, repoFromCwd(getSessionCwdFn(info.Name)), formatUptime(now.Sub(info.Created)), status, tasks)
+	}
+	return tw.Flush()
+}
+
+// formatUptime renders a duration with its two largest units, e.g. "3d4h",
+// "2h05m", "12m" or "40s".
+func formatUptime(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%dh", int(d/(24*time.Hour)), int(d%(24*time.Hour)/time.Hour))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%02dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	default:
+		return fmt.Sprintf("%ds", int(d/time.Second))
+	}
+}
+
+// applyTaskPatterns hands the user's task-patterns.yaml to the tmux task
+// filter.
+func applyTaskPatterns(cfg *config.Config) {
+	applyTaskFilterPreset(cfg, 0)
+}
+
+// applyTaskFilterPreset is applyTaskPatterns plus the patterns of task
+// filter preset active (1-based, as in model.activePreset; 0 adds none).
+func applyTaskFilterPreset(cfg *config.Config, active int) {
+	tmux.SetTaskFilterOptions(taskFilterOptions(cfg, active))
+}
+
+// taskFilterOptions returns the task patterns with preset active applied;
+// see applyTaskFilterPreset.
+func taskFilterOptions(cfg *config.Config, active int) tmux.FilterOptions {
+	opts := tmux.FilterOptions{
+		Noise:     cfg.TaskPatterns.Noise,
+		Highlight: cfg.TaskPatterns.Highlight,
+	}
+	if active > 0 && active <= len(cfg.UI.TaskFilterPresets) {
+		preset := cfg.UI.TaskFilterPresets[active-1]
+		if preset.Replace {
+			opts.Noise = nil
 		}
+		opts.Noise = append(append([]string{}, opts.Noise...), preset.NoisePatterns...)
+		opts.Highlight = append(append([]string{}, opts.Highlight...), preset.HighlightPatterns...)
 	}
-	return seen
+	return opts
+}
+
+// printTaskPatterns lists the built-in noise filter followed by the user's
+// patterns from task-patterns.yaml.
+func printTaskPatterns(w io.Writer, patterns config.TaskPatterns) {
+	section := func(title string, list []string) {
+		fmt.Fprintln(w, title)
+		if len(list) == 0 {
+			fmt.Fprintln(w, "  (none)")
+		}
+		for _, p := range list {
+			fmt.Fprintf(w, "  %s\n", p)
+		}
+	}
+	path, _ := config.TaskPatternsPath()
+	section("Built-in noise:", tmux.BuiltinNoisePatterns())
+	section(fmt.Sprintf("User noise (%s):", path), patterns.Noise)
+	section("User highlight:", patterns.Highlight)
 }
 
-func printToolTasks() {
-	if printToolTasksForSocket(os.Stdout) {
+func printToolTasks(cfg *config.Config, opts taskListOptions) {
+	if printToolTasksForSocket(os.Stdout, cfg, opts) {
 		return
 	}
 
@@ -2048,16 +6594,120 @@ func printToolTasks() {
 	level := os.Getenv("PB_LEVEL")
 	if level != "" {
 		_ = os.Unsetenv("PB_LEVEL")
-		found := printToolTasksForSocket(os.Stdout)
+		found := printToolTasksForSocket(os.Stdout, cfg, opts)
 		_ = os.Setenv("PB_LEVEL", level)
 		if found {
 			return
 		}
 	}
 
+	if opts.Session != "" {
+		fmt.Fprintf(os.Stderr, "Session %q not found.\n", opts.Session)
+		os.Exit(1)
+	}
+
+	if opts.JSON {
+		writeTaskListJSON(os.Stdout, nil)
+		return
+	}
 	fmt.Println("No claude/codex/cursor sessions are running.")
 }
 
+// printDoctor writes a diagnostic table and reports whether required checks
+// passed. A stopped tmux server is not a failure; it starts on first use.
+func printDoctor(w io.Writer) bool {
+	ok := true
+	row := func(check, status, detail string) {
+		fmt.Fprintf(w, "%-8s %-8s %s\n", check, status, detail)
+	}
+
+	if path, err := exec.LookPath("tmux"); err != nil {
+		row("tmux", "missing", "install with: brew install tmux")
+		ok = false
+	} else {
+		row("tmux", "ok", path)
+	}
+
+	if info, err := getServerInfoFn(); err != nil {
+		row("server", "stopped", fmt.Sprintf("socket=%s (starts on first session)", tmux.SocketName()))
+	} else {
+		row("server", "ok", info.String())
+	}
+
+	path, _ := config.ConfigPath()
+	if _, err := loadConfig(); err != nil {
+		row("config", "error", err.Error())
+		ok = false
+	} else if _, statErr := os.Stat(path); statErr != nil {
+		row("config", "default", path+" (not found, using defaults)")
+	} else {
+		row("config", "ok", path)
+	}
+
+	if fasderAvailable() {
+		row("fasder", "ok", "z directory jump enabled")
+	} else {
+		row("fasder", "missing", "optional; install to use z")
+	}
+	return ok
+}
+
+// printConfigTable writes the resolved session list as a table, including
+// disabled tools, and marks keys shared by more than one enabled session.
+// It returns false when the config fails validation.
+func printConfigTable(w io.Writer, cfg *config.Config) bool {
+	type configRow struct {
+		session config.SessionConfig
+		enabled bool
+	}
+	var rows []configRow
+	for _, tool := range cfg.Tools {
+		rows = append(rows, configRow{config.SessionConfig{Name: tool.Name, Command: tool.Command, Key: tool.Key}, tool.Enabled})
+	}
+	for _, sess := range cfg.Sessions {
+		rows = append(rows, configRow{sess, true})
+	}
+
+	keyCounts := make(map[string]int)
+	for _, r := range rows {
+		if r.enabled {
+			keyCounts[r.session.Key]++
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tCOMMAND\tKEY\tENABLED")
+	for _, r := range rows {
+		key := r.session.Key
+		if r.enabled && keyCounts[key] > 1 {
+			key += " [conflict]"
+		}
+		enabled := "yes"
+		if !r.enabled {
+			enabled = "[disabled]"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.session.Name, r.session.Command, key, enabled)
+	}
+	tw.Flush()
+
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintf(w, "\nError: %v\n", err)
+		return false
+	}
+	return true
+}
+
+func runTestConfig() {
+	cfg, err := loadConfigUnvalidated()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !printConfigTable(os.Stdout, cfg) {
+		os.Exit(1)
+	}
+}
+
 func runCommand(name string, args ...string) {
 	cmd := exec.Command(name, args...)
 	cmd.Stdin = os.Stdin
@@ -2100,8 +6750,42 @@ Usage:
   pb install      Install to $GOPATH/bin
   pb run          Run development version
   pb demo         Run a simple demo session (for testing)
-  pb sessions     List active tmux sessions
+  pb sessions     List sessions with tool, repo, uptime, status, and task count
+                  --raw  print plain tmux list-sessions output
   pb tasks        List descendant processes for running claude/codex/cursor sessions (spike)
+                  --session <name>  only this session   --all  include helper processes
+                  --json  print tasks as a JSON array
+                  --list-patterns  print built-in and task-patterns.yaml filter patterns
+  pb new <tool>   Create and attach a new claude/codex/cursor/scratch (or tools:) session
+                  --fresh  start without resuming previous context
+  pb label <session> <1-9|none>
+                  Set a priority label; lower numbers list first in the home view and pickers
+  pb list [--json[=false]]
+                  Print sessions as JSON (name, tool, cwd, yolo, running, task_count);
+                  --json=false prints the same fields as a table
+  pb attach <session> | --last
+                  Attach to a session by name, or the most recently attached one
+  pb doctor       Show tmux server, config, and dependency diagnostics
+  pb config init [--force]
+                  Write a default ~/.config/pocketbot/config.yaml (--force replaces one)
+  pb config validate
+                  Check the config; print enabled tools and sessions, or the error
+  pb config show  Print which config file or PB_* variable set each non-default field
+  pb --test-config
+                  Print resolved sessions (NAME COMMAND KEY ENABLED); exit 1 if invalid
+  pb --observe -L <socket> | -S <path>
+                  Monitor an existing tmux server read-only (no create/kill/rename)
+  pb --print-socket
+                  Print the tmux socket name (or path under PB_TMUX_SOCKET_DIR) and exit
+  pb --log-file <path> [command]
+                  Append timestamped debug logs to path (the TUI keeps stderr clean)
+  pb --no-alt-screen [command]
+                  Render the TUI inline instead of on the alternate screen
+  pb --readonly [command]
+                  Refuse every tmux write (keys and new/label/clean/kill-all/detach-all)
+                  (--log-file, --no-alt-screen and --readonly combine in any order)
+  pb detach-all   Detach all clients (they return to the pb home screen)
+  pb clean        Kill sessions whose panes have exited or whose process is a zombie
   pb kill-all     Kill all sessions
   pb help         Show this help
 
@@ -2109,11 +6793,28 @@ Interactive mode keybindings:
   c               Attach claude (picker if multiple, create if none)
   x               Attach codex (picker if multiple, create if none)
   u               Attach cursor (picker if multiple, create if none)
+  s               Attach a scratch shell ($SHELL; n then s starts another)
   z               Jump directory with fasder query
-  n               New instance (then a for auto or y for yolo, then c/x/u)
-  k               Kill one instance (then c/x/u and picker if needed)
+  n               New instance (then a for auto or y for yolo, then c/x/u, or s for a shell)
+  k               Kill one instance (then c/x/u/s and picker if needed)
   r               Rename one instance (same flow as k)
+  m               Add or edit a one-line memo on an instance (same flow as k)
+  N               Add or edit a multi-line note (alt+enter for a new line; shown in the row tooltip)
+  S               Send: type one line into one session without attaching (same flow as k)
+  B               Broadcast: type one line into every session of a tool (then c/x/u/s)
+  /               Search running sessions by name (enter attaches first match)
+                  In a picker: type to filter (enter keeps it, esc clears it)
+  Tab             In a picker: toggle order between most active first and by name
+  :               Command palette: run any action by (fuzzy) name
+  ?               List all actions
+  Up/Down         Focus a session row; its command, cwd, uptime, and tasks show after a moment
+                  Past the last row on screen, moves to the next page of sessions
   t               Toggle per-session task lines on home screen
+  T               Expand every session with its task lines, even past 10 sessions (independent of t)
+  i               Expand/collapse sessions hidden by hide_idle_after
+  Y               Toggle global yolo: every new session skips all permissions
+  W               Dismiss the config error shown under the dir line
+  F               Cycle task filter presets (ui.task_filter_presets)
   Esc             Go back/cancel in menus
   Ctrl+D          Detach from session (back to pb)
   d               Quit pb (sessions keep running)
@@ -2,18 +2,45 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
 	"github.com/zakandrewking/pocketbot/internal/config"
 	"github.com/zakandrewking/pocketbot/internal/tmux"
 )
 
+// TestMain runs the tests from a scratch directory so sessions created by
+// integration tests leave their .pocketbot launch locks there rather than
+// in the source tree.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "pb-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func requireTmuxSessionCreation(t *testing.T) {
 	t.Helper()
 	name := fmt.Sprintf("test-probe-%d", time.Now().UnixNano())
@@ -101,7 +128,7 @@ func TestNEntersNewMode(t *testing.T) {
 
 func TestNModeHidesDisabledTool(t *testing.T) {
 	cfg := config.DefaultConfig()
-	cfg.Cursor.Enabled = false
+	cfg.Tool("cursor").Enabled = false
 	m := model{
 		config:      cfg,
 		sessions:    map[string]*tmux.Session{},
@@ -121,7 +148,7 @@ func TestNModeHidesDisabledTool(t *testing.T) {
 
 func TestDisabledToolHotkeyIgnoredInHome(t *testing.T) {
 	cfg := config.DefaultConfig()
-	cfg.Cursor.Enabled = false
+	cfg.Tool("cursor").Enabled = false
 	m := model{
 		config:      cfg,
 		sessions:    map[string]*tmux.Session{},
@@ -143,7 +170,7 @@ func TestDisabledToolHotkeyIgnoredInHome(t *testing.T) {
 
 func TestDisabledToolHotkeyIgnoredInNewMode(t *testing.T) {
 	cfg := config.DefaultConfig()
-	cfg.Cursor.Enabled = false
+	cfg.Tool("cursor").Enabled = false
 	m := model{
 		config:      cfg,
 		sessions:    map[string]*tmux.Session{},
@@ -165,7 +192,7 @@ func TestDisabledToolHotkeyIgnoredInNewMode(t *testing.T) {
 
 func TestRemappedCursorKeyShownInNewMode(t *testing.T) {
 	cfg := config.DefaultConfig()
-	cfg.Cursor.Key = "r"
+	cfg.Tool("cursor").Key = "r"
 	m := model{
 		config:      cfg,
 		sessions:    map[string]*tmux.Session{},
@@ -205,7 +232,7 @@ func TestRemappedCursorKeyHandledInNewMode(t *testing.T) {
 	defer tmux.KillSession(sessionName)
 
 	cfg := config.DefaultConfig()
-	cfg.Cursor.Key = "r"
+	cfg.Tool("cursor").Key = "r"
 	m := model{
 		config: cfg,
 		sessions: map[string]*tmux.Session{
@@ -286,7 +313,7 @@ func TestKEntersKillMode(t *testing.T) {
 	cfg := config.DefaultConfig()
 	m := model{
 		config:      cfg,
-		sessions:    map[string]*tmux.Session{"codex": tmux.NewSession("codex", cfg.Codex.Command)},
+		sessions:    map[string]*tmux.Session{"codex": tmux.NewSession("codex", cfg.Tool("codex").Command)},
 		bindings:    map[string]commandBinding{},
 		windowWidth: 80,
 		viewState:   viewHome,
@@ -308,7 +335,7 @@ func TestKEntersKillMode(t *testing.T) {
 	if m.mode != modeKillTool {
 		t.Fatal("k should enter kill-tool mode")
 	}
-	if !contains(m.View(), "kill codex") {
+	if !contains(m.View(), "kill 🟢 codex") {
 		t.Fatal("expected kill-tool picker to include running target")
 	}
 }
@@ -317,7 +344,7 @@ func TestREntersRenameMode(t *testing.T) {
 	cfg := config.DefaultConfig()
 	m := model{
 		config:      cfg,
-		sessions:    map[string]*tmux.Session{"codex": tmux.NewSession("codex", cfg.Codex.Command)},
+		sessions:    map[string]*tmux.Session{"codex": tmux.NewSession("codex", cfg.Tool("codex").Command)},
 		bindings:    map[string]commandBinding{},
 		windowWidth: 80,
 		viewState:   viewHome,
@@ -376,8 +403,8 @@ func TestEscCancelsAttachPickerMode(t *testing.T) {
 		viewState:   viewHome,
 		mode:        modePickAttach,
 		pickerTool:  "claude",
-		pickerTargets: map[string]string{
-			"a": "claude-1",
+		pickerEntries: []pickerEntry{
+			{key: "a", session: "claude-1"},
 		},
 	}
 
@@ -493,6 +520,7 @@ func TestZShowsHelpfulNoticeWhenFasderMissing(t *testing.T) {
 }
 
 func TestDirJumpEnterChangesDirectory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
 	var changedTo string
 	m := model{
 		config:       config.DefaultConfig(),
@@ -534,6 +562,223 @@ func TestDirJumpEnterChangesDirectory(t *testing.T) {
 	}
 }
 
+func TestCreateAndAttachToolRefusesWhenCwdMissing(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			// A session with no recorded cwd must not look like it lives in
+			// the (unknown) current directory.
+			"claude": {SessionName: "claude", Running: true, Tool: "claude"},
+		},
+		viewState: viewHome,
+		mode:      modeNewTool,
+		getwd: func() (string, error) {
+			return "", fmt.Errorf("getwd: %w", os.ErrNotExist)
+		},
+	}
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'c'}})
+	m = updated.(model)
+	if cmd != nil || m.shouldAttach {
+		t.Fatalf("expected no attach or create, got shouldAttach=%v session=%q", m.shouldAttach, m.sessionToAttach)
+	}
+	if m.homeNotice != missingCwdNotice {
+		t.Fatalf("unexpected notice: %q", m.homeNotice)
+	}
+	if m.mode != modeHome {
+		t.Fatalf("expected home mode, got %v", m.mode)
+	}
+	if _, ok := m.sessions["claude-2"]; ok {
+		t.Fatal("expected no session to be created")
+	}
+	view := m.View()
+	if !contains(view, "dir: (missing)") || !contains(view, "press z to jump elsewhere") {
+		t.Fatalf("expected missing-dir header and notice, got: %s", view)
+	}
+}
+
+func TestViewHomeShowsMissingCwdWithoutAction(t *testing.T) {
+	m := model{
+		config:    config.DefaultConfig(),
+		sessions:  map[string]*tmux.Session{},
+		bindings:  map[string]commandBinding{},
+		viewState: viewHome,
+		mode:      modeHome,
+		getwd:     func() (string, error) { return "", os.ErrNotExist },
+	}
+	view := m.View()
+	if !contains(view, "dir: (missing)") || !contains(view, missingCwdNotice) {
+		t.Fatalf("expected missing-dir notice, got: %s", view)
+	}
+}
+
+func TestAutoCwdChangeFollowsSessionCwd(t *testing.T) {
+	originalCwd := getSessionCwdFn
+	defer func() { getSessionCwdFn = originalCwd }()
+	getSessionCwdFn = func(name string) string {
+		if name != "claude" {
+			t.Fatalf("unexpected session: %s", name)
+		}
+		return "/tmp/worktree"
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.UI.FollowSessionCwd = true
+	var changedTo string
+	m := model{
+		config:    cfg,
+		sessions:  map[string]*tmux.Session{},
+		bindings:  map[string]commandBinding{},
+		viewState: viewHome,
+		mode:      modeHome,
+		getwd:     func() (string, error) { return "/tmp/project", nil },
+		chdir: func(path string) error {
+			changedTo = path
+			return nil
+		},
+	}
+
+	m.autoCwdChange("claude")
+	if changedTo != "/tmp/worktree" {
+		t.Fatalf("expected chdir to /tmp/worktree, got %q", changedTo)
+	}
+	if m.homeNotice != "cwd changed to /tmp/worktree" {
+		t.Fatalf("unexpected notice: %q", m.homeNotice)
+	}
+	if view := m.View(); !contains(view, "cwd changed to /tmp/worktree") {
+		t.Fatalf("expected notice on home screen, got: %s", view)
+	}
+}
+
+func TestAutoCwdChangeSkipsWhenDisabledOrSameDir(t *testing.T) {
+	originalCwd := getSessionCwdFn
+	defer func() { getSessionCwdFn = originalCwd }()
+	getSessionCwdFn = func(string) string { return "/tmp/project" }
+
+	chdir := func(path string) error {
+		t.Fatalf("unexpected chdir to %q", path)
+		return nil
+	}
+	getwd := func() (string, error) { return "/tmp/project", nil }
+
+	disabled := model{config: config.DefaultConfig(), chdir: chdir, getwd: func() (string, error) { return "/elsewhere", nil }}
+	disabled.autoCwdChange("claude")
+
+	cfg := config.DefaultConfig()
+	cfg.UI.FollowSessionCwd = true
+	same := model{config: cfg, chdir: chdir, getwd: getwd}
+	same.autoCwdChange("claude")
+	if same.homeNotice != "" {
+		t.Fatalf("expected no notice when cwd is unchanged, got %q", same.homeNotice)
+	}
+}
+
+func TestPushDirHistoryDedupesAndCaps(t *testing.T) {
+	var history []string
+	history = pushDirHistory(history, "proj")
+	history = pushDirHistory(history, "  proj ")
+	history = pushDirHistory(history, "")
+	if strings.Join(history, ",") != "proj" {
+		t.Fatalf("expected blank and repeated queries dropped, got %v", history)
+	}
+	history = pushDirHistory(history, "docs")
+	history = pushDirHistory(history, "proj")
+	if strings.Join(history, ",") != "proj,docs,proj" {
+		t.Fatalf("expected only consecutive repeats dropped, got %v", history)
+	}
+
+	history = nil
+	for i := 0; i < maxDirHistory+5; i++ {
+		history = pushDirHistory(history, fmt.Sprintf("q%d", i))
+	}
+	if len(history) != maxDirHistory || history[0] != "q5" || history[len(history)-1] != fmt.Sprintf("q%d", maxDirHistory+4) {
+		t.Fatalf("expected the newest %d queries, got %v", maxDirHistory, history)
+	}
+}
+
+func TestDirJumpUpCyclesHistory(t *testing.T) {
+	var lookups []string
+	m := model{
+		config:          config.DefaultConfig(),
+		sessions:        map[string]*tmux.Session{},
+		bindings:        map[string]commandBinding{},
+		viewState:       viewHome,
+		mode:            modeDirJump,
+		dirQueryHistory: []string{"old", "new"},
+		lookupDirs: func(query string) ([]string, error) {
+			lookups = append(lookups, query)
+			return []string{"/tmp/" + query}, nil
+		},
+	}
+	press := func(keyType tea.KeyType) {
+		t.Helper()
+		updated, _ := m.Update(tea.KeyMsg{Type: keyType})
+		m = updated.(model)
+	}
+
+	press(tea.KeyUp)
+	if m.dirQuery != "new" || m.dirCursor != 3 {
+		t.Fatalf("expected newest history entry, got %q (cursor %d)", m.dirQuery, m.dirCursor)
+	}
+	press(tea.KeyUp)
+	press(tea.KeyUp)
+	if m.dirQuery != "old" {
+		t.Fatalf("expected up to stop at the oldest entry, got %q", m.dirQuery)
+	}
+	press(tea.KeyDown)
+	if m.dirQuery != "new" {
+		t.Fatalf("expected down to step to a newer entry, got %q", m.dirQuery)
+	}
+	press(tea.KeyDown)
+	if m.dirQuery != "" || m.dirHistoryPos != 0 {
+		t.Fatalf("expected down past history to clear the query, got %q (pos %d)", m.dirQuery, m.dirHistoryPos)
+	}
+	if strings.Join(lookups, ",") != "new,old,old,new," {
+		t.Fatalf("expected suggestions refreshed for each entry, got %q", lookups)
+	}
+
+	// Once the user types, up/down move the suggestion selection again.
+	m.dirSuggestions = []string{"/tmp/a", "/tmp/b"}
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m = updated.(model)
+	m.dirSuggestions = []string{"/tmp/a", "/tmp/b"}
+	press(tea.KeyDown)
+	if m.dirQuery != "a" || m.dirSelection != 1 {
+		t.Fatalf("expected down to move selection while typing, got query %q selection %d", m.dirQuery, m.dirSelection)
+	}
+}
+
+func TestDirJumpEnterPersistsHistory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	m := model{
+		config:          config.DefaultConfig(),
+		sessions:        map[string]*tmux.Session{},
+		bindings:        map[string]commandBinding{},
+		viewState:       viewHome,
+		mode:            modeDirJump,
+		dirQuery:        "proj",
+		dirSuggestions:  []string{"/tmp/project"},
+		dirQueryHistory: []string{"proj"},
+		chdir:           func(string) error { return nil },
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if strings.Join(m.dirQueryHistory, ",") != "proj" {
+		t.Fatalf("expected repeated query not to be duplicated, got %v", m.dirQueryHistory)
+	}
+
+	m.mode = modeDirJump
+	m.dirQuery = "docs"
+	m.dirSuggestions = []string{"/tmp/docs"}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if got := loadDirHistory(); strings.Join(got, ",") != "proj,docs" {
+		t.Fatalf("expected persisted history proj,docs, got %v", got)
+	}
+}
+
 func TestDirJumpTypingDoesNotSelectSuggestion(t *testing.T) {
 	m := model{
 		config:         config.DefaultConfig(),
@@ -578,6 +823,7 @@ func TestReverseStrings(t *testing.T) {
 }
 
 func TestDirJumpArrowSelectChangesDirectory(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
 	var changedTo string
 	m := model{
 		config:         config.DefaultConfig(),
@@ -642,11 +888,196 @@ func TestDefaultInstructionsShowMobileShortcuts(t *testing.T) {
 	}
 }
 
+func TestBuildTooltip(t *testing.T) {
+	m := model{
+		config: config.DefaultConfig(),
+		bindings: map[string]commandBinding{
+			"codex-2": {
+				SessionName: "codex-2",
+				Cwd:         "/home/me/src/pocketbot",
+				Running:     true,
+				Tool:        "codex",
+				Memo:        "fix flaky test",
+				Command:     "codex resume --last || codex",
+				Created:     time.Now().Add(-(2*time.Hour + 5*time.Minute + 30*time.Second)),
+				AttachCount: 3,
+				LastSeen:    time.Now(),
+			},
+		},
+		taskCounts:   map[string]int{"codex-2": 2},
+		taskCommands: map[string][]string{"codex-2": {"make dev", "go test ./..."}},
+	}
+
+	want := "cmd: codex resume --last || codex\n" +
+		"cwd: /home/me/src/pocketbot\n" +
+		"up: 2h05m\n" +
+		"tasks: 2 (make dev, go test ./...)\n" +
+		"visits: 3"
+	if got := m.buildTooltip("codex-2"); got != want {
+		t.Fatalf("buildTooltip mismatch:\n got: %q\nwant: %q", got, want)
+	}
+
+	if got := m.buildTooltip("missing"); got != "cmd: -\ncwd: -\nup: -\ntasks: 0\nvisits: 0" {
+		t.Fatalf("unexpected tooltip for unknown session: %q", got)
+	}
+}
+
+func TestArrowKeysScheduleTooltip(t *testing.T) {
+	m := model{
+		config: config.DefaultConfig(),
+		bindings: map[string]commandBinding{
+			"claude":  {SessionName: "claude", Running: true, Tool: "claude", Command: "claude"},
+			"codex":   {SessionName: "codex", Running: true, Tool: "codex"},
+			"codex-2": {SessionName: "codex-2", Running: true, Tool: "codex"},
+		},
+		windowWidth: 80,
+		viewState:   viewHome,
+		mode:        modeHome,
+	}
+
+	m, cmd := m.moveTooltipFocus(1)
+	if m.tooltipSession != "claude" || cmd == nil {
+		t.Fatalf("expected focus on claude with a wake-up cmd, got %q", m.tooltipSession)
+	}
+	if rows := strings.Join(m.detailedRows("claude", []string{"claude"}), "\n"); contains(rows, "cmd: claude") {
+		t.Fatalf("tooltip should not show before the delay, got: %s", rows)
+	}
+	m.tooltipShowAt = time.Now().Add(-time.Millisecond)
+	rows := m.detailedRows("claude", []string{"claude"})
+	if len(rows) != 6 || !contains(rows[1], "│ cmd: claude") || !contains(rows[5], "│ visits: 0") {
+		t.Fatalf("expected tooltip block below the focused row, got: %q", rows)
+	}
+
+	m, _ = m.moveTooltipFocus(-1)
+	if m.tooltipSession != "codex-2" {
+		t.Fatalf("expected up to wrap to the last row, got %q", m.tooltipSession)
+	}
+	if !m.tooltipShowAt.After(time.Now()) {
+		t.Fatal("expected moving focus to restart the delay")
+	}
+}
+
+func TestNextSessionNameRepoScheme(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.NameScheme = config.NameSchemeRepo
+	m := model{
+		config:   cfg,
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+		getwd:    func() (string, error) { return "/home/me/src/pocketbot", nil },
+	}
+
+	if got := m.nextSessionName("codex"); got != "codex-pocketbot" {
+		t.Fatalf("nextSessionName()=%q, want codex-pocketbot", got)
+	}
+	if got := toolFromSessionName(nil, "codex-pocketbot"); got != "codex" {
+		t.Fatalf("expected codex-pocketbot to classify as codex, got %q", got)
+	}
+
+	// Same repo name elsewhere, and a stale second session, push the suffix up.
+	m.bindings["codex-pocketbot"] = commandBinding{SessionName: "codex-pocketbot", Running: true, Tool: "codex", Cwd: "/tmp/pocketbot"}
+	m.sessions["codex-pocketbot-2"] = tmux.NewSession("codex-pocketbot-2", "")
+	if got := m.nextSessionName("codex"); got != "codex-pocketbot-3" {
+		t.Fatalf("nextSessionName() with collisions=%q, want codex-pocketbot-3", got)
+	}
+	if got := m.nextSessionName("claude"); got != "claude-pocketbot" {
+		t.Fatalf("expected per-tool names to be independent, got %q", got)
+	}
+}
+
+func TestNextSessionNameRepoSchemeSanitizesAndFallsBack(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.NameScheme = config.NameSchemeRepo
+	m := model{
+		config:   cfg,
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+		getwd:    func() (string, error) { return "/home/me/src/my site.io", nil },
+	}
+	if got := m.nextSessionName("claude"); got != "claude-my-site-io" {
+		t.Fatalf("nextSessionName()=%q, want claude-my-site-io", got)
+	}
+
+	m.getwd = func() (string, error) { return "", os.ErrNotExist }
+	if got := m.nextSessionName("claude"); got != "claude" {
+		t.Fatalf("expected tool-number fallback without a cwd, got %q", got)
+	}
+
+	m.config = config.DefaultConfig()
+	m.getwd = func() (string, error) { return "/home/me/src/pocketbot", nil }
+	if got := m.nextSessionName("claude"); got != "claude" {
+		t.Fatalf("expected default tool-number scheme, got %q", got)
+	}
+}
+
+func TestScratchClassification(t *testing.T) {
+	for name, want := range map[string]string{
+		"scratch":    scratchTool,
+		"scratch-3":  scratchTool,
+		"scratchpad": "",
+		"codex-2":    "codex",
+	} {
+		if got := toolFromSessionName(nil, name); got != want {
+			t.Errorf("toolFromSessionName(%q)=%q, want %q", name, got, want)
+		}
+	}
+	if normalizeToolName(nil, scratchTool) != scratchTool {
+		t.Error("expected scratch to be a recognized @pb_tool value")
+	}
+
+	m := model{config: config.DefaultConfig()}
+	if got := m.toolForKey("s"); got != scratchTool {
+		t.Fatalf("toolForKey(s)=%q, want scratch", got)
+	}
+	m.config.Sessions = []config.SessionConfig{{Name: "shell", Command: "zsh", Key: "s"}}
+	if got := m.toolForKey("s"); got != "" {
+		t.Fatalf("expected custom session to keep key s, got %q", got)
+	}
+	if m.toolEnabled(scratchTool) {
+		t.Fatal("expected scratch disabled when its key is taken")
+	}
+}
+
+func TestHomeHidesScratchRowWhenNoneRunning(t *testing.T) {
+	m := model{
+		config:      config.DefaultConfig(),
+		sessions:    map[string]*tmux.Session{},
+		bindings:    map[string]commandBinding{},
+		windowWidth: 80,
+		viewState:   viewHome,
+		mode:        modeHome,
+	}
+	if view := m.View(); contains(view, "scratch") {
+		t.Fatalf("did not expect a scratch row with no scratch sessions, got: %s", view)
+	}
+	m.mode = modeNewTool
+	if view := m.View(); !contains(view, "new scratch shell") {
+		t.Fatalf("expected scratch option in new menu, got: %s", view)
+	}
+}
+
+func TestNewMenuListsEachToolOnce(t *testing.T) {
+	m := model{
+		config:      config.DefaultConfig(),
+		sessions:    map[string]*tmux.Session{},
+		bindings:    map[string]commandBinding{},
+		windowWidth: 80,
+		viewState:   viewHome,
+		mode:        modeNewTool,
+	}
+	view := stripANSI(m.View())
+	for _, tool := range []string{"claude", "codex", "cursor"} {
+		if n := strings.Count(view, "new "+tool); n != 1 {
+			t.Errorf("expected one %q row, got %d in: %s", "new "+tool, n, view)
+		}
+	}
+}
+
 func TestKillModeShowsOnlyRunningTargets(t *testing.T) {
 	cfg := config.DefaultConfig()
 	m := model{
 		config:      cfg,
-		sessions:    map[string]*tmux.Session{"codex": tmux.NewSession("codex", cfg.Codex.Command)},
+		sessions:    map[string]*tmux.Session{"codex": tmux.NewSession("codex", cfg.Tool("codex").Command)},
 		bindings:    map[string]commandBinding{},
 		windowWidth: 80,
 		viewState:   viewHome,
@@ -657,8 +1088,9 @@ func TestKillModeShowsOnlyRunningTargets(t *testing.T) {
 	}
 	defer m.sessions["codex"].Stop()
 
+	m.refreshBindings()
 	view := m.View()
-	if !contains(view, "kill codex") {
+	if !contains(view, "kill 🟢 codex") {
 		t.Fatal("expected running codex kill option")
 	}
 	if contains(view, "kill claude") || contains(view, "kill cursor") {
@@ -671,8 +1103,8 @@ func TestKillModeShowsSecondKeyHintsForMultipleToolSessions(t *testing.T) {
 	m := model{
 		config: cfg,
 		sessions: map[string]*tmux.Session{
-			"codex":   tmux.NewSession("codex", cfg.Codex.Command),
-			"codex-2": tmux.NewSession("codex-2", cfg.Codex.Command),
+			"codex":   tmux.NewSession("codex", cfg.Tool("codex").Command),
+			"codex-2": tmux.NewSession("codex-2", cfg.Tool("codex").Command),
 		},
 		bindings:    map[string]commandBinding{},
 		windowWidth: 80,
@@ -689,8 +1121,9 @@ func TestKillModeShowsSecondKeyHintsForMultipleToolSessions(t *testing.T) {
 	defer m.sessions["codex"].Stop()
 	defer m.sessions["codex-2"].Stop()
 
+	m.refreshBindings()
 	view := m.View()
-	if !contains(view, "(x a) codex repo:") || !contains(view, "(x b) codex-2 repo:") {
+	if !contains(view, "(x a) 🟢 codex repo:") || !contains(view, "(x b) 🟢 codex-2 repo:") {
 		t.Fatalf("expected session names in second-key hints for multiple codex sessions, got: %s", view)
 	}
 }
@@ -700,8 +1133,8 @@ func TestKillModeXStillOpensPickerWhenMultipleCodexSessions(t *testing.T) {
 	m := model{
 		config: cfg,
 		sessions: map[string]*tmux.Session{
-			"codex":   tmux.NewSession("codex", cfg.Codex.Command),
-			"codex-2": tmux.NewSession("codex-2", cfg.Codex.Command),
+			"codex":   tmux.NewSession("codex", cfg.Tool("codex").Command),
+			"codex-2": tmux.NewSession("codex-2", cfg.Tool("codex").Command),
 		},
 		bindings:    map[string]commandBinding{},
 		windowWidth: 80,
@@ -732,8 +1165,8 @@ func TestKillModeXStillOpensPickerWhenMultipleCodexSessions(t *testing.T) {
 	if m.shouldAttach {
 		t.Fatal("x in kill mode should never trigger attach")
 	}
-	if len(m.pickerTargets) != 2 {
-		t.Fatalf("expected 2 picker targets, got %d", len(m.pickerTargets))
+	if len(m.pickerEntries) != 2 {
+		t.Fatalf("expected 2 picker targets, got %d", len(m.pickerEntries))
 	}
 }
 
@@ -742,8 +1175,8 @@ func TestRenameModeXStillOpensPickerWhenMultipleCodexSessions(t *testing.T) {
 	m := model{
 		config: cfg,
 		sessions: map[string]*tmux.Session{
-			"codex":   tmux.NewSession("codex", cfg.Codex.Command),
-			"codex-2": tmux.NewSession("codex-2", cfg.Codex.Command),
+			"codex":   tmux.NewSession("codex", cfg.Tool("codex").Command),
+			"codex-2": tmux.NewSession("codex-2", cfg.Tool("codex").Command),
 		},
 		bindings:    map[string]commandBinding{},
 		windowWidth: 80,
@@ -771,8 +1204,8 @@ func TestRenameModeXStillOpensPickerWhenMultipleCodexSessions(t *testing.T) {
 	if m.mode != modePickRename {
 		t.Fatalf("expected modePickRename, got %v", m.mode)
 	}
-	if len(m.pickerTargets) != 2 {
-		t.Fatalf("expected 2 picker targets, got %d", len(m.pickerTargets))
+	if len(m.pickerEntries) != 2 {
+		t.Fatalf("expected 2 picker targets, got %d", len(m.pickerEntries))
 	}
 }
 
@@ -781,8 +1214,8 @@ func TestRenameModeShowsSessionNamesForMultipleToolSessions(t *testing.T) {
 	m := model{
 		config: cfg,
 		sessions: map[string]*tmux.Session{
-			"codex":   tmux.NewSession("codex", cfg.Codex.Command),
-			"codex-2": tmux.NewSession("codex-2", cfg.Codex.Command),
+			"codex":   tmux.NewSession("codex", cfg.Tool("codex").Command),
+			"codex-2": tmux.NewSession("codex-2", cfg.Tool("codex").Command),
 		},
 		bindings:    map[string]commandBinding{},
 		windowWidth: 80,
@@ -799,8 +1232,9 @@ func TestRenameModeShowsSessionNamesForMultipleToolSessions(t *testing.T) {
 	defer m.sessions["codex"].Stop()
 	defer m.sessions["codex-2"].Stop()
 
+	m.refreshBindings()
 	view := m.View()
-	if !contains(view, "(x a) codex repo:") || !contains(view, "(x b) codex-2 repo:") {
+	if !contains(view, "(x a) 🟢 codex repo:") || !contains(view, "(x b) 🟢 codex-2 repo:") {
 		t.Fatalf("expected session names in rename mode hints, got: %s", view)
 	}
 }
@@ -809,7 +1243,7 @@ func TestApplyRenameTargetRenamesSessionInModel(t *testing.T) {
 	cfg := config.DefaultConfig()
 	m := model{
 		config:       cfg,
-		sessions:     map[string]*tmux.Session{"codex": tmux.NewSession("codex", cfg.Codex.Command)},
+		sessions:     map[string]*tmux.Session{"codex": tmux.NewSession("codex", cfg.Tool("codex").Command)},
 		sessionTools: map[string]string{"codex": "codex"},
 		bindings:     map[string]commandBinding{},
 		mode:         modeRenameInput,
@@ -1061,13 +1495,13 @@ func TestRenameInputReadlineShortcuts(t *testing.T) {
 
 func TestDirJumpTypingDDoesNotExit(t *testing.T) {
 	m := model{
-		config:      config.DefaultConfig(),
-		sessions:    map[string]*tmux.Session{},
-		bindings:    map[string]commandBinding{},
-		viewState:   viewHome,
-		mode:        modeDirJump,
-		dirQuery:    "pro",
-		dirCursor:   3,
+		config:    config.DefaultConfig(),
+		sessions:  map[string]*tmux.Session{},
+		bindings:  map[string]commandBinding{},
+		viewState: viewHome,
+		mode:      modeDirJump,
+		dirQuery:  "pro",
+		dirCursor: 3,
 		lookupDirs: func(query string) ([]string, error) {
 			return []string{"/tmp/prod"}, nil
 		},
@@ -1102,6 +1536,70 @@ func TestRenameInputShowsCursorIndicator(t *testing.T) {
 	}
 }
 
+func TestRenameSuggestions(t *testing.T) {
+	m := model{sessions: map[string]*tmux.Session{
+		"claude":      nil,
+		"claude-work": nil,
+		"claude-api":  nil,
+		"codex":       nil,
+	}}
+	if got := strings.Join(m.renameSuggestions("cla"), ","); got != "claude,claude-api,claude-work" {
+		t.Fatalf("renameSuggestions(cla) = %s", got)
+	}
+	if got := strings.Join(m.renameSuggestions("claude"), ","); got != "claude-api,claude-work" {
+		t.Fatalf("expected exact match excluded, got %s", got)
+	}
+	if got := m.renameSuggestions("zzz"); len(got) != 0 {
+		t.Fatalf("expected no suggestions, got %v", got)
+	}
+	if got := m.renameSuggestions(""); len(got) != 0 {
+		t.Fatalf("expected no suggestions for empty input, got %v", got)
+	}
+}
+
+func TestRenameInputTabAcceptsSuggestion(t *testing.T) {
+	m := model{
+		config: config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{
+			"claude-api":  nil,
+			"claude-work": nil,
+			"codex":       nil,
+		},
+		bindings:     map[string]commandBinding{},
+		viewState:    viewHome,
+		mode:         modeRenameInput,
+		renameTarget: "codex",
+	}
+	press := func(msg tea.KeyMsg) {
+		t.Helper()
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("cla")})
+	if view := m.View(); !contains(view, "new name: cla▌ude-api") || !contains(view, "tab complete") {
+		t.Fatalf("expected inline completion hint, got: %s", view)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyDown})
+	if m.renameHint() != "claude-work" {
+		t.Fatalf("expected down to select the next suggestion, got %q", m.renameHint())
+	}
+	press(tea.KeyMsg{Type: tea.KeyTab})
+	if m.renameInput != "claude-work" || m.renameCursor != len("claude-work") {
+		t.Fatalf("expected tab to accept suggestion, got %q (cursor %d)", m.renameInput, m.renameCursor)
+	}
+	if m.mode != modeRenameInput {
+		t.Fatalf("expected to stay in rename input, got mode %v", m.mode)
+	}
+
+	// With the cursor mid-input there is no hint and tab does nothing.
+	m.renameInput, m.renameCursor = "cla", 1
+	press(tea.KeyMsg{Type: tea.KeyTab})
+	if m.renameInput != "cla" {
+		t.Fatalf("expected tab ignored away from the end, got %q", m.renameInput)
+	}
+}
+
 func TestRenameUpdatesHomeRowWithNewName(t *testing.T) {
 	requireTmuxSessionCreation(t)
 
@@ -1115,11 +1613,11 @@ func TestRenameUpdatesHomeRowWithNewName(t *testing.T) {
 
 	cfg := config.DefaultConfig()
 	m := model{
-		config:   cfg,
-		sessions: map[string]*tmux.Session{sessionName: tmux.NewSession(sessionName, cfg.Codex.Command)},
-		bindings: map[string]commandBinding{},
-		mode:     modeRenameInput,
-		viewState: viewHome,
+		config:       cfg,
+		sessions:     map[string]*tmux.Session{sessionName: tmux.NewSession(sessionName, cfg.Tool("codex").Command)},
+		bindings:     map[string]commandBinding{},
+		mode:         modeRenameInput,
+		viewState:    viewHome,
 		renameTarget: sessionName,
 		renameInput:  newName,
 	}
@@ -1141,17 +1639,16 @@ func TestIntegrationCreateThenRenameClaudeDoesNotDuplicateInHomeView(t *testing.
 
 	cfg := config.DefaultConfig()
 	m := model{
-		config:        cfg,
-		sessions:      map[string]*tmux.Session{},
-		sessionTools:  map[string]string{},
-		bindings:      map[string]commandBinding{},
-		taskCounts:    map[string]int{},
-		taskCommands:  map[string][]string{},
-		pickerTargets: map[string]string{},
-		viewState:     viewHome,
-		mode:          modeHome,
-		getwd:         os.Getwd,
-		chdir:         os.Chdir,
+		config:       cfg,
+		sessions:     map[string]*tmux.Session{},
+		sessionTools: map[string]string{},
+		bindings:     map[string]commandBinding{},
+		taskCounts:   map[string]int{},
+		taskCommands: map[string][]string{},
+		viewState:    viewHome,
+		mode:         modeHome,
+		getwd:        os.Getwd,
+		chdir:        os.Chdir,
 	}
 
 	createdModel, _ := m.createAndAttachTool("claude")
@@ -1194,30 +1691,83 @@ func TestIntegrationCreateThenRenameClaudeDoesNotDuplicateInHomeView(t *testing.
 	}
 }
 
-func TestIntegrationRenameToPrefixedNameDoesNotKeepOriginalRow(t *testing.T) {
+func TestIntegrationScratchSessionsGetOwnRow(t *testing.T) {
 	requireTmuxSessionCreation(t)
 
-	socketLevel := fmt.Sprintf("itest-prefix-%d", time.Now().UnixNano())
+	socketLevel := fmt.Sprintf("itest-%d", time.Now().UnixNano())
 	t.Setenv("PB_LEVEL", socketLevel)
+	t.Setenv("SHELL", "/bin/sh")
 	defer tmux.KillServer()
 
-	cfg := config.DefaultConfig()
 	m := model{
-		config:        cfg,
-		sessions:      map[string]*tmux.Session{},
-		sessionTools:  map[string]string{},
-		bindings:      map[string]commandBinding{},
-		taskCounts:    map[string]int{},
-		taskCommands:  map[string][]string{},
-		pickerTargets: map[string]string{},
-		viewState:     viewHome,
-		mode:          modeHome,
-		getwd:         os.Getwd,
-		chdir:         os.Chdir,
-	}
-
-	createdModel, _ := m.createAndAttachTool("codex")
-	m = createdModel
+		config:       config.DefaultConfig(),
+		sessions:     map[string]*tmux.Session{},
+		sessionTools: map[string]string{},
+		bindings:     map[string]commandBinding{},
+		taskCounts:   map[string]int{},
+		taskCommands: map[string][]string{},
+		windowWidth:  80,
+		viewState:    viewHome,
+		mode:         modeNewTool,
+		getwd:        os.Getwd,
+		chdir:        os.Chdir,
+	}
+
+	for _, want := range []string{"scratch", "scratch-2"} {
+		m.mode = modeNewTool
+		updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+		m = updated.(model)
+		if m.sessionToAttach != want {
+			t.Fatalf("expected new scratch session %q, got %q (notice %q)", want, m.sessionToAttach, m.homeNotice)
+		}
+		if got := tmux.GetSessionTool(want); got != scratchTool {
+			t.Fatalf("expected @pb_tool scratch on %s, got %q", want, got)
+		}
+		m.shouldAttach = false
+		m.sessionToAttach = ""
+		m.refreshBindings()
+	}
+
+	m.mode = modeHome
+	view := m.View()
+	if !contains(view, "(s a) 🐚 scratch repo:") || !contains(view, "(s b) 🐚 scratch-2 repo:") {
+		t.Fatalf("expected scratch rows, got: %s", view)
+	}
+	if contains(view, "(c a)") || contains(view, "(x a)") {
+		t.Fatalf("did not expect scratch shells grouped under a tool, got: %s", view)
+	}
+
+	m.mode = modeKillTool
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+	m = updated.(model)
+	if m.mode != modePickKill || m.pickerTool != scratchTool || len(m.pickerEntries) != 2 {
+		t.Fatalf("expected scratch kill picker, got mode=%v tool=%q entries=%v", m.mode, m.pickerTool, m.pickerEntries)
+	}
+}
+
+func TestIntegrationRenameToPrefixedNameDoesNotKeepOriginalRow(t *testing.T) {
+	requireTmuxSessionCreation(t)
+
+	socketLevel := fmt.Sprintf("itest-prefix-%d", time.Now().UnixNano())
+	t.Setenv("PB_LEVEL", socketLevel)
+	defer tmux.KillServer()
+
+	cfg := config.DefaultConfig()
+	m := model{
+		config:       cfg,
+		sessions:     map[string]*tmux.Session{},
+		sessionTools: map[string]string{},
+		bindings:     map[string]commandBinding{},
+		taskCounts:   map[string]int{},
+		taskCommands: map[string][]string{},
+		viewState:    viewHome,
+		mode:         modeHome,
+		getwd:        os.Getwd,
+		chdir:        os.Chdir,
+	}
+
+	createdModel, _ := m.createAndAttachTool("codex")
+	m = createdModel
 	createdName := m.sessionToAttach
 	if createdName == "" {
 		t.Fatal("expected created codex session name")
@@ -1234,6 +1784,7 @@ func TestIntegrationRenameToPrefixedNameDoesNotKeepOriginalRow(t *testing.T) {
 	m = m.applyRenameTarget()
 	m.homeNotice = ""
 
+	m.refreshBindings()
 	view := m.View()
 	if !contains(view, "codex-pb repo:") {
 		t.Fatalf("expected renamed codex-pb row, got: %s", view)
@@ -1263,16 +1814,16 @@ func TestIntegrationRenameToConfiguredNameAllowedWhenSessionNotRunning(t *testin
 	m := model{
 		config: cfg,
 		sessions: map[string]*tmux.Session{
-			"claude": tmux.NewSession("claude", cfg.Claude.Command), // configured wrapper, not running
-			"codex":  tmux.NewSession("codex", cfg.Codex.Command),
+			"claude": tmux.NewSession("claude", cfg.Tool("claude").Command), // configured wrapper, not running
+			"codex":  tmux.NewSession("codex", cfg.Tool("codex").Command),
 		},
 		sessionTools: map[string]string{
 			"claude": "claude",
 			"codex":  "codex",
 		},
-		bindings: map[string]commandBinding{},
-		mode:     modeRenameInput,
-		viewState: viewHome,
+		bindings:     map[string]commandBinding{},
+		mode:         modeRenameInput,
+		viewState:    viewHome,
 		renameTarget: "codex",
 		renameInput:  "claude",
 	}
@@ -1296,8 +1847,8 @@ func TestHomeAttachKeyOpensPickerWhenMultipleToolSessionsExist(t *testing.T) {
 	m := model{
 		config: cfg,
 		sessions: map[string]*tmux.Session{
-			"codex":   tmux.NewSession("codex", cfg.Codex.Command),
-			"codex-2": tmux.NewSession("codex-2", cfg.Codex.Command),
+			"codex":   tmux.NewSession("codex", cfg.Tool("codex").Command),
+			"codex-2": tmux.NewSession("codex-2", cfg.Tool("codex").Command),
 		},
 		bindings:    map[string]commandBinding{},
 		windowWidth: 80,
@@ -1315,7 +1866,7 @@ func TestHomeAttachKeyOpensPickerWhenMultipleToolSessionsExist(t *testing.T) {
 	defer m.sessions["codex"].Stop()
 	defer m.sessions["codex-2"].Stop()
 
-	updatedModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(cfg.Codex.Key)})
+	updatedModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(cfg.Tool("codex").Key)})
 	m, ok := updatedModel.(model)
 	if !ok {
 		t.Fatal("Update should return a model")
@@ -1329,8 +1880,8 @@ func TestHomeAttachKeyOpensPickerWhenMultipleToolSessionsExist(t *testing.T) {
 	if m.shouldAttach {
 		t.Fatal("attach key with multiple sessions should not immediately attach")
 	}
-	if len(m.pickerTargets) != 2 {
-		t.Fatalf("expected 2 picker targets, got %d", len(m.pickerTargets))
+	if len(m.pickerEntries) != 2 {
+		t.Fatalf("expected 2 picker targets, got %d", len(m.pickerEntries))
 	}
 }
 
@@ -1423,7 +1974,7 @@ func TestFreshCommandForTool(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := freshCommandForTool(tt.tool, tt.command)
+			got := freshCommandForTool(nil, tt.tool, tt.command)
 			if got != tt.want {
 				t.Fatalf("freshCommandForTool(%q, %q) = %q, want %q", tt.tool, tt.command, got, tt.want)
 			}
@@ -1688,7 +2239,7 @@ func TestYoloCommandForTool(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := yoloCommandForTool(tt.tool, tt.command)
+			got := yoloCommandForTool(nil, tt.tool, tt.command)
 			if got != tt.want {
 				t.Fatalf("yoloCommandForTool(%q, %q) = %q, want %q", tt.tool, tt.command, got, tt.want)
 			}
@@ -1856,7 +2407,7 @@ func TestFallbackCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := fallbackCommand(tt.tool, tt.command)
+			got := fallbackCommand(nil, tt.tool, tt.command)
 			if got != tt.want {
 				t.Fatalf("fallbackCommand(%q, %q) = %q, want %q", tt.tool, tt.command, got, tt.want)
 			}
@@ -1876,7 +2427,7 @@ func TestPressCSetsAttachFlag(t *testing.T) {
 		t.Error("shouldAttach should be false initially")
 	}
 
-	claudeSessCfg := m.config.Claude
+	claudeSessCfg := m.config.Tool("claude")
 	if !claudeSessCfg.Enabled {
 		t.Skip("claude session is disabled in config")
 	}
@@ -1957,7 +2508,8 @@ func TestHomeViewShowsSessionStatus(t *testing.T) {
 		}
 	}()
 
-	// View with running session
+	// View with running session; View only renders, so refresh as a tick would.
+	m.refreshBindings()
 	view = m.View()
 	if !contains(view, "claude") {
 		t.Error("Should show claude row when session is running")
@@ -1970,6 +2522,38 @@ func TestHomeViewShowsSessionStatus(t *testing.T) {
 	}
 }
 
+func TestViewDoesNotInvokeTmux(t *testing.T) {
+	dir := t.TempDir()
+	logPath := dir + "/tmux.log"
+	script := "#!/bin/sh\necho \"$@\" >> " + logPath + "\n"
+	if err := os.WriteFile(dir+"/tmux", []byte(script), 0o755); err != nil {
+		t.Fatalf("write fake tmux: %v", err)
+	}
+	t.Setenv("PATH", dir)
+
+	cfg := config.DefaultConfig()
+	m := model{
+		config: cfg,
+		sessions: map[string]*tmux.Session{
+			"codex": tmux.NewSession("codex", cfg.Tool("codex").Command),
+		},
+		bindings: map[string]commandBinding{
+			"codex":   {SessionName: "codex", Tool: "codex", Running: true},
+			"codex-2": {SessionName: "codex-2", Tool: "codex", Running: true},
+		},
+		windowWidth: 80,
+		viewState:   viewHome,
+	}
+	for _, mode := range []uiMode{modeHome, modeNewTool, modeKillTool, modeRenameTool, modeSearch} {
+		m.mode = mode
+		_ = m.View()
+	}
+
+	if data, err := os.ReadFile(logPath); err == nil && len(data) > 0 {
+		t.Fatalf("expected View to render without tmux calls, got:\n%s", data)
+	}
+}
+
 func TestDetailedRowsShowsTaskCountWhenPresent(t *testing.T) {
 	cfg := config.DefaultConfig()
 	m := model{
@@ -2129,7 +2713,7 @@ func TestCreateAndAttachToolReusesSessionInCurrentDirectory(t *testing.T) {
 	cfg := config.DefaultConfig()
 	m := model{
 		config:    cfg,
-		sessions:  map[string]*tmux.Session{"claude": tmux.NewSession("claude", cfg.Claude.Command)},
+		sessions:  map[string]*tmux.Session{"claude": tmux.NewSession("claude", cfg.Tool("claude").Command)},
 		bindings:  map[string]commandBinding{"claude": {SessionName: "claude", Cwd: "/repo", Running: true}},
 		viewState: viewHome,
 		mode:      modeHome,
@@ -2155,8 +2739,8 @@ func TestCreateAndAttachToolShowsPickerWhenMultipleSessionsInCurrentDirectory(t
 	m := model{
 		config: cfg,
 		sessions: map[string]*tmux.Session{
-			"claude":   tmux.NewSession("claude", cfg.Claude.Command),
-			"claude-2": tmux.NewSession("claude-2", cfg.Claude.Command),
+			"claude":   tmux.NewSession("claude", cfg.Tool("claude").Command),
+			"claude-2": tmux.NewSession("claude-2", cfg.Tool("claude").Command),
 		},
 		bindings: map[string]commandBinding{
 			"claude":   {SessionName: "claude", Cwd: "/repo", Running: true},
@@ -2176,8 +2760,102 @@ func TestCreateAndAttachToolShowsPickerWhenMultipleSessionsInCurrentDirectory(t
 	if updatedModel.mode != modePickAttach {
 		t.Fatalf("expected modePickAttach, got %v", updatedModel.mode)
 	}
-	if len(updatedModel.pickerTargets) != 2 {
-		t.Fatalf("expected 2 picker targets, got %d", len(updatedModel.pickerTargets))
+	if len(updatedModel.pickerEntries) != 2 {
+		t.Fatalf("expected 2 picker targets, got %d", len(updatedModel.pickerEntries))
+	}
+}
+
+func TestCreateAndAttachToolOpensWindowWhenReusingSessions(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ReuseSessions = true
+	m := model{
+		config:    cfg,
+		sessions:  map[string]*tmux.Session{"claude": tmux.NewSession("claude", cfg.Tool("claude").Command)},
+		bindings:  map[string]commandBinding{"claude": {SessionName: "claude", Cwd: "/repo", Running: true}},
+		viewState: viewHome,
+		mode:      modeNewTool,
+		getwd: func() (string, error) {
+			return "/repo", nil
+		},
+	}
+
+	originalNewWindow := newWindowFn
+	originalReadOnly := readOnlyFn
+	defer func() {
+		newWindowFn = originalNewWindow
+		readOnlyFn = originalReadOnly
+	}()
+	readOnlyFn = func() bool { return false }
+	var gotSession, gotCommand string
+	newWindowFn = func(session, command string) error {
+		gotSession, gotCommand = session, command
+		return nil
+	}
+
+	if view := stripANSI(m.View()); !contains(view, "new claude window") {
+		t.Fatalf("expected n menu to offer a new claude window, got: %s", view)
+	}
+	updatedModel, cmd := m.createAndAttachTool("claude")
+	if cmd == nil || !updatedModel.shouldAttach || updatedModel.sessionToAttach != "claude" {
+		t.Fatalf("expected attach to claude, got shouldAttach=%v target=%q notice=%q",
+			updatedModel.shouldAttach, updatedModel.sessionToAttach, updatedModel.homeNotice)
+	}
+	if gotSession != "claude" {
+		t.Fatalf("expected window in claude session, got %q", gotSession)
+	}
+	if !strings.HasPrefix(gotCommand, "claude") || strings.Contains(gotCommand, "--continue") {
+		t.Fatalf("expected a fresh claude command, got %q", gotCommand)
+	}
+}
+
+func TestAttachPickerListsWindowsWhenReusingSessions(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ReuseSessions = true
+	m := model{
+		config:       cfg,
+		sessions:     map[string]*tmux.Session{},
+		sessionTools: map[string]string{"claude": "claude", "claude-2": "claude"},
+		bindings: map[string]commandBinding{
+			"claude":   {SessionName: "claude", Tool: "claude", Running: true},
+			"claude-2": {SessionName: "claude-2", Tool: "claude", Running: true},
+		},
+		windowWidth: 80,
+		viewState:   viewHome,
+		mode:        modeHome,
+		// Alphabetical order keeps the expected keys stable.
+		pickerSortOrder: sortAlpha,
+	}
+
+	original := listPanesFn
+	defer func() { listPanesFn = original }()
+	listPanesFn = func(sessionName string) ([]tmux.Pane, error) {
+		if sessionName == "claude-2" {
+			return []tmux.Pane{{WindowIndex: 0, PaneIndex: 0, Active: true}}, nil
+		}
+		return []tmux.Pane{
+			{WindowIndex: 0, PaneIndex: 0, Active: true},
+			{WindowIndex: 1, PaneIndex: 0},
+			{WindowIndex: 1, PaneIndex: 1, Active: true},
+		}, nil
+	}
+
+	m = m.preparePicker("claude", modePickAttach)
+	want := []pickerEntry{
+		{key: "a", session: "claude", window: 0, target: tmux.PaneTarget("claude", 0, 0)},
+		{key: "b", session: "claude", window: 1, target: tmux.PaneTarget("claude", 1, 1)},
+		{key: "c", session: "claude-2"},
+	}
+	if !reflect.DeepEqual(m.pickerEntries, want) {
+		t.Fatalf("picker entries = %+v, want %+v", m.pickerEntries, want)
+	}
+	if view := stripANSI(m.View()); !contains(view, "window 1") {
+		t.Fatalf("expected window label in picker, got: %s", view)
+	}
+
+	// Toggling the sort keeps one entry per window.
+	m = m.togglePickerSort().togglePickerSort()
+	if len(m.pickerEntries) != 3 {
+		t.Fatalf("expected 3 entries after re-sorting, got %+v", m.pickerEntries)
 	}
 }
 
@@ -2194,10 +2872,10 @@ func TestDirectoryBindingAllowsAttachInDifferentDirectory(t *testing.T) {
 	defer os.Chdir(originalCwd)
 
 	cfg := config.DefaultConfig()
-	cfg.Claude.Enabled = false
-	cfg.Codex.Enabled = false
+	cfg.Tool("claude").Enabled = false
+	cfg.Tool("codex").Enabled = false
 	cfg.Sessions = []config.SessionConfig{
-		{Name: sessionName, Command: "sleep 60", Key: "t"},
+		{Name: sessionName, Command: "sleep 60", Key: "e"},
 	}
 
 	m := model{
@@ -2211,7 +2889,7 @@ func TestDirectoryBindingAllowsAttachInDifferentDirectory(t *testing.T) {
 	if err := os.Chdir(cwd1); err != nil {
 		t.Fatalf("failed to chdir to cwd1: %v", err)
 	}
-	updatedModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
+	updatedModel, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
 	m = updatedModel.(model)
 	defer m.sessions[sessionName].Stop()
 
@@ -2227,7 +2905,7 @@ func TestDirectoryBindingAllowsAttachInDifferentDirectory(t *testing.T) {
 	if err := os.Chdir(cwd2); err != nil {
 		t.Fatalf("failed to chdir to cwd2: %v", err)
 	}
-	updatedModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
+	updatedModel, cmd = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
 	m = updatedModel.(model)
 
 	if cmd == nil {
@@ -2253,10 +2931,10 @@ func TestDirectoryBindingClearsWhenSessionStops(t *testing.T) {
 	defer os.Chdir(originalCwd)
 
 	cfg := config.DefaultConfig()
-	cfg.Claude.Enabled = false
-	cfg.Codex.Enabled = false
+	cfg.Tool("claude").Enabled = false
+	cfg.Tool("codex").Enabled = false
 	cfg.Sessions = []config.SessionConfig{
-		{Name: sessionName, Command: "sleep 60", Key: "t"},
+		{Name: sessionName, Command: "sleep 60", Key: "e"},
 	}
 
 	m := model{
@@ -2270,7 +2948,7 @@ func TestDirectoryBindingClearsWhenSessionStops(t *testing.T) {
 	}
 
 	// Start and bind.
-	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
 	m = updatedModel.(model)
 	defer m.sessions[sessionName].Stop()
 
@@ -2344,7 +3022,7 @@ func TestPrintToolTasksFallsBackToRootSocketWhenNested(t *testing.T) {
 	}
 
 	var buf bytes.Buffer
-	if !printToolTasksForSocket(&buf) {
+	if !printToolTasksForSocket(&buf, nil, taskListOptions{}) {
 		// nested socket should have no sessions in this test setup
 	} else {
 		t.Fatal("expected nested socket pass to find no tool sessions")
@@ -2353,7 +3031,7 @@ func TestPrintToolTasksFallsBackToRootSocketWhenNested(t *testing.T) {
 	// Simulate root fallback pass.
 	_ = os.Unsetenv("PB_LEVEL")
 	defer os.Setenv("PB_LEVEL", "1")
-	found := printToolTasksForSocket(&buf)
+	found := printToolTasksForSocket(&buf, nil, taskListOptions{})
 	if !found {
 		t.Fatal("expected fallback socket to find claude session")
 	}
@@ -2388,7 +3066,7 @@ func TestPrintToolTasksCapsPerAgentOutput(t *testing.T) {
 	}
 
 	var buf bytes.Buffer
-	if !printToolTasksForSocket(&buf) {
+	if !printToolTasksForSocket(&buf, nil, taskListOptions{}) {
 		t.Fatal("expected tasks to be found")
 	}
 	out := buf.String()
@@ -2402,3 +3080,4715 @@ func TestPrintToolTasksCapsPerAgentOutput(t *testing.T) {
 		t.Fatalf("expected pid=1007 to be hidden by cap, got: %s", out)
 	}
 }
+
+func TestApplyWarningsFlagsMatchingSessions(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := model{
+		config:   cfg,
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Cwd: "/repo", Running: true},
+		},
+	}
+
+	m.applyWarnings(map[string]string{
+		"claude": "API Error: Rate limit reached. Try again later.",
+		"codex":  "thinking...",
+	})
+	if got := m.sessionWarnings["claude"]; got != "rate limit" {
+		t.Fatalf("expected claude rate limit warning, got %q", got)
+	}
+	if _, ok := m.sessionWarnings["codex"]; ok {
+		t.Fatal("expected codex to have no warning")
+	}
+	if m.homeNotice != "" {
+		t.Fatalf("expected no notice when notify is off, got %q", m.homeNotice)
+	}
+
+	rows := m.detailedRows("claude", []string{"claude"})
+	if len(rows) != 1 || !contains(rows[0], "⚠ rate limit") {
+		t.Fatalf("expected warning badge in row, got: %v", rows)
+	}
+
+	m.applyWarnings(map[string]string{"claude": "all good"})
+	if len(m.sessionWarnings) != 0 {
+		t.Fatalf("expected warning to clear, got %v", m.sessionWarnings)
+	}
+}
+
+func TestApplyWarningsNotifiesOnlyOnNewWarning(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Warnings.Patterns = []string{"credit balance"}
+	cfg.Warnings.Notify = true
+	m := model{config: cfg}
+
+	m.applyWarnings(map[string]string{"codex": "Your credit balance is too low"})
+	if m.homeNotice != "warning codex: credit balance" {
+		t.Fatalf("expected warning notice, got %q", m.homeNotice)
+	}
+
+	m.homeNotice = ""
+	m.applyWarnings(map[string]string{"codex": "Your credit balance is too low"})
+	if m.homeNotice != "" {
+		t.Fatalf("expected no repeat notice for unchanged warning, got %q", m.homeNotice)
+	}
+}
+
+func TestConfiguredWorkdir(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Sessions = []config.SessionConfig{
+		{Name: "api", Command: "go run ./cmd/api", Key: "a", Workdir: "/src/api"},
+		{Name: "logs", Command: "tail -f app.log", Key: "l"},
+	}
+	m := model{config: cfg}
+	for name, want := range map[string]string{"api": "/src/api", "logs": "", "claude": ""} {
+		if got := m.configuredWorkdir(name); got != want {
+			t.Errorf("configuredWorkdir(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestResolvePaneTarget(t *testing.T) {
+	original := listPanesFn
+	defer func() { listPanesFn = original }()
+
+	listPanesFn = func(sessionName string) ([]tmux.Pane, error) {
+		return []tmux.Pane{
+			{WindowIndex: 1, PaneIndex: 0},
+			{WindowIndex: 1, PaneIndex: 1},
+			{WindowIndex: 2, PaneIndex: 0},
+		}, nil
+	}
+
+	name := fmt.Sprintf("pane-target-missing-%d", time.Now().UnixNano())
+	tests := []struct {
+		pane string
+		want string
+	}{
+		{pane: "", want: ""},
+		{pane: "1", want: name + ":1.1"},
+		{pane: "2.0", want: name + ":2.0"},
+		{pane: "3.0", want: ""},
+	}
+	for _, tt := range tests {
+		if got := resolvePaneTarget(name, tt.pane); got != tt.want {
+			t.Fatalf("resolvePaneTarget(%q)=%q, want %q", tt.pane, got, tt.want)
+		}
+	}
+}
+
+func TestResolvePaneTargetFallsBackOnListError(t *testing.T) {
+	original := listPanesFn
+	defer func() { listPanesFn = original }()
+
+	listPanesFn = func(sessionName string) ([]tmux.Pane, error) {
+		return nil, errors.New("no server")
+	}
+	if got := resolvePaneTarget("logs", "1"); got != "" {
+		t.Fatalf("expected whole-session fallback, got %q", got)
+	}
+}
+
+func TestRenameBlockedWhenSessionAttached(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := model{
+		config:       cfg,
+		sessions:     map[string]*tmux.Session{"codex": tmux.NewSession("codex", cfg.Tool("codex").Command)},
+		sessionTools: map[string]string{"codex": "codex"},
+		bindings:     map[string]commandBinding{},
+		mode:         modeRenameInput,
+		renameTarget: "codex",
+		renameInput:  "focus",
+	}
+
+	originalRename := renameSessionFn
+	originalForce := forceRenameFn
+	originalSetTool := setSessionToolFn
+	originalListSessions := listSessionsFn
+	defer func() { renameSessionFn = originalRename }()
+	defer func() { forceRenameFn = originalForce }()
+	defer func() { setSessionToolFn = originalSetTool }()
+	defer func() { listSessionsFn = originalListSessions }()
+	renameSessionFn = func(oldName, newName string) error {
+		return fmt.Errorf("%s: %w", oldName, tmux.ErrSessionAttached)
+	}
+	forced := false
+	forceRenameFn = func(oldName, newName string) error {
+		forced = true
+		return nil
+	}
+	setSessionToolFn = func(sessionName, tool string) error { return nil }
+	listSessionsFn = func() []string { return []string{"focus"} }
+
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if m.mode != modeRenameInput {
+		t.Fatalf("expected to stay in rename input, got %v", m.mode)
+	}
+	want := "codex is currently attached; detach first or use shift+enter to force"
+	if m.homeNotice != want {
+		t.Fatalf("expected attached notice %q, got %q", want, m.homeNotice)
+	}
+	if forced {
+		t.Fatal("plain enter should not force rename")
+	}
+
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter, Alt: true})
+	m = updatedModel.(model)
+	if !forced {
+		t.Fatal("expected forced rename on shift+enter")
+	}
+	if m.renameForce {
+		t.Fatal("expected renameForce to reset after rename")
+	}
+	if !contains(m.homeNotice, "renamed codex to focus") {
+		t.Fatalf("expected rename notice, got %q", m.homeNotice)
+	}
+}
+
+func TestRenamedSessionKeepsToolIdleTimeout(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tool("codex").IdleTimeout = 5 * time.Minute
+	m := model{
+		config:       cfg,
+		sessions:     map[string]*tmux.Session{"codex-2": newSessionFor(cfg, "codex-2", "codex", "")},
+		sessionTools: map[string]string{"codex-2": "codex"},
+		bindings:     map[string]commandBinding{},
+		mode:         modeRenameInput,
+		renameTarget: "codex-2",
+		renameInput:  "review",
+	}
+
+	originalRename := renameSessionFn
+	originalSetTool := setSessionToolFn
+	originalListSessions := listSessionsFn
+	defer func() { renameSessionFn = originalRename }()
+	defer func() { setSessionToolFn = originalSetTool }()
+	defer func() { listSessionsFn = originalListSessions }()
+	renameSessionFn = func(oldName, newName string) error { return nil }
+	setSessionToolFn = func(sessionName, tool string) error { return nil }
+	listSessionsFn = func() []string { return []string{"review"} }
+
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updatedModel.(model)
+	if !contains(m.homeNotice, "renamed codex-2 to review") {
+		t.Fatalf("expected rename notice, got %q", m.homeNotice)
+	}
+	// "review" no longer names its tool, so the timeout must come from the
+	// tool the session was renamed with.
+	want := tmux.NewSessionWithOptions("review", cfg.Tool("codex").Command, tmux.WithIdleTimeout(5*time.Minute))
+	if got := m.sessions["review"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("renamed session = %+v, want the codex idle timeout", got)
+	}
+}
+
+func TestConfiguredSessionNameSetCachesPerConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Sessions = []config.SessionConfig{{Name: "logs", Command: "tail -f app.log", Key: "l"}}
+	m := model{config: cfg, configuredSessionNames: &sessionNameCache{}}
+
+	names := m.configuredSessionNameSet()
+	if !names["claude"] || !names["logs"] || names["ghost"] {
+		t.Fatalf("unexpected configured names: %v", names)
+	}
+	if m.configuredSessionNames.version != 1 {
+		t.Fatalf("expected cache version 1 after first build, got %d", m.configuredSessionNames.version)
+	}
+	// Update hands back copies of the model; they share the cache.
+	copied := m
+	copied.configuredSessionNameSet()
+	if m.configuredSessionNames.version != 1 {
+		t.Fatalf("expected cached lookup to keep version 1, got %d", m.configuredSessionNames.version)
+	}
+
+	next := config.DefaultConfig()
+	next.Tool("codex").Enabled = false
+	m.config = next
+	names = m.configuredSessionNameSet()
+	if m.configuredSessionNames.version != 2 {
+		t.Fatalf("expected config change to bump cache version, got %d", m.configuredSessionNames.version)
+	}
+	if names["codex"] || names["logs"] {
+		t.Fatalf("expected cache rebuilt from new config, got %v", names)
+	}
+}
+
+func BenchmarkConfiguredSessionNameSet(b *testing.B) {
+	cfg := config.DefaultConfig()
+	for i := 0; i < 50; i++ {
+		cfg.Sessions = append(cfg.Sessions, config.SessionConfig{
+			Name:    fmt.Sprintf("session-%d", i),
+			Command: "sleep 60",
+			Key:     fmt.Sprintf("k%d", i),
+		})
+	}
+
+	// Each lookup goes through a fresh model copy, as it does after Update.
+	b.Run("cached", func(b *testing.B) {
+		m := model{config: cfg, configuredSessionNames: &sessionNameCache{}}
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < 10000; j++ {
+				copied := m
+				copied.configuredSessionNameSet()
+			}
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		m := model{config: cfg}
+		for i := 0; i < b.N; i++ {
+			for j := 0; j < 10000; j++ {
+				copied := m
+				copied.configuredSessionNameSet()
+			}
+		}
+	})
+}
+
+func TestPrintToolTasksFiltersToSession(t *testing.T) {
+	originalListSessions := listSessionsFn
+	originalUserTasks := sessionUserTasksFn
+	originalAllTasks := sessionTasksFn
+	defer func() {
+		listSessionsFn = originalListSessions
+		sessionUserTasksFn = originalUserTasks
+		sessionTasksFn = originalAllTasks
+	}()
+
+	listSessionsFn = func() []string { return []string{"claude", "codex", "logs"} }
+	var asked []string
+	sessionUserTasksFn = func(sessionName string) ([]tmux.Task, error) {
+		asked = append(asked, sessionName)
+		return []tmux.Task{{PID: 10, PPID: 1, State: "S", Command: "make dev"}}, nil
+	}
+	sessionTasksFn = func(sessionName string) ([]tmux.Task, error) {
+		t.Fatalf("unexpected unfiltered lookup for %s", sessionName)
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if !printToolTasksForSocket(&buf, nil, taskListOptions{Session: "codex"}) {
+		t.Fatal("expected codex session to be found")
+	}
+	if len(asked) != 1 || asked[0] != "codex" {
+		t.Fatalf("expected only codex to be inspected, got %v", asked)
+	}
+	if contains(buf.String(), "claude:") {
+		t.Fatalf("expected other sessions filtered out, got: %s", buf.String())
+	}
+
+	// Non-tool sessions can be inspected when named explicitly.
+	buf.Reset()
+	if !printToolTasksForSocket(&buf, nil, taskListOptions{Session: "logs"}) {
+		t.Fatal("expected logs session to be found")
+	}
+
+	buf.Reset()
+	if printToolTasksForSocket(&buf, nil, taskListOptions{Session: "ghost"}) {
+		t.Fatal("expected missing session to report not found")
+	}
+}
+
+func TestPrintToolTasksAllUsesUnfilteredTasks(t *testing.T) {
+	originalListSessions := listSessionsFn
+	originalUserTasks := sessionUserTasksFn
+	originalAllTasks := sessionTasksFn
+	defer func() {
+		listSessionsFn = originalListSessions
+		sessionUserTasksFn = originalUserTasks
+		sessionTasksFn = originalAllTasks
+	}()
+
+	listSessionsFn = func() []string { return []string{"claude"} }
+	sessionUserTasksFn = func(sessionName string) ([]tmux.Task, error) {
+		t.Fatal("expected --all to skip the user task filter")
+		return nil, nil
+	}
+	sessionTasksFn = func(sessionName string) ([]tmux.Task, error) {
+		return []tmux.Task{{PID: 11, PPID: 1, State: "S", Command: "gopls"}}, nil
+	}
+
+	var buf bytes.Buffer
+	printToolTasksForSocket(&buf, nil, taskListOptions{Session: "claude", All: true})
+	if !contains(buf.String(), "cmd=gopls") {
+		t.Fatalf("expected unfiltered task output, got: %s", buf.String())
+	}
+}
+
+func TestParseTaskListArgs(t *testing.T) {
+	opts, err := parseTaskListArgs([]string{"--session", "codex-2", "--all"})
+	if err != nil {
+		t.Fatalf("parseTaskListArgs returned error: %v", err)
+	}
+	if opts.Session != "codex-2" || !opts.All {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+	if _, err := parseTaskListArgs([]string{"extra"}); err == nil {
+		t.Fatal("expected error for positional argument")
+	}
+	if _, err := parseTaskListArgs([]string{"--session", ""}); err == nil || !strings.Contains(err.Error(), "--session requires a session name") {
+		t.Fatalf("expected clear error for empty --session, got %v", err)
+	}
+	if _, err := parseTaskListArgs([]string{"--session"}); err == nil {
+		t.Fatal("expected error for --session without a value")
+	}
+	opts, err = parseTaskListArgs([]string{"--session", "logs", "--json"})
+	if err != nil || !opts.JSON || opts.Session != "logs" {
+		t.Fatalf("parseTaskListArgs(--json) = %+v, %v", opts, err)
+	}
+}
+
+func TestPrintToolTasksJSONAppliesSessionFilter(t *testing.T) {
+	originalListSessions := listSessionsFn
+	originalUserTasks := sessionUserTasksFn
+	defer func() {
+		listSessionsFn = originalListSessions
+		sessionUserTasksFn = originalUserTasks
+	}()
+
+	listSessionsFn = func() []string { return []string{"claude", "codex", "logs"} }
+	sessionUserTasksFn = func(sessionName string) ([]tmux.Task, error) {
+		if sessionName == "codex" {
+			return nil, errors.New("ps failed")
+		}
+		return []tmux.Task{{PID: 10, PPID: 1, State: "S", Command: "tail -f app.log"}}, nil
+	}
+
+	var buf bytes.Buffer
+	if !printToolTasksForSocket(&buf, nil, taskListOptions{Session: "logs", JSON: true}) {
+		t.Fatal("expected logs session to be found")
+	}
+	var entries []taskListEntry
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.String())
+	}
+	if len(entries) != 1 || entries[0].Session != "logs" {
+		t.Fatalf("expected only the logs session, got %+v", entries)
+	}
+	if len(entries[0].Tasks) != 1 || entries[0].Tasks[0].Command != "tail -f app.log" || entries[0].Tasks[0].PID != 10 {
+		t.Fatalf("unexpected tasks: %+v", entries[0].Tasks)
+	}
+
+	buf.Reset()
+	if !printToolTasksForSocket(&buf, nil, taskListOptions{JSON: true}) {
+		t.Fatal("expected tool sessions to be found")
+	}
+	entries = nil
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.String())
+	}
+	if len(entries) != 2 || entries[0].Session != "claude" || entries[1].Session != "codex" {
+		t.Fatalf("expected claude and codex without the session filter, got %+v", entries)
+	}
+	if entries[1].Error != "ps failed" || entries[1].Tasks == nil {
+		t.Fatalf("expected codex error with empty task list, got %+v", entries[1])
+	}
+
+	buf.Reset()
+	if printToolTasksForSocket(&buf, nil, taskListOptions{Session: "ghost", JSON: true}) {
+		t.Fatal("expected missing session to report not found")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("expected no output for a missing session, got %q", buf.String())
+	}
+}
+
+func TestPrintTaskPatternsListsBuiltinAndUser(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	opts, err := parseTaskListArgs([]string{"--list-patterns"})
+	if err != nil || !opts.ListPatterns {
+		t.Fatalf("parseTaskListArgs(--list-patterns) = %+v, %v", opts, err)
+	}
+
+	var buf bytes.Buffer
+	printTaskPatterns(&buf, config.TaskPatterns{Noise: []string{"tail -f"}})
+	out := buf.String()
+	for _, want := range []string{
+		"Built-in noise:\n  bin:claude\n",
+		"  fork-ts-checker-webpack-plugin\n",
+		"User noise (/home/me/.config/pocketbot/task-patterns.yaml):\n  tail -f\n",
+		"User highlight:\n  (none)\n",
+	} {
+		if !contains(out, want) {
+			t.Fatalf("expected %q in output, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrintSessionsTableColumns(t *testing.T) {
+	originalDetailed := listDetailedFn
+	originalTool := getSessionToolFn
+	originalCwd := getSessionCwdFn
+	originalTasks := sessionUserTasksFn
+	defer func() {
+		listDetailedFn = originalDetailed
+		getSessionToolFn = originalTool
+		getSessionCwdFn = originalCwd
+		sessionUserTasksFn = originalTasks
+	}()
+
+	now := time.Unix(1_700_000_000, 0)
+	listDetailedFn = func() ([]tmux.SessionInfo, error) {
+		return []tmux.SessionInfo{
+			{Name: "notes", Created: now.Add(-40 * time.Second), LastActivity: now.Add(-time.Minute)},
+			{Name: "codex-2", Created: now.Add(-(26*time.Hour + 5*time.Minute)), LastActivity: now.Add(-time.Second), Attached: 1},
+			{Name: "api", Created: now.Add(-(2*time.Hour + 5*time.Minute)), LastActivity: now.Add(-time.Hour)},
+		}, nil
+	}
+	getSessionToolFn = func(name string) string {
+		if name == "api" {
+			return "claude"
+		}
+		return ""
+	}
+	getSessionCwdFn = func(name string) string {
+		if name == "notes" {
+			return ""
+		}
+		return "/home/me/src/pocketbot"
+	}
+	sessionUserTasksFn = func(name string) ([]tmux.Task, error) {
+		if name == "codex-2" {
+			return []tmux.Task{{PID: 1}, {PID: 2}}, nil
+		}
+		if name == "notes" {
+			return nil, fmt.Errorf("ps failed")
+		}
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := printSessionsTable(&buf, nil, now); err != nil {
+		t.Fatalf("printSessionsTable returned error: %v", err)
+	}
+	var rows []string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		rows = append(rows, strings.Join(strings.Fields(line), " "))
+	}
+	want := []string{
+		"NAME TOOL REPO UPTIME STATUS TASKS",
+		"api claude pocketbot 2h05m idle 0",
+		"codex-2 codex pocketbot 1d2h active, attached 2",
+		"notes - - 40s idle -",
+	}
+	if strings.Join(rows, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected table:\n%s", buf.String())
+	}
+}
+
+func TestCleanDeadSessionsKillsOnlyDeadOrZombieSessions(t *testing.T) {
+	originalList := listSessionsFn
+	originalAlive := sessionAliveFn
+	originalZombies := zombieSessionsFn
+	originalKill := killSessionFn
+	originalTool := getSessionToolFn
+	originalCwd := getSessionCwdFn
+	defer func() {
+		listSessionsFn = originalList
+		sessionAliveFn = originalAlive
+		zombieSessionsFn = originalZombies
+		killSessionFn = originalKill
+		getSessionToolFn = originalTool
+		getSessionCwdFn = originalCwd
+	}()
+
+	cwd := t.TempDir()
+	if err := writeDirLock(cwd, "claude", "claude"); err != nil {
+		t.Fatalf("writeDirLock: %v", err)
+	}
+	listSessionsFn = func() []string { return []string{"codex", "claude", "api", "stuck"} }
+	sessionAliveFn = func(name string) bool { return name != "claude" && name != "api" }
+	zombieSessionsFn = func(names []string) (map[string]bool, error) {
+		return map[string]bool{"stuck": true}, nil
+	}
+	getSessionToolFn = func(name string) string { return name }
+	getSessionCwdFn = func(name string) string { return cwd }
+	var killed []string
+	killSessionFn = func(name string, opts ...tmux.KillOptions) error {
+		killed = append(killed, name)
+		if name == "api" {
+			return errors.New("no such session")
+		}
+		return nil
+	}
+
+	var out bytes.Buffer
+	n, err := cleanDeadSessions(&out, nil)
+	if err == nil || !contains(err.Error(), "api: no such session") {
+		t.Fatalf("expected the api kill failure to be reported, got %v", err)
+	}
+	if n != 2 || strings.Join(killed, ",") != "api,claude,stuck" {
+		t.Fatalf("cleaned %d, kill calls %v", n, killed)
+	}
+	want := "killed claude\nkilled stuck\ncleaned 2 of 4 sessions\n"
+	if out.String() != want {
+		t.Fatalf("output = %q, want %q", out.String(), want)
+	}
+	if locked := readDirLock(cwd, "claude"); locked != "" {
+		t.Fatalf("expected claude's launch lock released, still held by %q", locked)
+	}
+}
+
+func TestListSessionsJSON(t *testing.T) {
+	originalList := listSessionsFn
+	originalOpts := getSessionPBOptionsFn
+	originalAlive := sessionAliveFn
+	originalTasks := sessionUserTasksFn
+	originalLoad := loadConfigFn
+	defer func() {
+		listSessionsFn = originalList
+		getSessionPBOptionsFn = originalOpts
+		sessionAliveFn = originalAlive
+		sessionUserTasksFn = originalTasks
+		loadConfigFn = originalLoad
+	}()
+
+	listSessionsFn = func() []string { return []string{"codex-2", "api"} }
+	getSessionPBOptionsFn = func(name string) (map[string]string, error) {
+		opts := map[string]string{"@pb_cwd": "/src/" + name}
+		if name == "codex-2" {
+			opts["@pb_yolo"] = "1"
+		}
+		return opts, nil
+	}
+	sessionAliveFn = func(name string) bool { return name == "codex-2" }
+	sessionUserTasksFn = func(name string) ([]tmux.Task, error) {
+		if name == "codex-2" {
+			return []tmux.Task{{PID: 1}, {PID: 2}}, nil
+		}
+		return nil, fmt.Errorf("ps failed")
+	}
+	loadConfigFn = func() (*config.Config, error) {
+		cfg := config.DefaultConfig()
+		cfg.Sessions = []config.SessionConfig{
+			{Name: "api", Command: "go run .", Key: "a"},
+			{Name: "logs", Command: "tail -f log", Key: "l"},
+			{Name: "claude-review", Command: "claude", Key: "v"},
+		}
+		return cfg, nil
+	}
+
+	var buf bytes.Buffer
+	if err := listSessionsJSON(&buf); err != nil {
+		t.Fatalf("listSessionsJSON: %v", err)
+	}
+	var got []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	want := []map[string]any{
+		// api's pane is dead, so it is listed but not running.
+		{"name": "api", "tool": "", "cwd": "/src/api", "yolo": false, "running": false, "task_count": 0.0},
+		{"name": "codex-2", "tool": "codex", "cwd": "/src/codex-2", "yolo": true, "running": true, "task_count": 2.0},
+		{"name": "logs", "tool": "", "cwd": "", "yolo": false, "running": false, "task_count": 0.0},
+		{"name": "claude-review", "tool": "claude", "cwd": "", "yolo": false, "running": false, "task_count": 0.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	buf.Reset()
+	if err := listSessionsTable(&buf); err != nil {
+		t.Fatalf("listSessionsTable: %v", err)
+	}
+	rows := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(rows) != 5 || strings.Join(strings.Fields(rows[2]), " ") != "codex-2 codex /src/codex-2 true true 2" {
+		t.Errorf("expected a table, got:\n%s", buf.String())
+	}
+}
+
+func TestParseListArgs(t *testing.T) {
+	for args, want := range map[string]bool{"": true, "--json": true, "--json=false": false} {
+		opts, err := parseListArgs(strings.Fields(args))
+		if err != nil || opts.JSON != want {
+			t.Errorf("parseListArgs(%q) = %+v, %v; want JSON=%v", args, opts, err, want)
+		}
+	}
+	if _, err := parseListArgs([]string{"extra"}); err == nil {
+		t.Error("expected an error for a positional argument")
+	}
+}
+
+func TestPrintSessionsTableEmpty(t *testing.T) {
+	originalDetailed := listDetailedFn
+	defer func() { listDetailedFn = originalDetailed }()
+	listDetailedFn = func() ([]tmux.SessionInfo, error) { return nil, nil }
+
+	var buf bytes.Buffer
+	if err := printSessionsTable(&buf, nil, time.Now()); err != nil {
+		t.Fatalf("printSessionsTable returned error: %v", err)
+	}
+	if buf.String() != "No sessions are running.\n" {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestParseSessionsArgs(t *testing.T) {
+	opts, err := parseSessionsArgs([]string{"--raw"})
+	if err != nil || !opts.Raw {
+		t.Fatalf("parseSessionsArgs(--raw) = %+v, %v", opts, err)
+	}
+	if _, err := parseSessionsArgs([]string{"extra"}); err == nil {
+		t.Fatal("expected error for positional argument")
+	}
+}
+
+func TestStartingSessionTransitions(t *testing.T) {
+	originalExists := sessionExistsFn
+	originalCapture := capturePaneFn
+	defer func() {
+		sessionExistsFn = originalExists
+		capturePaneFn = originalCapture
+	}()
+
+	exists := map[string]bool{}
+	captureErr := errors.New("no pane yet")
+	sessionExistsFn = func(name string) bool { return exists[name] }
+	capturePaneFn = func(name string) (string, error) { return "", captureErr }
+
+	m := model{
+		config:      config.DefaultConfig(),
+		sessions:    map[string]*tmux.Session{},
+		bindings:    map[string]commandBinding{},
+		windowWidth: 80,
+	}
+	m.markStarting("codex")
+
+	// Not yet in the bindings, the row still shows as starting rather than
+	// "not running".
+	rows := strings.Join(m.detailedRows("codex", m.runningToolSessions("codex")), "\n")
+	if !contains(rows, "…starting") || contains(rows, "not running") {
+		t.Fatalf("expected starting badge, got: %s", rows)
+	}
+
+	now := time.Now()
+	m.settleStartingSessions(now)
+	if !m.isStarting("codex") {
+		t.Fatal("expected codex to stay starting before tmux reports it")
+	}
+	exists["codex"] = true
+	m.settleStartingSessions(now)
+	if !m.isStarting("codex") {
+		t.Fatal("expected codex to stay starting until a capture succeeds")
+	}
+	captureErr = nil
+	m.settleStartingSessions(now)
+	if m.isStarting("codex") {
+		t.Fatal("expected starting state cleared after first capture")
+	}
+	if rows := strings.Join(m.detailedRows("codex", m.runningToolSessions("codex")), "\n"); contains(rows, "starting") {
+		t.Fatalf("expected starting badge gone, got: %s", rows)
+	}
+
+	// Sessions that never come up stop showing as starting after the timeout.
+	m.markStarting("claude")
+	m.settleStartingSessions(time.Now().Add(startingTimeout))
+	if m.isStarting("claude") {
+		t.Fatal("expected starting state to expire")
+	}
+}
+
+func TestUnseenOutputTransitions(t *testing.T) {
+	originalCapture := sessionCaptureFn
+	originalReset := resetBaselineFn
+	originalSetSeen := setSeenHashFn
+	defer func() {
+		sessionCaptureFn = originalCapture
+		resetBaselineFn = originalReset
+		setSeenHashFn = originalSetSeen
+	}()
+
+	claude := tmux.NewSession("claude", "")
+	codex := tmux.NewSession("codex", "")
+	panes := map[*tmux.Session]string{claude: "> done\n", codex: "idle\n"}
+	seenHashes := map[string]string{}
+	sessionCaptureFn = func(s *tmux.Session) string { return panes[s] }
+	resetBaselineFn = func(s *tmux.Session) (string, error) { return panes[s], nil }
+	setSeenHashFn = func(name, hash string) error {
+		seenHashes[name] = hash
+		return nil
+	}
+
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{"claude": claude, "codex": codex},
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Running: true},
+			"codex":  {SessionName: "codex", Running: true},
+		},
+	}
+
+	// Never-attached sessions have no recorded hash and are not marked.
+	m.refreshUnseenOutput()
+	if len(m.unseenOutput) != 0 {
+		t.Fatalf("expected no unseen output before first attach, got %v", m.unseenOutput)
+	}
+
+	// Attaching records what is on screen, in tmux and on the binding.
+	m.markSessionSeen("claude")
+	if seenHashes["claude"] == "" || m.bindings["claude"].SeenHash != seenHashes["claude"] {
+		t.Fatalf("expected recorded seen hash, got %q", m.bindings["claude"].SeenHash)
+	}
+	m.refreshUnseenOutput()
+	if m.unseenOutput["claude"] {
+		t.Fatal("expected claude to be seen right after attach")
+	}
+
+	// New output after attach marks the session unseen, even if idle.
+	panes[claude] = "> done\nAll tests passed\n"
+	m.refreshUnseenOutput()
+	if !m.unseenOutput["claude"] {
+		t.Fatal("expected claude to have unseen output")
+	}
+	rows := m.detailedRows("claude", []string{"claude"})
+	if len(rows) != 1 || !contains(rows[0], "●new") {
+		t.Fatalf("expected new badge in row, got: %v", rows)
+	}
+
+	// Attaching again clears the badge.
+	m.markSessionSeen("claude")
+	if m.unseenOutput["claude"] {
+		t.Fatal("expected attach to clear unseen badge")
+	}
+	m.refreshUnseenOutput()
+	if m.unseenOutput["claude"] {
+		t.Fatal("expected claude seen after re-attach")
+	}
+}
+
+func TestPreparePickerOrdersEntriesBySessionName(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"codex-3": {SessionName: "codex-3", Running: true, Tool: "codex"},
+			"codex":   {SessionName: "codex", Running: true, Tool: "codex"},
+			"codex-2": {SessionName: "codex-2", Running: true, Tool: "codex"},
+		},
+	}
+
+	for i := 0; i < 5; i++ {
+		got := m.preparePicker("codex", modePickAttach).pickerEntries
+		want := []pickerEntry{
+			{key: "a", session: "codex"},
+			{key: "b", session: "codex-2"},
+			{key: "c", session: "codex-3"},
+		}
+		if len(got) != len(want) {
+			t.Fatalf("expected %d entries, got %v", len(want), got)
+		}
+		for j := range want {
+			if got[j] != want[j] {
+				t.Fatalf("entry %d = %+v, want %+v", j, got[j], want[j])
+			}
+		}
+	}
+}
+
+func TestPreparePickerAssignsKeysAThroughZ(t *testing.T) {
+	bindings := map[string]commandBinding{}
+	for i := 0; i < 26; i++ {
+		name := fmt.Sprintf("claude-%02d", i+1)
+		bindings[name] = commandBinding{SessionName: name, Running: true, Tool: "claude"}
+	}
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: bindings,
+	}
+
+	entries := m.preparePicker("claude", modePickKill).pickerEntries
+	if len(entries) != 26 {
+		t.Fatalf("expected 26 entries, got %d", len(entries))
+	}
+	for i, entry := range entries {
+		wantKey := string(rune('a' + i))
+		wantSession := fmt.Sprintf("claude-%02d", i+1)
+		if entry.key != wantKey || entry.session != wantSession {
+			t.Fatalf("entry %d = %+v, want key %q session %q", i, entry, wantKey, wantSession)
+		}
+	}
+	if target, ok := m.preparePicker("claude", modePickKill).pickerTarget("z"); !ok || target != "claude-26" {
+		t.Fatalf("expected z to select claude-26, got %q (ok=%v)", target, ok)
+	}
+}
+
+func TestParseNewArgs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    newSessionOptions
+		wantErr bool
+	}{
+		{args: []string{"claude"}, want: newSessionOptions{Tool: "claude"}},
+		{args: []string{"codex", "--fresh"}, want: newSessionOptions{Tool: "codex", Fresh: true}},
+		{args: []string{"--fresh", "cursor"}, want: newSessionOptions{Tool: "cursor", Fresh: true}},
+		{args: []string{}, wantErr: true},
+		{args: []string{"vim"}, wantErr: true},
+		{args: []string{"claude", "codex"}, wantErr: true},
+		{args: []string{"claude", "--bogus"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseNewArgs(nil, tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("parseNewArgs(%v) expected error, got %+v", tt.args, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("parseNewArgs(%v) returned error: %v", tt.args, err)
+		}
+		if got != tt.want {
+			t.Fatalf("parseNewArgs(%v) = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestGlobalYoloToggleAndTitle(t *testing.T) {
+	originalSet := setGlobalYoloFn
+	defer func() { setGlobalYoloFn = originalSet }()
+	var saved []bool
+	setGlobalYoloFn = func(enabled bool) error {
+		saved = append(saved, enabled)
+		return nil
+	}
+
+	m := model{
+		config:    config.DefaultConfig(),
+		sessions:  map[string]*tmux.Session{},
+		bindings:  map[string]commandBinding{},
+		viewState: viewHome,
+		mode:      modeHome,
+	}
+	if contains(m.View(), "(global yolo)") {
+		t.Fatal("did not expect global yolo marker before toggling")
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'Y'}})
+	m = updated.(model)
+	if !m.globalYolo {
+		t.Fatal("expected Y to enable global yolo")
+	}
+	firstLine := strings.SplitN(m.View(), "\n", 2)[0]
+	if !contains(firstLine, "(global yolo)") {
+		t.Fatalf("expected title to show global yolo, got %q", firstLine)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'Y'}})
+	m = updated.(model)
+	if m.globalYolo {
+		t.Fatal("expected second Y to disable global yolo")
+	}
+	if len(saved) != 2 || !saved[0] || saved[1] {
+		t.Fatalf("expected @pb_global_yolo writes [true false], got %v", saved)
+	}
+}
+
+func TestGlobalYoloAppliesToNewSessions(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := model{config: cfg, globalYolo: true, newToolAuto: true}
+
+	opts := m.launchOptions("codex")
+	if !opts.Yolo || opts.Auto {
+		t.Fatalf("expected global yolo to force yolo over auto, got %+v", opts)
+	}
+	if got := buildLaunchCommand(cfg, "codex", cfg.Tool("codex").Command, opts); got != "codex --yolo resume --last || codex --yolo" {
+		t.Fatalf("unexpected codex launch command: %q", got)
+	}
+	if got := buildLaunchCommand(cfg, "claude", cfg.Tool("claude").Command, m.launchOptions("claude")); !contains(got, "--dangerously-skip-permissions") {
+		t.Fatalf("expected claude yolo launch command, got %q", got)
+	}
+	if opts := m.launchOptions(scratchTool); opts.Yolo {
+		t.Fatal("scratch shells have no yolo mode")
+	}
+
+	m.globalYolo = false
+	m.newToolAuto = false
+	if opts := m.launchOptions("codex"); opts.Yolo {
+		t.Fatal("expected no yolo without global or per-session toggle")
+	}
+}
+
+func TestGlobalYoloBlockedWhenReadOnly(t *testing.T) {
+	originalReadOnly := readOnlyFn
+	originalSet := setGlobalYoloFn
+	defer func() {
+		readOnlyFn = originalReadOnly
+		setGlobalYoloFn = originalSet
+	}()
+	readOnlyFn = func() bool { return true }
+	setGlobalYoloFn = func(bool) error {
+		t.Fatal("unexpected write while read-only")
+		return nil
+	}
+
+	m, _ := model{config: config.DefaultConfig(), mode: modeHome}.toggleGlobalYolo()
+	if m.globalYolo || !contains(m.homeNotice, "read-only") {
+		t.Fatalf("expected read-only notice, got globalYolo=%v notice=%q", m.globalYolo, m.homeNotice)
+	}
+}
+
+func TestCustomToolHelpers(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tools = append(cfg.Tools,
+		config.ToolConfig{Name: "aider", Command: "aider --restore-chat-history", Key: "a", Enabled: true, Icon: "🟡", YoloFlag: "--yes-always", FallbackSuffix: "--restore-chat-history"},
+		config.ToolConfig{Name: "gemini", Command: "gemini", Key: "g"},
+	)
+	cfg.Tool("cursor").YoloFlag = "--force"
+	m := model{config: cfg}
+
+	if got := normalizeToolName(cfg, "aider"); got != "aider" {
+		t.Errorf("normalizeToolName(aider) = %q", got)
+	}
+	if got := normalizeToolName(cfg, "aiderx"); got != "" {
+		t.Errorf("normalizeToolName(aiderx) = %q, want empty", got)
+	}
+	for name, want := range map[string]string{"aider": "aider", "aider-2": "aider", "gemini-pocketbot": "gemini", "aiderx": ""} {
+		if got := toolFromSessionName(cfg, name); got != want {
+			t.Errorf("toolFromSessionName(%q) = %q, want %q", name, got, want)
+		}
+	}
+	if got := allTools(cfg); !slices.Equal(got, []string{"claude", "codex", "cursor", "aider", "gemini", scratchTool}) {
+		t.Errorf("allTools() = %v", got)
+	}
+	if m.commandForTool("aider") != "aider --restore-chat-history" || m.keyForTool("aider") != "a" || m.toolIcon("aider") != "🟡" {
+		t.Errorf("aider command/key/icon = %q %q %q", m.commandForTool("aider"), m.keyForTool("aider"), m.toolIcon("aider"))
+	}
+	if !m.toolEnabled("aider") || m.toolEnabled("gemini") {
+		t.Errorf("enabled: aider=%v gemini=%v, want true false", m.toolEnabled("aider"), m.toolEnabled("gemini"))
+	}
+	if m.toolForKey("a") != "aider" || m.toolForKey("g") != "" || !m.disabledToolKey("g") {
+		t.Errorf("toolForKey(a)=%q toolForKey(g)=%q disabledToolKey(g)=%v", m.toolForKey("a"), m.toolForKey("g"), m.disabledToolKey("g"))
+	}
+
+	for _, tc := range []struct {
+		opts LaunchOptions
+		want string
+	}{
+		{LaunchOptions{}, "aider --restore-chat-history || aider"},
+		{LaunchOptions{Fresh: true}, "aider"},
+		{LaunchOptions{Yolo: true}, "aider --yes-always --restore-chat-history || aider --yes-always"},
+		{LaunchOptions{Fresh: true, Yolo: true}, "aider --yes-always"},
+	} {
+		if got := buildLaunchCommand(cfg, "aider", cfg.Tool("aider").Command, tc.opts); got != tc.want {
+			t.Errorf("buildLaunchCommand(aider, %+v) = %q, want %q", tc.opts, got, tc.want)
+		}
+	}
+	if got := buildLaunchCommand(cfg, "gemini", "gemini", LaunchOptions{Yolo: true}); got != "gemini" {
+		t.Errorf("tool without yolo_flag: got %q, want unchanged", got)
+	}
+	if got := buildLaunchCommand(cfg, "cursor", "agent resume", LaunchOptions{Yolo: true}); got != "agent --force resume" {
+		t.Errorf("cursor with yolo_flag: got %q, want agent --force resume", got)
+	}
+}
+
+func TestViewHomeShowsCustomToolRows(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tools = append(cfg.Tools, config.ToolConfig{Name: "aider", Command: "aider", Key: "a", Enabled: true})
+	m := model{
+		config: cfg,
+		bindings: map[string]commandBinding{
+			"claude":  {SessionName: "claude", Running: true, Tool: "claude"},
+			"aider-2": {SessionName: "aider-2", Running: true, Tool: "aider"},
+		},
+		sessions:    map[string]*tmux.Session{},
+		windowWidth: 100,
+		viewState:   viewHome,
+	}
+	view := stripANSI(m.viewHome())
+	if !contains(view, "(a) aider-2") {
+		t.Fatalf("expected an aider row, got:\n%s", view)
+	}
+	if strings.Index(view, "(u) 🔵 cursor") > strings.Index(view, "(a) aider-2") {
+		t.Fatalf("expected configured tools after the built-ins, got:\n%s", view)
+	}
+
+	m.mode = modeKillTool
+	if view := stripANSI(m.viewHome()); !contains(view, "a kill aider-2") {
+		t.Fatalf("expected aider in the kill menu, got:\n%s", view)
+	}
+	m.mode = modeNewTool
+	m.getwd = func() (string, error) { return "/src/app", nil }
+	if view := stripANSI(m.viewHome()); !contains(view, "a new aider") {
+		t.Fatalf("expected aider in the new menu, got:\n%s", view)
+	}
+}
+
+func TestBuildLaunchCommandComposesTransforms(t *testing.T) {
+	cfg := config.DefaultConfig()
+	tests := []struct {
+		name    string
+		tool    string
+		command string
+		opts    LaunchOptions
+		want    string
+	}{
+		{
+			name:    "claude default keeps resume fallback",
+			tool:    "claude",
+			command: cfg.Tool("claude").Command,
+			want:    "claude --continue --permission-mode acceptEdits || claude --permission-mode acceptEdits",
+		},
+		{
+			name:    "claude fresh only",
+			tool:    "claude",
+			command: cfg.Tool("claude").Command,
+			opts:    LaunchOptions{Fresh: true},
+			want:    "claude --permission-mode acceptEdits",
+		},
+		{
+			name:    "claude yolo only",
+			tool:    "claude",
+			command: cfg.Tool("claude").Command,
+			opts:    LaunchOptions{Yolo: true},
+			want:    "claude --continue --dangerously-skip-permissions || claude --dangerously-skip-permissions",
+		},
+		{
+			name:    "claude yolo and fresh",
+			tool:    "claude",
+			command: cfg.Tool("claude").Command,
+			opts:    LaunchOptions{Fresh: true, Yolo: true},
+			want:    "claude --dangerously-skip-permissions",
+		},
+		{
+			name:    "claude auto and fresh",
+			tool:    "claude",
+			command: cfg.Tool("claude").Command,
+			opts:    LaunchOptions{Fresh: true, Auto: true},
+			want:    "claude --permission-mode auto",
+		},
+		{
+			name:    "claude yolo wins over auto",
+			tool:    "claude",
+			command: cfg.Tool("claude").Command,
+			opts:    LaunchOptions{Auto: true, Yolo: true},
+			want:    "claude --continue --dangerously-skip-permissions || claude --dangerously-skip-permissions",
+		},
+		{
+			name:    "codex default keeps resume fallback",
+			tool:    "codex",
+			command: cfg.Tool("codex").Command,
+			want:    "codex resume --last || codex",
+		},
+		{
+			name:    "codex fresh only",
+			tool:    "codex",
+			command: cfg.Tool("codex").Command,
+			opts:    LaunchOptions{Fresh: true},
+			want:    "codex",
+		},
+		{
+			name:    "codex yolo only",
+			tool:    "codex",
+			command: cfg.Tool("codex").Command,
+			opts:    LaunchOptions{Yolo: true},
+			want:    "codex --yolo resume --last || codex --yolo",
+		},
+		{
+			name:    "codex yolo and fresh",
+			tool:    "codex",
+			command: cfg.Tool("codex").Command,
+			opts:    LaunchOptions{Fresh: true, Yolo: true},
+			want:    "codex --yolo",
+		},
+		{
+			name:    "codex auto and fresh",
+			tool:    "codex",
+			command: cfg.Tool("codex").Command,
+			opts:    LaunchOptions{Fresh: true, Auto: true},
+			want:    "codex --full-auto",
+		},
+		{
+			name:    "cursor default keeps resume fallback",
+			tool:    "cursor",
+			command: cfg.Tool("cursor").Command,
+			want:    "agent resume || agent",
+		},
+		{
+			name:    "cursor fresh only",
+			tool:    "cursor",
+			command: cfg.Tool("cursor").Command,
+			opts:    LaunchOptions{Fresh: true},
+			want:    "agent",
+		},
+		{
+			name:    "cursor yolo has no flag",
+			tool:    "cursor",
+			command: cfg.Tool("cursor").Command,
+			opts:    LaunchOptions{Yolo: true},
+			want:    "agent resume || agent",
+		},
+		{
+			name:    "cursor yolo and fresh",
+			tool:    "cursor",
+			command: cfg.Tool("cursor").Command,
+			opts:    LaunchOptions{Fresh: true, Yolo: true},
+			want:    "agent",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildLaunchCommand(nil, tt.tool, tt.command, tt.opts)
+			if got != tt.want {
+				t.Fatalf("buildLaunchCommand(%q, %q, %+v) = %q, want %q", tt.tool, tt.command, tt.opts, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoInputSavesMemo(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := model{
+		config:   cfg,
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"codex": {SessionName: "codex", Running: true, Tool: "codex", Memo: "auth refactor"},
+		},
+		mode: modePickMemo,
+		pickerEntries: []pickerEntry{
+			{key: "a", session: "codex"},
+		},
+	}
+
+	original := setSessionOptionFn
+	defer func() { setSessionOptionFn = original }()
+	saved := map[string]string{}
+	setSessionOptionFn = func(sessionName, option, value string) error {
+		saved[sessionName+" "+option] = value
+		return nil
+	}
+
+	m = m.beginMemoTarget("codex")
+	if m.mode != modeMemoInput {
+		t.Fatalf("expected modeMemoInput, got %v", m.mode)
+	}
+	if m.memoInput != "auth refactor" || m.memoCursor != len("auth refactor") {
+		t.Fatalf("expected existing memo pre-populated, got %q cursor %d", m.memoInput, m.memoCursor)
+	}
+
+	// "d" and other shortcut keys are text while typing a memo.
+	for _, msg := range []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune(" ")},
+		{Type: tea.KeyRunes, Runes: []rune("d")},
+		{Type: tea.KeyRunes, Runes: []rune("one")},
+	} {
+		updated, cmd := m.Update(msg)
+		if cmd != nil {
+			t.Fatal("typing in memo input should not quit")
+		}
+		m = updated.(model)
+	}
+	if m.memoInput != "auth refactor done" {
+		t.Fatalf("expected edited memo, got %q", m.memoInput)
+	}
+	if !contains(m.View(), "memo for codex") {
+		t.Fatalf("expected memo input view, got: %s", m.View())
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if m.mode != modeHome {
+		t.Fatalf("expected modeHome after saving memo, got %v", m.mode)
+	}
+	if got := saved["codex @pb_memo"]; got != "auth refactor done" {
+		t.Fatalf("expected memo written to @pb_memo, got %q", got)
+	}
+}
+
+func TestMemoInputEscCancels(t *testing.T) {
+	m := model{
+		config:     config.DefaultConfig(),
+		bindings:   map[string]commandBinding{},
+		mode:       modeMemoInput,
+		memoTarget: "claude",
+		memoInput:  "draft",
+		memoCursor: 5,
+	}
+
+	original := setSessionOptionFn
+	defer func() { setSessionOptionFn = original }()
+	setSessionOptionFn = func(sessionName, option, value string) error {
+		t.Fatal("esc should not save memo")
+		return nil
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(model)
+	if m.mode != modeHome || m.memoTarget != "" || m.memoInput != "" {
+		t.Fatalf("expected memo input reset on esc, got mode=%v target=%q input=%q", m.mode, m.memoTarget, m.memoInput)
+	}
+}
+
+func TestDetailedRowsShowsMemoBelowSession(t *testing.T) {
+	m := model{
+		config: config.DefaultConfig(),
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Cwd: "/repo", Running: true, Memo: "working on auth refactor"},
+			"codex":  {SessionName: "codex", Cwd: "/repo", Running: true},
+		},
+		sessions: map[string]*tmux.Session{},
+	}
+
+	rows := m.detailedRows("claude", []string{"claude"})
+	if len(rows) != 2 {
+		t.Fatalf("expected session row plus memo row, got %v", rows)
+	}
+	if !contains(rows[1], "  working on auth refactor") {
+		t.Fatalf("expected indented memo line, got %q", rows[1])
+	}
+
+	rows = m.detailedRows("codex", []string{"codex"})
+	if len(rows) != 1 {
+		t.Fatalf("expected no memo row without memo, got %v", rows)
+	}
+}
+
+func TestPrintDoctorReportsServerInfo(t *testing.T) {
+	original := getServerInfoFn
+	defer func() { getServerInfoFn = original }()
+	t.Setenv("HOME", t.TempDir())
+
+	getServerInfoFn = func() (tmux.ServerInfo, error) {
+		return tmux.ServerInfo{Version: "3.4", SocketPath: "/tmp/tmux-501/pocketbot", SessionCount: 2, ClientCount: 1}, nil
+	}
+	var buf bytes.Buffer
+	printDoctor(&buf)
+	out := buf.String()
+	if !contains(out, "server   ok       tmux 3.4 socket=/tmp/tmux-501/pocketbot sessions=2 clients=1") {
+		t.Fatalf("expected server info row, got:\n%s", out)
+	}
+	if !contains(out, "config   default") {
+		t.Fatalf("expected default config row, got:\n%s", out)
+	}
+
+	getServerInfoFn = func() (tmux.ServerInfo, error) {
+		return tmux.ServerInfo{}, errors.New("no server running")
+	}
+	buf.Reset()
+	printDoctor(&buf)
+	if !contains(buf.String(), "server   stopped") {
+		t.Fatalf("expected stopped server row, got:\n%s", buf.String())
+	}
+}
+
+func TestIdleColorFadesWithIdleDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		idleFor time.Duration
+		want    string
+	}{
+		{name: "just went idle", idleFor: 6 * time.Second, want: "#FFB000"},
+		{name: "recently idle", idleFor: 29 * time.Second, want: "#FFB000"},
+		{name: "cooling off", idleFor: 90 * time.Second, want: "#C8A96A"},
+		{name: "stale", idleFor: 10 * time.Minute, want: "#999999"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(idleColor(tt.idleFor)); got != tt.want {
+				t.Fatalf("idleColor(%v)=%s, want %s", tt.idleFor, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildFallbackCommand(t *testing.T) {
+	if got := buildFallbackCommand("aider --restore", "aider"); got != "aider --restore || aider" {
+		t.Fatalf("buildFallbackCommand = %q", got)
+	}
+}
+
+func TestFallbackCommandCustomSession(t *testing.T) {
+	sessions := []config.SessionConfig{
+		{Name: "aider", Command: "aider --restore-chat-history", FallbackCommand: "aider"},
+		{Name: "logs", Command: "tail -f log"},
+	}
+
+	if got := fallbackCommand(nil, "", "aider --restore-chat-history", sessions...); got != "aider --restore-chat-history || aider" {
+		t.Fatalf("custom fallback = %q", got)
+	}
+	if got := fallbackCommand(nil, "", "aider --other", sessions...); got != "aider --other" {
+		t.Fatalf("non-matching command should be unchanged, got %q", got)
+	}
+	if got := fallbackCommand(nil, "", "tail -f log", sessions...); got != "tail -f log" {
+		t.Fatalf("session without fallback should be unchanged, got %q", got)
+	}
+	if got := buildLaunchCommand(nil, "", "aider --restore-chat-history", LaunchOptions{}, sessions...); got != "aider --restore-chat-history || aider" {
+		t.Fatalf("buildLaunchCommand should apply custom fallback, got %q", got)
+	}
+}
+
+func TestPrintConfigTableValid(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tool("cursor").Enabled = false
+	cfg.Sessions = []config.SessionConfig{{Name: "logs", Command: "tail -f log", Key: "l"}}
+
+	var buf bytes.Buffer
+	if !printConfigTable(&buf, cfg) {
+		t.Fatalf("expected valid config, got:\n%s", buf.String())
+	}
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("expected header + 4 rows, got %d:\n%s", len(lines), buf.String())
+	}
+	if fields := strings.Fields(lines[0]); strings.Join(fields, " ") != "NAME COMMAND KEY ENABLED" {
+		t.Fatalf("unexpected header %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "claude ") || !strings.HasSuffix(lines[1], "yes") {
+		t.Fatalf("unexpected claude row %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[3], "cursor ") || !strings.HasSuffix(lines[3], "[disabled]") {
+		t.Fatalf("expected disabled cursor row, got %q", lines[3])
+	}
+	if !strings.Contains(lines[4], "tail -f log") {
+		t.Fatalf("expected custom session row, got %q", lines[4])
+	}
+	if strings.Contains(buf.String(), "[conflict]") {
+		t.Fatalf("did not expect conflicts:\n%s", buf.String())
+	}
+}
+
+func TestPrintConfigTableHighlightsKeyConflicts(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Sessions = []config.SessionConfig{{Name: "cargo", Command: "cargo watch", Key: "c"}}
+
+	var buf bytes.Buffer
+	if printConfigTable(&buf, cfg) {
+		t.Fatal("expected conflicting keys to fail validation")
+	}
+	out := buf.String()
+	if strings.Count(out, "c [conflict]") != 2 {
+		t.Fatalf("expected both claude and cargo keys marked, got:\n%s", out)
+	}
+	if !strings.Contains(out, "Error: duplicate key") {
+		t.Fatalf("expected validation error in output, got:\n%s", out)
+	}
+}
+
+func TestPrintConfigTableIgnoresDisabledKeyConflicts(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tool("codex").Enabled = false
+	cfg.Sessions = []config.SessionConfig{{Name: "xterm", Command: "xterm", Key: "x"}}
+
+	var buf bytes.Buffer
+	if !printConfigTable(&buf, cfg) {
+		t.Fatalf("disabled tool should not conflict:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "[conflict]") {
+		t.Fatalf("did not expect conflict marker:\n%s", buf.String())
+	}
+}
+
+func TestTestConfigReadsProjectConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	project := filepath.Join(home, "app")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, ".pocketbot.yaml"),
+		[]byte("sessions:\n  - {name: cargo, command: cargo watch, key: c}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(project)
+
+	cfg, err := loadConfigUnvalidated()
+	if err != nil {
+		t.Fatalf("loadConfigUnvalidated: %v", err)
+	}
+	var buf bytes.Buffer
+	if printConfigTable(&buf, cfg) {
+		t.Fatalf("expected the project session's key conflict to fail:\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "cargo") {
+		t.Fatalf("expected the project session in the table, got:\n%s", buf.String())
+	}
+}
+
+func TestSessionImportance(t *testing.T) {
+	now := time.Now()
+	active := map[*tmux.Session]bool{}
+	origActive := sessionActiveFn
+	sessionActiveFn = func(s *tmux.Session) bool { return active[s] }
+	defer func() { sessionActiveFn = origActive }()
+
+	m := model{
+		config:     config.DefaultConfig(),
+		sessions:   map[string]*tmux.Session{},
+		bindings:   map[string]commandBinding{},
+		taskCounts: map[string]int{},
+	}
+	for _, name := range []string{"plain", "busy", "tasks", "fresh", "stale", "everything"} {
+		m.sessions[name] = tmux.NewSession(name, "")
+		m.bindings[name] = commandBinding{SessionName: name, Running: true, Created: now.Add(-48 * time.Hour)}
+	}
+	active[m.sessions["busy"]] = true
+	active[m.sessions["everything"]] = true
+	m.taskCounts["tasks"] = 2
+	m.taskCounts["everything"] = 1
+	fresh := m.bindings["fresh"]
+	fresh.Created = now.Add(-10 * time.Minute)
+	m.bindings["fresh"] = fresh
+	stale := m.bindings["stale"]
+	stale.LastAttached = now.Add(-30 * time.Hour)
+	m.bindings["stale"] = stale
+	everything := m.bindings["everything"]
+	everything.Created = now.Add(-30 * time.Minute)
+	everything.LastAttached = now.Add(-time.Minute)
+	m.bindings["everything"] = everything
+
+	want := map[string]int{"plain": 0, "busy": 10, "tasks": 5, "fresh": 3, "stale": -5, "everything": 18}
+	for name, score := range want {
+		if got := m.sessionImportance(name); got != score {
+			t.Errorf("sessionImportance(%q) = %d, want %d", name, got, score)
+		}
+	}
+	if got := m.sessionImportance("unknown"); got != 0 {
+		t.Errorf("expected unknown session to score 0, got %d", got)
+	}
+}
+
+func TestPreparePickerOrdersByImportance(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+	}
+	for _, name := range []string{"codex", "codex-2", "codex-3"} {
+		m.sessions[name] = tmux.NewSession(name, "")
+		m.bindings[name] = commandBinding{SessionName: name, Tool: "codex", Running: true}
+	}
+	origActive := sessionActiveFn
+	sessionActiveFn = func(s *tmux.Session) bool { return s == m.sessions["codex-3"] }
+	defer func() { sessionActiveFn = origActive }()
+
+	m = m.preparePicker("codex", modePickAttach)
+	var order []string
+	for _, entry := range m.pickerEntries {
+		order = append(order, entry.key+"="+entry.session)
+	}
+	if got := strings.Join(order, ","); got != "a=codex-3,b=codex,c=codex-2" {
+		t.Fatalf("expected active session first with name order after, got %s", got)
+	}
+
+	// Home rows keep name order but hint the same picker keys.
+	m.mode = modeHome
+	rows := strings.Join(m.detailedRows("codex", m.runningToolSessions("codex")), "\n")
+	if !contains(rows, "(x a) 🟢 codex-3") || !contains(rows, "(x b) 🟢 codex ") {
+		t.Fatalf("expected row hints to match picker keys, got:\n%s", rows)
+	}
+}
+
+func TestDetailedRowsCollapsesSessionsIdlePastThreshold(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.HideIdleAfter = 10 * time.Minute
+	idleByName := map[string]time.Duration{
+		"codex":   time.Minute,
+		"codex-2": 15 * time.Minute,
+		"codex-3": time.Hour,
+	}
+	names := []string{"codex", "codex-2", "codex-3"}
+	m := model{
+		config:   cfg,
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+	}
+	idle := map[*tmux.Session]time.Duration{}
+	for _, name := range names {
+		sess := tmux.NewSession(name, cfg.Tool("codex").Command)
+		m.sessions[name] = sess
+		idle[sess] = idleByName[name]
+	}
+	origIdle := sessionIdleFn
+	sessionIdleFn = func(s *tmux.Session) (time.Duration, bool) {
+		d, ok := idle[s]
+		return d, ok
+	}
+	defer func() { sessionIdleFn = origIdle }()
+
+	out := strings.Join(m.detailedRows("codex", names), "\n")
+	if !strings.Contains(out, "(x a) 🟢 codex ") {
+		t.Fatalf("expected recently idle session row, got:\n%s", out)
+	}
+	if strings.Contains(out, "codex-2") || strings.Contains(out, "codex-3") {
+		t.Fatalf("expected long-idle sessions collapsed, got:\n%s", out)
+	}
+	if !strings.Contains(out, "+2 idle") {
+		t.Fatalf("expected +2 idle summary, got:\n%s", out)
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("i")})
+	m = updated.(model)
+	out = strings.Join(m.detailedRows("codex", names), "\n")
+	if !strings.Contains(out, "(x c) 🟢 codex-3") || strings.Contains(out, "+2 idle") {
+		t.Fatalf("expected expanded rows after i, got:\n%s", out)
+	}
+}
+
+func TestDetailedRowsShowsAllWhenHideIdleUnset(t *testing.T) {
+	origIdle := sessionIdleFn
+	sessionIdleFn = func(*tmux.Session) (time.Duration, bool) { return 24 * time.Hour, true }
+	defer func() { sessionIdleFn = origIdle }()
+
+	cfg := config.DefaultConfig()
+	m := model{
+		config:   cfg,
+		sessions: map[string]*tmux.Session{"codex": tmux.NewSession("codex", cfg.Tool("codex").Command)},
+		bindings: map[string]commandBinding{},
+	}
+	out := strings.Join(m.detailedRows("codex", []string{"codex"}), "\n")
+	if strings.Contains(out, "+1 idle") {
+		t.Fatalf("did not expect collapse without hide_idle_after, got:\n%s", out)
+	}
+	if !strings.Contains(out, "codex") {
+		t.Fatalf("expected codex row, got:\n%s", out)
+	}
+}
+
+func TestParseAttachArgs(t *testing.T) {
+	if opts, err := parseAttachArgs([]string{"--last"}); err != nil || !opts.Last {
+		t.Fatalf("parseAttachArgs(--last) = %+v, %v", opts, err)
+	}
+	if _, err := parseAttachArgs(nil); err == nil {
+		t.Fatal("expected error without --last")
+	}
+	if _, err := parseAttachArgs([]string{"--last", "codex"}); err == nil {
+		t.Fatal("expected error for extra argument")
+	}
+	if opts, err := parseAttachArgs([]string{"codex-2"}); err != nil || opts.Last || opts.Session != "codex-2" {
+		t.Fatalf("parseAttachArgs(codex-2) = %+v, %v", opts, err)
+	}
+	if _, err := parseAttachArgs([]string{"codex", "claude"}); err == nil {
+		t.Fatal("expected error for two session names")
+	}
+}
+
+func TestAttachTarget(t *testing.T) {
+	origExists, origList, origLast := sessionExistsFn, listSessionsFn, getLastAttachedFn
+	defer func() { sessionExistsFn, listSessionsFn, getLastAttachedFn = origExists, origList, origLast }()
+
+	tests := []struct {
+		name    string
+		opts    attachOptions
+		running []string
+		want    string
+		wantErr string
+	}{
+		{name: "running session", opts: attachOptions{Session: "codex-2"}, running: []string{"claude", "codex-2"}, want: "codex-2"},
+		{name: "missing session lists running ones", opts: attachOptions{Session: "codex-3"}, running: []string{"codex-2", "claude"}, wantErr: `no session named "codex-3" (running: claude, codex-2)`},
+		{name: "nothing running", opts: attachOptions{Session: "codex"}, wantErr: `no session named "codex" (no sessions are running)`},
+		{name: "last", opts: attachOptions{Last: true}, running: []string{"claude"}, want: "claude"},
+	}
+	for _, tc := range tests {
+		live := make(map[string]bool)
+		for _, name := range tc.running {
+			live[name] = true
+		}
+		sessionExistsFn = func(name string) bool { return live[name] }
+		listSessionsFn = func() []string { return slices.Clone(tc.running) }
+		getLastAttachedFn = func() string { return "claude" }
+
+		got, err := attachTarget(tc.opts)
+		if tc.wantErr != "" {
+			if err == nil || err.Error() != tc.wantErr {
+				t.Errorf("%s: err = %v, want %q", tc.name, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: attachTarget = %q, %v; want %q", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestLastAttachedSession(t *testing.T) {
+	origGet, origExists := getLastAttachedFn, sessionExistsFn
+	defer func() { getLastAttachedFn, sessionExistsFn = origGet, origExists }()
+
+	last := ""
+	live := map[string]bool{"codex-2": true}
+	getLastAttachedFn = func() string { return last }
+	sessionExistsFn = func(name string) bool { return live[name] }
+
+	if _, err := lastAttachedSession(); err == nil {
+		t.Fatal("expected error when nothing was attached")
+	}
+
+	last = "codex-2"
+	if name, err := lastAttachedSession(); err != nil || name != "codex-2" {
+		t.Fatalf("lastAttachedSession = %q, %v", name, err)
+	}
+
+	last = "claude"
+	_, err := lastAttachedSession()
+	if err == nil || !strings.Contains(err.Error(), "gone") {
+		t.Fatalf("expected gone error for missing session, got %v", err)
+	}
+}
+
+func TestTruncateName(t *testing.T) {
+	tests := []struct {
+		name string
+		max  int
+		want string
+	}{
+		{name: "codex", max: 24, want: "codex"},
+		{name: "claude-refactor-auth", max: 20, want: "claude-refactor-auth"},
+		{name: "claude-refactor-auth-flow", max: 10, want: "claude-re…"},
+		{name: "日本語のセッション", max: 5, want: "日本…"},
+		{name: "🚀🚀🚀 launch", max: 6, want: "🚀🚀…"},
+		{name: "anything", max: 0, want: "anything"},
+	}
+	for _, tt := range tests {
+		if got := truncateName(tt.name, tt.max); got != tt.want {
+			t.Fatalf("truncateName(%q, %d)=%q, want %q", tt.name, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestLongSessionNameTruncatesInDisplayOnly(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxNameDisplay = 12
+	long := "codex-migrate-billing-service"
+	m := model{
+		config: cfg,
+		bindings: map[string]commandBinding{
+			long:    {SessionName: long, Cwd: "/repo", Running: true, Tool: "codex"},
+			"codex": {SessionName: "codex", Cwd: "/repo", Running: true, Tool: "codex"},
+		},
+		sessions: map[string]*tmux.Session{},
+	}
+
+	rows := m.detailedRows("codex", []string{"codex", long})
+	if !contains(rows[1], "codex-migra… repo:") {
+		t.Fatalf("expected truncated name in row, got: %s", rows[1])
+	}
+	if contains(rows[1], long) {
+		t.Fatalf("expected full name to be hidden in row, got: %s", rows[1])
+	}
+
+	m = m.preparePicker("codex", modePickKill)
+	view := m.View()
+	if !contains(view, "codex-migra…") || contains(view, long) {
+		t.Fatalf("expected truncated name in picker, got: %s", view)
+	}
+	target, ok := m.pickerTarget("b")
+	if !ok || target != long {
+		t.Fatalf("expected picker to resolve full name %q, got %q (ok=%v)", long, target, ok)
+	}
+}
+
+func TestRenameWarnsWhenNameExceedsDisplayWidth(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxNameDisplay = 10
+	m := model{
+		config:       cfg,
+		sessions:     map[string]*tmux.Session{},
+		sessionTools: map[string]string{"codex": "codex"},
+		bindings:     map[string]commandBinding{},
+		mode:         modeRenameInput,
+		renameTarget: "codex",
+		renameInput:  "codex-very-long-name",
+	}
+
+	originalRename := renameSessionFn
+	originalSetTool := setSessionToolFn
+	originalListSessions := listSessionsFn
+	defer func() { renameSessionFn = originalRename }()
+	defer func() { setSessionToolFn = originalSetTool }()
+	defer func() { listSessionsFn = originalListSessions }()
+	var renamedTo string
+	renameSessionFn = func(oldName, newName string) error {
+		renamedTo = newName
+		return nil
+	}
+	setSessionToolFn = func(sessionName, tool string) error { return nil }
+	listSessionsFn = func() []string { return nil }
+
+	m = m.applyRenameTarget()
+	if renamedTo != "codex-very-long-name" {
+		t.Fatalf("expected rename to use the full name, got %q", renamedTo)
+	}
+	if !contains(m.homeNotice, "names over 10 chars are shortened on screen") {
+		t.Fatalf("expected long-name warning, got %q", m.homeNotice)
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	got := stripANSI("\x1b[1;38;2;77;163;255m(c)\x1b[0m claude \x1b[38;5;99mrepo:\x1b[0m")
+	if got != "(c) claude repo:" {
+		t.Fatalf("stripANSI()=%q", got)
+	}
+}
+
+func TestHighlightMatchPlainText(t *testing.T) {
+	style := lipgloss.NewStyle().Bold(true).Underline(true)
+	got := highlightMatch("claude-api repo:web", "API", style)
+	want := "claude-" + style.Render("api") + " repo:web"
+	if got != want {
+		t.Fatalf("highlightMatch()=%q, want %q", got, want)
+	}
+	if got := highlightMatch("claude repo:web", "codex", style); got != "claude repo:web" {
+		t.Fatalf("expected no-match input unchanged, got %q", got)
+	}
+}
+
+func TestHighlightMatchWithANSI(t *testing.T) {
+	style := lipgloss.NewStyle().Bold(true).Underline(true)
+	bold := "\x1b[1m"
+	reset := "\x1b[0m"
+	in := "codex-api " + bold + "repo:" + reset + bold + "billing" + reset
+
+	got := highlightMatch(in, "bill", style)
+	want := "codex-api " + bold + "repo:" + reset + bold + style.Render("bill") + bold + "ing" + reset
+	if got != want {
+		t.Fatalf("highlightMatch()=%q, want %q", got, want)
+	}
+	if stripANSI(got) != stripANSI(in) {
+		t.Fatalf("visible text changed: %q -> %q", stripANSI(in), stripANSI(got))
+	}
+}
+
+func TestSlashSearchAttachesFirstMatch(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := model{
+		config: cfg,
+		bindings: map[string]commandBinding{
+			"claude":     {SessionName: "claude", Running: true, Tool: "claude"},
+			"codex-api":  {SessionName: "codex-api", Running: true, Tool: "codex"},
+			"codex-docs": {SessionName: "codex-docs", Running: true, Tool: "codex"},
+		},
+		sessions: map[string]*tmux.Session{},
+		mode:     modeSearch,
+	}
+	for _, r := range "doc" {
+		updated, _ := m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = updated.(model)
+	}
+	if m.searchQuery != "doc" {
+		t.Fatalf("expected query to accumulate, got %q", m.searchQuery)
+	}
+	if got := m.searchMatches(); len(got) != 1 || got[0] != "codex-docs" {
+		t.Fatalf("searchMatches()=%v, want [codex-docs]", got)
+	}
+	rows := m.detailedRows("codex", []string{"codex-api", "codex-docs"})
+	plain := m
+	plain.mode = modeHome
+	plainRows := plain.detailedRows("codex", []string{"codex-api", "codex-docs"})
+	if stripANSI(rows[1]) != stripANSI(plainRows[1]) {
+		t.Fatalf("highlight changed visible row text: %q vs %q", rows[1], plainRows[1])
+	}
+
+	updated, _ := m.updateHome(tea.KeyMsg{Type: tea.KeyEsc})
+	m = updated.(model)
+	if m.mode != modeHome || m.searchQuery != "" {
+		t.Fatalf("expected esc to clear search, got mode=%v query=%q", m.mode, m.searchQuery)
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := fuzzyScore("rnm", "rename"); !ok {
+		t.Fatal("expected in-order subsequence to match")
+	}
+	if _, ok := fuzzyScore("mr", "rename"); ok {
+		t.Fatal("expected out-of-order characters not to match")
+	}
+	prefix, _ := fuzzyScore("kill", "kill-task")
+	spread, _ := fuzzyScore("kt", "kill-task")
+	if prefix >= spread {
+		t.Fatalf("expected contiguous prefix to score better: %d vs %d", prefix, spread)
+	}
+}
+
+func TestPaletteMatchesRanksBestFirst(t *testing.T) {
+	m := model{config: config.DefaultConfig(), paletteQuery: "kt"}
+	matches := m.paletteMatches()
+	if len(matches) == 0 || matches[0].name != "kill-task" {
+		names := make([]string, len(matches))
+		for i, a := range matches {
+			names[i] = a.name
+		}
+		t.Fatalf("expected kill-task first, got %v", names)
+	}
+
+	m.paletteQuery = ""
+	if got := len(m.paletteMatches()); got != len(homeActions()) {
+		t.Fatalf("expected empty query to list all %d actions, got %d", len(homeActions()), got)
+	}
+}
+
+func TestCommandPaletteDispatchesSelectedAction(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+		mode:     modeHome,
+	}
+
+	updated, _ := m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{':'}})
+	m = updated.(model)
+	if m.mode != modeCommand {
+		t.Fatalf("expected : to open the palette, got mode %v", m.mode)
+	}
+	for _, r := range "tgtsk" {
+		updated, _ = m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = updated.(model)
+	}
+	if !contains(m.View(), "> toggle-tasks") {
+		t.Fatalf("expected toggle-tasks selected, got: %s", m.View())
+	}
+	updated, _ = m.updateHome(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if m.mode != modeHome || !m.showTaskDetails {
+		t.Fatalf("expected toggle-tasks to run and return home, got mode=%v tasks=%v", m.mode, m.showTaskDetails)
+	}
+	if m.paletteQuery != "" {
+		t.Fatalf("expected palette query cleared, got %q", m.paletteQuery)
+	}
+
+	updated, _ = m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{':'}})
+	m = updated.(model)
+	updated, _ = m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	m = updated.(model)
+	updated, _ = m.updateHome(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if m.mode != modeNewTool {
+		t.Fatalf("expected palette new to open the tool picker, got mode %v", m.mode)
+	}
+}
+
+func TestHelpOverlayListsEveryAction(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+		mode:     modeHome,
+	}
+	updated, _ := m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'?'}})
+	m = updated.(model)
+	view := m.View()
+	for _, action := range homeActions() {
+		if !contains(view, action.name) {
+			t.Fatalf("expected help to list %q, got: %s", action.name, view)
+		}
+	}
+	updated, _ = m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	if updated.(model).mode != modeHome {
+		t.Fatal("expected any key to close help")
+	}
+}
+
+func TestToolIconsShownByDefault(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := model{
+		config: cfg,
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Cwd: "/repo", Running: true, Tool: "claude"},
+		},
+		sessions: map[string]*tmux.Session{},
+	}
+
+	if rows := m.detailedRows("claude", []string{"claude"}); !contains(rows[0], "🟣 claude repo:") {
+		t.Fatalf("expected claude icon in row, got: %s", rows[0])
+	}
+	if rows := m.detailedRows("cursor", nil); !contains(rows[0], "🔵 cursor") {
+		t.Fatalf("expected cursor icon in not-running row, got: %s", rows[0])
+	}
+	if row := m.summaryRow("codex", []string{"codex"}); !strings.HasPrefix(row, "🟢 codex") {
+		t.Fatalf("expected codex icon in summary, got: %s", row)
+	}
+
+	cfg.Tool("codex").Icon = "X"
+	if row := m.summaryRow("codex", nil); !strings.HasPrefix(row, "X codex") {
+		t.Fatalf("expected configured icon, got: %s", row)
+	}
+}
+
+func TestDisableIconsRemovesPrefix(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.DisableIcons = true
+	m := model{
+		config: cfg,
+		bindings: map[string]commandBinding{
+			"codex":   {SessionName: "codex", Cwd: "/repo", Running: true, Tool: "codex"},
+			"codex-2": {SessionName: "codex-2", Cwd: "/repo", Running: true, Tool: "codex"},
+		},
+		sessions: map[string]*tmux.Session{},
+	}
+
+	rows := strings.Join(m.detailedRows("codex", []string{"codex", "codex-2"}), "\n")
+	if strings.Contains(rows, "🟢") || !contains(rows, "(x a) codex repo:") {
+		t.Fatalf("expected plain rows without icon, got:\n%s", rows)
+	}
+	m = m.preparePicker("codex", modePickAttach)
+	if view := m.View(); strings.Contains(view, "🟢") {
+		t.Fatalf("expected plain picker without icon, got: %s", view)
+	}
+}
+
+func TestZUsesPrefetchedSuggestionsDeliveredOnTick(t *testing.T) {
+	m := model{
+		config:      config.DefaultConfig(),
+		sessions:    map[string]*tmux.Session{},
+		bindings:    map[string]commandBinding{},
+		windowWidth: 80,
+		viewState:   viewHome,
+		mode:        modeHome,
+		hasFasder:   true,
+		lookupDirs: func(query string) ([]string, error) {
+			t.Fatalf("expected prefetched suggestions instead of lookup %q", query)
+			return nil, nil
+		},
+	}
+	m.dirPrefetch = prefetchDirSuggestions(func(query string) ([]string, error) {
+		return []string{"/src/pocketbot", "/src/api"}, nil
+	})
+	// Wait for the background lookup so the tick sees it.
+	for len(m.dirPrefetch) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	updatedModel, _ := m.Update(tickMsg(time.Now()))
+	m = updatedModel.(model)
+	if len(m.prefetchedDirSuggestions) != 2 {
+		t.Fatalf("expected tick to store prefetched suggestions, got %v", m.prefetchedDirSuggestions)
+	}
+
+	updatedModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("z")})
+	m = updatedModel.(model)
+	if m.mode != modeDirJump {
+		t.Fatalf("expected dir-jump mode, got %v", m.mode)
+	}
+	if len(m.dirSuggestions) != 2 || m.dirSuggestions[0] != "/src/pocketbot" {
+		t.Fatalf("expected prefetched suggestions, got %v", m.dirSuggestions)
+	}
+	if m.prefetchedDirSuggestions != nil {
+		t.Fatal("expected prefetch to be used only once")
+	}
+}
+
+func TestZFallsBackToLookupWhenPrefetchPending(t *testing.T) {
+	looked := false
+	m := model{
+		config:      config.DefaultConfig(),
+		sessions:    map[string]*tmux.Session{},
+		bindings:    map[string]commandBinding{},
+		mode:        modeHome,
+		hasFasder:   true,
+		dirPrefetch: make(chan []string, 1),
+		lookupDirs: func(query string) ([]string, error) {
+			looked = true
+			return []string{"/tmp/a"}, nil
+		},
+	}
+
+	updatedModel, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("z")})
+	m = updatedModel.(model)
+	if !looked || len(m.dirSuggestions) != 1 {
+		t.Fatalf("expected live lookup while prefetch is pending, looked=%v suggestions=%v", looked, m.dirSuggestions)
+	}
+}
+
+func TestDetailedRowsSwitchesToCompactOnNarrowWindow(t *testing.T) {
+	long := "codex-refactor-payment-reconciliation"
+	m := model{
+		config: config.DefaultConfig(),
+		bindings: map[string]commandBinding{
+			"codex": {SessionName: "codex", Cwd: "/src/myproject", Running: true, Tool: "codex"},
+			long:    {SessionName: long, Cwd: "/src/an-extremely-long-repository-name", Running: true, Tool: "codex"},
+		},
+		taskCounts: map[string]int{"codex": 3},
+		sessions:   map[string]*tmux.Session{},
+	}
+	names := []string{"codex", long}
+
+	m.windowWidth = 100
+	wide := m.detailedRows("codex", names)
+	if !contains(wide[0], "repo:") || !contains(wide[0], "tasks:3") {
+		t.Fatalf("expected full layout at width 100, got: %s", wide[0])
+	}
+
+	m.windowWidth = 50
+	compact := m.detailedRows("codex", names)
+	if len(compact) != 2 {
+		t.Fatalf("expected two compact rows, got %d: %v", len(compact), compact)
+	}
+	if contains(compact[0], "repo:") || contains(compact[0], "tasks:") {
+		t.Fatalf("expected compact row without repo label or task count, got: %s", compact[0])
+	}
+	if !contains(compact[0], "myproject") {
+		t.Fatalf("expected compact row to keep repo name, got: %s", compact[0])
+	}
+	for _, row := range compact {
+		if w := lipgloss.Width(row); w > 50 {
+			t.Fatalf("expected compact row within 50 columns, got %d: %q", w, row)
+		}
+	}
+}
+
+func TestParseObserveArgs(t *testing.T) {
+	opts, err := parseObserveArgs([]string{"-L", "main"})
+	if err != nil || opts.SocketName != "main" {
+		t.Fatalf("parseObserveArgs(-L main)=%+v, %v", opts, err)
+	}
+	opts, err = parseObserveArgs([]string{"-S", "/tmp/tmux-501/default"})
+	if err != nil || opts.SocketPath != "/tmp/tmux-501/default" {
+		t.Fatalf("parseObserveArgs(-S)=%+v, %v", opts, err)
+	}
+	for _, args := range [][]string{nil, {"-L", "a", "-S", "/b"}, {"-L", "a", "extra"}} {
+		if _, err := parseObserveArgs(args); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+}
+
+func TestObserveModeMakesMutatingActionsInert(t *testing.T) {
+	originalReadOnly := readOnlyFn
+	originalKill := killTaskPIDFn
+	defer func() {
+		readOnlyFn = originalReadOnly
+		killTaskPIDFn = originalKill
+	}()
+	readOnlyFn = func() bool { return true }
+	killTaskPIDFn = func(pid int) error {
+		t.Fatalf("unexpected task kill of pid %d", pid)
+		return nil
+	}
+
+	m := model{
+		config: config.DefaultConfig(),
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Running: true, Tool: "claude"},
+		},
+		sessions: map[string]*tmux.Session{},
+		mode:     modeHome,
+	}
+
+	for _, key := range []string{"n", "k", "r", "m"} {
+		updated, _ := m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		got := updated.(model)
+		if got.mode != modeHome {
+			t.Fatalf("%s: expected to stay home in observe mode, got mode %v", key, got.mode)
+		}
+		if !contains(got.homeNotice, "read-only") {
+			t.Fatalf("%s: expected read-only notice, got %q", key, got.homeNotice)
+		}
+	}
+
+	created, _ := m.createAndAttachTool("codex")
+	if created.shouldAttach || !contains(created.homeNotice, "read-only") {
+		t.Fatalf("expected create to be refused, got attach=%v notice=%q", created.shouldAttach, created.homeNotice)
+	}
+	killed, _ := m.enterTaskKillPicker()
+	if killed.mode == modePickKillTask {
+		t.Fatal("expected task kill picker to be refused")
+	}
+}
+
+func TestReadonlyModeBlocksMutations(t *testing.T) {
+	originalKill := killTaskPIDFn
+	defer func() { killTaskPIDFn = originalKill }()
+	killTaskPIDFn = func(pid int) error {
+		t.Fatalf("unexpected task kill of pid %d", pid)
+		return nil
+	}
+
+	m := model{
+		config: config.DefaultConfig(),
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Running: true, Tool: "claude"},
+		},
+		sessions: map[string]*tmux.Session{},
+		mode:     modeHome,
+		readonly: true,
+	}
+
+	for _, key := range []string{"n", "k", "r", "m", "N", "Y", "B"} {
+		updated, _ := m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		got := updated.(model)
+		if got.mode != modeHome {
+			t.Fatalf("%s: expected to stay home in readonly mode, got mode %v", key, got.mode)
+		}
+		if got.homeNotice != readonlyNotice {
+			t.Fatalf("%s: expected readonly notice, got %q", key, got.homeNotice)
+		}
+		if got.globalYolo {
+			t.Fatalf("%s: expected global yolo untouched", key)
+		}
+	}
+
+	updated, cmd := m.updateHome(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if got := updated.(model); cmd != nil || got.homeNotice != readonlyNotice {
+		t.Fatalf("ctrl+c: expected refusal without quitting, got notice %q cmd %v", got.homeNotice, cmd)
+	}
+	created, _ := m.createAndAttachTool("codex")
+	if created.shouldAttach || created.homeNotice != readonlyNotice {
+		t.Fatalf("expected create to be refused, got attach=%v notice=%q", created.shouldAttach, created.homeNotice)
+	}
+	killed, _ := m.doubleTapKill("claude")
+	if killed.homeNotice != readonlyNotice {
+		t.Fatalf("expected double-tap kill to be refused, got %q", killed.homeNotice)
+	}
+	picker, _ := m.enterTaskKillPicker()
+	if picker.mode == modePickKillTask {
+		t.Fatal("expected task kill picker to be refused")
+	}
+
+	// Keys that only change the view keep working.
+	updated, _ = m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
+	if got := updated.(model); !got.showTaskDetails || got.homeNotice != "" {
+		t.Fatalf("t: expected task lines shown without a notice, got %v %q", got.showTaskDetails, got.homeNotice)
+	}
+	updated, _ = m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("?")})
+	if got := updated.(model); got.mode != modeHelp {
+		t.Fatalf("?: expected help, got mode %v", got.mode)
+	}
+	if _, cmd := m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")}); cmd == nil {
+		t.Fatal("d: expected quit to keep working")
+	}
+
+	if view := stripANSI(m.viewHome()); !contains(view, "Welcome to PocketBot (readonly)") {
+		t.Fatalf("expected readonly title, got:\n%s", view)
+	}
+}
+
+func TestReadonlyRefusedSubcommands(t *testing.T) {
+	for _, cmd := range []string{"new", "kill-all", "label", "clean", "detach-all"} {
+		if !readonlyRefusedSubcommands[cmd] {
+			t.Errorf("expected pb %s refused in readonly mode", cmd)
+		}
+	}
+	for _, cmd := range []string{"sessions", "tasks", "doctor", "attach"} {
+		if readonlyRefusedSubcommands[cmd] {
+			t.Errorf("expected pb %s allowed in readonly mode", cmd)
+		}
+	}
+}
+
+func TestExtractGlobalFlags(t *testing.T) {
+	cases := []struct {
+		args     []string
+		want     globalFlags
+		wantRest string
+	}{
+		{nil, globalFlags{}, ""},
+		{[]string{"sessions", "--raw"}, globalFlags{}, "sessions --raw"},
+		{[]string{"--log-file", "/tmp/pb.log"}, globalFlags{logPath: "/tmp/pb.log"}, ""},
+		{[]string{"--log-file", "/tmp/pb.log", "tasks", "--all"}, globalFlags{logPath: "/tmp/pb.log"}, "tasks --all"},
+		{[]string{"--log-file=/tmp/pb.log", "doctor"}, globalFlags{logPath: "/tmp/pb.log"}, "doctor"},
+		{[]string{"--readonly", "--no-alt-screen"}, globalFlags{readonly: true, noAltScreen: true}, ""},
+		{[]string{"--no-alt-screen", "--readonly", "list"}, globalFlags{readonly: true, noAltScreen: true}, "list"},
+		{
+			[]string{"--log-file=/tmp/pb.log", "--readonly", "--no-alt-screen", "--observe", "-L", "x"},
+			globalFlags{readonly: true, noAltScreen: true, logPath: "/tmp/pb.log"},
+			"--observe -L x",
+		},
+		// Flags after the subcommand belong to it.
+		{[]string{"list", "--readonly"}, globalFlags{}, "list --readonly"},
+	}
+	for _, tc := range cases {
+		got, rest, err := extractGlobalFlags(tc.args)
+		if err != nil {
+			t.Fatalf("extractGlobalFlags(%v) returned error: %v", tc.args, err)
+		}
+		if got != tc.want || strings.Join(rest, " ") != tc.wantRest {
+			t.Errorf("extractGlobalFlags(%v) = %+v, %v; want %+v, %q", tc.args, got, rest, tc.want, tc.wantRest)
+		}
+	}
+	for _, args := range [][]string{{"--log-file"}, {"--log-file="}, {"--readonly", "--log-file"}} {
+		if _, _, err := extractGlobalFlags(args); err == nil {
+			t.Errorf("extractGlobalFlags(%v) should require a path", args)
+		}
+	}
+}
+
+func TestStripCommandPaths(t *testing.T) {
+	prefixes := config.DefaultCommandStripPrefixes()
+	cases := []struct{ in, want string }{
+		{"/opt/homebrew/bin/node server.js", "node server.js"},
+		{"/usr/local/bin/python3 -m http.server", "python3 -m http.server"},
+		{"/usr/bin/make integration-test-backend", "make integration-test-backend"},
+		{"node /repo/node_modules/.bin/nx serve backend", "node nx serve backend"},
+		{"node /home/user/.nvm/versions/node/v20.11.0/lib/node_modules/npm/bin/npm-cli.js run dev", "node npm/bin/npm-cli.js run dev"},
+		{"/repo/node_modules/@esbuild/darwin-arm64/bin/esbuild --ping", "@esbuild/darwin-arm64/bin/esbuild --ping"},
+		{"ls /bin/", "ls /bin/"},
+		{"/home/me/bin/tool", "/home/me/bin/tool"},
+	}
+	for _, tc := range cases {
+		if got := stripCommandPaths(tc.in, prefixes); got != tc.want {
+			t.Errorf("stripCommandPaths(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	if got := stripCommandPaths("/opt/homebrew/bin/node x", nil); got != "/opt/homebrew/bin/node x" {
+		t.Errorf("expected no prefix stripping without prefixes, got %q", got)
+	}
+	if got := stripCommandPaths("/nix/store/abc/bin/go test", []string{"/nix/store/abc/bin/"}); got != "go test" {
+		t.Errorf("expected configured prefix stripped, got %q", got)
+	}
+}
+
+func TestTruncateMiddle(t *testing.T) {
+	if got := truncateMiddle("short", 10); got != "short" {
+		t.Errorf("expected short string unchanged, got %q", got)
+	}
+	got := truncateMiddle("npm run dev --port 3000 --host 0.0.0.0", 20)
+	if lipgloss.Width(got) != 20 {
+		t.Errorf("expected 20 columns, got %q (%d)", got, lipgloss.Width(got))
+	}
+	if !strings.HasPrefix(got, "npm run d") || !strings.HasSuffix(got, "0.0.0.0") || !strings.Contains(got, "…") {
+		t.Errorf("expected head and tail kept around an ellipsis, got %q", got)
+	}
+	if got := truncateMiddle("abcdef", 1); got != "…" {
+		t.Errorf("truncateMiddle to 1 = %q", got)
+	}
+}
+
+func TestTruncateCommandAndWidth(t *testing.T) {
+	m := model{config: config.DefaultConfig(), windowWidth: 200}
+	if m.taskCommandWidth() != 60 {
+		t.Fatalf("expected 60-column cap on wide terminals, got %d", m.taskCommandWidth())
+	}
+	m.windowWidth = 50
+	if m.taskCommandWidth() != 40 {
+		t.Fatalf("expected windowWidth-10 on narrow terminals, got %d", m.taskCommandWidth())
+	}
+
+	long := "node /home/user/.nvm/versions/node/v20.11.0/lib/node_modules/npm/bin/npm-cli.js run dev -- --port 3000"
+	got := m.truncateCommand(long, m.taskCommandWidth())
+	if lipgloss.Width(got) != 40 || !strings.HasPrefix(got, "node npm/bin/") || !strings.HasSuffix(got, "3000") {
+		t.Fatalf("expected stripped then middle-truncated command, got %q", got)
+	}
+
+	m.windowWidth = 80
+	got = m.truncateCommand(long, m.taskCommandWidth())
+	m.showTaskDetails = true
+	m.taskCommands = map[string][]string{"claude": {long}}
+	m.sessions = map[string]*tmux.Session{}
+	m.bindings = map[string]commandBinding{}
+	rows := strings.Join(m.detailedRows("claude", []string{"claude"}), "\n")
+	if !contains(rows, "task: "+got) || contains(rows, ".nvm") {
+		t.Fatalf("expected truncated task line, got:\n%s", rows)
+	}
+}
+
+func TestConfigChangedMsgReloadsConfig(t *testing.T) {
+	origLoad := loadConfigFn
+	defer func() { loadConfigFn = origLoad }()
+
+	reloaded := config.DefaultConfig()
+	reloaded.Sessions = []config.SessionConfig{{Name: "api", Command: "go run ./api", Key: "a"}}
+	loadConfigFn = func() (*config.Config, error) { return reloaded, nil }
+
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+	}
+	updated, cmd := m.Update(configChangedMsg{})
+	m = updated.(model)
+	if cmd != nil {
+		t.Fatal("config reload should not schedule a command")
+	}
+	if m.config != reloaded {
+		t.Fatal("expected reloaded config to replace the current one")
+	}
+	if m.homeNotice != "config reloaded" {
+		t.Fatalf("homeNotice = %q, want config reloaded", m.homeNotice)
+	}
+	if _, ok := m.sessions["api"]; !ok {
+		t.Fatal("expected newly configured session to be tracked")
+	}
+}
+
+func TestConfigChangedMsgUpdatesIdleTimeoutOfExistingSessions(t *testing.T) {
+	origLoad := loadConfigFn
+	defer func() { loadConfigFn = origLoad }()
+
+	reloaded := config.DefaultConfig()
+	reloaded.Tool("codex").IdleTimeout = 5 * time.Minute
+	loadConfigFn = func() (*config.Config, error) { return reloaded, nil }
+
+	cfg := config.DefaultConfig()
+	m := model{
+		config:       cfg,
+		sessions:     map[string]*tmux.Session{"review": newSessionFor(cfg, "review", "codex", "")},
+		sessionTools: map[string]string{"review": "codex"},
+		bindings:     map[string]commandBinding{},
+	}
+	updated, _ := m.Update(configChangedMsg{})
+	m = updated.(model)
+	want := tmux.NewSessionWithOptions("review", "", tmux.WithIdleTimeout(5*time.Minute))
+	if got := m.sessions["review"]; !reflect.DeepEqual(got, want) {
+		t.Fatalf("review session = %+v, want the reloaded codex idle timeout", got)
+	}
+}
+
+func TestConfigChangedMsgKeepsConfigOnError(t *testing.T) {
+	origLoad := loadConfigFn
+	defer func() { loadConfigFn = origLoad }()
+	loadConfigFn = func() (*config.Config, error) { return nil, errors.New("duplicate key \"c\"") }
+
+	original := config.DefaultConfig()
+	m := model{config: original, sessions: map[string]*tmux.Session{}}
+	updated, _ := m.Update(configChangedMsg{})
+	m = updated.(model)
+	if m.config != original {
+		t.Fatal("expected failed reload to keep the current config")
+	}
+	if m.homeNotice != `config reload failed: duplicate key "c"` {
+		t.Fatalf("homeNotice = %q", m.homeNotice)
+	}
+}
+
+func TestWatchConfigFilesSignalsChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("sessions: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := model{configChanged: watchConfigFiles(func() []string { return []string{path} }, 10*time.Millisecond)}
+	if cmd := m.configChangeCmd(); cmd != nil {
+		t.Fatal("expected no change signal before the file changes")
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	var cmd tea.Cmd
+	for cmd == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		cmd = m.configChangeCmd()
+	}
+	if cmd == nil {
+		t.Fatal("expected a change signal after the file was modified")
+	}
+	if _, ok := cmd().(configChangedMsg); !ok {
+		t.Fatal("expected the command to deliver configChangedMsg")
+	}
+	if m.configChangeCmd() != nil {
+		t.Fatal("expected one signal per change")
+	}
+}
+
+func TestWatchConfigFilesSeesNewProjectFile(t *testing.T) {
+	home, project := t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+	m := model{configChanged: watchConfigFiles(func() []string { return config.WatchPaths(project) }, 10*time.Millisecond)}
+
+	if err := os.WriteFile(filepath.Join(project, config.ProjectConfigName), []byte("sessions: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	var cmd tea.Cmd
+	for cmd == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		cmd = m.configChangeCmd()
+	}
+	if cmd == nil {
+		t.Fatal("expected a change signal after a project config file was created")
+	}
+}
+
+func TestActivitySummary(t *testing.T) {
+	active := map[*tmux.Session]bool{}
+	origActive := sessionActiveFn
+	sessionActiveFn = func(s *tmux.Session) bool { return active[s] }
+	defer func() { sessionActiveFn = origActive }()
+
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+	}
+	if a, total := m.activitySummary(); a != 0 || total != 0 {
+		t.Fatalf("empty model: got %d/%d, want 0/0", a, total)
+	}
+
+	for _, name := range []string{"claude", "codex", "codex-2", "stopped"} {
+		m.sessions[name] = tmux.NewSession(name, "")
+		m.bindings[name] = commandBinding{SessionName: name, Running: name != "stopped"}
+	}
+	// A running binding without a tracked session still counts toward the total.
+	m.bindings["orphan"] = commandBinding{SessionName: "orphan", Running: true}
+	if a, total := m.activitySummary(); a != 0 || total != 4 {
+		t.Fatalf("all idle: got %d/%d, want 0/4", a, total)
+	}
+
+	active[m.sessions["claude"]] = true
+	active[m.sessions["codex-2"]] = true
+	active[m.sessions["stopped"]] = true
+	if a, total := m.activitySummary(); a != 2 || total != 4 {
+		t.Fatalf("mixed: got %d/%d, want 2/4", a, total)
+	}
+
+	m.getwd = func() (string, error) { return "/tmp", nil }
+	titleLine := strings.SplitN(stripANSI(m.View()), "\n", 2)[0]
+	if !strings.HasSuffix(titleLine, "[2/4 active]") {
+		t.Fatalf("expected activity badge in title, got: %q", titleLine)
+	}
+}
+
+func TestNewProgramHonorsNoAltScreen(t *testing.T) {
+	var gotOpts []tea.ProgramOption
+	factory := func(mdl tea.Model, opts ...tea.ProgramOption) *tea.Program {
+		gotOpts = opts
+		return tea.NewProgram(mdl, opts...)
+	}
+
+	m := model{teaProgramFactory: factory}
+	if p := m.newProgram(); p == nil {
+		t.Fatal("expected a program")
+	}
+	if len(gotOpts) != 1 {
+		t.Fatalf("expected the alt screen option by default, got %d options", len(gotOpts))
+	}
+
+	m.noAltScreen = true
+	m.newProgram()
+	if len(gotOpts) != 0 {
+		t.Fatalf("expected no alt screen option with --no-alt-screen, got %d options", len(gotOpts))
+	}
+}
+
+func TestNoAltScreenViewLeavesGapAbovePreviousOutput(t *testing.T) {
+	m := model{
+		config:    config.DefaultConfig(),
+		sessions:  map[string]*tmux.Session{},
+		bindings:  map[string]commandBinding{},
+		viewState: viewHome,
+		getwd:     func() (string, error) { return "/tmp", nil },
+	}
+	if strings.HasPrefix(m.View(), "\n") {
+		t.Fatal("alt screen view should start with the title")
+	}
+	m.noAltScreen = true
+	if !strings.HasPrefix(m.View(), "\n\n") {
+		t.Fatalf("inline view should start with blank lines, got %q", m.View())
+	}
+}
+
+func TestRunningToolSessionsSortsByPriorityThenName(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+	}
+	priorities := map[string]int{"codex": 0, "codex-2": 3, "codex-3": 1, "codex-4": 3, "codex-5": 0}
+	for name, p := range priorities {
+		m.sessions[name] = tmux.NewSession(name, "")
+		m.bindings[name] = commandBinding{SessionName: name, Tool: "codex", Running: true, Priority: p}
+	}
+	got := strings.Join(m.runningToolSessions("codex"), ",")
+	if got != "codex-3,codex-2,codex-4,codex,codex-5" {
+		t.Fatalf("got %s", got)
+	}
+
+	// Priority beats activity in the attach picker.
+	origActive := sessionActiveFn
+	sessionActiveFn = func(s *tmux.Session) bool { return s == m.sessions["codex-5"] }
+	defer func() { sessionActiveFn = origActive }()
+	m = m.preparePicker("codex", modePickAttach)
+	var order []string
+	for _, entry := range m.pickerEntries {
+		order = append(order, entry.key+"="+entry.session)
+	}
+	if got := strings.Join(order, ","); got != "a=codex-3,b=codex-2,c=codex-4,d=codex-5,e=codex" {
+		t.Fatalf("expected labelled sessions first in the picker, got %s", got)
+	}
+}
+
+func TestDetailedRowsShowPriorityLabelBeforeName(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+	}
+	for _, name := range []string{"codex", "codex-2"} {
+		m.sessions[name] = tmux.NewSession(name, "")
+		m.bindings[name] = commandBinding{SessionName: name, Tool: "codex", Running: true}
+	}
+	labelled := m.bindings["codex-2"]
+	labelled.Priority = 3
+	m.bindings["codex-2"] = labelled
+
+	rows := m.detailedRows("codex", m.runningToolSessions("codex"))
+	if len(rows) != 2 {
+		t.Fatalf("expected two rows, got %q", rows)
+	}
+	if first := stripANSI(rows[0]); !strings.HasPrefix(first, "(x a) [3] 🟢 codex-2 repo:") {
+		t.Fatalf("expected label between key hint and name, got %q", first)
+	}
+	if second := stripANSI(rows[1]); contains(second, "[") || !strings.HasPrefix(second, "(x b) 🟢 codex repo:") {
+		t.Fatalf("expected unlabelled row without a label, got %q", second)
+	}
+}
+
+func TestParseLabelArgs(t *testing.T) {
+	opts, err := parseLabelArgs([]string{"codex-2", "3"})
+	if err != nil || opts.Session != "codex-2" || opts.Priority != 3 {
+		t.Fatalf("got %+v, %v", opts, err)
+	}
+	opts, err = parseLabelArgs([]string{"codex-2", "none"})
+	if err != nil || opts.Priority != 0 {
+		t.Fatalf("none should clear the label, got %+v, %v", opts, err)
+	}
+	for _, args := range [][]string{{"codex-2"}, {"codex-2", "0"}, {"codex-2", "10"}, {"codex-2", "high"}, {"a", "1", "b"}} {
+		if _, err := parseLabelArgs(args); err == nil {
+			t.Errorf("parseLabelArgs(%v) should fail", args)
+		}
+	}
+}
+
+func TestParseAttachCount(t *testing.T) {
+	for value, want := range map[string]int{"": 0, "1": 1, " 12\n": 12, "-3": 0, "many": 0} {
+		if got := parseAttachCount(value); got != want {
+			t.Errorf("parseAttachCount(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestRecordAttachIncrementsCount(t *testing.T) {
+	origGet, origSet := getSessionOptionFn, setSessionOptionFn
+	defer func() { getSessionOptionFn, setSessionOptionFn = origGet, origSet }()
+	stored := map[string]string{"codex @pb_attach_count": "garbage"}
+	getSessionOptionFn = func(name, option string) string { return stored[name+" "+option] }
+	setSessionOptionFn = func(name, option, value string) error {
+		stored[name+" "+option] = value
+		return nil
+	}
+
+	for want := 1; want <= 3; want++ {
+		if err := recordAttach("codex"); err != nil {
+			t.Fatal(err)
+		}
+		if got := parseAttachCount(getSessionOptionFn("codex", "@pb_attach_count")); got != want {
+			t.Fatalf("after attach %d: count = %d (stored %v)", want, got, stored)
+		}
+	}
+	if stored["claude @pb_attach_count"] != "" {
+		t.Fatalf("other sessions should be untouched, got %v", stored)
+	}
+}
+
+func TestDetailedRowsShowVisitCountWhenEnabled(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := model{
+		config: cfg,
+		bindings: map[string]commandBinding{
+			"codex":   {SessionName: "codex", Running: true, Tool: "codex", AttachCount: 4},
+			"codex-2": {SessionName: "codex-2", Running: true, Tool: "codex", AttachCount: 1},
+		},
+		windowWidth: 80,
+	}
+	if got := stripANSI(strings.Join(m.detailedRows("codex", []string{"codex", "codex-2"}), "\n")); contains(got, "visits:") {
+		t.Fatalf("visit counts should be off by default, got:\n%s", got)
+	}
+
+	cfg.UI.ShowVisitCount = true
+	got := stripANSI(strings.Join(m.detailedRows("codex", []string{"codex", "codex-2"}), "\n"))
+	if !contains(got, "visits:4") || contains(got, "visits:1") {
+		t.Fatalf("expected visits only for the session attached more than once, got:\n%s", got)
+	}
+
+	// Listing the column places it like any other.
+	cfg.UI.Columns = []string{"visits", "name"}
+	rows := m.detailedRows("codex", []string{"codex", "codex-2"})
+	if got := stripANSI(rows[0]); !strings.HasPrefix(got, "visits:4 ") {
+		t.Fatalf("expected visits first, got %q", got)
+	}
+	if got := stripANSI(strings.Join(rows, "\n")); strings.Count(got, "visits:") != 1 {
+		t.Fatalf("expected the visits column once, got:\n%s", got)
+	}
+}
+
+func TestApplyLabelStoresPriorityOption(t *testing.T) {
+	origExists, origSet := sessionExistsFn, setSessionOptionFn
+	defer func() { sessionExistsFn, setSessionOptionFn = origExists, origSet }()
+	sessionExistsFn = func(name string) bool { return name == "codex" }
+	stored := map[string]string{}
+	setSessionOptionFn = func(name, option, value string) error {
+		stored[name+" "+option] = value
+		return nil
+	}
+
+	if err := applyLabel(labelOptions{Session: "codex", Priority: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if stored["codex @pb_priority"] != "2" {
+		t.Fatalf("expected priority 2 stored, got %v", stored)
+	}
+	if err := applyLabel(labelOptions{Session: "codex"}); err != nil {
+		t.Fatal(err)
+	}
+	if stored["codex @pb_priority"] != "" {
+		t.Fatalf("expected priority cleared, got %v", stored)
+	}
+	if err := applyLabel(labelOptions{Session: "gone", Priority: 1}); err == nil {
+		t.Fatal("expected an error for a missing session")
+	}
+}
+
+func TestBroadcastInputSendsToEveryToolSession(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"claude":   {SessionName: "claude", Running: true, Tool: "claude"},
+			"claude-2": {SessionName: "claude-2", Running: true, Tool: "claude"},
+			"codex":    {SessionName: "codex", Running: true, Tool: "codex"},
+		},
+	}
+
+	original := broadcastKeysFn
+	originalExists := sessionExistsFn
+	defer func() {
+		broadcastKeysFn = original
+		sessionExistsFn = originalExists
+	}()
+	sessionExistsFn = func(name string) bool { return true }
+	var gotNames []string
+	var gotKeys string
+	broadcastKeysFn = func(names []string, keys string) []error {
+		gotNames, gotKeys = names, keys
+		return nil
+	}
+
+	m, _ = m.beginBroadcastTool()
+	if m.mode != modeBroadcastTool {
+		t.Fatalf("expected modeBroadcastTool, got %v", m.mode)
+	}
+	if view := m.View(); !contains(view, "claude (2)") || !contains(view, "codex (1)") {
+		t.Fatalf("expected per-tool session counts, got: %s", view)
+	}
+
+	m.mode = modeBroadcastInput
+	m.broadcastTarget = "claude"
+	// Shortcut keys are text while typing.
+	for _, msg := range []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune("run ")},
+		{Type: tea.KeyRunes, Runes: []rune("d")},
+		{Type: tea.KeyRunes, Runes: []rune("ev tests")},
+	} {
+		updated, cmd := m.Update(msg)
+		if cmd != nil {
+			t.Fatal("typing in broadcast input should not quit")
+		}
+		m = updated.(model)
+	}
+	if !contains(m.View(), "broadcast to 2 claude sessions") {
+		t.Fatalf("expected broadcast input view, got: %s", m.View())
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if m.mode != modeHome || m.broadcastInput != "" || m.broadcastTarget != "" {
+		t.Fatalf("expected broadcast state reset, got mode=%v target=%q input=%q", m.mode, m.broadcastTarget, m.broadcastInput)
+	}
+	if strings.Join(gotNames, ",") != "claude,claude-2" || gotKeys != "run dev tests" {
+		t.Fatalf("broadcast got names=%v keys=%q", gotNames, gotKeys)
+	}
+	if m.homeNotice != "sent to 2 claude sessions" {
+		t.Fatalf("homeNotice = %q", m.homeNotice)
+	}
+}
+
+func TestBroadcastReportsPartialFailure(t *testing.T) {
+	m := model{
+		config: config.DefaultConfig(),
+		bindings: map[string]commandBinding{
+			"codex":   {SessionName: "codex", Running: true, Tool: "codex"},
+			"codex-2": {SessionName: "codex-2", Running: true, Tool: "codex"},
+		},
+		mode:            modeBroadcastInput,
+		broadcastTarget: "codex",
+		broadcastInput:  "git status",
+		broadcastCursor: len("git status"),
+	}
+
+	original := broadcastKeysFn
+	originalExists := sessionExistsFn
+	defer func() {
+		broadcastKeysFn = original
+		sessionExistsFn = originalExists
+	}()
+	sessionExistsFn = func(name string) bool { return true }
+	broadcastKeysFn = func(names []string, keys string) []error {
+		return []error{errors.New("codex-2: no such session")}
+	}
+
+	m = m.applyBroadcast()
+	if m.homeNotice != "sent to 1/2 codex sessions; codex-2: no such session" {
+		t.Fatalf("homeNotice = %q", m.homeNotice)
+	}
+
+	m.mode = modeBroadcastInput
+	m.broadcastTarget = "codex"
+	m.broadcastInput = "   "
+	m = m.applyBroadcast()
+	if m.mode != modeBroadcastInput || m.homeNotice != "nothing to broadcast" {
+		t.Fatalf("expected blank input to stay in broadcast mode, got mode=%v notice=%q", m.mode, m.homeNotice)
+	}
+}
+
+func TestSendKeysTypesLineIntoPickedSession(t *testing.T) {
+	requireTmuxSessionCreation(t)
+
+	suffix := time.Now().UnixNano()
+	first := fmt.Sprintf("claude-send-a-%d", suffix)
+	second := fmt.Sprintf("claude-send-b-%d", suffix)
+	m := model{
+		config: config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{
+			first:  tmux.NewSession(first, "sleep 60"),
+			second: tmux.NewSession(second, "sleep 60"),
+		},
+		bindings:        map[string]commandBinding{},
+		windowWidth:     80,
+		viewState:       viewHome,
+		mode:            modeHome,
+		pickerSortOrder: sortAlpha,
+	}
+	for _, sess := range m.sessions {
+		if err := sess.Start(); err != nil {
+			t.Skipf("tmux sessions cannot be started in this environment: %v", err)
+		}
+		defer sess.Stop()
+	}
+
+	originalSend := sendKeysFn
+	defer func() { sendKeysFn = originalSend }()
+	var gotName, gotKeys string
+	sendKeysFn = func(name, keys string) error {
+		gotName, gotKeys = name, keys
+		return nil
+	}
+
+	press := func(msg tea.KeyMsg) {
+		t.Helper()
+		updated, cmd := m.Update(msg)
+		if cmd != nil {
+			t.Fatalf("%q should not quit", msg.String())
+		}
+		m = updated.(model)
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("S")})
+	if m.mode != modeSendTool {
+		t.Fatalf("expected S to enter modeSendTool, got %v (notice %q)", m.mode, m.homeNotice)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	if m.mode != modePickSend {
+		t.Fatalf("expected the send picker for two claude sessions, got %v (notice %q)", m.mode, m.homeNotice)
+	}
+	if view := stripANSI(m.View()); !contains(view, "send to claude") {
+		t.Fatalf("expected send picker, got: %s", view)
+	}
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	if m.mode != modeSendKeys || m.sendTarget != second {
+		t.Fatalf("expected b to pick %s, got mode=%v target=%q", second, m.mode, m.sendTarget)
+	}
+	// Shortcut keys are text while typing.
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o it")})
+	if !contains(m.View(), "send to "+m.displayName(second)) {
+		t.Fatalf("expected send input view, got: %s", m.View())
+	}
+
+	press(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.mode != modeHome || m.sendInput != "" || m.sendTarget != "" {
+		t.Fatalf("expected send state reset, got mode=%v target=%q input=%q", m.mode, m.sendTarget, m.sendInput)
+	}
+	if gotName != second || gotKeys != "do it" {
+		t.Fatalf("send got name=%q keys=%q", gotName, gotKeys)
+	}
+	if m.homeNotice != "sent to "+second {
+		t.Fatalf("homeNotice = %q", m.homeNotice)
+	}
+}
+
+func TestSendKeysReportsSessionThatExited(t *testing.T) {
+	m := model{
+		config:     config.DefaultConfig(),
+		bindings:   map[string]commandBinding{},
+		mode:       modeSendKeys,
+		sendTarget: "codex",
+		sendInput:  "continue",
+		sendCursor: len("continue"),
+	}
+
+	originalSend := sendKeysFn
+	originalExists := sessionExistsFn
+	defer func() {
+		sendKeysFn = originalSend
+		sessionExistsFn = originalExists
+	}()
+	sessionExistsFn = func(name string) bool { return false }
+	sendKeysFn = func(name, keys string) error {
+		t.Fatalf("did not expect keys sent to exited session %s", name)
+		return nil
+	}
+
+	m = m.applySendKeys()
+	if m.mode != modeHome || m.homeNotice != "codex is no longer running" {
+		t.Fatalf("expected exited-session notice, got mode=%v notice=%q", m.mode, m.homeNotice)
+	}
+}
+
+func TestBroadcastSkipsSessionsThatExited(t *testing.T) {
+	m := model{
+		config: config.DefaultConfig(),
+		bindings: map[string]commandBinding{
+			"claude":   {SessionName: "claude", Running: true, Tool: "claude"},
+			"claude-2": {SessionName: "claude-2", Running: true, Tool: "claude"},
+			"claude-3": {SessionName: "claude-3", Running: true, Tool: "claude"},
+		},
+		mode:            modeBroadcastInput,
+		broadcastTarget: "claude",
+		broadcastInput:  "/compact",
+	}
+
+	original := broadcastKeysFn
+	originalExists := sessionExistsFn
+	defer func() {
+		broadcastKeysFn = original
+		sessionExistsFn = originalExists
+	}()
+	sessionExistsFn = func(name string) bool { return name != "claude-2" }
+	var gotNames []string
+	broadcastKeysFn = func(names []string, keys string) []error {
+		gotNames = names
+		return nil
+	}
+
+	m = m.applyBroadcast()
+	if strings.Join(gotNames, ",") != "claude,claude-3" {
+		t.Fatalf("expected exited claude-2 to be skipped, sent to %v", gotNames)
+	}
+	if m.homeNotice != "sent to 2 claude sessions (skipped 1 that exited)" {
+		t.Fatalf("homeNotice = %q", m.homeNotice)
+	}
+
+	sessionExistsFn = func(name string) bool { return false }
+	m.mode = modeBroadcastInput
+	m.broadcastTarget = "claude"
+	m.broadcastInput = "/compact"
+	gotNames = nil
+	m = m.applyBroadcast()
+	if gotNames != nil || m.homeNotice != "claude is not running" {
+		t.Fatalf("expected nothing sent when every session exited, got %v notice %q", gotNames, m.homeNotice)
+	}
+}
+
+func TestActiveIndicatorBlinksOnOddTicks(t *testing.T) {
+	origProfile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	defer lipgloss.SetColorProfile(origProfile)
+
+	activeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#04B575")).Bold(true)
+	bright := activeStyle.Render("●")
+	dim := lipgloss.NewStyle().Foreground(lipgloss.Color("#888888")).Render("●")
+	if bright == dim {
+		t.Fatal("expected bright and dim indicators to differ")
+	}
+
+	m := model{config: config.DefaultConfig()}
+	for tick, want := range map[int]string{0: bright, 1: dim, 2: bright, 7: dim} {
+		m.tickCount = tick
+		if got := m.activeIndicator("●", activeStyle); got != want {
+			t.Errorf("tick %d: got %q, want %q", tick, got, want)
+		}
+	}
+
+	m.config.UI.DisableAnimations = true
+	m.tickCount = 1
+	if got := m.activeIndicator("●", activeStyle); got != bright {
+		t.Errorf("disable_animations: got %q, want steady %q", got, bright)
+	}
+}
+
+func TestZombieSessionsShowWarnings(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+		getwd:    func() (string, error) { return "/tmp", nil },
+	}
+	for _, name := range []string{"codex", "codex-2"} {
+		m.sessions[name] = tmux.NewSession(name, "")
+		m.bindings[name] = commandBinding{SessionName: name, Tool: "codex", Running: true}
+	}
+	if m.hasZombieSessions() {
+		t.Fatal("expected no zombies before detection")
+	}
+	if title := strings.SplitN(stripANSI(m.View()), "\n", 2)[0]; contains(title, "zombie") {
+		t.Fatalf("did not expect a zombie badge, got %q", title)
+	}
+
+	m.zombieSessions = map[string]bool{"codex-2": true}
+	if !m.hasZombieSessions() {
+		t.Fatal("expected hasZombieSessions to report codex-2")
+	}
+	if title := strings.SplitN(stripANSI(m.View()), "\n", 2)[0]; !contains(title, "⚠ zombie") {
+		t.Fatalf("expected zombie badge in title, got %q", title)
+	}
+	rows := m.detailedRows("codex", m.runningToolSessions("codex"))
+	if contains(rows[0], "zombie") || !contains(stripANSI(rows[1]), "codex-2 repo:- ⚠ zombie") {
+		t.Fatalf("expected only codex-2 flagged, got %q", rows)
+	}
+	if !contains(m.buildTooltip("codex-2"), "kill (k) and start it again") {
+		t.Fatalf("expected tooltip to suggest a restart, got %q", m.buildTooltip("codex-2"))
+	}
+	if contains(m.buildTooltip("codex"), "zombie") {
+		t.Fatal("did not expect zombie advice for a healthy session")
+	}
+}
+
+func TestRefreshTaskCountsKeepsZombiesWhenSnapshotFails(t *testing.T) {
+	original := zombieSessionsFn
+	defer func() { zombieSessionsFn = original }()
+
+	m := model{zombieSessions: map[string]bool{"codex": true}}
+	zombieSessionsFn = func([]string) (map[string]bool, error) { return nil, errors.New("ps failed") }
+	m.refreshTaskCounts()
+	if !m.zombieSessions["codex"] {
+		t.Fatal("expected a failed snapshot to keep the last zombie result")
+	}
+
+	m.taskRefreshAt = time.Time{}
+	zombieSessionsFn = func([]string) (map[string]bool, error) { return map[string]bool{}, nil }
+	m.refreshTaskCounts()
+	if m.hasZombieSessions() {
+		t.Fatal("expected a clean snapshot to clear zombies")
+	}
+}
+
+func TestCreateAndAttachToolRespectsMaxInstances(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tool("claude").MaxInstances = 2
+	m := model{
+		config:       cfg,
+		sessions:     map[string]*tmux.Session{},
+		sessionTools: map[string]string{},
+		bindings:     map[string]commandBinding{},
+		getwd:        func() (string, error) { return "/work/new", nil },
+	}
+	for _, name := range []string{"claude", "claude-2"} {
+		m.sessions[name] = tmux.NewSession(name, "")
+		m.bindings[name] = commandBinding{SessionName: name, Tool: "claude", Running: true, Cwd: "/work/" + name}
+	}
+	m.newToolYolo = true
+
+	m, cmd := m.createAndAttachTool("claude")
+	if cmd != nil || m.shouldAttach || m.sessionToAttach != "" {
+		t.Fatalf("expected creation to be refused, got attach=%v target=%q", m.shouldAttach, m.sessionToAttach)
+	}
+	if m.homeNotice != "claude instance limit (2) reached" {
+		t.Fatalf("homeNotice = %q", m.homeNotice)
+	}
+	if len(m.sessions) != 2 {
+		t.Fatalf("expected no new session, got %v", m.sessions)
+	}
+	if !m.newToolYolo {
+		t.Fatal("expected n-menu toggles kept when creation is refused")
+	}
+}
+
+func TestPrintSocket(t *testing.T) {
+	cases := []struct{ level, dir, want string }{
+		{"", "", "pocketbot\n"},
+		{"2", "", "pocketbot-2\n"},
+		{"", "/run/pb", "/run/pb/pocketbot\n"},
+	}
+	for _, tc := range cases {
+		t.Setenv("PB_LEVEL", tc.level)
+		t.Setenv("PB_TMUX_SOCKET_DIR", tc.dir)
+		var buf bytes.Buffer
+		printSocket(&buf)
+		if buf.String() != tc.want {
+			t.Errorf("PB_LEVEL=%q PB_TMUX_SOCKET_DIR=%q: printed %q, want %q", tc.level, tc.dir, buf.String(), tc.want)
+		}
+	}
+}
+
+func TestShiftTTogglesAllTaskDetailsIndependently(t *testing.T) {
+	m := model{
+		config:       config.DefaultConfig(),
+		sessions:     map[string]*tmux.Session{},
+		bindings:     map[string]commandBinding{},
+		taskCounts:   map[string]int{},
+		taskCommands: map[string][]string{},
+		mode:         modeHome,
+		getwd:        func() (string, error) { return "/tmp", nil },
+	}
+	press := func(key string) {
+		t.Helper()
+		updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+		m = updated.(model)
+	}
+
+	press("T")
+	if !m.showAllTaskDetails || m.showTaskDetails {
+		t.Fatalf("T: got all=%v tasks=%v, want all only", m.showAllTaskDetails, m.showTaskDetails)
+	}
+	press("t")
+	if !m.showAllTaskDetails || !m.showTaskDetails {
+		t.Fatalf("T then t: got all=%v tasks=%v, want both", m.showAllTaskDetails, m.showTaskDetails)
+	}
+	press("T")
+	if m.showAllTaskDetails || !m.showTaskDetails {
+		t.Fatalf("T again: got all=%v tasks=%v, want tasks only", m.showAllTaskDetails, m.showTaskDetails)
+	}
+	if !m.showTaskLines() {
+		t.Fatal("expected task lines while t is on")
+	}
+}
+
+func TestShowAllTaskDetailsExpandsSummaryRows(t *testing.T) {
+	m := model{
+		config:       config.DefaultConfig(),
+		sessions:     map[string]*tmux.Session{},
+		bindings:     map[string]commandBinding{},
+		taskCounts:   map[string]int{},
+		taskCommands: map[string][]string{"codex-3": {"npm test"}},
+		viewState:    viewHome,
+		mode:         modeHome,
+		windowWidth:  120,
+		getwd:        func() (string, error) { return "/tmp", nil },
+	}
+	for i := 1; i <= 10; i++ {
+		name := "codex"
+		if i > 1 {
+			name = fmt.Sprintf("codex-%d", i)
+		}
+		m.sessions[name] = tmux.NewSession(name, "")
+		m.bindings[name] = commandBinding{SessionName: name, Tool: "codex", Running: true}
+	}
+	m.taskCounts["codex-3"] = 1
+
+	if view := m.View(); contains(view, "task: npm test") || !contains(view, "active:0") {
+		t.Fatalf("expected summary rows with 10 sessions, got: %s", view)
+	}
+	m.showTaskDetails = true
+	if view := m.View(); contains(view, "task: npm test") {
+		t.Fatalf("t alone should keep summary rows, got: %s", view)
+	}
+	m.showTaskDetails = false
+	m.showAllTaskDetails = true
+	if view := m.View(); !contains(view, "task: npm test") {
+		t.Fatalf("expected T to expand rows with task lines, got: %s", view)
+	}
+}
+
+func TestNoteEncodeDecodeRoundTrip(t *testing.T) {
+	for _, note := range []string{
+		"plain",
+		"line one\nline two",
+		`quotes "double" 'single' and $vars; semicolons`,
+		"unicode ✎ ünïcödé 日本語",
+	} {
+		encoded := encodeNote(note)
+		if strings.ContainsAny(encoded, "\n\"'; ") {
+			t.Errorf("encoded note %q is not tmux-safe: %q", note, encoded)
+		}
+		if got := decodeNote(encoded); got != note {
+			t.Errorf("round trip of %q = %q", note, got)
+		}
+	}
+	if encodeNote("") != "" || decodeNote("") != "" {
+		t.Fatal("expected empty notes to stay empty")
+	}
+	if got := decodeNote("not base64!"); got != "" {
+		t.Fatalf("expected invalid base64 to decode to empty, got %q", got)
+	}
+}
+
+func TestNoteLengthIsLimited(t *testing.T) {
+	long := strings.Repeat("é", maxNoteRunes+50)
+	if got := decodeNote(base64.StdEncoding.EncodeToString([]byte(long))); len([]rune(got)) != maxNoteRunes {
+		t.Fatalf("expected decoded note cut to %d runes, got %d", maxNoteRunes, len([]rune(got)))
+	}
+	many := "1\n2\n3\n4\n5\n6\n7"
+	if got := decodeNote(encodeNote(many)); got != "1\n2\n3\n4\n5" {
+		t.Fatalf("expected note cut to %d lines, got %q", maxNoteLines, got)
+	}
+}
+
+func TestNoteInputSupportsNewlinesAndSavesEncoded(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"codex": {SessionName: "codex", Running: true, Tool: "codex"},
+		},
+	}
+	original := setSessionOptionFn
+	defer func() { setSessionOptionFn = original }()
+	saved := map[string]string{}
+	setSessionOptionFn = func(sessionName, option, value string) error {
+		saved[sessionName+" "+option] = value
+		return nil
+	}
+
+	m = m.beginNoteTarget("codex")
+	if m.mode != modeNoteInput {
+		t.Fatalf("expected modeNoteInput, got %v", m.mode)
+	}
+	for _, msg := range []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune("waiting on CI")},
+		{Type: tea.KeyEnter, Alt: true},
+		{Type: tea.KeyRunes, Runes: []rune("then deploy")},
+		{Type: tea.KeyCtrlJ},
+		{Type: tea.KeyCtrlJ},
+		{Type: tea.KeyCtrlJ},
+		{Type: tea.KeyCtrlJ}, // a sixth line is refused
+	} {
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+	if m.noteInput != "waiting on CI\nthen deploy\n\n\n" {
+		t.Fatalf("noteInput = %q", m.noteInput)
+	}
+	if view := m.View(); !contains(view, "note for codex") || !contains(view, "note: waiting on CI") {
+		t.Fatalf("expected note input view, got: %s", view)
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(model)
+	if m.mode != modeHome || m.homeNotice != "saved note for codex" {
+		t.Fatalf("expected note saved, got mode=%v notice=%q", m.mode, m.homeNotice)
+	}
+	if got := decodeNote(saved["codex @pb_note"]); got != "waiting on CI\nthen deploy" {
+		t.Fatalf("expected trimmed note stored base64-encoded, got %q (raw %q)", got, saved["codex @pb_note"])
+	}
+	if m.bindings["codex"].Note != "waiting on CI\nthen deploy" {
+		t.Fatalf("expected binding note updated, got %q", m.bindings["codex"].Note)
+	}
+}
+
+func TestTooltipShowsNoteInBox(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{"codex": tmux.NewSession("codex", "")},
+		bindings: map[string]commandBinding{
+			"codex": {SessionName: "codex", Running: true, Tool: "codex", Note: "first\nsecond"},
+		},
+		windowWidth: 100,
+	}
+	rows := strings.Join(m.detailedRows("codex", []string{"codex"}), "\n")
+	if contains(rows, "first") {
+		t.Fatalf("expected note hidden until the tooltip shows, got:\n%s", rows)
+	}
+
+	m.tooltipSession = "codex"
+	m.tooltipShowAt = time.Now().Add(-time.Millisecond)
+	rows = stripANSI(strings.Join(m.detailedRows("codex", []string{"codex"}), "\n"))
+	for _, want := range []string{"╭", "│ first  │", "│ second │", "╰"} {
+		if !contains(rows, want) {
+			t.Fatalf("expected %q in boxed note, got:\n%s", want, rows)
+		}
+	}
+}
+
+func TestKillAndRenameModesIgnoreDisabledToolKeys(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tool("cursor").Enabled = false
+	sessions := map[string]*tmux.Session{
+		"codex":   tmux.NewSession("codex", cfg.Tool("codex").Command),
+		"codex-2": tmux.NewSession("codex-2", cfg.Tool("codex").Command),
+	}
+	if err := sessions["codex"].Start(); err != nil {
+		t.Skipf("tmux sessions cannot be started in this environment: %v", err)
+	}
+	if err := sessions["codex-2"].Start(); err != nil {
+		_ = sessions["codex"].Stop()
+		t.Skipf("tmux sessions cannot be started in this environment: %v", err)
+	}
+	defer sessions["codex"].Stop()
+	defer sessions["codex-2"].Stop()
+
+	tests := []struct {
+		name       string
+		mode       uiMode
+		pickMode   uiMode
+		unknownMsg string
+	}{
+		{"kill", modeKillTool, modePickKill, `Unknown kill target "q".`},
+		{"rename", modeRenameTool, modePickRename, `Unknown rename target "q".`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			press := func(key string) model {
+				m := model{
+					config:      cfg,
+					sessions:    sessions,
+					bindings:    map[string]commandBinding{},
+					windowWidth: 80,
+					viewState:   viewHome,
+					mode:        tt.mode,
+				}
+				updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)})
+				if cmd != nil {
+					t.Fatalf("%q should not return a command", key)
+				}
+				return updated.(model)
+			}
+
+			m := press(cfg.Tool("cursor").Key)
+			if m.mode != tt.mode {
+				t.Fatalf("disabled tool key should keep mode %v, got %v", tt.mode, m.mode)
+			}
+			if m.homeNotice != "" {
+				t.Fatalf("disabled tool key should be silent, got notice %q", m.homeNotice)
+			}
+
+			m = press(cfg.Tool("codex").Key)
+			if m.mode != tt.pickMode {
+				t.Fatalf("enabled tool key should open picker %v, got %v", tt.pickMode, m.mode)
+			}
+
+			m = press("q")
+			if m.homeNotice != tt.unknownMsg {
+				t.Fatalf("expected notice %q, got %q", tt.unknownMsg, m.homeNotice)
+			}
+		})
+	}
+}
+
+func TestConfigWarningShownAndDismissedWithW(t *testing.T) {
+	m := model{
+		config:        config.DefaultConfig(),
+		configWarning: `config error: duplicate key "c" (using defaults)`,
+		sessions:      map[string]*tmux.Session{},
+		bindings:      map[string]commandBinding{},
+		windowWidth:   80,
+		viewState:     viewHome,
+		mode:          modeHome,
+	}
+	view := m.View()
+	if !contains(view, `config error: duplicate key "c" (using defaults)`) || !contains(view, "(W dismiss)") {
+		t.Fatalf("expected config warning in view, got: %s", view)
+	}
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("W")})
+	m = updated.(model)
+	if cmd != nil {
+		t.Fatal("dismissing the warning should not return a command")
+	}
+	if m.configWarning != "" {
+		t.Fatalf("expected W to clear the warning, got %q", m.configWarning)
+	}
+	if contains(m.View(), "config error") {
+		t.Fatal("expected dismissed warning to be gone from the view")
+	}
+}
+
+func TestConfigReloadClearsConfigWarning(t *testing.T) {
+	origLoad := loadConfigFn
+	defer func() { loadConfigFn = origLoad }()
+	loadConfigFn = func() (*config.Config, error) { return config.DefaultConfig(), nil }
+
+	m := model{config: config.DefaultConfig(), configWarning: "config error: bad", sessions: map[string]*tmux.Session{}}
+	updated, _ := m.Update(configChangedMsg{})
+	if got := updated.(model).configWarning; got != "" {
+		t.Fatalf("expected successful reload to clear the warning, got %q", got)
+	}
+}
+
+func TestPickerSortToggleSwitchesBetweenImportanceAndName(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"claude":   {SessionName: "claude", Running: true, Tool: "claude"},
+			"claude-2": {SessionName: "claude-2", Running: true, Tool: "claude", Created: time.Now()},
+		},
+		windowWidth: 80,
+		viewState:   viewHome,
+	}
+
+	m = m.preparePicker("claude", modePickAttach)
+	if target, _ := m.pickerTarget("a"); target != "claude-2" {
+		t.Fatalf("importance sort should give a to the recently created session, got %q", target)
+	}
+	if !contains(m.View(), "tab toggle sort (by activity)") {
+		t.Fatalf("expected sort hint in picker footer, got: %s", m.View())
+	}
+
+	alpha := m.togglePickerSort()
+	if alpha.pickerSortOrder != sortAlpha {
+		t.Fatalf("expected alphabetical sort after toggle, got %v", alpha.pickerSortOrder)
+	}
+	want := []pickerEntry{{key: "a", session: "claude"}, {key: "b", session: "claude-2"}}
+	for i := range want {
+		if alpha.pickerEntries[i] != want[i] {
+			t.Fatalf("entry %d = %+v, want %+v", i, alpha.pickerEntries[i], want[i])
+		}
+	}
+	if !contains(alpha.View(), "tab toggle sort (by name)") {
+		t.Fatalf("expected name sort hint, got: %s", alpha.View())
+	}
+	if letters := alpha.pickerLetters("claude", []string{"claude-2", "claude"}); letters["claude"] != "a" {
+		t.Fatalf("row hints should follow the picker sort, got %v", letters)
+	}
+
+	back := alpha.togglePickerSort()
+	if target, _ := back.pickerTarget("a"); back.pickerSortOrder != sortImportance || target != "claude-2" {
+		t.Fatalf("expected toggling again to restore importance order, got %+v", back.pickerEntries)
+	}
+}
+
+func TestPickerKeysHoldStillWhileOpen(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"claude":   {SessionName: "claude", Running: true, Tool: "claude"},
+			"claude-2": {SessionName: "claude-2", Running: true, Tool: "claude", Created: time.Now()},
+		},
+		windowWidth: 80,
+		viewState:   viewHome,
+	}
+	names := []string{"claude", "claude-2"}
+
+	m = m.preparePicker("claude", modePickKill)
+	// A task in claude now outranks claude-2's recent start.
+	m.taskCounts = map[string]int{"claude": 1}
+	if letters := m.pickerLetters("claude", names); letters["claude-2"] != "a" || letters["claude"] != "b" {
+		t.Fatalf("row hints should keep the open picker's keys, got %v", letters)
+	}
+	m = m.setPickerFilter("claude")
+	if target, _ := m.pickerTarget("a"); target != "claude-2" {
+		t.Fatalf("filtering should not re-sort the open picker, got %+v", m.pickerEntries)
+	}
+
+	m.mode = modeHome
+	if letters := m.pickerLetters("claude", names); letters["claude"] != "a" {
+		t.Fatalf("row hints should follow importance once the picker closes, got %v", letters)
+	}
+}
+
+func TestPickerTabTogglesSortInEveryPickMode(t *testing.T) {
+	for _, mode := range []uiMode{modePickAttach, modePickKill, modePickRename} {
+		m := model{
+			config:   config.DefaultConfig(),
+			sessions: map[string]*tmux.Session{},
+			bindings: map[string]commandBinding{},
+		}
+		m = m.openPicker("claude", mode, []string{"claude-2", "claude"})
+		updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyTab})
+		m = updated.(model)
+		if cmd != nil || m.mode != mode {
+			t.Fatalf("mode %v: tab should stay in the picker, got mode %v", mode, m.mode)
+		}
+		if m.pickerSortOrder != sortAlpha {
+			t.Fatalf("mode %v: expected tab to switch to name sort", mode)
+		}
+		if target, _ := m.pickerTarget("a"); target != "claude" {
+			t.Fatalf("mode %v: expected a→claude after name sort, got %q", mode, target)
+		}
+	}
+}
+
+func TestPickerFilterNarrowsAndReassignsKeys(t *testing.T) {
+	for _, mode := range []uiMode{modePickAttach, modePickKill, modePickRename} {
+		m := model{
+			config:          config.DefaultConfig(),
+			sessions:        map[string]*tmux.Session{},
+			bindings:        map[string]commandBinding{},
+			windowWidth:     80,
+			viewState:       viewHome,
+			pickerSortOrder: sortAlpha,
+		}
+		m = m.openPicker("claude", mode, []string{"claude-api", "claude-web", "claude-worker"})
+		press := func(msg tea.KeyMsg) {
+			t.Helper()
+			updated, cmd := m.Update(msg)
+			m = updated.(model)
+			if cmd != nil || m.mode != mode {
+				t.Fatalf("mode %v: %q should stay in the picker, got mode %v", mode, msg.String(), m.mode)
+			}
+		}
+		typeText := func(text string) {
+			for _, r := range text {
+				press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+			}
+		}
+
+		press(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+		if !m.pickerFiltering {
+			t.Fatalf("mode %v: expected / to start filtering", mode)
+		}
+		typeText("wk")
+		want := []pickerEntry{{key: "a", session: "claude-worker"}}
+		if !reflect.DeepEqual(m.pickerEntries, want) {
+			t.Fatalf("mode %v: filter %q gave %+v, want %+v", mode, m.pickerFilter, m.pickerEntries, want)
+		}
+		if view := stripANSI(m.View()); !contains(view, "filter: wk") {
+			t.Fatalf("mode %v: expected filter prompt, got: %s", mode, view)
+		}
+
+		press(tea.KeyMsg{Type: tea.KeyBackspace})
+		want = []pickerEntry{{key: "a", session: "claude-web"}, {key: "b", session: "claude-worker"}}
+		if m.pickerFilter != "w" || !reflect.DeepEqual(m.pickerEntries, want) {
+			t.Fatalf("mode %v: after backspace got filter %q entries %+v", mode, m.pickerFilter, m.pickerEntries)
+		}
+
+		typeText("zz")
+		if len(m.pickerEntries) != 0 || !contains(stripANSI(m.View()), "no sessions match") {
+			t.Fatalf("mode %v: expected no matches for %q, got %+v", mode, m.pickerFilter, m.pickerEntries)
+		}
+
+		press(tea.KeyMsg{Type: tea.KeyEsc})
+		if m.pickerFiltering || m.pickerFilter != "" || len(m.pickerEntries) != 3 {
+			t.Fatalf("mode %v: esc should clear the filter, got filtering=%v filter=%q entries=%+v",
+				mode, m.pickerFiltering, m.pickerFilter, m.pickerEntries)
+		}
+	}
+}
+
+func TestPickerKeptFilterLeavesLettersForPicking(t *testing.T) {
+	m := model{
+		config:          config.DefaultConfig(),
+		sessions:        map[string]*tmux.Session{},
+		bindings:        map[string]commandBinding{},
+		pickerSortOrder: sortAlpha,
+	}
+	m = m.openPicker("claude", modePickRename, []string{"claude-api", "claude-web"})
+	for _, msg := range []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune("/")},
+		{Type: tea.KeyRunes, Runes: []rune("web")},
+		{Type: tea.KeyEnter},
+	} {
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+	if m.pickerFiltering || m.pickerFilter != "web" {
+		t.Fatalf("expected enter to keep filter %q and stop typing, got filtering=%v filter=%q", "web", m.pickerFiltering, m.pickerFilter)
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m = updated.(model)
+	if m.mode != modeRenameInput || m.renameTarget != "claude-web" {
+		t.Fatalf("expected a to pick the filtered claude-web, got mode %v target %q", m.mode, m.renameTarget)
+	}
+}
+
+func TestDetailedRowsFollowConfiguredColumnOrder(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.UI.Columns = []string{"repo", "yolo", "name", "key", "uptime"}
+	m := model{
+		config:   cfg,
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Running: true, Tool: "claude", Cwd: "/src/pocketbot", Yolo: true, Created: time.Now().Add(-2 * time.Hour)},
+		},
+		taskCounts:  map[string]int{"claude": 3},
+		windowWidth: 120,
+	}
+
+	rows := m.detailedRows("claude", []string{"claude"})
+	if len(rows) == 0 {
+		t.Fatal("expected a row")
+	}
+	got := stripANSI(rows[0])
+	want := "repo:pocketbot (yolo) 🟣 claude (c) up:2h00m"
+	if got != want {
+		t.Fatalf("row = %q, want %q", got, want)
+	}
+
+	m.config.UI.Columns = nil
+	got = stripANSI(m.detailedRows("claude", []string{"claude"})[0])
+	if want := "(c) 🟣 claude repo:pocketbot (yolo) tasks:3"; got != want {
+		t.Fatalf("default columns row = %q, want %q", got, want)
+	}
+}
+
+func TestKillOptionsUsesSessionThenToolPreKillKeys(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Tool("codex").PreKillKeys = "C-c"
+	cfg.Sessions = []config.SessionConfig{
+		{Name: "dev-server", Command: "npm run dev", Key: "d", PreKillKeys: "q"},
+		{Name: "codex-notes", Command: "vim notes", Key: "v", PreKillKeys: ":wq Enter"},
+	}
+	m := model{config: cfg, sessionTools: map[string]string{"codex-2": "codex", "codex-notes": "codex"}}
+
+	for name, want := range map[string]string{
+		"codex-2":     "C-c",
+		"dev-server":  "q",
+		"codex-notes": ":wq Enter",
+		"claude":      "",
+		"unknown":     "",
+	} {
+		if got := m.killOptions(name).PreKillKeys; got != want {
+			t.Errorf("killOptions(%q).PreKillKeys = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPickerKillPassesPreKillKeys(t *testing.T) {
+	origKill := killSessionFn
+	defer func() { killSessionFn = origKill }()
+	var gotName string
+	var gotOpts []tmux.KillOptions
+	killSessionFn = func(name string, opts ...tmux.KillOptions) error {
+		gotName, gotOpts = name, opts
+		return nil
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Tool("codex").PreKillKeys = "C-c"
+	m := model{
+		config:        cfg,
+		sessions:      map[string]*tmux.Session{},
+		sessionTools:  map[string]string{"codex-2": "codex"},
+		bindings:      map[string]commandBinding{},
+		mode:          modePickKill,
+		pickerTool:    "codex",
+		pickerEntries: []pickerEntry{{key: "a", session: "codex"}, {key: "b", session: "codex-2"}},
+	}
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("b")})
+	m = updated.(model)
+	if gotName != "" || m.homeNotice != "stopping codex-2..." {
+		t.Fatalf("the kill should run outside Update, got kill %q notice %q", gotName, m.homeNotice)
+	}
+	m = finishKillCmd(t, m, cmd)
+	if gotName != "codex-2" || len(gotOpts) != 1 || gotOpts[0].PreKillKeys != "C-c" {
+		t.Fatalf("kill called with %q %+v, want codex-2 with pre-kill keys C-c", gotName, gotOpts)
+	}
+	if m.homeNotice != "stopped codex-2" {
+		t.Fatalf("homeNotice = %q, want stopped codex-2", m.homeNotice)
+	}
+}
+
+func pagedHomeModel(n int) model {
+	bindings := map[string]commandBinding{}
+	for i := 1; i <= n; i++ {
+		name := fmt.Sprintf("claude-%02d", i)
+		bindings[name] = commandBinding{SessionName: name, Running: true, Tool: "claude"}
+	}
+	return model{
+		config:             config.DefaultConfig(),
+		sessions:           map[string]*tmux.Session{},
+		bindings:           bindings,
+		windowWidth:        80,
+		viewState:          viewHome,
+		mode:               modeHome,
+		showAllTaskDetails: true,
+	}
+}
+
+func TestHomePaginationSplitsRowsIntoPages(t *testing.T) {
+	m := pagedHomeModel(12)
+	if got := m.homePageCount(); got != 2 {
+		t.Fatalf("homePageCount() = %d, want 2", got)
+	}
+
+	view := stripANSI(m.View())
+	if !contains(view, "page 1/2") || !contains(view, "claude-01") || contains(view, "claude-12") {
+		t.Fatalf("expected first page with indicator, got: %s", view)
+	}
+	if lines := strings.Split(strings.TrimRight(view, "\n"), "\n"); len(lines) > homeMaxLines || contains(view, "...") {
+		t.Fatalf("paged view should fit in %d lines without truncation, got %d: %s", homeMaxLines, len(lines), view)
+	}
+
+	m.homePage = 1
+	view = stripANSI(m.View())
+	if !contains(view, "page 2/2") || !contains(view, "claude-12") || contains(view, "claude-01") {
+		t.Fatalf("expected second page, got: %s", view)
+	}
+
+	if small := pagedHomeModel(3); small.homePageCount() != 1 || contains(small.View(), "page 1/1") {
+		t.Fatal("a single page should not show an indicator")
+	}
+}
+
+func TestHomePaginationCountsRenderedLines(t *testing.T) {
+	m := pagedHomeModel(6)
+	m.taskCommands = map[string][]string{}
+	for _, name := range m.homeRowSessions() {
+		m.taskCommands[name] = []string{"npm run dev", "go vet"}
+	}
+	// Three lines a row leave room for three rows a page.
+	pages := m.homePages()
+	if len(pages) != 2 || len(pages[0]) != 3 || len(pages[1]) != 3 {
+		t.Fatalf("expected two pages of three rows, got %v", pages)
+	}
+	for page := range pages {
+		m.homePage = page
+		view := stripANSI(m.View())
+		if lines := strings.Split(strings.TrimRight(view, "\n"), "\n"); len(lines) > homeMaxLines || contains(view, "...") {
+			t.Fatalf("page %d should fit in %d lines without truncation, got %d: %s", page+1, homeMaxLines, len(lines), view)
+		}
+	}
+}
+
+func TestHomePaginationFollowsArrowFocus(t *testing.T) {
+	m := pagedHomeModel(12)
+	size := len(m.homePages()[0])
+	names := m.homeRowSessions()
+
+	m.tooltipSession = names[size-1]
+	m, _ = m.moveTooltipFocus(1)
+	if m.tooltipSession != names[size] || m.homePage != 1 {
+		t.Fatalf("down past the last visible row: focus %q page %d, want %q page 1", m.tooltipSession, m.homePage, names[size])
+	}
+
+	m, _ = m.moveTooltipFocus(-1)
+	if m.tooltipSession != names[size-1] || m.homePage != 0 {
+		t.Fatalf("up at the top row: focus %q page %d, want %q page 0", m.tooltipSession, m.homePage, names[size-1])
+	}
+
+	m.tooltipSession = names[len(names)-1]
+	m.homePage = 1
+	m, _ = m.moveTooltipFocus(1)
+	if m.tooltipSession != names[len(names)-1] || m.homePage != 1 {
+		t.Fatalf("down on the last page should be a no-op, got focus %q page %d", m.tooltipSession, m.homePage)
+	}
+
+	m.tooltipSession = names[0]
+	m.homePage = 0
+	m, _ = m.moveTooltipFocus(-1)
+	if m.tooltipSession != names[0] || m.homePage != 0 {
+		t.Fatalf("up on the first row should be a no-op, got focus %q page %d", m.tooltipSession, m.homePage)
+	}
+}
+
+func TestBeginSearchResetsHomePage(t *testing.T) {
+	m := pagedHomeModel(12)
+	m.homePage = 1
+	m, _ = m.beginSearch()
+	if m.mode != modeSearch || m.homePage != 0 {
+		t.Fatalf("expected search mode on page 0, got mode %v page %d", m.mode, m.homePage)
+	}
+}
+
+func TestResolveRepoDisplayUsesLongestAliasPrefix(t *testing.T) {
+	t.Setenv("HOME", "/home/me")
+	cfg := config.DefaultConfig()
+	cfg.UI.CwdAliases = map[string]string{
+		"/Users/alice/work":              "work",
+		"/Users/alice/work/clients/acme": "acme",
+		"~/src/":                         "src",
+	}
+	m := model{config: cfg}
+
+	tests := map[string]string{
+		"/Users/alice/work/clients/acme-corp/backend-api": "work/clients/acme-corp/backend-api",
+		"/Users/alice/work/clients/acme/backend-api":      "acme/backend-api",
+		"/Users/alice/work":                               "work",
+		"/Users/alice/workshop/tool":                      "tool",
+		"/home/me/src/pocketbot":                          "src/pocketbot",
+		"/opt/other/repo":                                 "repo",
+		"":                                                "-",
+	}
+	for cwd, want := range tests {
+		if got := m.resolveRepoDisplay(cwd); got != want {
+			t.Errorf("resolveRepoDisplay(%q) = %q, want %q", cwd, got, want)
+		}
+	}
+}
+
+func TestValidateKeyAssignmentsDetectsConflicts(t *testing.T) {
+	cfg := config.DefaultConfig()
+	m := model{config: cfg}
+	if err := m.validateKeyAssignments(); err != nil {
+		t.Fatalf("default config should have no key conflicts, got %v", err)
+	}
+
+	cfg.Sessions = []config.SessionConfig{{Name: "my-session", Command: "top", Key: "c"}}
+	if err := m.validateKeyAssignments(); err == nil || err.Error() != "key conflict: 'c' used by claude and my-session" {
+		t.Fatalf("expected claude/my-session conflict, got %v", err)
+	}
+
+	cfg.Sessions = []config.SessionConfig{{Name: "dev-server", Command: "npm run dev", Key: "d"}}
+	if err := m.validateKeyAssignments(); err == nil || err.Error() != "key conflict: 'd' used by quit and dev-server" {
+		t.Fatalf("expected a hotkey conflict, got %v", err)
+	}
+	for key, owner := range map[string]string{":": "command palette", "W": "dismiss config warning"} {
+		cfg.Sessions = []config.SessionConfig{{Name: "dev-server", Command: "npm run dev", Key: key}}
+		want := fmt.Sprintf("key conflict: '%s' used by %s and dev-server", key, owner)
+		if err := m.validateKeyAssignments(); err == nil || err.Error() != want {
+			t.Fatalf("expected %q, got %v", want, err)
+		}
+	}
+
+	// A disabled tool's key is free for a custom session.
+	cfg.Tool("claude").Enabled = false
+	cfg.Sessions = []config.SessionConfig{{Name: "my-session", Command: "top", Key: "c"}}
+	if err := m.validateKeyAssignments(); err != nil {
+		t.Fatalf("disabled tool key should not conflict, got %v", err)
+	}
+}
+
+func TestConfigReservesEveryHomeActionKey(t *testing.T) {
+	cfg := config.DefaultConfig()
+	for _, action := range homeActions() {
+		if action.key == "" {
+			continue
+		}
+		if owner, ok := cfg.ReservedKey(action.key); !ok || owner != action.name {
+			t.Errorf("home key %q is reserved in config for %q, want %q", action.key, owner, action.name)
+		}
+	}
+	for _, key := range []string{":", "W"} {
+		if _, ok := cfg.ReservedKey(key); !ok {
+			t.Errorf("home key %q is not reserved in config, so pb config validate misses clashes with it", key)
+		}
+	}
+}
+
+func TestKeyConflictNoticeAfterReloadAndSync(t *testing.T) {
+	origLoad, origList := loadConfigFn, listSessionsFn
+	defer func() { loadConfigFn, listSessionsFn = origLoad, origList }()
+	listSessionsFn = func() []string { return nil }
+
+	conflicting := config.DefaultConfig()
+	conflicting.Sessions = []config.SessionConfig{{Name: "my-session", Command: "top", Key: "c"}}
+	loadConfigFn = func() (*config.Config, error) { return conflicting, nil }
+
+	m := model{config: config.DefaultConfig(), sessions: map[string]*tmux.Session{}}
+	updated, _ := m.Update(configChangedMsg{})
+	if got := updated.(model).homeNotice; got != "key conflict: 'c' used by claude and my-session" {
+		t.Fatalf("homeNotice after reload = %q", got)
+	}
+
+	m = model{config: conflicting, sessions: map[string]*tmux.Session{}}
+	m.syncSessionsWithTmux()
+	if m.homeNotice != "key conflict: 'c' used by claude and my-session" {
+		t.Fatalf("homeNotice after sync added sessions = %q", m.homeNotice)
+	}
+}
+
+func TestKillAllTasksKillsEveryTaskAndCountsErrors(t *testing.T) {
+	originalKill, originalTasks := killTaskPIDFn, sessionUserTasksFn
+	defer func() { killTaskPIDFn, sessionUserTasksFn = originalKill, originalTasks }()
+
+	sessionUserTasksFn = func(name string) ([]tmux.Task, error) {
+		switch name {
+		case "claude":
+			return []tmux.Task{{PID: 101}, {PID: 102}}, nil
+		case "codex":
+			return []tmux.Task{{PID: 201}, {PID: 202}, {PID: 203}}, nil
+		default:
+			return nil, errors.New("ps failed")
+		}
+	}
+	var mu sync.Mutex
+	var killedPIDs []int
+	killTaskPIDFn = func(pid int) error {
+		mu.Lock()
+		defer mu.Unlock()
+		killedPIDs = append(killedPIDs, pid)
+		if pid == 202 || pid == 203 {
+			return errors.New("no such process")
+		}
+		return nil
+	}
+
+	m := model{
+		config: config.DefaultConfig(),
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Running: true},
+			"codex":  {SessionName: "codex", Running: true},
+			"logs":   {SessionName: "logs", Running: true},
+			"old":    {SessionName: "old"},
+		},
+	}
+	killed, errs := m.killAllTasks()
+	if killed != 3 || errs != 2 {
+		t.Fatalf("killAllTasks() = %d, %d; want 3, 2", killed, errs)
+	}
+	slices.Sort(killedPIDs)
+	if want := []int{101, 102, 201, 202, 203}; !slices.Equal(killedPIDs, want) {
+		t.Fatalf("killed pids %v, want %v", killedPIDs, want)
+	}
+
+	// runKillAll runs the command T schedules once confirmed.
+	runKillAll := func(m model) model {
+		t.Helper()
+		updated, _ := m.Update(m.killAllTasksCmd()())
+		return updated.(model)
+	}
+
+	m = runKillAll(m)
+	if m.homeNotice != "killed 3 tasks, 2 errors" {
+		t.Fatalf("homeNotice = %q, want killed 3 tasks, 2 errors", m.homeNotice)
+	}
+
+	killTaskPIDFn = func(int) error { return nil }
+	m = runKillAll(m)
+	if m.homeNotice != "killed 5 tasks" {
+		t.Fatalf("homeNotice = %q, want killed 5 tasks", m.homeNotice)
+	}
+
+	sessionUserTasksFn = func(string) ([]tmux.Task, error) { return nil, nil }
+	m = runKillAll(m)
+	if m.homeNotice != "no tasks to kill" {
+		t.Fatalf("homeNotice = %q, want no tasks to kill", m.homeNotice)
+	}
+}
+
+func TestKillAllTasksAsksForConfirmation(t *testing.T) {
+	m := model{config: config.DefaultConfig(), mode: modeKillTool}
+	m, cmd := m.enterTaskKillAllMode()
+	if m.mode != modeConfirmKillAllTasks || cmd != nil {
+		t.Fatalf("expected T to ask for confirmation, got mode %v", m.mode)
+	}
+	if view := stripANSI(m.View()); !contains(view, "kill all tasks in every session?") {
+		t.Fatalf("expected the confirmation prompt, got:\n%s", view)
+	}
+
+	updated, cmd := m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	got := updated.(model)
+	if got.mode != modeHome || cmd != nil || got.homeNotice != "kill cancelled" {
+		t.Fatalf("expected n to cancel, got mode %v notice %q", got.mode, got.homeNotice)
+	}
+
+	updated, cmd = m.updateHome(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	got = updated.(model)
+	if got.mode != modeHome || cmd == nil {
+		t.Fatalf("expected y to schedule the kill, got mode %v", got.mode)
+	}
+}
+
+func TestHomeViewSeparatesToolGroups(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Running: true, Tool: "claude"},
+			"codex":  {SessionName: "codex", Running: true, Tool: "codex"},
+		},
+		windowWidth: 80,
+		viewState:   viewHome,
+		mode:        modeHome,
+	}
+	if got := m.toolsWithRunningSessions(allTools(m.config)); !slices.Equal(got, []string{"claude", "codex"}) {
+		t.Fatalf("toolsWithRunningSessions() = %v, want [claude codex]", got)
+	}
+
+	rule := strings.Repeat("─", 80)
+	lines := strings.Split(stripANSI(m.View()), "\n")
+	claudeRow, codexRow, cursorRow := -1, -1, -1
+	var rules []int
+	for i, line := range lines {
+		switch {
+		case line == rule:
+			rules = append(rules, i)
+		case strings.Contains(line, "claude repo:"):
+			claudeRow = i
+		case strings.Contains(line, "codex repo:"):
+			codexRow = i
+		case strings.Contains(line, "cursor repo:"):
+			cursorRow = i
+		}
+	}
+	if len(rules) != 2 || rules[0] != claudeRow+1 || codexRow != rules[0]+1 || rules[1] != codexRow+1 || cursorRow != rules[1]+1 {
+		t.Fatalf("expected a rule between each tool group, got rules at %v in:\n%s", rules, strings.Join(lines, "\n"))
+	}
+	if got := len(m.homeRowLines()); got != 5 {
+		t.Fatalf("homeRowLines() has %d lines, want 3 rows and 2 rules", got)
+	}
+}
+
+func TestHomeViewHasNoSeparatorForSingleTool(t *testing.T) {
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"claude":   {SessionName: "claude", Running: true, Tool: "claude"},
+			"claude-2": {SessionName: "claude-2", Running: true, Tool: "claude"},
+		},
+		windowWidth: 80,
+		viewState:   viewHome,
+		mode:        modeHome,
+	}
+	if view := m.View(); contains(view, "─────") {
+		t.Fatalf("expected no group separator with one running tool, got:\n%s", view)
+	}
+}
+
+func TestIsErrorNoticeCategorizesNotices(t *testing.T) {
+	for notice, want := range map[string]bool{
+		"failed to stop codex: exit 1":   true,
+		"error reading config":           true,
+		"Error: tmux not found":          true,
+		"stopped codex":                  false,
+		"config reloaded":                false,
+		"Unknown kill target \"q\".":     false,
+		"config reload failed: bad yaml": false,
+		"":                               false,
+	} {
+		if got := isErrorNotice(notice); got != want {
+			t.Errorf("isErrorNotice(%q) = %v, want %v", notice, got, want)
+		}
+	}
+}
+
+func TestErrorLogKeepsMostRecentEntries(t *testing.T) {
+	var m model
+	start := time.Now()
+	for i := 0; i < maxErrorLog+5; i++ {
+		m = m.logError(fmt.Sprintf("failed %d", i), start.Add(time.Duration(i)*time.Second))
+	}
+	if len(m.errorLog) != maxErrorLog {
+		t.Fatalf("error log has %d entries, want %d", len(m.errorLog), maxErrorLog)
+	}
+	if m.errorLog[0].msg != "failed 5" || m.errorLog[maxErrorLog-1].msg != fmt.Sprintf("failed %d", maxErrorLog+4) {
+		t.Fatalf("expected oldest entries dropped, got first %q last %q", m.errorLog[0].msg, m.errorLog[maxErrorLog-1].msg)
+	}
+	if m = m.clearErrorLog(); len(m.errorLog) != 0 {
+		t.Fatalf("clearErrorLog left %d entries", len(m.errorLog))
+	}
+}
+
+func TestUpdateLogsErrorNoticesForHelp(t *testing.T) {
+	origKill := killSessionFn
+	defer func() { killSessionFn = origKill }()
+	killSessionFn = func(string, ...tmux.KillOptions) error { return errors.New("no server") }
+
+	m := model{
+		config:        config.DefaultConfig(),
+		sessions:      map[string]*tmux.Session{},
+		bindings:      map[string]commandBinding{},
+		windowWidth:   80,
+		viewState:     viewHome,
+		mode:          modePickKill,
+		pickerTool:    "codex",
+		pickerEntries: []pickerEntry{{key: "a", session: "codex"}, {key: "b", session: "codex-2"}},
+	}
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	m = finishKillCmd(t, updated.(model), cmd)
+	if len(m.errorLog) != 1 || m.errorLog[0].msg != "failed to stop codex: no server" {
+		t.Fatalf("expected the failure to be logged, got %+v", m.errorLog)
+	}
+
+	m, _ = m.showHelp()
+	view := stripANSI(m.View())
+	if !contains(view, "recent errors: 1, latest") || !contains(view, "E show   any other key closes") {
+		t.Fatalf("expected recent errors in help, got:\n%s", view)
+	}
+	if lines := strings.Split(strings.TrimRight(view, "\n"), "\n"); len(lines) > homeMaxLines || contains(view, "...") {
+		t.Fatalf("help with errors should fit without truncation:\n%s", view)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("E")})
+	m = updated.(model)
+	view = stripANSI(m.View())
+	if m.mode != modeHelp || !contains(view, "recent errors (newest first)") || !contains(view, "failed to stop codex: no server") || contains(view, "actions (: to run by name)") {
+		t.Fatalf("E should show only the error log, got:\n%s", view)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("E")})
+	m = updated.(model)
+	if len(m.errorLog) != 0 || !contains(stripANSI(m.View()), "(none)") {
+		t.Fatalf("E in the error log should clear it, got %+v", m.errorLog)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	m = updated.(model)
+	if m.mode != modeHome || m.helpShowErrors {
+		t.Fatalf("any other key should close help, got mode %v showErrors %v", m.mode, m.helpShowErrors)
+	}
+}
+
+// finishKillCmd runs the command a kill returned and feeds its result back
+// through Update, as Bubble Tea would.
+func finishKillCmd(t *testing.T, m model, cmd tea.Cmd) model {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("expected a kill command")
+	}
+	msg, ok := cmd().(sessionKilledMsg)
+	if !ok {
+		t.Fatalf("expected a sessionKilledMsg, got %T", msg)
+	}
+	updated, _ := m.Update(msg)
+	return updated.(model)
+}
+
+func doubleTapModel() model {
+	cfg := config.DefaultConfig()
+	cfg.UI.DoubleTapToKill = true
+	now := time.Now()
+	return model{
+		config:   cfg,
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"codex":   {SessionName: "codex", Running: true, Tool: "codex", Created: now.Add(-time.Hour)},
+			"codex-2": {SessionName: "codex-2", Running: true, Tool: "codex", Created: now.Add(-time.Minute)},
+		},
+		windowWidth: 80,
+		viewState:   viewHome,
+		mode:        modeHome,
+	}
+}
+
+func TestDoubleTapToolKeyKillsNewestSession(t *testing.T) {
+	origKill := killSessionFn
+	defer func() { killSessionFn = origKill }()
+	var killed []string
+	killSessionFn = func(name string, _ ...tmux.KillOptions) error {
+		killed = append(killed, name)
+		return nil
+	}
+
+	m := doubleTapModel()
+	start := time.Now()
+	m, cmd := m.handleToolTap("codex", "x", start)
+	if cmd == nil || m.lastKey != "x" || m.mode != modeHome || len(killed) != 0 {
+		t.Fatalf("first tap should wait for the window, got mode %v lastKey %q killed %v", m.mode, m.lastKey, killed)
+	}
+	first := doubleTapMsg{key: "x", at: start}
+
+	m, cmd = m.handleToolTap("codex", "x", start.Add(200*time.Millisecond))
+	m = finishKillCmd(t, m, cmd)
+	if !slices.Equal(killed, []string{"codex-2"}) {
+		t.Fatalf("double tap should kill the newest codex session, killed %v", killed)
+	}
+	if m.homeNotice != "double-tap kill: killed codex-2" || m.lastKey != "" {
+		t.Fatalf("unexpected state after double tap: notice %q lastKey %q", m.homeNotice, m.lastKey)
+	}
+
+	// The first tap's window closing later must not attach.
+	m, cmd = m.finishToolTap(first)
+	if cmd != nil || m.mode != modeHome || m.shouldAttach {
+		t.Fatalf("consumed tap should be ignored, got mode %v", m.mode)
+	}
+}
+
+func TestSingleToolKeyTapAttachesAfterWindow(t *testing.T) {
+	origKill := killSessionFn
+	defer func() { killSessionFn = origKill }()
+	killSessionFn = func(name string, _ ...tmux.KillOptions) error {
+		t.Fatalf("single taps must not kill, killed %s", name)
+		return nil
+	}
+
+	m := doubleTapModel()
+	start := time.Now()
+	m, _ = m.handleToolTap("codex", "x", start)
+	// A second press after the window is a new single tap.
+	m, _ = m.handleToolTap("codex", "x", start.Add(doubleTapWindow+time.Millisecond))
+	if m.lastKeyTime.Equal(start) {
+		t.Fatal("late second press should restart the window")
+	}
+	if next, _ := m.finishToolTap(doubleTapMsg{key: "x", at: start}); next.mode != modeHome {
+		t.Fatalf("superseded tap should be ignored, got mode %v", next.mode)
+	}
+
+	updated, _ := m.Update(doubleTapMsg{key: "x", at: m.lastKeyTime})
+	m = updated.(model)
+	if m.mode != modePickAttach || m.pickerTool != "codex" || m.lastKey != "" {
+		t.Fatalf("expected the attach picker once the window closed, got mode %v tool %q", m.mode, m.pickerTool)
+	}
+}
+
+func TestCPUBadgeOnlyAboveThreshold(t *testing.T) {
+	origProfile := lipgloss.ColorProfile()
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	defer lipgloss.SetColorProfile(origProfile)
+
+	cfg := config.DefaultConfig()
+	m := model{
+		config: cfg,
+		bindings: map[string]commandBinding{
+			"codex":   {SessionName: "codex", Running: true, Tool: "codex"},
+			"codex-2": {SessionName: "codex-2", Running: true, Tool: "codex"},
+		},
+		sessionCpuPct: map[string]float64{"codex": 95.4, "codex-2": 80},
+		windowWidth:   80,
+	}
+	if got := m.cpuWarnThreshold(); got != config.DefaultCPUWarnThreshold {
+		t.Fatalf("default threshold = %v, want %v", got, config.DefaultCPUWarnThreshold)
+	}
+	if got := m.cpuBadge("codex"); got != "cpu:95%" {
+		t.Fatalf("expected a badge above the threshold, got %q", got)
+	}
+	if got := m.cpuBadge("codex-2"); got != "" {
+		t.Fatalf("a sample at the threshold should not be flagged, got %q", got)
+	}
+	if got := m.cpuBadge("missing"); got != "" {
+		t.Fatalf("unsampled sessions should not be flagged, got %q", got)
+	}
+
+	alertStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#4DA3FF"))
+	rows := strings.Join(m.detailedRows("codex", []string{"codex", "codex-2"}), "\n")
+	if !contains(rows, alertStyle.Render("cpu:95%")) || contains(stripANSI(rows), "cpu:80%") {
+		t.Fatalf("expected an alert-styled badge only for codex, got:\n%s", stripANSI(rows))
+	}
+
+	cfg.UI.CPUWarnThreshold = 50
+	if got := m.cpuBadge("codex-2"); got != "cpu:80%" {
+		t.Fatalf("expected a badge with a lower threshold, got %q", got)
+	}
+}
+
+func TestCPUUpdateMsgStoresSampleAndReschedules(t *testing.T) {
+	m := model{
+		config:        config.DefaultConfig(),
+		sessionCpuPct: map[string]float64{"old": 99},
+		viewState:     viewHome,
+	}
+	updated, cmd := m.Update(cpuUpdateMsg{pct: map[string]float64{"codex": 12.5}})
+	m = updated.(model)
+	if cmd == nil {
+		t.Fatal("expected the next CPU sample to be scheduled")
+	}
+	if !maps.Equal(m.sessionCpuPct, map[string]float64{"codex": 12.5}) {
+		t.Fatalf("expected the sample to replace the old one, got %v", m.sessionCpuPct)
+	}
+}
+
+func TestTerminalTitleFollowsStateWhenEnabled(t *testing.T) {
+	requireTmuxSessionCreation(t)
+
+	// Pressing x below starts a real codex session; keep it off the
+	// default socket.
+	t.Setenv("PB_LEVEL", fmt.Sprintf("itest-title-%d", time.Now().UnixNano()))
+	defer tmux.KillServer()
+
+	m := model{
+		config: config.DefaultConfig(),
+		bindings: map[string]commandBinding{
+			"codex":   {SessionName: "codex", Running: true, Tool: "codex"},
+			"codex-2": {SessionName: "codex-2", Running: true, Tool: "codex"},
+		},
+		sessions:    map[string]*tmux.Session{},
+		windowWidth: 80,
+		viewState:   viewHome,
+	}
+	if got := m.terminalTitle(); got != "pb: 2 sessions active" {
+		t.Fatalf("terminalTitle() = %q", got)
+	}
+	if view := m.View(); contains(view, "\033]") {
+		t.Fatalf("the title must not be drawn into the view, got %q", view)
+	}
+	if cmd := m.titleCmd(); cmd == nil {
+		t.Fatal("expected Init's title command")
+	}
+
+	// Choosing a session retitles before the attach quits the program.
+	m.shouldAttach, m.sessionToAttach = true, "evil\007name\n"
+	if got := m.terminalTitle(); got != "pb → evilname" {
+		t.Fatalf("expected control characters dropped from the title, got %q", got)
+	}
+	m.shouldAttach, m.sessionToAttach = false, ""
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	if got := updated.(model); !got.shouldAttach || cmd == nil {
+		t.Fatalf("expected x to attach codex with a command, got attach=%v", got.shouldAttach)
+	}
+	if _, ok := cmd().(tea.QuitMsg); ok {
+		t.Fatal("expected the title to be set before the quit")
+	}
+
+	// An unchanged title sends nothing.
+	if _, cmd := m.Update(tooltipMsg{}); cmd != nil {
+		t.Fatal("expected no command when the title is unchanged")
+	}
+
+	m.config.UI.TerminalTitle = false
+	if got := m.terminalTitle(); got != "" || m.titleCmd() != nil {
+		t.Fatalf("terminal_title: false should leave the title alone, got %q", got)
+	}
+}
+
+func TestDirLockWriteReadRemove(t *testing.T) {
+	dir := t.TempDir()
+	if got := dirLockPath(dir, "codex"); got != filepath.Join(dir, ".pocketbot", "codex.lock") {
+		t.Fatalf("dirLockPath = %q", got)
+	}
+	if got := readDirLock(dir, "codex"); got != "" {
+		t.Fatalf("expected no lock yet, got %q", got)
+	}
+	if err := writeDirLock(dir, "codex", "codex-2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readDirLock(dir, "codex"); got != "codex-2" {
+		t.Fatalf("readDirLock = %q, want codex-2", got)
+	}
+
+	// Only the holder's kill removes the lock.
+	removeDirLock(dir, "codex", "codex")
+	if got := readDirLock(dir, "codex"); got != "codex-2" {
+		t.Fatalf("another session's kill should keep the lock, got %q", got)
+	}
+	removeDirLock(dir, "codex", "codex-2")
+	if _, err := os.Stat(filepath.Join(dir, ".pocketbot")); !os.IsNotExist(err) {
+		t.Fatalf("expected the empty .pocketbot dir removed, stat err=%v", err)
+	}
+}
+
+func TestCreateAndAttachToolHonorsDirLock(t *testing.T) {
+	origExists, origReadOnly, origOpts := sessionExistsFn, readOnlyFn, getSessionPBOptionsFn
+	defer func() {
+		sessionExistsFn, readOnlyFn, getSessionPBOptionsFn = origExists, origReadOnly, origOpts
+	}()
+	dir := t.TempDir()
+	if err := writeDirLock(dir, "codex", "codex-3"); err != nil {
+		t.Fatal(err)
+	}
+	opts := map[string]string{"@pb_cwd": dir, "@pb_tool": "codex"}
+	getSessionPBOptionsFn = func(string) (map[string]string, error) { return opts, nil }
+
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{},
+		getwd:    func() (string, error) { return dir, nil },
+	}
+	sessionExistsFn = func(name string) bool { return name == "codex-3" }
+	next, cmd := m.createAndAttachTool("codex")
+	if !next.shouldAttach || next.sessionToAttach != "codex-3" || cmd == nil {
+		t.Fatalf("expected to attach to the locked session, got attach=%v target=%q", next.shouldAttach, next.sessionToAttach)
+	}
+
+	// A reused name now running elsewhere does not hold the lock.
+	readOnlyFn = func() bool { return true }
+	opts = map[string]string{"@pb_cwd": "/elsewhere", "@pb_tool": "codex"}
+	next, _ = m.createAndAttachTool("codex")
+	if next.shouldAttach || readDirLock(dir, "codex") != "" {
+		t.Fatalf("expected a lock on a session in another dir dropped, got attach=%v target=%q", next.shouldAttach, next.sessionToAttach)
+	}
+
+	// Nor does one running another tool.
+	if err := writeDirLock(dir, "codex", "codex-3"); err != nil {
+		t.Fatal(err)
+	}
+	opts = map[string]string{"@pb_cwd": dir, "@pb_tool": "claude"}
+	next, _ = m.createAndAttachTool("codex")
+	if next.shouldAttach || readDirLock(dir, "codex") != "" {
+		t.Fatalf("expected a lock on another tool's session dropped, got attach=%v target=%q", next.shouldAttach, next.sessionToAttach)
+	}
+
+	// A lock naming a session that no longer exists is stale: it is dropped
+	// and creation goes ahead (refused here by read-only mode, so nothing is
+	// actually started).
+	if err := writeDirLock(dir, "codex", "codex-3"); err != nil {
+		t.Fatal(err)
+	}
+	sessionExistsFn = func(string) bool { return false }
+	next, _ = m.createAndAttachTool("codex")
+	if next.shouldAttach || !strings.HasPrefix(next.homeNotice, "read-only") {
+		t.Fatalf("expected creation to proceed past the stale lock, got attach=%v notice=%q", next.shouldAttach, next.homeNotice)
+	}
+	if got := readDirLock(dir, "codex"); got != "" {
+		t.Fatalf("expected the stale lock removed, got %q", got)
+	}
+}
+
+func TestKillReleasesDirLock(t *testing.T) {
+	origKill := killSessionFn
+	defer func() { killSessionFn = origKill }()
+	killSessionFn = func(string, ...tmux.KillOptions) error { return nil }
+	dir := t.TempDir()
+	if err := writeDirLock(dir, "codex", "codex"); err != nil {
+		t.Fatal(err)
+	}
+
+	m := model{
+		config:   config.DefaultConfig(),
+		sessions: map[string]*tmux.Session{},
+		bindings: map[string]commandBinding{
+			"codex": {SessionName: "codex", Running: true, Tool: "codex", Cwd: dir},
+		},
+	}
+	m, cmd := m.handleToolKill("codex")
+	m = finishKillCmd(t, m, cmd)
+	if m.homeNotice != "stopped codex" {
+		t.Fatalf("unexpected notice %q", m.homeNotice)
+	}
+	if got := readDirLock(dir, "codex"); got != "" {
+		t.Fatalf("expected the kill to release the lock, got %q", got)
+	}
+}
+
+func TestShiftFCyclesTaskFilterPresets(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.UI.TaskFilterPresets = []config.TaskFilterPreset{
+		{Name: "build", NoisePatterns: []string{"node"}},
+		{Name: "quiet", NoisePatterns: []string{"tail -f"}},
+	}
+	defer applyTaskPatterns(config.DefaultConfig())
+
+	m := model{
+		config:      cfg,
+		sessions:    map[string]*tmux.Session{},
+		bindings:    map[string]commandBinding{},
+		windowWidth: 80,
+		viewState:   viewHome,
+		mode:        modeHome,
+	}
+	press := func() {
+		t.Helper()
+		updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("F")})
+		m = updated.(model)
+	}
+
+	for _, want := range []string{"build", "quiet"} {
+		press()
+		if m.activePresetName() != want || m.homeNotice != "task filter: "+want {
+			t.Fatalf("expected preset %q, got %q (notice %q)", want, m.activePresetName(), m.homeNotice)
+		}
+		if title := strings.SplitN(stripANSI(m.View()), "\n", 2)[0]; !contains(title, "filter:"+want) {
+			t.Fatalf("expected filter:%s in the title, got %q", want, title)
+		}
+	}
+	press()
+	if m.activePreset != 0 || m.homeNotice != "task filter: default" || contains(strings.SplitN(stripANSI(m.View()), "\n", 2)[0], "filter:") {
+		t.Fatalf("expected F to wrap back to the default filter, got preset %d notice %q", m.activePreset, m.homeNotice)
+	}
+
+	// Dropping presets on reload falls back to the default filter.
+	press()
+	origLoad := loadConfigFn
+	defer func() { loadConfigFn = origLoad }()
+	loadConfigFn = func() (*config.Config, error) { return config.DefaultConfig(), nil }
+	m = m.reloadConfig()
+	if m.activePreset != 0 {
+		t.Fatalf("expected reload without presets to reset the filter, got %d", m.activePreset)
+	}
+}
+
+func TestTaskFilterPresetReplacesNoise(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.TaskPatterns = config.TaskPatterns{Noise: []string{"node"}, Highlight: []string{"make"}}
+	cfg.UI.TaskFilterPresets = []config.TaskFilterPreset{
+		{Name: "build", NoisePatterns: []string{"sleep"}},
+		{Name: "all", NoisePatterns: []string{"sleep"}, HighlightPatterns: []string{"go"}, Replace: true},
+	}
+
+	if got := taskFilterOptions(cfg, 0); !slices.Equal(got.Noise, []string{"node"}) {
+		t.Errorf("default noise = %v, want [node]", got.Noise)
+	}
+	if got := taskFilterOptions(cfg, 1); !slices.Equal(got.Noise, []string{"node", "sleep"}) {
+		t.Errorf("build noise = %v, want the preset added to [node]", got.Noise)
+	}
+	got := taskFilterOptions(cfg, 2)
+	if !slices.Equal(got.Noise, []string{"sleep"}) {
+		t.Errorf("all noise = %v, want [sleep] alone", got.Noise)
+	}
+	if !slices.Equal(got.Highlight, []string{"make", "go"}) {
+		t.Errorf("all highlight = %v, want highlights still added", got.Highlight)
+	}
+}
+
+func TestShiftFIgnoredWithoutPresets(t *testing.T) {
+	m := model{
+		config:    config.DefaultConfig(),
+		sessions:  map[string]*tmux.Session{},
+		bindings:  map[string]commandBinding{},
+		viewState: viewHome,
+		mode:      modeHome,
+	}
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("F")})
+	if got := updated.(model); got.activePreset != 0 || got.homeNotice != "" {
+		t.Fatalf("F should do nothing without presets, got preset %d notice %q", got.activePreset, got.homeNotice)
+	}
+}
+
+func TestEffectiveWidthCapsWideTerminals(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.UI.MaxRenderWidth = 120
+	m := model{config: cfg, windowWidth: 200}
+	if got := m.effectiveWidth(); got != 120 {
+		t.Fatalf("effectiveWidth() at 200 columns = %d, want 120", got)
+	}
+	m.windowWidth = 80
+	if got := m.effectiveWidth(); got != 80 {
+		t.Fatalf("effectiveWidth() at 80 columns = %d, want 80", got)
+	}
+	m.config = config.DefaultConfig()
+	m.windowWidth = 300
+	if got := m.effectiveWidth(); got != config.DefaultMaxRenderWidth {
+		t.Fatalf("effectiveWidth() with no max_render_width = %d, want %d", got, config.DefaultMaxRenderWidth)
+	}
+}
+
+func TestViewHomeClipsLinesToEffectiveWidth(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.UI.MaxRenderWidth = 50
+	m := model{
+		config: cfg,
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Running: true, Tool: "claude", Memo: strings.Repeat("long memo ", 20)},
+			"codex":  {SessionName: "codex", Running: true, Tool: "codex"},
+		},
+		sessions:    map[string]*tmux.Session{},
+		windowWidth: 200,
+		viewState:   viewHome,
+	}
+	view := stripANSI(m.View())
+	for _, line := range strings.Split(strings.TrimRight(view, "\n"), "\n") {
+		if w := lipgloss.Width(line); w > 50 {
+			t.Fatalf("line wider than max_render_width (%d): %q", w, line)
+		}
+	}
+	if !contains(view, strings.Repeat("─", 50)) || contains(view, strings.Repeat("─", 51)) {
+		t.Fatalf("expected the group separator drawn at the capped width, got:\n%s", view)
+	}
+}
+
+func TestDetailedRowsShortenRepoToKeepBadgesInsideWidth(t *testing.T) {
+	repo := "/src/" + strings.Repeat("very-long-repository-name-", 6)
+	m := model{
+		config: config.DefaultConfig(),
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Cwd: repo, Running: true, Tool: "claude"},
+		},
+		sessions:        map[string]*tmux.Session{},
+		sessionWarnings: map[string]string{"claude": "high memory"},
+		windowWidth:     200,
+	}
+	rows := m.detailedRows("claude", []string{"claude"})
+	if len(rows) != 1 {
+		t.Fatalf("expected one row, got %d: %v", len(rows), rows)
+	}
+	row := stripANSI(rows[0])
+	if w := lipgloss.Width(row); w > config.DefaultMaxRenderWidth {
+		t.Fatalf("row wider than %d columns (%d): %q", config.DefaultMaxRenderWidth, w, row)
+	}
+	if !strings.HasSuffix(row, "⚠ high memory") {
+		t.Fatalf("expected the warning badge to survive, got: %q", row)
+	}
+	if !contains(row, "repo:very-long") || !contains(row, "…") {
+		t.Fatalf("expected a shortened repo, got: %q", row)
+	}
+}
+
+func TestViewHomeFollowsConfiguredSections(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.UI.TerminalTitle = false
+	m := model{
+		config: cfg,
+		bindings: map[string]commandBinding{
+			"claude": {SessionName: "claude", Running: true, Tool: "claude"},
+		},
+		sessions:    map[string]*tmux.Session{},
+		homeNotice:  "saved",
+		windowWidth: 100,
+		viewState:   viewHome,
+	}
+	index := func(view, substr string) int {
+		t.Helper()
+		i := strings.Index(view, substr)
+		if i < 0 {
+			t.Fatalf("expected %q in view:\n%s", substr, view)
+		}
+		return i
+	}
+
+	view := stripANSI(m.viewHome())
+	if !(index(view, "Welcome to PocketBot") < index(view, "dir: ") &&
+		index(view, "dir: ") < index(view, "saved") &&
+		index(view, "saved") < index(view, "🟣 claude") &&
+		index(view, "🟣 claude") < index(view, "jump-dir")) {
+		t.Fatalf("default sections out of order:\n%s", view)
+	}
+
+	m.config.UI.Sections = []string{"hotkeys", "sessions", "title", "notice"}
+	view = stripANSI(m.viewHome())
+	if !strings.HasPrefix(view, "z jump-dir") {
+		t.Fatalf("expected hotkeys first, got:\n%s", view)
+	}
+	if !(index(view, "jump-dir") < index(view, "🟣 claude") &&
+		index(view, "🟣 claude") < index(view, "Welcome to PocketBot") &&
+		index(view, "Welcome to PocketBot") < index(view, "saved")) {
+		t.Fatalf("sections not reordered:\n%s", view)
+	}
+	if contains(view, "dir: ") {
+		t.Fatalf("expected the dir section dropped, got:\n%s", view)
+	}
+	if got := m.renderSection("footer"); len(got) != 0 {
+		t.Fatalf("unknown section rendered %q", got)
+	}
+}
+
+func TestRenderActivitySparkline(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		history []bool
+		want    string
+	}{
+		{"empty", nil, ""},
+		{"all active", []bool{true, true, true, true, true, true, true, true, true, true}, "██████████"},
+		{"all idle", []bool{false, false, false, false, false, false, false, false, false, false}, "░░░░░░░░░░"},
+		{"alternating", []bool{true, false, true, false, true, false, true, false, true, false}, "█░█░█░█░█░"},
+		{"partial", []bool{false, false, true}, "░░█"},
+	} {
+		if got := renderActivitySparkline(tc.history); got != tc.want {
+			t.Errorf("%s: sparkline = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRecordActivityHistoryKeepsLastTicks(t *testing.T) {
+	origActive := sessionActiveFn
+	defer func() { sessionActiveFn = origActive }()
+
+	m := model{
+		config:          config.DefaultConfig(),
+		sessions:        map[string]*tmux.Session{"claude": tmux.NewSession("claude", "sleep 60")},
+		activityHistory: map[string][]bool{"gone": {true}},
+	}
+	for i := 0; i < activityHistoryLen+3; i++ {
+		active := i%4 == 0
+		sessionActiveFn = func(*tmux.Session) bool { return active }
+		m.recordActivityHistory()
+	}
+	if _, ok := m.activityHistory["gone"]; ok {
+		t.Fatal("expected history of a vanished session dropped")
+	}
+	if got := renderActivitySparkline(m.activityHistory["claude"]); got != "░█░░░█░░░█" {
+		t.Fatalf("history = %q, want the last %d ticks", got, activityHistoryLen)
+	}
+
+	m.bindings = map[string]commandBinding{"claude": {SessionName: "claude", Running: true, Tool: "claude"}}
+	m.windowWidth = 120
+	row := stripANSI(m.detailedRows("claude", []string{"claude"})[0])
+	if contains(row, "░") {
+		t.Fatalf("expected no sparkline with task lines hidden, got %q", row)
+	}
+	m.showTaskDetails = true
+	row = stripANSI(m.detailedRows("claude", []string{"claude"})[0])
+	if !contains(row, "░█░░░█░░░█") {
+		t.Fatalf("expected sparkline with task lines shown, got %q", row)
+	}
+}
+
+func TestConfigInitWritesDefaultConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	handleSubcommand("config", []string{"init"}, nil, false, false)
+
+	path := filepath.Join(home, ".config", "pocketbot", "config.yaml")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected config file to be written: %v", err)
+	}
+	if string(data) != config.DefaultConfigYAML {
+		t.Fatalf("config file does not match DefaultConfigYAML:\n%s", data)
+	}
+	cfg, err := loadConfigUnvalidated()
+	if err != nil {
+		t.Fatalf("loadConfigUnvalidated: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, config.DefaultConfig()) {
+		t.Errorf("scaffold parses to\n%+v\nwant\n%+v", cfg, config.DefaultConfig())
+	}
+
+	if err := os.WriteFile(path, []byte("disable_icons: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := initConfigFile(false); err == nil || !contains(err.Error(), "--force") {
+		t.Fatalf("expected refusal mentioning --force, got %v", err)
+	}
+	if _, err := initConfigFile(true); err != nil {
+		t.Fatalf("initConfigFile(force): %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != config.DefaultConfigYAML {
+		t.Errorf("--force did not rewrite the file:\n%s", data)
+	}
+
+	for _, args := range [][]string{nil, {"edit"}, {"init", "extra"}} {
+		if _, err := parseConfigArgs(args); err == nil {
+			t.Errorf("parseConfigArgs(%q): expected error", args)
+		}
+	}
+	if opts, err := parseConfigArgs([]string{"init", "--force"}); err != nil || !opts.Force {
+		t.Errorf("parseConfigArgs(init --force) = %+v, %v", opts, err)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+	path := filepath.Join(home, ".config", "pocketbot", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("cursor:\n  enabled: false\nsessions:\n  - {name: dev, command: npm run dev, key: v}\n")
+	summary, err := validateConfig()
+	if err != nil {
+		t.Fatalf("validateConfig: %v", err)
+	}
+	if want := "config OK\ntools: claude (c), codex (x)\nsessions: dev (v)\n"; summary != want {
+		t.Errorf("summary = %q, want %q", summary, want)
+	}
+
+	write("sessions:\n  - name: dev\n    command: [npm\n")
+	if _, err := validateConfig(); err == nil || !contains(err.Error(), "line 2") || !contains(err.Error(), path) {
+		t.Errorf("expected a parse error naming the file and line, got %v", err)
+	}
+
+	write("sessions:\n  - {name: dev, command: npm run dev, key: c}\n")
+	if _, err := validateConfig(); err == nil || !contains(err.Error(), `duplicate key "c"`) {
+		t.Errorf("expected the validation error, got %v", err)
+	}
+
+	if opts, err := parseConfigArgs([]string{"validate"}); err != nil || !opts.Validate {
+		t.Errorf("parseConfigArgs(validate) = %+v, %v", opts, err)
+	}
+	if _, err := parseConfigArgs([]string{"validate", "--force"}); err == nil {
+		t.Error("expected --force to be rejected for validate")
+	}
+}
+
+func TestShowConfigListsSources(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PB_CODEX_KEY", "")
+	project := filepath.Join(home, "app")
+	t.Chdir(home)
+
+	var buf bytes.Buffer
+	if err := showConfig(&buf); err != nil {
+		t.Fatalf("showConfig: %v", err)
+	}
+	if got := buf.String(); got != "all settings are defaults\n" {
+		t.Errorf("showConfig with no layers = %q", got)
+	}
+
+	global := filepath.Join(home, ".config", "pocketbot", "config.yaml")
+	for path, content := range map[string]string{
+		global: "hide_idle_after: 1h\n",
+		filepath.Join(project, ".pocketbot.yaml"): "sessions:\n  - {name: dev, command: npm run dev, key: v}\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PB_CODEX_KEY", "o")
+	t.Chdir(project)
+
+	buf.Reset()
+	if err := showConfig(&buf); err != nil {
+		t.Fatalf("showConfig: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"hide_idle_after  " + global,
+		"sessions.dev     " + filepath.Join(project, ".pocketbot.yaml"),
+		"codex.key        env:PB_CODEX_KEY",
+	} {
+		if !contains(out, want) {
+			t.Errorf("showConfig output missing %q:\n%s", want, out)
+		}
+	}
+
+	if opts, err := parseConfigArgs([]string{"show"}); err != nil || !opts.Show || opts.Validate {
+		t.Errorf("parseConfigArgs(show) = %+v, %v", opts, err)
+	}
+}
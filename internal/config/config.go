@@ -4,37 +4,222 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the pocketbot configuration
 type Config struct {
-	Claude   ClaudeConfig    `yaml:"claude"`
-	Codex    CodexConfig     `yaml:"codex"`
-	Cursor   CursorConfig    `yaml:"cursor"`
+	// Tools are the agent tools in display order, each with its own key,
+	// rows and n/k/r flows. LoadAll starts from DefaultTools and overlays the
+	// legacy claude:, codex: and cursor: blocks, then tools: entries, by
+	// name.
+	Tools    []ToolConfig    `yaml:"tools"`
 	Sessions []SessionConfig `yaml:"sessions"`
+	Warnings WarningsConfig  `yaml:"warnings"`
+	// HideIdleAfter collapses sessions idle longer than this into a
+	// per-tool "+N idle" line on the home screen. Zero shows all sessions.
+	HideIdleAfter time.Duration `yaml:"hide_idle_after"`
+	// MaxNameDisplay truncates session names longer than this many characters
+	// on screen. Zero uses DefaultMaxNameDisplay.
+	MaxNameDisplay int `yaml:"max_name_display"`
+	// NameScheme picks how new sessions are named: NameSchemeToolNumber
+	// (codex, codex-2, ...) or NameSchemeRepo (codex-pocketbot). Empty means
+	// NameSchemeToolNumber.
+	NameScheme string `yaml:"name_scheme"`
+	// Socket runs pocketbot's tmux server on this socket name instead of
+	// "pocketbot", keeping unrelated projects' sessions apart. Nested pb
+	// levels append -<level>.
+	Socket string `yaml:"socket"`
+	// DisableIcons drops the per-tool icon prefix for terminals without
+	// emoji or nerd-font support.
+	DisableIcons bool `yaml:"disable_icons"`
+	// ReuseSessions makes n open a new window in a tool's existing session
+	// for the current directory instead of refusing, and lets pickers
+	// choose between a session's windows.
+	ReuseSessions bool     `yaml:"reuse_sessions"`
+	UI            UIConfig `yaml:"ui"`
+	// TaskPatterns is loaded from task-patterns.yaml by LoadAll, not from the
+	// main config file.
+	TaskPatterns TaskPatterns `yaml:"-"`
+
+	disableIconsSet  bool // DisableIcons was given explicitly; used by Merge
+	reuseSessionsSet bool // ReuseSessions was given explicitly; used by Merge
 }
 
-// ClaudeConfig represents the Claude session configuration
-type ClaudeConfig struct {
+// Session naming schemes for NameScheme.
+const (
+	NameSchemeToolNumber = "tool-number"
+	NameSchemeRepo       = "repo"
+)
+
+// DefaultMaxNameDisplay is the display width for session names when
+// max_name_display is not set.
+const DefaultMaxNameDisplay = 32
+
+// DefaultMaxRenderWidth is the widest the home screen renders when
+// ui.max_render_width is not set.
+const DefaultMaxRenderWidth = 120
+
+// DefaultCPUWarnThreshold is the CPU percentage that flags a busy session
+// when ui.cpu_warn_threshold is not set.
+const DefaultCPUWarnThreshold = 80.0
+
+// ToolConfig defines an agent tool: claude, codex, cursor or one added
+// under tools:. Enabled defaults to true.
+type ToolConfig struct {
+	Name    string `yaml:"name"`
 	Command string `yaml:"command"`
 	Key     string `yaml:"key"`
 	Enabled bool   `yaml:"enabled"`
+	Icon    string `yaml:"icon"`
+	// MaxInstances caps how many sessions of this tool pb will create; 0
+	// means unlimited.
+	MaxInstances int `yaml:"max_instances"`
+	// PreKillKeys are tmux key names sent before pb kills a session of this
+	// tool, e.g. "C-c" to let it exit cleanly.
+	PreKillKeys string `yaml:"pre_kill_keys"`
+	// YoloFlag is the flag that skips all permission prompts, added after
+	// the program name for yolo launches. Empty uses pb's built-in rewrite
+	// for claude and codex and leaves other commands as is.
+	YoloFlag string `yaml:"yolo_flag"`
+	// FallbackSuffix is the trailing part of Command that resumes previous
+	// work, e.g. "--restore-chat-history". When the command ends with it,
+	// pb falls back to the command without it if resuming fails, and fresh
+	// launches drop it.
+	FallbackSuffix string `yaml:"fallback_suffix"`
+	// IdleTimeout marks this tool's sessions idle after this long without
+	// output, e.g. "2m" or "500ms". Zero uses the tmux package default.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+
+	enabledSet bool // Enabled was given explicitly; used by Merge
 }
 
-// CodexConfig represents the Codex session configuration
-type CodexConfig struct {
-	Command string `yaml:"command"`
-	Key     string `yaml:"key"`
-	Enabled bool   `yaml:"enabled"`
+// UnmarshalYAML defaults Enabled to true when it is not given.
+func (t *ToolConfig) UnmarshalYAML(value *yaml.Node) error {
+	type plain ToolConfig
+	tool := plain{Enabled: true}
+	if err := value.Decode(&tool); err != nil {
+		return err
+	}
+	*t = ToolConfig(tool)
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		if value.Content[i].Value == "enabled" {
+			t.enabledSet = true
+		}
+	}
+	return nil
 }
 
-// CursorConfig represents the Cursor session configuration
-type CursorConfig struct {
-	Command string `yaml:"command"`
-	Key     string `yaml:"key"`
-	Enabled bool   `yaml:"enabled"`
+// DefaultTools returns the tools pocketbot knows without configuration.
+func DefaultTools() []ToolConfig {
+	return []ToolConfig{
+		{Name: "claude", Command: "claude --continue --permission-mode acceptEdits", Key: "c", Enabled: true, Icon: "🟣"},
+		{Name: "codex", Command: "codex resume --last", Key: "x", Enabled: true, Icon: "🟢"},
+		{Name: "cursor", Command: "agent resume", Key: "u", Enabled: true, Icon: "🔵"},
+	}
+}
+
+// legacyToolBlocks are the top-level claude:, codex: and cursor: blocks
+// from before tools: existed. parseToolOverlays folds them into the tool
+// list.
+type legacyToolBlocks struct {
+	Claude *ToolConfig  `yaml:"claude"`
+	Codex  *ToolConfig  `yaml:"codex"`
+	Cursor *ToolConfig  `yaml:"cursor"`
+	Tools  []ToolConfig `yaml:"tools"`
+}
+
+// parseToolOverlays returns the tool entries a config file sets: its
+// legacy blocks first, then its tools: entries, for mergeTools to apply in
+// that order.
+func parseToolOverlays(data []byte) ([]ToolConfig, error) {
+	var blocks legacyToolBlocks
+	if err := yaml.Unmarshal(data, &blocks); err != nil {
+		return nil, err
+	}
+	var tools []ToolConfig
+	for _, legacy := range []struct {
+		name string
+		tool *ToolConfig
+	}{{"claude", blocks.Claude}, {"codex", blocks.Codex}, {"cursor", blocks.Cursor}} {
+		if legacy.tool != nil {
+			legacy.tool.Name = legacy.name
+			tools = append(tools, *legacy.tool)
+		}
+	}
+	names := make(map[string]bool)
+	for _, tool := range blocks.Tools {
+		if names[tool.Name] {
+			return nil, fmt.Errorf("duplicate tool %q", tool.Name)
+		}
+		names[tool.Name] = true
+	}
+	return append(tools, blocks.Tools...), nil
+}
+
+// mergeTools overlays each entry onto the tool of the same name, keeping
+// fields the entry leaves empty, or appends it as a new tool. It returns
+// the changed fields as "<tool>.<field>", or "tools.<tool>" for new tools.
+func mergeTools(dst *[]ToolConfig, overlays []ToolConfig) []string {
+	var changed []string
+	for _, tool := range overlays {
+		i := slices.IndexFunc(*dst, func(t ToolConfig) bool { return t.Name == tool.Name })
+		if i < 0 {
+			tool.enabledSet = false
+			*dst = append(*dst, tool)
+			changed = append(changed, "tools."+tool.Name)
+			continue
+		}
+		cur := &(*dst)[i]
+		mergeString := func(field string, dst *string, src string) {
+			if src != "" && src != *dst {
+				*dst = src
+				changed = append(changed, tool.Name+"."+field)
+			}
+		}
+		mergeString("command", &cur.Command, tool.Command)
+		mergeString("key", &cur.Key, tool.Key)
+		if tool.enabledSet && tool.Enabled != cur.Enabled {
+			cur.Enabled = tool.Enabled
+			changed = append(changed, tool.Name+".enabled")
+		}
+		mergeString("icon", &cur.Icon, tool.Icon)
+		if tool.MaxInstances != 0 && tool.MaxInstances != cur.MaxInstances {
+			cur.MaxInstances = tool.MaxInstances
+			changed = append(changed, tool.Name+".max_instances")
+		}
+		mergeString("pre_kill_keys", &cur.PreKillKeys, tool.PreKillKeys)
+		mergeString("yolo_flag", &cur.YoloFlag, tool.YoloFlag)
+		mergeString("fallback_suffix", &cur.FallbackSuffix, tool.FallbackSuffix)
+		if tool.IdleTimeout != 0 && tool.IdleTimeout != cur.IdleTimeout {
+			cur.IdleTimeout = tool.IdleTimeout
+			changed = append(changed, tool.Name+".idle_timeout")
+		}
+	}
+	return changed
+}
+
+// socketNamePattern keeps socket names to a single file name under tmux's
+// socket directory.
+var socketNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// toolNamePattern keeps tool names usable as session name prefixes.
+var toolNamePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Tool returns the tool named name, or nil if there is none. Changes
+// through the pointer update the config.
+func (c *Config) Tool(name string) *ToolConfig {
+	for i := range c.Tools {
+		if c.Tools[i].Name == name {
+			return &c.Tools[i]
+		}
+	}
+	return nil
 }
 
 // SessionConfig represents a custom session configuration
@@ -42,30 +227,219 @@ type SessionConfig struct {
 	Name    string `yaml:"name"`
 	Command string `yaml:"command"`
 	Key     string `yaml:"key"`
+	// Pane optionally targets a pane on attach, as "window.pane" or a pane
+	// index in the first window. Empty attaches to the whole session.
+	Pane string `yaml:"pane,omitempty"`
+	// FallbackCommand runs if Command exits non-zero (e.g. when there is no
+	// previous conversation to resume).
+	FallbackCommand string `yaml:"fallback_command,omitempty"`
+	// PreKillKeys are tmux key names (e.g. "C-c" or "q Enter") sent to the
+	// session before pb kills it. Empty kills immediately.
+	PreKillKeys string `yaml:"pre_kill_keys,omitempty"`
+	// Workdir launches the session in this directory (a leading ~ is the
+	// home directory). Empty uses the directory pb was started in.
+	Workdir string `yaml:"workdir,omitempty"`
+	// IdleTimeout marks the session idle after this long without output.
+	// Zero uses the tmux package default.
+	IdleTimeout time.Duration `yaml:"idle_timeout,omitempty"`
+}
+
+// WorkdirPath returns Workdir with a leading ~ expanded to the home
+// directory.
+func (s SessionConfig) WorkdirPath() string {
+	if s.Workdir == "~" || strings.HasPrefix(s.Workdir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, strings.TrimPrefix(s.Workdir, "~"))
+		}
+	}
+	return s.Workdir
+}
+
+// WarningsConfig controls pane-scan patterns for agent warnings such as
+// rate limits or quota errors.
+type WarningsConfig struct {
+	Patterns []string `yaml:"patterns"`
+	Notify   bool     `yaml:"notify"`
+
+	notifySet bool // Notify was given explicitly; used by Merge
+}
+
+// UIConfig holds home-screen behavior toggles.
+type UIConfig struct {
+	// FollowSessionCwd changes pb's working directory to the attached
+	// session's @pb_cwd after detaching.
+	FollowSessionCwd bool `yaml:"follow_session_cwd"`
+	// CommandStripPrefixes are path prefixes dropped from each word of a
+	// task command before it is shortened for display.
+	CommandStripPrefixes []string `yaml:"command_strip_prefixes"`
+	// DisableAnimations keeps the active indicator steady instead of
+	// blinking on every tick.
+	DisableAnimations bool `yaml:"disable_animations"`
+	// ShowTasksOnStart starts the home screen with task lines shown, as if
+	// t had been pressed.
+	ShowTasksOnStart bool `yaml:"show_tasks_on_start"`
+	// Columns picks and orders the parts of each home-screen session row,
+	// from ValidColumns. Nil uses DefaultColumns.
+	Columns []string `yaml:"columns"`
+	// CwdAliases maps path prefixes to short names shown in place of the
+	// repo, e.g. "~/work": "work" shows ~/work/api/server as work/api/server.
+	// The longest matching prefix wins.
+	CwdAliases map[string]string `yaml:"cwd_aliases"`
+	// ShowVisitCount adds the visits column, visits:N on rows of sessions
+	// attached more than once, to the end of Columns if it is not listed.
+	ShowVisitCount bool `yaml:"show_visit_count"`
+	// DoubleTapToKill makes pressing a tool key twice within 500ms kill
+	// that tool's most recent session. Single presses then wait out the
+	// window before attaching.
+	DoubleTapToKill bool `yaml:"double_tap_to_kill"`
+	// CPUWarnThreshold is the pane CPU percentage above which a session row
+	// shows a cpu:N% badge. Zero uses DefaultCPUWarnThreshold.
+	CPUWarnThreshold float64 `yaml:"cpu_warn_threshold"`
+	// TerminalTitle sets the terminal window title to the number of active
+	// sessions on the home screen and to the session name while attached.
+	// Defaults to true.
+	TerminalTitle bool `yaml:"terminal_title"`
+	// TaskFilterPresets are extra task filters that F cycles through on the
+	// home screen, after the default of task-patterns.yaml alone.
+	TaskFilterPresets []TaskFilterPreset `yaml:"task_filter_presets"`
+	// MaxRenderWidth caps how many columns the home screen draws on wide
+	// terminals. Zero uses DefaultMaxRenderWidth.
+	MaxRenderWidth int `yaml:"max_render_width"`
+	// Sections picks and orders the parts of the home screen, from
+	// ValidSections. It must include notice. Nil uses DefaultSections.
+	Sections []string `yaml:"sections"`
+
+	followSessionCwdSet  bool // FollowSessionCwd was given explicitly; used by Merge
+	disableAnimationsSet bool // DisableAnimations was given explicitly; used by Merge
+	showTasksOnStartSet  bool // ShowTasksOnStart was given explicitly; used by Merge
+	showVisitCountSet    bool // ShowVisitCount was given explicitly; used by Merge
+	doubleTapToKillSet   bool // DoubleTapToKill was given explicitly; used by Merge
+	terminalTitleSet     bool // TerminalTitle was given explicitly; used by Merge
+}
+
+// TaskFilterPreset is a named set of task patterns applied on top of
+// task-patterns.yaml (or in place of its noise, with Replace) while the
+// preset is active. Patterns are case-insensitive substrings, as in
+// task-patterns.yaml.
+type TaskFilterPreset struct {
+	Name              string   `yaml:"name"`
+	NoisePatterns     []string `yaml:"noise"`
+	HighlightPatterns []string `yaml:"highlight"`
+	// Replace makes NoisePatterns replace task-patterns.yaml's noise list
+	// instead of adding to it. The built-in noise filter still applies.
+	Replace bool `yaml:"replace"`
+}
+
+// DefaultWarningPatterns returns the built-in warning patterns.
+func DefaultWarningPatterns() []string {
+	return []string{"rate limit", "quota exceeded", "context window"}
+}
+
+// DefaultCommandStripPrefixes returns the built-in task command path
+// prefixes hidden on screen.
+func DefaultCommandStripPrefixes() []string {
+	return []string{"/opt/homebrew/bin/", "/usr/local/bin/", "/usr/bin/", "/bin/"}
+}
+
+// ValidColumns lists the session row columns accepted in ui.columns.
+func ValidColumns() []string {
+	return []string{"key", "name", "repo", "yolo", "status", "tasks", "uptime", "visits"}
+}
+
+// DefaultColumns returns the session row columns shown when ui.columns is
+// not set.
+func DefaultColumns() []string {
+	return []string{"key", "name", "repo", "yolo", "tasks", "status"}
+}
+
+// ValidSections lists the home screen sections accepted in ui.sections.
+func ValidSections() []string {
+	return []string{"title", "dir", "notice", "sessions", "hotkeys"}
+}
+
+// DefaultSections returns the home screen sections shown when ui.sections
+// is not set.
+func DefaultSections() []string {
+	return []string{"title", "dir", "notice", "sessions", "hotkeys"}
 }
 
 // DefaultConfig returns the default configuration
 func DefaultConfig() *Config {
 	return &Config{
-		Claude: ClaudeConfig{
-			Command: "claude --continue --permission-mode acceptEdits",
-			Key:     "c",
-			Enabled: true,
-		},
-		Codex: CodexConfig{
-			Command: "codex resume --last",
-			Key:     "x",
-			Enabled: true,
+		Tools:    DefaultTools(),
+		Sessions: []SessionConfig{},
+		Warnings: WarningsConfig{
+			Patterns: DefaultWarningPatterns(),
 		},
-		Cursor: CursorConfig{
-			Command: "agent resume",
-			Key:     "u",
-			Enabled: true,
+		UI: UIConfig{
+			CommandStripPrefixes: DefaultCommandStripPrefixes(),
+			Columns:              DefaultColumns(),
+			Sections:             DefaultSections(),
+			TerminalTitle:        true,
 		},
-		Sessions: []SessionConfig{},
 	}
 }
 
+// DefaultConfigYAML is the file `pb config init` writes: the defaults
+// spelled out, with the optional settings commented. It parses to
+// DefaultConfig.
+const DefaultConfigYAML = `# pocketbot config. Uncomment a setting to change it; see
+# config.example.yaml in the repository for more.
+
+# Agent tools, in home screen order. Other tools need a name, command and
+# key; yolo_flag and fallback_suffix are optional.
+tools:
+  - name: claude
+    command: "claude --continue --permission-mode acceptEdits"
+    key: "c"
+    icon: "🟣"
+  - name: codex
+    command: "codex resume --last"
+    key: "x"
+    icon: "🟢"
+  - name: cursor
+    command: "agent resume"
+    key: "u"
+    icon: "🔵"
+  # - name: aider
+  #   command: "aider --restore-chat-history"
+  #   key: "a"
+  #   yolo_flag: "--yes-always"
+  #   fallback_suffix: "--restore-chat-history"
+
+# Custom sessions, each on its own key. Replace [] with entries like:
+#   - name: "dev-server"
+#     command: "npm run dev"
+#     key: "v"
+#     # fallback_command: "npm install && npm run dev"
+#     # workdir: "~/src/app"
+sessions: []
+
+# Pane-scan patterns for agent warnings (case-insensitive).
+warnings:
+  patterns: ["rate limit", "quota exceeded", "context window"]
+  notify: false
+
+# hide_idle_after: 30m
+# max_name_display: 32
+# name_scheme: repo
+# socket: myproject
+# disable_icons: true
+# reuse_sessions: true
+
+# ui:
+#   follow_session_cwd: true
+#   disable_animations: true
+#   show_tasks_on_start: true
+#   columns: [key, name, repo, yolo, tasks, status]
+#   sections: [title, dir, notice, sessions, hotkeys]
+#   show_visit_count: true
+#   double_tap_to_kill: true
+#   cpu_warn_threshold: 80
+#   max_render_width: 120
+#   terminal_title: false
+`
+
 // ConfigPath returns the path to the config file
 func ConfigPath() (string, error) {
 	home, err := os.UserHomeDir()
@@ -75,140 +449,169 @@ func ConfigPath() (string, error) {
 	return filepath.Join(home, ".config", "pocketbot", "config.yaml"), nil
 }
 
-// Load loads the configuration from the config file
-// If the file doesn't exist, returns the default config
-func Load() (*Config, error) {
-	path, err := ConfigPath()
-	if err != nil {
-		return nil, err
+// WriteDefault writes DefaultConfigYAML to path, creating its directory.
+// It refuses to replace an existing file unless force is set.
+func WriteDefault(path string, force bool) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%s already exists; pass --force to overwrite it", path)
 	}
-
-	// If config file doesn't exist, return default
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return DefaultConfig(), nil
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
 	}
-
-	// Read config file
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+	if err := os.WriteFile(path, []byte(DefaultConfigYAML), 0644); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
 	}
+	return nil
+}
 
-	// Parse YAML
-	var raw map[string]any
-	if err := yaml.Unmarshal(data, &raw); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+// expandCommandEnv expands $VAR and ${VAR} in every command and
+// fallback_command, so launch-time rewrites such as the yolo flag and the
+// fallback see the values rather than the references. Only variables set in
+// pb's environment are expanded; other references, like awk's $1, reach the
+// shell as written. $$ is not an escape for a literal $: it is left for the
+// shell, which reads it as its process ID.
+func (c *Config) expandCommandEnv() {
+	for i := range c.Tools {
+		c.Tools[i].Command = expandSetEnv(c.Tools[i].Command)
 	}
+	for i := range c.Sessions {
+		c.Sessions[i].Command = expandSetEnv(c.Sessions[i].Command)
+		c.Sessions[i].FallbackCommand = expandSetEnv(c.Sessions[i].FallbackCommand)
+	}
+}
 
-	_, hasCodexBlock := raw["codex"]
-	hasCodexEnabled := false
-	if hasCodexBlock {
-		if codexMap, ok := raw["codex"].(map[string]any); ok {
-			_, hasCodexEnabled = codexMap["enabled"]
+// expandSetEnv is os.ExpandEnv, except that references to unset variables
+// are kept as written.
+func expandSetEnv(s string) string {
+	// os.Expand visits references left to right, so the next one in s that
+	// names the variable is the one being expanded; pos tracks it to keep
+	// its exact spelling.
+	pos := 0
+	return os.Expand(s, func(name string) string {
+		ref := "$" + name
+		i := strings.Index(s[pos:], ref)
+		if j := strings.Index(s[pos:], "${"+name+"}"); j >= 0 && (i < 0 || j < i) {
+			i, ref = j, "${"+name+"}"
 		}
-	}
-	_, hasCursorBlock := raw["cursor"]
-	hasCursorEnabled := false
-	if hasCursorBlock {
-		if cursorMap, ok := raw["cursor"].(map[string]any); ok {
-			_, hasCursorEnabled = cursorMap["enabled"]
+		if i >= 0 {
+			pos += i + len(ref)
 		}
-	}
-
-	_, hasClaudeBlock := raw["claude"]
-	hasClaudeEnabled := false
-	if hasClaudeBlock {
-		if claudeMap, ok := raw["claude"].(map[string]any); ok {
-			_, hasClaudeEnabled = claudeMap["enabled"]
+		if value, ok := os.LookupEnv(name); ok {
+			return value
 		}
+		return ref
+	})
+}
+
+// reservedKeys are the home screen shortcuts, mapped to the home action
+// that owns them. pb reports a tool or session bound to one of them as a
+// key conflict, so they are rejected here too. Keep in step with
+// homeActions in cmd/pb; a test there checks every action key is listed.
+var reservedKeys = map[string]string{
+	"d": "quit",
+	"/": "search",
+	"z": "jump-dir",
+	"n": "new",
+	"k": "kill",
+	"r": "rename",
+	"m": "memo",
+	"N": "note",
+	"S": "send",
+	"B": "broadcast",
+	"t": "toggle-tasks",
+	"T": "toggle-all-tasks",
+	"i": "toggle-idle",
+	":": "the command palette",
+	"?": "help",
+	"Y": "global-yolo",
+	"W": "dismissing the config warning",
+}
+
+// ReservedKey reports which built-in shortcut owns key, if any. "F" is only
+// reserved while task filter presets are configured.
+func (c *Config) ReservedKey(key string) (string, bool) {
+	if key == "F" && len(c.UI.TaskFilterPresets) > 0 {
+		return "task filter presets", true
 	}
+	action, ok := reservedKeys[key]
+	return action, ok
+}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+// Validate checks if the configuration is valid
+func (c *Config) Validate() error {
+	// Check for duplicate keys
+	keys := make(map[string]string)
+
+	if c.Socket != "" && !socketNamePattern.MatchString(c.Socket) {
+		return fmt.Errorf("socket %q must be letters, digits, '.', '_' or '-'", c.Socket)
 	}
 
-	// Apply defaults for missing fields
-	if !hasClaudeBlock {
-		cfg.Claude = DefaultConfig().Claude
-	} else {
-		if cfg.Claude.Command == "" {
-			cfg.Claude.Command = "claude --continue --permission-mode acceptEdits"
-		}
-		if cfg.Claude.Key == "" {
-			cfg.Claude.Key = "c"
+	toolNames := make(map[string]bool)
+	for _, tool := range c.Tools {
+		if !toolNamePattern.MatchString(tool.Name) {
+			return fmt.Errorf("tool name %q must be lowercase letters, digits or _", tool.Name)
 		}
-		if !hasClaudeEnabled {
-			cfg.Claude.Enabled = true
+		if tool.Name == "scratch" {
+			return fmt.Errorf("tool name %q is reserved for scratch shells", tool.Name)
 		}
-	}
-	if !hasCodexBlock {
-		cfg.Codex = DefaultConfig().Codex
-	} else {
-		if cfg.Codex.Command == "" {
-			cfg.Codex.Command = "codex resume --last"
+		if toolNames[tool.Name] {
+			return fmt.Errorf("duplicate tool %q", tool.Name)
 		}
-		if cfg.Codex.Key == "" {
-			cfg.Codex.Key = "x"
+		toolNames[tool.Name] = true
+		if tool.Command == "" {
+			return fmt.Errorf("tool %q missing command", tool.Name)
 		}
-		if !hasCodexEnabled {
-			cfg.Codex.Enabled = true
+		if tool.Key == "" {
+			return fmt.Errorf("tool %q missing key", tool.Name)
 		}
-	}
-	if !hasCursorBlock {
-		cfg.Cursor = DefaultConfig().Cursor
-	} else {
-		if cfg.Cursor.Command == "" {
-			cfg.Cursor.Command = "agent resume"
+		if tool.MaxInstances < 0 {
+			return fmt.Errorf("%s.max_instances must not be negative, got %d", tool.Name, tool.MaxInstances)
 		}
-		if cfg.Cursor.Key == "" {
-			cfg.Cursor.Key = "u"
+		if tool.IdleTimeout < 0 {
+			return fmt.Errorf("%s.idle_timeout must not be negative, got %s", tool.Name, tool.IdleTimeout)
 		}
-		if !hasCursorEnabled {
-			cfg.Cursor.Enabled = true
+		if !tool.Enabled {
+			continue
 		}
-	}
-
-	// Validate
-	if err := cfg.Validate(); err != nil {
-		return nil, err
-	}
-
-	return &cfg, nil
-}
-
-// Validate checks if the configuration is valid
-func (c *Config) Validate() error {
-	// Check for duplicate keys
-	keys := make(map[string]string)
-
-	if c.Claude.Enabled {
-		keys[c.Claude.Key] = "claude"
-	}
-	if c.Codex.Enabled {
-		if existing, ok := keys[c.Codex.Key]; ok {
-			return fmt.Errorf("duplicate key %q used by %q and %q", c.Codex.Key, existing, "codex")
+		if action, ok := c.ReservedKey(tool.Key); ok {
+			return fmt.Errorf("tool %q key %q is reserved for %s", tool.Name, tool.Key, action)
 		}
-		keys[c.Codex.Key] = "codex"
-	}
-	if c.Cursor.Enabled {
-		if existing, ok := keys[c.Cursor.Key]; ok {
-			return fmt.Errorf("duplicate key %q used by %q and %q", c.Cursor.Key, existing, "cursor")
+		if existing, ok := keys[tool.Key]; ok {
+			return fmt.Errorf("duplicate key %q used by %q and %q", tool.Key, existing, tool.Name)
 		}
-		keys[c.Cursor.Key] = "cursor"
+		keys[tool.Key] = tool.Name
 	}
 
+	sessionNames := make(map[string]bool)
 	for _, session := range c.Sessions {
 		if session.Name == "" {
 			return fmt.Errorf("session missing name")
 		}
+		if sessionNames[session.Name] {
+			return fmt.Errorf("duplicate session %q", session.Name)
+		}
+		sessionNames[session.Name] = true
 		if session.Command == "" {
 			return fmt.Errorf("session %q missing command", session.Name)
 		}
 		if session.Key == "" {
 			return fmt.Errorf("session %q missing key", session.Name)
 		}
+		if toolNames[session.Name] {
+			return fmt.Errorf("session %q has the same name as a tool", session.Name)
+		}
+		if session.IdleTimeout < 0 {
+			return fmt.Errorf("session %q idle_timeout must not be negative, got %s", session.Name, session.IdleTimeout)
+		}
+		if session.Workdir != "" {
+			if info, err := os.Stat(session.WorkdirPath()); err != nil || !info.IsDir() {
+				return fmt.Errorf("session %q workdir %q does not exist", session.Name, session.Workdir)
+			}
+		}
 
+		if action, ok := c.ReservedKey(session.Key); ok {
+			return fmt.Errorf("session %q key %q is reserved for %s", session.Name, session.Key, action)
+		}
 		// Check for duplicate key
 		if existing, ok := keys[session.Key]; ok {
 			return fmt.Errorf("duplicate key %q used by %q and %q", session.Key, existing, session.Name)
@@ -216,33 +619,91 @@ func (c *Config) Validate() error {
 		keys[session.Key] = session.Name
 	}
 
+	if c.UI.MaxRenderWidth < 0 {
+		return fmt.Errorf("ui.max_render_width must not be negative, got %d", c.UI.MaxRenderWidth)
+	}
+	if c.UI.CPUWarnThreshold < 0 {
+		return fmt.Errorf("ui.cpu_warn_threshold must not be negative, got %g", c.UI.CPUWarnThreshold)
+	}
+
+	if c.UI.Columns != nil && len(c.UI.Columns) == 0 {
+		return fmt.Errorf("ui.columns must list at least one column")
+	}
+	for _, col := range c.UI.Columns {
+		if !slices.Contains(ValidColumns(), col) {
+			return fmt.Errorf("unknown ui.columns entry %q (want one of %s)", col, strings.Join(ValidColumns(), ", "))
+		}
+	}
+	if c.UI.Sections != nil && len(c.UI.Sections) == 0 {
+		return fmt.Errorf("ui.sections must list at least one section")
+	}
+	for _, section := range c.UI.Sections {
+		if !slices.Contains(ValidSections(), section) {
+			return fmt.Errorf("unknown ui.sections entry %q (want one of %s)", section, strings.Join(ValidSections(), ", "))
+		}
+	}
+	if c.UI.Sections != nil && !slices.Contains(c.UI.Sections, "notice") {
+		// Errors and picker prompts show there, in every mode.
+		return fmt.Errorf("ui.sections must include notice")
+	}
+
+	presetNames := make(map[string]bool)
+	for _, preset := range c.UI.TaskFilterPresets {
+		if preset.Name == "" {
+			return fmt.Errorf("ui.task_filter_presets entry missing name")
+		}
+		if presetNames[preset.Name] {
+			return fmt.Errorf("duplicate ui.task_filter_presets name %q", preset.Name)
+		}
+		presetNames[preset.Name] = true
+	}
+
+	switch c.NameScheme {
+	case "", NameSchemeToolNumber, NameSchemeRepo:
+	default:
+		return fmt.Errorf("unknown name_scheme %q (want %q or %q)", c.NameScheme, NameSchemeToolNumber, NameSchemeRepo)
+	}
+
 	return nil
 }
 
-// AllSessions returns all configured sessions including Claude
+// MaxInstancesForTool returns the max_instances cap for tool, or 0
+// (unlimited) for tools that are not configured, like scratch.
+func (c *Config) MaxInstancesForTool(tool string) int {
+	if tc := c.Tool(tool); tc != nil {
+		return tc.MaxInstances
+	}
+	return 0
+}
+
+// IdleTimeout returns the idle timeout configured for the named session:
+// its custom session entry's, or else its tool's. Zero means the default.
+func (c *Config) IdleTimeout(name, tool string) time.Duration {
+	for _, sess := range c.Sessions {
+		if sess.Name == name {
+			return sess.IdleTimeout
+		}
+	}
+	if tc := c.Tool(tool); tc != nil {
+		return tc.IdleTimeout
+	}
+	return 0
+}
+
+// AllSessions returns every enabled tool as a session, then the custom
+// sessions.
 func (c *Config) AllSessions() []SessionConfig {
 	sessions := []SessionConfig{}
-
-	if c.Claude.Enabled {
-		sessions = append(sessions, SessionConfig{
-			Name:    "claude",
-			Command: c.Claude.Command,
-			Key:     c.Claude.Key,
-		})
-	}
-	if c.Codex.Enabled {
-		sessions = append(sessions, SessionConfig{
-			Name:    "codex",
-			Command: c.Codex.Command,
-			Key:     c.Codex.Key,
-		})
-	}
-	if c.Cursor.Enabled {
-		sessions = append(sessions, SessionConfig{
-			Name:    "cursor",
-			Command: c.Cursor.Command,
-			Key:     c.Cursor.Key,
-		})
+	for _, tool := range c.Tools {
+		if tool.Enabled {
+			sessions = append(sessions, SessionConfig{
+				Name:        tool.Name,
+				Command:     tool.Command,
+				Key:         tool.Key,
+				PreKillKeys: tool.PreKillKeys,
+				IdleTimeout: tool.IdleTimeout,
+			})
+		}
 	}
 
 	sessions = append(sessions, c.Sessions...)
@@ -1,39 +1,43 @@
 package config
 
 import (
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestDefaultConfig(t *testing.T) {
 	cfg := DefaultConfig()
 
-	if cfg.Claude.Command != "claude --continue --permission-mode acceptEdits" {
-		t.Errorf("Expected default claude command, got %q", cfg.Claude.Command)
+	if cfg.Tool("claude").Command != "claude --continue --permission-mode acceptEdits" {
+		t.Errorf("Expected default claude command, got %q", cfg.Tool("claude").Command)
 	}
-	if cfg.Claude.Key != "c" {
-		t.Errorf("Expected default key 'c', got %q", cfg.Claude.Key)
+	if cfg.Tool("claude").Key != "c" {
+		t.Errorf("Expected default key 'c', got %q", cfg.Tool("claude").Key)
 	}
-	if !cfg.Claude.Enabled {
+	if !cfg.Tool("claude").Enabled {
 		t.Error("Claude should be enabled by default")
 	}
-	if cfg.Codex.Command != "codex resume --last" {
-		t.Errorf("Expected default codex command, got %q", cfg.Codex.Command)
+	if cfg.Tool("codex").Command != "codex resume --last" {
+		t.Errorf("Expected default codex command, got %q", cfg.Tool("codex").Command)
 	}
-	if cfg.Codex.Key != "x" {
-		t.Errorf("Expected default codex key 'x', got %q", cfg.Codex.Key)
+	if cfg.Tool("codex").Key != "x" {
+		t.Errorf("Expected default codex key 'x', got %q", cfg.Tool("codex").Key)
 	}
-	if !cfg.Codex.Enabled {
+	if !cfg.Tool("codex").Enabled {
 		t.Error("Codex should be enabled by default")
 	}
-	if cfg.Cursor.Command != "agent resume" {
-		t.Errorf("Expected default cursor command, got %q", cfg.Cursor.Command)
+	if cfg.Tool("cursor").Command != "agent resume" {
+		t.Errorf("Expected default cursor command, got %q", cfg.Tool("cursor").Command)
 	}
-	if cfg.Cursor.Key != "u" {
-		t.Errorf("Expected default cursor key 'u', got %q", cfg.Cursor.Key)
+	if cfg.Tool("cursor").Key != "u" {
+		t.Errorf("Expected default cursor key 'u', got %q", cfg.Tool("cursor").Key)
 	}
-	if !cfg.Cursor.Enabled {
+	if !cfg.Tool("cursor").Enabled {
 		t.Error("Cursor should be enabled by default")
 	}
 	if len(cfg.Sessions) != 0 {
@@ -48,18 +52,18 @@ func TestLoadDefaultWhenNoFile(t *testing.T) {
 	os.Setenv("HOME", tmpDir)
 	defer os.Setenv("HOME", origHome)
 
-	cfg, err := Load()
+	cfg, _, err := LoadAll("")
 	if err != nil {
 		t.Fatalf("Load should not error when file doesn't exist: %v", err)
 	}
 
-	if cfg.Claude.Command != "claude --continue --permission-mode acceptEdits" {
+	if cfg.Tool("claude").Command != "claude --continue --permission-mode acceptEdits" {
 		t.Error("Should return default config when file doesn't exist")
 	}
-	if cfg.Codex.Command != "codex resume --last" || cfg.Codex.Key != "x" || !cfg.Codex.Enabled {
+	if cfg.Tool("codex").Command != "codex resume --last" || cfg.Tool("codex").Key != "x" || !cfg.Tool("codex").Enabled {
 		t.Error("Should include default codex config when file doesn't exist")
 	}
-	if cfg.Cursor.Command != "agent resume" || cfg.Cursor.Key != "u" || !cfg.Cursor.Enabled {
+	if cfg.Tool("cursor").Command != "agent resume" || cfg.Tool("cursor").Key != "u" || !cfg.Tool("cursor").Enabled {
 		t.Error("Should include default cursor config when file doesn't exist")
 	}
 }
@@ -88,7 +92,7 @@ cursor:
 sessions:
   - name: "dev-server"
     command: "npm run dev"
-    key: "d"
+    key: "v"
   - name: "api"
     command: "go run main.go"
     key: "a"
@@ -102,7 +106,7 @@ sessions:
 	os.Setenv("HOME", tmpDir)
 	defer os.Setenv("HOME", origHome)
 
-	cfg, err := Load()
+	cfg, _, err := LoadAll("")
 	if err != nil {
 		t.Fatalf("Failed to load config: %v", err)
 	}
@@ -110,24 +114,24 @@ sessions:
 	if len(cfg.Sessions) != 2 {
 		t.Errorf("Expected 2 sessions, got %d", len(cfg.Sessions))
 	}
-	if cfg.Codex.Command != "codex --model gpt-5" {
-		t.Errorf("Expected codex command to be loaded, got %q", cfg.Codex.Command)
+	if cfg.Tool("codex").Command != "codex --model gpt-5" {
+		t.Errorf("Expected codex command to be loaded, got %q", cfg.Tool("codex").Command)
 	}
-	if cfg.Codex.Key != "x" {
-		t.Errorf("Expected codex key 'x', got %q", cfg.Codex.Key)
+	if cfg.Tool("codex").Key != "x" {
+		t.Errorf("Expected codex key 'x', got %q", cfg.Tool("codex").Key)
 	}
-	if cfg.Cursor.Command != "agent resume" {
-		t.Errorf("Expected cursor command to be loaded, got %q", cfg.Cursor.Command)
+	if cfg.Tool("cursor").Command != "agent resume" {
+		t.Errorf("Expected cursor command to be loaded, got %q", cfg.Tool("cursor").Command)
 	}
-	if cfg.Cursor.Key != "u" {
-		t.Errorf("Expected cursor key 'u', got %q", cfg.Cursor.Key)
+	if cfg.Tool("cursor").Key != "u" {
+		t.Errorf("Expected cursor key 'u', got %q", cfg.Tool("cursor").Key)
 	}
 
 	if cfg.Sessions[0].Name != "dev-server" {
 		t.Errorf("Expected session name 'dev-server', got %q", cfg.Sessions[0].Name)
 	}
-	if cfg.Sessions[0].Key != "d" {
-		t.Errorf("Expected key 'd', got %q", cfg.Sessions[0].Key)
+	if cfg.Sessions[0].Key != "v" {
+		t.Errorf("Expected key 'v', got %q", cfg.Sessions[0].Key)
 	}
 }
 
@@ -161,12 +165,12 @@ cursor:
 	os.Setenv("HOME", tmpDir)
 	defer os.Setenv("HOME", origHome)
 
-	cfg, err := Load()
+	cfg, _, err := LoadAll("")
 	if err != nil {
 		t.Fatalf("Failed to load config: %v", err)
 	}
 
-	if cfg.Codex.Enabled {
+	if cfg.Tool("codex").Enabled {
 		t.Error("Expected codex to remain disabled when explicitly set to false")
 	}
 }
@@ -189,19 +193,19 @@ cursor:
 	os.Setenv("HOME", tmpDir)
 	defer os.Setenv("HOME", origHome)
 
-	cfg, err := Load()
+	cfg, _, err := LoadAll("")
 	if err != nil {
 		t.Fatalf("Failed to load config: %v", err)
 	}
 
-	if cfg.Cursor.Enabled {
+	if cfg.Tool("cursor").Enabled {
 		t.Error("Expected cursor to remain disabled when explicitly set to false")
 	}
-	if cfg.Cursor.Command != "agent resume" {
-		t.Errorf("Expected default cursor command, got %q", cfg.Cursor.Command)
+	if cfg.Tool("cursor").Command != "agent resume" {
+		t.Errorf("Expected default cursor command, got %q", cfg.Tool("cursor").Command)
 	}
-	if cfg.Cursor.Key != "u" {
-		t.Errorf("Expected default cursor key 'u', got %q", cfg.Cursor.Key)
+	if cfg.Tool("cursor").Key != "u" {
+		t.Errorf("Expected default cursor key 'u', got %q", cfg.Tool("cursor").Key)
 	}
 }
 
@@ -214,7 +218,7 @@ func TestLoadDefaultsEnabledWhenBlocksMissing(t *testing.T) {
 sessions:
   - name: "test"
     command: "echo ok"
-    key: "t"
+    key: "e"
 `
 	configPath := filepath.Join(configDir, "config.yaml")
 	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
@@ -225,38 +229,627 @@ sessions:
 	os.Setenv("HOME", tmpDir)
 	defer os.Setenv("HOME", origHome)
 
-	cfg, err := Load()
+	cfg, _, err := LoadAll("")
 	if err != nil {
 		t.Fatalf("Failed to load config: %v", err)
 	}
 
-	if !cfg.Claude.Enabled {
+	if !cfg.Tool("claude").Enabled {
 		t.Error("Expected claude enabled by default when claude block is missing")
 	}
-	if !cfg.Codex.Enabled {
+	if !cfg.Tool("codex").Enabled {
 		t.Error("Expected codex enabled by default when codex block is missing")
 	}
-	if !cfg.Cursor.Enabled {
+	if !cfg.Tool("cursor").Enabled {
 		t.Error("Expected cursor enabled by default when cursor block is missing")
 	}
 }
 
+func TestLoadWarningPatterns(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+
+	configContent := `
+warnings:
+  patterns:
+    - "usage limit"
+    - "credit balance"
+  notify: true
+`
+	configPath := filepath.Join(configDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.Warnings.Patterns) != 2 || cfg.Warnings.Patterns[0] != "usage limit" {
+		t.Errorf("Expected custom warning patterns, got %v", cfg.Warnings.Patterns)
+	}
+	if !cfg.Warnings.Notify {
+		t.Error("Expected warnings notify to be enabled")
+	}
+}
+
+func TestLoadSessionFallbackCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+
+	configContent := `
+sessions:
+  - name: "aider"
+    command: "aider --restore-chat-history"
+    fallback_command: "aider"
+    key: "a"
+`
+	configPath := filepath.Join(configDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.Sessions) != 1 || cfg.Sessions[0].FallbackCommand != "aider" {
+		t.Errorf("Expected fallback_command to load, got %+v", cfg.Sessions)
+	}
+}
+
+func TestLoadSessionWorkdir(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	os.MkdirAll(filepath.Join(tmpDir, "src", "api"), 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	cases := []struct {
+		workdir string
+		want    string
+		wantErr string
+	}{
+		{workdir: "", want: ""},
+		{workdir: filepath.Join(tmpDir, "src", "api"), want: filepath.Join(tmpDir, "src", "api")},
+		{workdir: "~/src/api", want: filepath.Join(tmpDir, "src", "api")},
+		{workdir: "~/src/missing", wantErr: `session "api" workdir "~/src/missing" does not exist`},
+		{workdir: filepath.Join(configDir, "config.yaml"), wantErr: "does not exist"},
+	}
+	for _, tc := range cases {
+		yaml := "sessions:\n  - name: api\n    command: go run ./cmd/api\n    key: a\n    workdir: \"" + tc.workdir + "\"\n"
+		if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		cfg, _, err := LoadAll("")
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("workdir %q: expected error containing %q, got %v", tc.workdir, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("workdir %q: failed to load config: %v", tc.workdir, err)
+		}
+		if got := cfg.Sessions[0].WorkdirPath(); got != tc.want {
+			t.Errorf("workdir %q: WorkdirPath() = %q, want %q", tc.workdir, got, tc.want)
+		}
+	}
+}
+
+func TestLoadTools(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	configContent := `
+tools:
+  - name: aider
+    command: "aider --restore-chat-history"
+    key: "a"
+    yolo_flag: "--yes-always"
+    fallback_suffix: "--restore-chat-history"
+  - name: gemini
+    command: "gemini"
+    key: "g"
+    enabled: false
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	aider := cfg.Tool("aider")
+	if aider == nil || !aider.Enabled || aider.YoloFlag != "--yes-always" || aider.FallbackSuffix != "--restore-chat-history" {
+		t.Fatalf("aider = %+v", aider)
+	}
+	if gemini := cfg.Tool("gemini"); gemini == nil || gemini.Enabled {
+		t.Error("expected gemini disabled")
+	}
+	var names []string
+	for _, sess := range cfg.AllSessions() {
+		names = append(names, sess.Name)
+	}
+	if !slices.Contains(names, "aider") || slices.Contains(names, "gemini") {
+		t.Errorf("AllSessions names = %v, want aider but not gemini", names)
+	}
+
+	for yaml, wantErr := range map[string]string{
+		"tools:\n  - name: scratch\n    command: sh\n    key: q\n":                                           `tool name "scratch" is reserved`,
+		"tools:\n  - name: Aider\n    command: aider\n    key: a\n":                                          `tool name "Aider" must be`,
+		"tools:\n  - name: my-tool\n    command: aider\n    key: a\n":                                        `tool name "my-tool" must be`,
+		"tools:\n  - name: aider\n    key: a\n":                                                              `tool "aider" missing command`,
+		"tools:\n  - name: aider\n    command: aider\n    key: c\n":                                          `duplicate key "c" used by "claude" and "aider"`,
+		"tools:\n  - {name: aider, command: aider, key: a}\n  - {name: aider, command: a, key: b}\n":         `duplicate tool "aider"`,
+		"tools:\n  - {name: api, command: aider, key: a}\nsessions:\n  - {name: api, command: go, key: b}\n": `session "api" has the same name as a tool`,
+	} {
+		if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		if _, _, err := LoadAll(""); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("config %q: expected error containing %q, got %v", yaml, wantErr, err)
+		}
+	}
+}
+
+func TestLoadToolsOverlayBuiltins(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	configContent := `
+codex:
+  command: "codex --model o3"
+  enabled: false
+tools:
+  - name: codex
+    key: "q"
+  - name: claude
+    yolo_flag: "--yolo"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	var names []string
+	for _, tool := range cfg.Tools {
+		names = append(names, tool.Name)
+	}
+	if !slices.Equal(names, []string{"claude", "codex", "cursor"}) {
+		t.Fatalf("tool names = %v, want [claude codex cursor]", names)
+	}
+	codex := cfg.Tool("codex")
+	if codex.Command != "codex --model o3" || codex.Key != "q" || codex.Enabled || codex.Icon != "🟢" {
+		t.Errorf("codex = %+v, want legacy block overlaid by tools: entry", codex)
+	}
+	if claude := cfg.Tool("claude"); claude.YoloFlag != "--yolo" || claude.Key != "c" || !claude.Enabled {
+		t.Errorf("claude = %+v, want defaults plus yolo_flag", claude)
+	}
+}
+
+func TestLoadSocket(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	cases := []struct {
+		yaml    string
+		want    string
+		wantErr string
+	}{
+		{yaml: "sessions: []\n", want: ""},
+		{yaml: "socket: proj-a\n", want: "proj-a"},
+		{yaml: "socket: ../other\n", wantErr: `socket "../other" must be`},
+		{yaml: "socket: \"my project\"\n", wantErr: `socket "my project" must be`},
+	}
+	for _, tc := range cases {
+		if err := os.WriteFile(configPath, []byte(tc.yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		cfg, _, err := LoadAll("")
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("config %q: expected error containing %q, got %v", tc.yaml, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.Socket != tc.want {
+			t.Errorf("config %q: socket = %q, want %q", tc.yaml, cfg.Socket, tc.want)
+		}
+	}
+}
+
+func TestLoadHideIdleAfter(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+
+	configPath := filepath.Join(configDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("hide_idle_after: 30m\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.HideIdleAfter != 30*time.Minute {
+		t.Errorf("Expected hide_idle_after of 30m, got %v", cfg.HideIdleAfter)
+	}
+}
+
+func TestLoadDefaultWarningPatternsWhenMissing(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+
+	configPath := filepath.Join(configDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("sessions: []\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.Warnings.Patterns) != len(DefaultWarningPatterns()) {
+		t.Errorf("Expected default warning patterns, got %v", cfg.Warnings.Patterns)
+	}
+	if cfg.Warnings.Notify {
+		t.Error("Expected warnings notify to default off")
+	}
+}
+
+func TestLoadCommandStripPrefixes(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	cases := []struct {
+		yaml string
+		want []string
+	}{
+		{"sessions: []\n", DefaultCommandStripPrefixes()},
+		{"ui:\n  command_strip_prefixes: [\"/nix/store/\"]\n", []string{"/nix/store/"}},
+		{"ui:\n  command_strip_prefixes: []\n", []string{}},
+	}
+	for _, tc := range cases {
+		if err := os.WriteFile(configPath, []byte(tc.yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		cfg, _, err := LoadAll("")
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if !slices.Equal(cfg.UI.CommandStripPrefixes, tc.want) {
+			t.Errorf("config %q: command_strip_prefixes = %v, want %v", tc.yaml, cfg.UI.CommandStripPrefixes, tc.want)
+		}
+	}
+}
+
+func TestLoadDisableAnimations(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	for yaml, want := range map[string]bool{
+		"sessions: []\n":                    false,
+		"ui:\n  disable_animations: true\n": true,
+	} {
+		if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		cfg, _, err := LoadAll("")
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.UI.DisableAnimations != want {
+			t.Errorf("config %q: disable_animations = %v, want %v", yaml, cfg.UI.DisableAnimations, want)
+		}
+	}
+}
+
+func TestLoadShowTasksOnStart(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	for yaml, want := range map[string]bool{
+		"sessions: []\n":                      false,
+		"ui:\n  show_tasks_on_start: true\n":  true,
+		"ui:\n  show_tasks_on_start: false\n": false,
+	} {
+		if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		cfg, _, err := LoadAll("")
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.UI.ShowTasksOnStart != want {
+			t.Errorf("config %q: show_tasks_on_start = %v, want %v", yaml, cfg.UI.ShowTasksOnStart, want)
+		}
+	}
+}
+
+func TestLoadShowVisitCount(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	for yaml, want := range map[string]bool{
+		"sessions: []\n":                   false,
+		"ui:\n  show_visit_count: true\n":  true,
+		"ui:\n  show_visit_count: false\n": false,
+	} {
+		if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		cfg, _, err := LoadAll("")
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.UI.ShowVisitCount != want {
+			t.Errorf("config %q: show_visit_count = %v, want %v", yaml, cfg.UI.ShowVisitCount, want)
+		}
+	}
+}
+
+func TestLoadDoubleTapToKill(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	for yaml, want := range map[string]bool{
+		"sessions: []\n":                     false,
+		"ui:\n  double_tap_to_kill: true\n":  true,
+		"ui:\n  double_tap_to_kill: false\n": false,
+	} {
+		if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		cfg, _, err := LoadAll("")
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.UI.DoubleTapToKill != want {
+			t.Errorf("config %q: double_tap_to_kill = %v, want %v", yaml, cfg.UI.DoubleTapToKill, want)
+		}
+	}
+}
+
+func TestLoadTerminalTitle(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	if !DefaultConfig().UI.TerminalTitle {
+		t.Fatal("terminal_title should default to true")
+	}
+	for yaml, want := range map[string]bool{
+		"sessions: []\n":                    true,
+		"ui:\n  disable_animations: true\n": true,
+		"ui:\n  terminal_title: false\n":    false,
+		"ui:\n  terminal_title: true\n":     true,
+	} {
+		if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		cfg, _, err := LoadAll("")
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.UI.TerminalTitle != want {
+			t.Errorf("config %q: terminal_title = %v, want %v", yaml, cfg.UI.TerminalTitle, want)
+		}
+	}
+}
+
+func TestLoadColumns(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	cases := []struct {
+		yaml    string
+		want    []string
+		wantErr string
+	}{
+		{yaml: "sessions: []\n", want: DefaultColumns()},
+		{yaml: "ui:\n  columns: [status, name, uptime]\n", want: []string{"status", "name", "uptime"}},
+		{yaml: "ui:\n  columns: [name, branch]\n", wantErr: `unknown ui.columns entry "branch"`},
+		{yaml: "ui:\n  columns: []\n", wantErr: "ui.columns must list at least one column"},
+	}
+	for _, tc := range cases {
+		if err := os.WriteFile(configPath, []byte(tc.yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		cfg, _, err := LoadAll("")
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("config %q: expected error containing %q, got %v", tc.yaml, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if !slices.Equal(cfg.UI.Columns, tc.want) {
+			t.Errorf("config %q: columns = %v, want %v", tc.yaml, cfg.UI.Columns, tc.want)
+		}
+	}
+}
+
+func TestLoadSections(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	cases := []struct {
+		yaml    string
+		want    []string
+		wantErr string
+	}{
+		{yaml: "sessions: []\n", want: DefaultSections()},
+		{yaml: "ui:\n  sections: [hotkeys, title, sessions, notice]\n", want: []string{"hotkeys", "title", "sessions", "notice"}},
+		{yaml: "ui:\n  sections: [title, sessions]\n", wantErr: "ui.sections must include notice"},
+		{yaml: "ui:\n  sections: [title, footer]\n", wantErr: `unknown ui.sections entry "footer"`},
+		{yaml: "ui:\n  sections: []\n", wantErr: "ui.sections must list at least one section"},
+	}
+	for _, tc := range cases {
+		if err := os.WriteFile(configPath, []byte(tc.yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		cfg, _, err := LoadAll("")
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("config %q: expected error containing %q, got %v", tc.yaml, tc.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if !slices.Equal(cfg.UI.Sections, tc.want) {
+			t.Errorf("config %q: sections = %v, want %v", tc.yaml, cfg.UI.Sections, tc.want)
+		}
+	}
+}
+
+func TestLoadTaskFilterPresets(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	yaml := `ui:
+  task_filter_presets:
+    - name: build
+      noise: ["node", "sleep"]
+      highlight: ["make"]
+    - name: quiet
+      noise: ["tail -f"]
+    - name: all
+      noise: []
+      replace: true
+`
+	if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	want := []TaskFilterPreset{
+		{Name: "build", NoisePatterns: []string{"node", "sleep"}, HighlightPatterns: []string{"make"}},
+		{Name: "quiet", NoisePatterns: []string{"tail -f"}},
+		{Name: "all", NoisePatterns: []string{}, Replace: true},
+	}
+	if !slices.EqualFunc(cfg.UI.TaskFilterPresets, want, equalTaskFilterPreset) {
+		t.Fatalf("task_filter_presets = %+v, want %+v", cfg.UI.TaskFilterPresets, want)
+	}
+
+	for yaml, wantErr := range map[string]string{
+		"ui:\n  task_filter_presets:\n    - noise: [node]\n":          "missing name",
+		"ui:\n  task_filter_presets:\n    - name: a\n    - name: a\n": `duplicate ui.task_filter_presets name "a"`,
+	} {
+		if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+			t.Fatalf("Failed to write test config: %v", err)
+		}
+		if _, _, err := LoadAll(""); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("config %q: expected error containing %q, got %v", yaml, wantErr, err)
+		}
+	}
+}
+
+func TestLoadCwdAliases(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	yaml := "ui:\n  cwd_aliases:\n    /Users/alice/work: work\n    \"~/src\": src\n"
+	if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	want := map[string]string{"/Users/alice/work": "work", "~/src": "src"}
+	if !maps.Equal(cfg.UI.CwdAliases, want) {
+		t.Errorf("cwd_aliases = %v, want %v", cfg.UI.CwdAliases, want)
+	}
+
+	if err := os.WriteFile(configPath, []byte("sessions: []\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, _, err = LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(cfg.UI.CwdAliases) != 0 {
+		t.Errorf("expected no aliases by default, got %v", cfg.UI.CwdAliases)
+	}
+}
+
 func TestValidateDuplicateKeys(t *testing.T) {
 	cfg := &Config{
-		Claude: ClaudeConfig{
-			Command: "claude --continue",
-			Key:     "c",
-			Enabled: true,
-		},
-		Codex: CodexConfig{
-			Command: "codex resume --last",
-			Key:     "x",
-			Enabled: true,
-		},
-		Cursor: CursorConfig{
-			Command: "agent resume",
-			Key:     "u",
-			Enabled: true,
+		Tools: []ToolConfig{
+			{Name: "claude", Command: "claude --continue", Key: "c", Enabled: true},
+			{Name: "codex", Command: "codex resume --last", Key: "x", Enabled: true},
+			{Name: "cursor", Command: "agent resume", Key: "u", Enabled: true},
 		},
 		Sessions: []SessionConfig{
 			{Name: "test", Command: "echo test", Key: "x"}, // Duplicate key with codex
@@ -269,6 +862,45 @@ func TestValidateDuplicateKeys(t *testing.T) {
 	}
 }
 
+func TestValidateNameScheme(t *testing.T) {
+	for _, scheme := range []string{"", NameSchemeToolNumber, NameSchemeRepo} {
+		cfg := DefaultConfig()
+		cfg.NameScheme = scheme
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("name_scheme %q: unexpected error %v", scheme, err)
+		}
+	}
+	cfg := DefaultConfig()
+	cfg.NameScheme = "branch"
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for unknown name_scheme")
+	}
+}
+
+func TestValidateMaxRenderWidth(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.UI.MaxRenderWidth = 100
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cfg.UI.MaxRenderWidth = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for a negative max_render_width")
+	}
+}
+
+func TestValidateCPUWarnThreshold(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.UI.CPUWarnThreshold = 50
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cfg.UI.CPUWarnThreshold = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for a negative cpu_warn_threshold")
+	}
+}
+
 func TestValidateMissingFields(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -277,12 +909,12 @@ func TestValidateMissingFields(t *testing.T) {
 	}{
 		{
 			name:    "missing name",
-			session: SessionConfig{Command: "test", Key: "t"},
+			session: SessionConfig{Command: "test", Key: "e"},
 			wantErr: true,
 		},
 		{
 			name:    "missing command",
-			session: SessionConfig{Name: "test", Key: "t"},
+			session: SessionConfig{Name: "test", Key: "e"},
 			wantErr: true,
 		},
 		{
@@ -292,7 +924,7 @@ func TestValidateMissingFields(t *testing.T) {
 		},
 		{
 			name:    "all fields present",
-			session: SessionConfig{Name: "test", Command: "test", Key: "t"},
+			session: SessionConfig{Name: "test", Command: "test", Key: "e"},
 			wantErr: false,
 		},
 	}
@@ -300,8 +932,6 @@ func TestValidateMissingFields(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			cfg := &Config{
-				Claude:   ClaudeConfig{Enabled: false},
-				Codex:    CodexConfig{Enabled: false},
 				Sessions: []SessionConfig{tt.session},
 			}
 			err := cfg.Validate()
@@ -314,23 +944,13 @@ func TestValidateMissingFields(t *testing.T) {
 
 func TestAllSessions(t *testing.T) {
 	cfg := &Config{
-		Claude: ClaudeConfig{
-			Command: "claude --continue",
-			Key:     "c",
-			Enabled: true,
-		},
-		Codex: CodexConfig{
-			Command: "codex resume --last",
-			Key:     "x",
-			Enabled: true,
-		},
-		Cursor: CursorConfig{
-			Command: "agent resume",
-			Key:     "u",
-			Enabled: true,
+		Tools: []ToolConfig{
+			{Name: "claude", Command: "claude --continue", Key: "c", Enabled: true},
+			{Name: "codex", Command: "codex resume --last", Key: "x", Enabled: true},
+			{Name: "cursor", Command: "agent resume", Key: "u", Enabled: true},
 		},
 		Sessions: []SessionConfig{
-			{Name: "test1", Command: "test1", Key: "t"},
+			{Name: "test1", Command: "test1", Key: "e"},
 			{Name: "test2", Command: "test2", Key: "v"},
 		},
 	}
@@ -353,23 +973,13 @@ func TestAllSessions(t *testing.T) {
 
 func TestAllSessionsClaudeDisabled(t *testing.T) {
 	cfg := &Config{
-		Claude: ClaudeConfig{
-			Command: "claude --continue",
-			Key:     "c",
-			Enabled: false,
-		},
-		Codex: CodexConfig{
-			Command: "codex resume --last",
-			Key:     "x",
-			Enabled: false,
-		},
-		Cursor: CursorConfig{
-			Command: "agent resume",
-			Key:     "u",
-			Enabled: false,
+		Tools: []ToolConfig{
+			{Name: "claude", Command: "claude --continue", Key: "c", Enabled: false},
+			{Name: "codex", Command: "codex resume --last", Key: "x", Enabled: false},
+			{Name: "cursor", Command: "agent resume", Key: "u", Enabled: false},
 		},
 		Sessions: []SessionConfig{
-			{Name: "test1", Command: "test1", Key: "t"},
+			{Name: "test1", Command: "test1", Key: "e"},
 		},
 	}
 
@@ -382,3 +992,230 @@ func TestAllSessionsClaudeDisabled(t *testing.T) {
 		t.Error("Should not include claude when disabled")
 	}
 }
+
+func TestLoadToolIconsDefaultAndOverride(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+
+	configPath := filepath.Join(configDir, "config.yaml")
+	content := "claude:\n  icon: \"C\"\ncodex:\n  command: \"codex\"\ndisable_icons: true\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	origHome := os.Getenv("HOME")
+	os.Setenv("HOME", tmpDir)
+	defer os.Setenv("HOME", origHome)
+
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.Tool("claude").Icon != "C" {
+		t.Errorf("Expected claude icon override, got %q", cfg.Tool("claude").Icon)
+	}
+	if cfg.Tool("codex").Icon != DefaultConfig().Tool("codex").Icon || cfg.Tool("cursor").Icon != DefaultConfig().Tool("cursor").Icon {
+		t.Errorf("Expected default icons for codex/cursor, got %q/%q", cfg.Tool("codex").Icon, cfg.Tool("cursor").Icon)
+	}
+	if !cfg.DisableIcons {
+		t.Error("Expected disable_icons to be set")
+	}
+}
+
+func TestLoadMaxInstances(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	yaml := "claude:\n  max_instances: 3\ncodex:\n  max_instances: 1\n"
+	if err := os.WriteFile(configPath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	for tool, want := range map[string]int{"claude": 3, "codex": 1, "cursor": 0, "scratch": 0} {
+		if got := cfg.MaxInstancesForTool(tool); got != want {
+			t.Errorf("MaxInstancesForTool(%q) = %d, want %d", tool, got, want)
+		}
+	}
+
+	if err := os.WriteFile(configPath, []byte("cursor:\n  max_instances: -1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	if _, _, err := LoadAll(""); err == nil || !strings.Contains(err.Error(), "cursor.max_instances") {
+		t.Fatalf("expected negative max_instances to be rejected, got %v", err)
+	}
+}
+
+func TestWriteDefault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "config.yaml")
+	if err := WriteDefault(path, false); err != nil {
+		t.Fatalf("WriteDefault: %v", err)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != DefaultConfigYAML {
+		t.Fatalf("read back %q, %v", data, err)
+	}
+	if err := WriteDefault(path, false); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("expected refusal to overwrite, got %v", err)
+	}
+	if err := WriteDefault(path, true); err != nil {
+		t.Fatalf("WriteDefault with force: %v", err)
+	}
+}
+
+func TestLoadExpandsEnvInCommands(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("CLAUDE_MODEL", "opus")
+	t.Setenv("DEV_PORT", "3000")
+
+	configContent := `
+claude:
+  command: "claude --model $CLAUDE_MODEL --continue"
+sessions:
+  - name: dev
+    command: "npm run dev -- --port ${DEV_PORT}"
+    fallback_command: "npm install && npm run dev -- --port $DEV_PORT"
+    key: v
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if got := cfg.Tool("claude").Command; got != "claude --model opus --continue" {
+		t.Errorf("claude command = %q, want $CLAUDE_MODEL expanded", got)
+	}
+	if got := cfg.Sessions[0].Command; got != "npm run dev -- --port 3000" {
+		t.Errorf("session command = %q, want ${DEV_PORT} expanded", got)
+	}
+	if got := cfg.Sessions[0].FallbackCommand; got != "npm install && npm run dev -- --port 3000" {
+		t.Errorf("fallback command = %q, want $DEV_PORT expanded", got)
+	}
+}
+
+func TestExpandSetEnvKeepsUnsetReferences(t *testing.T) {
+	t.Setenv("DEV_PORT", "3000")
+	t.Setenv("EMPTY", "")
+	os.Unsetenv("PB_TEST_UNSET")
+	for in, want := range map[string]string{
+		"serve --port $DEV_PORT":               "serve --port 3000",
+		"serve --port ${DEV_PORT}0":            "serve --port 30000",
+		"echo $PB_TEST_UNSET":                  "echo $PB_TEST_UNSET",
+		"echo ${PB_TEST_UNSET}x $DEV_PORT":     "echo ${PB_TEST_UNSET}x 3000",
+		"echo $PB_TEST_UNSET ${PB_TEST_UNSET}": "echo $PB_TEST_UNSET ${PB_TEST_UNSET}",
+		"echo [$EMPTY]":                        "echo []",
+		"kill $$":                              "kill $$",
+		"awk '{print $1, $NF}' log":            "awk '{print $1, $NF}' log",
+	} {
+		if got := expandSetEnv(in); got != want {
+			t.Errorf("expandSetEnv(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLoadIdleTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	configDir := filepath.Join(tmpDir, ".config", "pocketbot")
+	os.MkdirAll(configDir, 0755)
+	configPath := filepath.Join(configDir, "config.yaml")
+	t.Setenv("HOME", tmpDir)
+
+	configContent := `
+tools:
+  - name: codex
+    idle_timeout: 2s
+  - name: cursor
+    idle_timeout: 90s
+sessions:
+  - {name: dev, command: "npm run dev", key: v, idle_timeout: 2m}
+  - {name: logs, command: "tail -f log", key: l, idle_timeout: 500ms}
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	for _, tc := range []struct {
+		name, tool string
+		want       time.Duration
+	}{
+		{"dev", "", 2 * time.Minute},
+		{"codex-2", "codex", 2 * time.Second},
+		{"cursor", "cursor", 90 * time.Second},
+		{"logs", "", 500 * time.Millisecond},
+		{"claude", "claude", 0},
+		{"notes", "", 0},
+	} {
+		if got := cfg.IdleTimeout(tc.name, tc.tool); got != tc.want {
+			t.Errorf("IdleTimeout(%q, %q) = %v, want %v", tc.name, tc.tool, got, tc.want)
+		}
+	}
+
+	if err := os.WriteFile(configPath, []byte("sessions:\n  - {name: dev, command: x, key: v, idle_timeout: -1s}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	if _, _, err := LoadAll(""); err == nil || !strings.Contains(err.Error(), "idle_timeout must not be negative") {
+		t.Fatalf("expected negative idle_timeout to be rejected, got %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte("tools:\n  - {name: claude, idle_timeout: -1s}\n"), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	if _, _, err := LoadAll(""); err == nil || !strings.Contains(err.Error(), "claude.idle_timeout must not be negative") {
+		t.Fatalf("expected negative tool idle_timeout to be rejected, got %v", err)
+	}
+}
+
+func TestAllSessionsKeepsToolIdleTimeout(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Tool("claude").IdleTimeout = 90 * time.Second
+
+	for _, sess := range cfg.AllSessions() {
+		if sess.Name == "claude" && sess.IdleTimeout != 90*time.Second {
+			t.Errorf("claude IdleTimeout = %v, want 90s", sess.IdleTimeout)
+		}
+	}
+}
+
+func TestValidateRejectsBuiltInHomeKeys(t *testing.T) {
+	for _, key := range []string{"d", "/", "n", "m", "N", "S", "B", "t", "T", "i", ":", "?", "Y", "W"} {
+		cfg := DefaultConfig()
+		cfg.Sessions = []SessionConfig{{Name: "dev", Command: "npm run dev", Key: key}}
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "is reserved for") {
+			t.Errorf("session key %q: expected reserved key error, got %v", key, err)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Tool("claude").Key = "S"
+	if err := cfg.Validate(); err == nil || err.Error() != `tool "claude" key "S" is reserved for send` {
+		t.Errorf("expected tool key conflict with send, got %v", err)
+	}
+	cfg.Tool("claude").Enabled = false
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("a disabled tool's key should not be checked, got %v", err)
+	}
+
+	// F only belongs to the home screen while presets exist to cycle.
+	cfg = DefaultConfig()
+	cfg.Sessions = []SessionConfig{{Name: "fmt", Command: "go fmt", Key: "F"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("F without presets: %v", err)
+	}
+	cfg.UI.TaskFilterPresets = []TaskFilterPreset{{Name: "quiet"}}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "task filter presets") {
+		t.Errorf("F with presets: expected reserved key error, got %v", err)
+	}
+}
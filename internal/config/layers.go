@@ -0,0 +1,350 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigName is the per-project config file looked up by LoadAll.
+const ProjectConfigName = ".pocketbot.yaml"
+
+// LoadSource records which layer last set a non-default config field.
+type LoadSource struct {
+	Field  string // e.g. "claude.command" or "sessions.logs"
+	Source string // file path, or "env:<VAR>"
+}
+
+// LoadAll builds the config from every layer, later layers overriding
+// earlier ones: defaults, the global config file, each .pocketbot.yaml from
+// the filesystem root down to cwd, then PB_* environment variables. An
+// empty cwd skips the project files.
+func LoadAll(cwd string) (*Config, []LoadSource, error) {
+	cfg, sources, err := LoadAllUnvalidated(cwd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	patterns, err := loadTaskPatterns()
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg.TaskPatterns = patterns
+	return cfg, sources, nil
+}
+
+// LoadAllUnvalidated is like LoadAll but skips Validate and task patterns,
+// so callers can inspect a merged config that has key conflicts.
+//
+// A project session replaces one of the same name from a project file
+// further up, but may not reuse the name of a session in the global
+// config: it is appended alongside it so Validate reports the duplicate.
+func LoadAllUnvalidated(cwd string) (*Config, []LoadSource, error) {
+	cfg := DefaultConfig()
+	var sources []LoadSource
+	apply := func(layer *Config, source string) {
+		for _, field := range cfg.Merge(layer) {
+			sources = setSource(sources, field, source)
+		}
+	}
+
+	globalPath, err := ConfigPath()
+	if err != nil {
+		return nil, nil, err
+	}
+	if layer, err := loadLayerFile(globalPath); err != nil {
+		return nil, nil, err
+	} else if layer != nil {
+		apply(layer, globalPath)
+	}
+	userSessions := make(map[string]bool, len(cfg.Sessions))
+	for _, sess := range cfg.Sessions {
+		userSessions[sess.Name] = true
+	}
+
+	for _, path := range projectConfigPaths(cwd) {
+		layer, err := loadLayerFile(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		if layer == nil {
+			continue
+		}
+		layer.Sessions = slices.DeleteFunc(layer.Sessions, func(sess SessionConfig) bool {
+			if !userSessions[sess.Name] {
+				return false
+			}
+			cfg.Sessions = append(cfg.Sessions, sess)
+			return true
+		})
+		apply(layer, path)
+	}
+
+	var toolNames []string
+	for _, tool := range cfg.Tools {
+		toolNames = append(toolNames, tool.Name)
+	}
+	envLayer, envSources, err := loadEnvLayer(toolNames)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, field := range cfg.Merge(envLayer) {
+		sources = setSource(sources, field, envSources[field])
+	}
+	return cfg, sources, nil
+}
+
+// Merge overlays the fields o sets onto c and returns the names of fields
+// whose value changed. Empty strings, nil slices and zero numbers in o
+// leave c untouched; tools are matched by name and overlaid field by field,
+// sessions by name and replaced, and unmatched ones appended.
+func (c *Config) Merge(o *Config) []string {
+	var changed []string
+	mergeString := func(field string, dst *string, src string) {
+		if src != "" && src != *dst {
+			*dst = src
+			changed = append(changed, field)
+		}
+	}
+	mergeBool := func(field string, dst *bool, src, set bool) {
+		if set && src != *dst {
+			*dst = src
+			changed = append(changed, field)
+		}
+	}
+
+	changed = append(changed, mergeTools(&c.Tools, o.Tools)...)
+
+	for _, sess := range o.Sessions {
+		i := slices.IndexFunc(c.Sessions, func(s SessionConfig) bool { return s.Name == sess.Name })
+		switch {
+		case i < 0:
+			c.Sessions = append(c.Sessions, sess)
+		case c.Sessions[i] != sess:
+			c.Sessions[i] = sess
+		default:
+			continue
+		}
+		changed = append(changed, "sessions."+sess.Name)
+	}
+
+	if o.Warnings.Patterns != nil && !slices.Equal(o.Warnings.Patterns, c.Warnings.Patterns) {
+		c.Warnings.Patterns = slices.Clone(o.Warnings.Patterns)
+		changed = append(changed, "warnings.patterns")
+	}
+	mergeBool("warnings.notify", &c.Warnings.Notify, o.Warnings.Notify, o.Warnings.notifySet)
+
+	if o.HideIdleAfter != 0 && o.HideIdleAfter != c.HideIdleAfter {
+		c.HideIdleAfter = o.HideIdleAfter
+		changed = append(changed, "hide_idle_after")
+	}
+	if o.MaxNameDisplay != 0 && o.MaxNameDisplay != c.MaxNameDisplay {
+		c.MaxNameDisplay = o.MaxNameDisplay
+		changed = append(changed, "max_name_display")
+	}
+	mergeString("name_scheme", &c.NameScheme, o.NameScheme)
+	mergeString("socket", &c.Socket, o.Socket)
+	mergeBool("disable_icons", &c.DisableIcons, o.DisableIcons, o.disableIconsSet)
+	mergeBool("reuse_sessions", &c.ReuseSessions, o.ReuseSessions, o.reuseSessionsSet)
+	mergeBool("ui.follow_session_cwd", &c.UI.FollowSessionCwd, o.UI.FollowSessionCwd, o.UI.followSessionCwdSet)
+	mergeBool("ui.disable_animations", &c.UI.DisableAnimations, o.UI.DisableAnimations, o.UI.disableAnimationsSet)
+	mergeBool("ui.show_tasks_on_start", &c.UI.ShowTasksOnStart, o.UI.ShowTasksOnStart, o.UI.showTasksOnStartSet)
+	mergeBool("ui.show_visit_count", &c.UI.ShowVisitCount, o.UI.ShowVisitCount, o.UI.showVisitCountSet)
+	if o.UI.MaxRenderWidth != 0 && o.UI.MaxRenderWidth != c.UI.MaxRenderWidth {
+		c.UI.MaxRenderWidth = o.UI.MaxRenderWidth
+		changed = append(changed, "ui.max_render_width")
+	}
+	if o.UI.CPUWarnThreshold != 0 && o.UI.CPUWarnThreshold != c.UI.CPUWarnThreshold {
+		c.UI.CPUWarnThreshold = o.UI.CPUWarnThreshold
+		changed = append(changed, "ui.cpu_warn_threshold")
+	}
+	mergeBool("ui.terminal_title", &c.UI.TerminalTitle, o.UI.TerminalTitle, o.UI.terminalTitleSet)
+	mergeBool("ui.double_tap_to_kill", &c.UI.DoubleTapToKill, o.UI.DoubleTapToKill, o.UI.doubleTapToKillSet)
+	if o.UI.CommandStripPrefixes != nil && !slices.Equal(o.UI.CommandStripPrefixes, c.UI.CommandStripPrefixes) {
+		c.UI.CommandStripPrefixes = slices.Clone(o.UI.CommandStripPrefixes)
+		changed = append(changed, "ui.command_strip_prefixes")
+	}
+	if o.UI.TaskFilterPresets != nil && !slices.EqualFunc(o.UI.TaskFilterPresets, c.UI.TaskFilterPresets, equalTaskFilterPreset) {
+		c.UI.TaskFilterPresets = make([]TaskFilterPreset, len(o.UI.TaskFilterPresets))
+		for i, preset := range o.UI.TaskFilterPresets {
+			preset.NoisePatterns = slices.Clone(preset.NoisePatterns)
+			preset.HighlightPatterns = slices.Clone(preset.HighlightPatterns)
+			c.UI.TaskFilterPresets[i] = preset
+		}
+		changed = append(changed, "ui.task_filter_presets")
+	}
+	if o.UI.Columns != nil && !slices.Equal(o.UI.Columns, c.UI.Columns) {
+		c.UI.Columns = slices.Clone(o.UI.Columns)
+		changed = append(changed, "ui.columns")
+	}
+	if o.UI.Sections != nil && !slices.Equal(o.UI.Sections, c.UI.Sections) {
+		c.UI.Sections = slices.Clone(o.UI.Sections)
+		changed = append(changed, "ui.sections")
+	}
+	for _, prefix := range slices.Sorted(maps.Keys(o.UI.CwdAliases)) {
+		alias := o.UI.CwdAliases[prefix]
+		if current, ok := c.UI.CwdAliases[prefix]; ok && current == alias {
+			continue
+		}
+		if c.UI.CwdAliases == nil {
+			c.UI.CwdAliases = make(map[string]string)
+		}
+		c.UI.CwdAliases[prefix] = alias
+		changed = append(changed, "ui.cwd_aliases."+prefix)
+	}
+	return changed
+}
+
+func setSource(sources []LoadSource, field, source string) []LoadSource {
+	for i := range sources {
+		if sources[i].Field == field {
+			sources[i].Source = source
+			return sources
+		}
+	}
+	return append(sources, LoadSource{Field: field, Source: source})
+}
+
+// projectConfigPaths returns existing project config files from the
+// filesystem root down to cwd, so nearer files are merged last. An empty
+// cwd (unknown working directory) has no project files.
+func projectConfigPaths(cwd string) []string {
+	var paths []string
+	for _, path := range projectConfigCandidates(cwd) {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// projectConfigCandidates returns every path a project config file for cwd
+// could have, from the filesystem root down to cwd.
+func projectConfigCandidates(cwd string) []string {
+	if cwd == "" {
+		return nil
+	}
+	var paths []string
+	dir := filepath.Clean(cwd)
+	for {
+		paths = append(paths, filepath.Join(dir, ProjectConfigName))
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	slices.Reverse(paths)
+	return paths
+}
+
+// WatchPaths returns the config files LoadAll(cwd) reads: the global config
+// file, then every path a project file could have, whether or not it exists
+// yet, so a watcher also notices project files being created.
+func WatchPaths(cwd string) []string {
+	var paths []string
+	if path, err := ConfigPath(); err == nil {
+		paths = append(paths, path)
+	}
+	return append(paths, projectConfigCandidates(cwd)...)
+}
+
+// loadLayerFile parses one config file without applying defaults. It
+// returns nil if the file does not exist.
+func loadLayerFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	var layer Config
+	if err := yaml.Unmarshal(data, &layer); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	var raw map[string]any
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	if layer.Tools, err = parseToolOverlays(data); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	layer.expandCommandEnv()
+	layer.Warnings.notifySet = blockHasKey(raw, "warnings", "notify")
+	_, layer.disableIconsSet = raw["disable_icons"]
+	_, layer.reuseSessionsSet = raw["reuse_sessions"]
+	layer.UI.followSessionCwdSet = blockHasKey(raw, "ui", "follow_session_cwd")
+	layer.UI.disableAnimationsSet = blockHasKey(raw, "ui", "disable_animations")
+	layer.UI.showTasksOnStartSet = blockHasKey(raw, "ui", "show_tasks_on_start")
+	layer.UI.showVisitCountSet = blockHasKey(raw, "ui", "show_visit_count")
+	layer.UI.doubleTapToKillSet = blockHasKey(raw, "ui", "double_tap_to_kill")
+	layer.UI.terminalTitleSet = blockHasKey(raw, "ui", "terminal_title")
+	return &layer, nil
+}
+
+func blockHasKey(raw map[string]any, block, key string) bool {
+	m, ok := raw[block].(map[string]any)
+	if !ok {
+		return false
+	}
+	_, ok = m[key]
+	return ok
+}
+
+// loadEnvLayer reads PB_<TOOL>_COMMAND, PB_<TOOL>_KEY and PB_<TOOL>_ENABLED
+// for each named tool, and PB_HIDE_IDLE_AFTER. It also returns the env var
+// behind each field.
+func loadEnvLayer(toolNames []string) (*Config, map[string]string, error) {
+	var layer Config
+	vars := make(map[string]string)
+
+	for _, name := range toolNames {
+		tool := ToolConfig{Name: name}
+		prefix := "PB_" + strings.ToUpper(name) + "_"
+		if v := os.Getenv(prefix + "COMMAND"); v != "" {
+			tool.Command = v
+			vars[name+".command"] = "env:" + prefix + "COMMAND"
+		}
+		if v := os.Getenv(prefix + "KEY"); v != "" {
+			tool.Key = v
+			vars[name+".key"] = "env:" + prefix + "KEY"
+		}
+		if v := os.Getenv(prefix + "ENABLED"); v != "" {
+			enabled, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid %sENABLED %q: %w", prefix, v, err)
+			}
+			tool.Enabled = enabled
+			tool.enabledSet = true
+			vars[name+".enabled"] = "env:" + prefix + "ENABLED"
+		}
+		layer.Tools = append(layer.Tools, tool)
+	}
+
+	if v := os.Getenv("PB_HIDE_IDLE_AFTER"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid PB_HIDE_IDLE_AFTER %q: %w", v, err)
+		}
+		layer.HideIdleAfter = d
+		vars["hide_idle_after"] = "env:PB_HIDE_IDLE_AFTER"
+	}
+	return &layer, vars, nil
+}
+
+// equalTaskFilterPreset compares two presets field by field for Merge.
+func equalTaskFilterPreset(a, b TaskFilterPreset) bool {
+	return a.Name == b.Name && a.Replace == b.Replace && slices.Equal(a.NoisePatterns, b.NoisePatterns) &&
+		slices.Equal(a.HighlightPatterns, b.HighlightPatterns)
+}
@@ -0,0 +1,433 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// setupLayers points HOME at a temp dir, clears PB_* overrides, and returns
+// the home dir and a nested project dir inside it.
+func setupLayers(t *testing.T) (home, project string) {
+	t.Helper()
+	home = t.TempDir()
+	t.Setenv("HOME", home)
+	for _, v := range []string{
+		"PB_CLAUDE_COMMAND", "PB_CLAUDE_KEY", "PB_CLAUDE_ENABLED",
+		"PB_CODEX_COMMAND", "PB_CODEX_KEY", "PB_CODEX_ENABLED",
+		"PB_CURSOR_COMMAND", "PB_CURSOR_KEY", "PB_CURSOR_ENABLED",
+		"PB_HIDE_IDLE_AFTER",
+	} {
+		t.Setenv(v, "")
+	}
+	project = filepath.Join(home, "src", "app", "sub")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatalf("mkdir project: %v", err)
+	}
+	return home, project
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func sourceFor(sources []LoadSource, field string) string {
+	for _, s := range sources {
+		if s.Field == field {
+			return s.Source
+		}
+	}
+	return ""
+}
+
+func TestLoadAllDefaultsWithNoLayers(t *testing.T) {
+	_, project := setupLayers(t)
+
+	cfg, sources, err := LoadAll(project)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if !slices.Equal(cfg.Tools, DefaultTools()) || cfg.Tool("codex").Key != "x" {
+		t.Errorf("expected defaults, got %+v", cfg)
+	}
+	if len(sources) != 0 {
+		t.Errorf("expected no sources, got %+v", sources)
+	}
+}
+
+func TestLoadAllGlobalLayer(t *testing.T) {
+	home, project := setupLayers(t)
+	global := filepath.Join(home, ".config", "pocketbot", "config.yaml")
+	writeFile(t, global, `
+codex:
+  enabled: false
+claude:
+  command: "claude"
+`)
+
+	cfg, sources, err := LoadAll(project)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if cfg.Tool("codex").Enabled {
+		t.Error("expected codex disabled by global config")
+	}
+	if cfg.Tool("claude").Command != "claude" || cfg.Tool("claude").Key != "c" || !cfg.Tool("claude").Enabled {
+		t.Errorf("expected claude command override with default key/enabled, got %+v", cfg.Tool("claude"))
+	}
+	if got := sourceFor(sources, "codex.enabled"); got != global {
+		t.Errorf("codex.enabled source = %q, want %q", got, global)
+	}
+	if got := sourceFor(sources, "claude.key"); got != "" {
+		t.Errorf("unchanged field should have no source, got %q", got)
+	}
+}
+
+func TestLoadAllProjectLayerNearestWins(t *testing.T) {
+	home, project := setupLayers(t)
+	outer := filepath.Join(home, "src", ProjectConfigName)
+	inner := filepath.Join(home, "src", "app", ProjectConfigName)
+	writeFile(t, outer, `
+cursor:
+  command: "agent"
+sessions:
+  - name: "logs"
+    command: "tail -f outer.log"
+    key: "l"
+`)
+	writeFile(t, inner, `
+sessions:
+  - name: "logs"
+    command: "tail -f inner.log"
+    key: "l"
+  - name: "dev"
+    command: "npm run dev"
+    key: "v"
+`)
+
+	cfg, sources, err := LoadAll(project)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if cfg.Tool("cursor").Command != "agent" {
+		t.Errorf("expected outer project cursor command, got %q", cfg.Tool("cursor").Command)
+	}
+	if len(cfg.Sessions) != 2 || cfg.Sessions[0].Command != "tail -f inner.log" || cfg.Sessions[1].Name != "dev" {
+		t.Errorf("expected inner project to replace logs and add dev, got %+v", cfg.Sessions)
+	}
+	if got := sourceFor(sources, "sessions.logs"); got != inner {
+		t.Errorf("sessions.logs source = %q, want %q", got, inner)
+	}
+	if got := sourceFor(sources, "cursor.command"); got != outer {
+		t.Errorf("cursor.command source = %q, want %q", got, outer)
+	}
+}
+
+func TestLoadAllEnvLayer(t *testing.T) {
+	_, project := setupLayers(t)
+	t.Setenv("PB_CODEX_COMMAND", "codex --model o3")
+	t.Setenv("PB_CURSOR_ENABLED", "false")
+	t.Setenv("PB_HIDE_IDLE_AFTER", "45m")
+
+	cfg, sources, err := LoadAll(project)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if cfg.Tool("codex").Command != "codex --model o3" {
+		t.Errorf("expected env codex command, got %q", cfg.Tool("codex").Command)
+	}
+	if cfg.Tool("cursor").Enabled {
+		t.Error("expected cursor disabled by env")
+	}
+	if cfg.HideIdleAfter != 45*time.Minute {
+		t.Errorf("expected hide_idle_after from env, got %v", cfg.HideIdleAfter)
+	}
+	if got := sourceFor(sources, "cursor.enabled"); got != "env:PB_CURSOR_ENABLED" {
+		t.Errorf("cursor.enabled source = %q", got)
+	}
+}
+
+func TestLoadAllInvalidEnvValue(t *testing.T) {
+	_, project := setupLayers(t)
+	t.Setenv("PB_CLAUDE_ENABLED", "maybe")
+
+	if _, _, err := LoadAll(project); err == nil {
+		t.Fatal("expected error for invalid PB_CLAUDE_ENABLED")
+	}
+}
+
+func TestLoadAllLayersCombine(t *testing.T) {
+	home, project := setupLayers(t)
+	global := filepath.Join(home, ".config", "pocketbot", "config.yaml")
+	proj := filepath.Join(home, "src", "app", ProjectConfigName)
+	writeFile(t, global, `
+claude:
+  command: "claude --global"
+codex:
+  enabled: false
+warnings:
+  notify: true
+`)
+	writeFile(t, proj, `
+claude:
+  command: "claude --project"
+codex:
+  enabled: true
+warnings:
+  notify: false
+`)
+	t.Setenv("PB_CLAUDE_COMMAND", "claude --env")
+
+	cfg, sources, err := LoadAll(project)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if cfg.Tool("claude").Command != "claude --env" {
+		t.Errorf("expected env to win for claude command, got %q", cfg.Tool("claude").Command)
+	}
+	if !cfg.Tool("codex").Enabled {
+		t.Error("expected project to re-enable codex")
+	}
+	if cfg.Warnings.Notify {
+		t.Error("expected project to turn notify back off")
+	}
+	if got := sourceFor(sources, "claude.command"); got != "env:PB_CLAUDE_COMMAND" {
+		t.Errorf("claude.command source = %q", got)
+	}
+	if got := sourceFor(sources, "codex.enabled"); got != proj {
+		t.Errorf("codex.enabled source = %q, want %q", got, proj)
+	}
+}
+
+func TestLoadAllValidatesMergedConfig(t *testing.T) {
+	home, project := setupLayers(t)
+	writeFile(t, filepath.Join(home, "src", ProjectConfigName), `
+sessions:
+  - name: "cargo"
+    command: "cargo watch"
+    key: "c"
+`)
+
+	if _, _, err := LoadAll(project); err == nil {
+		t.Fatal("expected duplicate key error from merged config")
+	}
+}
+
+func TestMergeLeavesUnsetFieldsAlone(t *testing.T) {
+	cfg := DefaultConfig()
+	changed := cfg.Merge(&Config{Tools: []ToolConfig{{Name: "codex", Key: "o"}}})
+
+	if len(changed) != 1 || changed[0] != "codex.key" {
+		t.Fatalf("expected only codex.key changed, got %v", changed)
+	}
+	if !cfg.Tool("codex").Enabled || cfg.Tool("codex").Command != "codex resume --last" {
+		t.Errorf("unset fields should keep defaults, got %+v", cfg.Tool("codex"))
+	}
+}
+
+func TestLoadAllProjectCanTurnOffFollowSessionCwd(t *testing.T) {
+	home, project := setupLayers(t)
+	global := filepath.Join(home, ".config", "pocketbot", "config.yaml")
+	writeFile(t, global, "ui:\n  follow_session_cwd: true\n")
+
+	cfg, _, err := LoadAll(project)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if !cfg.UI.FollowSessionCwd {
+		t.Fatal("expected global config to enable follow_session_cwd")
+	}
+
+	local := filepath.Join(home, "src", "app", ProjectConfigName)
+	writeFile(t, local, "ui:\n  follow_session_cwd: false\n")
+	cfg, sources, err := LoadAll(project)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if cfg.UI.FollowSessionCwd {
+		t.Error("expected explicit false in project config to win")
+	}
+	if got := sourceFor(sources, "ui.follow_session_cwd"); got != local {
+		t.Errorf("ui.follow_session_cwd source = %q, want %q", got, local)
+	}
+}
+
+func TestMergeMaxInstances(t *testing.T) {
+	cfg := DefaultConfig()
+	changed := cfg.Merge(&Config{Tools: []ToolConfig{{Name: "codex", MaxInstances: 4}}})
+	if len(changed) != 1 || changed[0] != "codex.max_instances" {
+		t.Fatalf("expected only codex.max_instances changed, got %v", changed)
+	}
+	if cfg.Tool("codex").MaxInstances != 4 || cfg.Tool("claude").MaxInstances != 0 {
+		t.Errorf("got claude=%d codex=%d, want 0 and 4", cfg.Tool("claude").MaxInstances, cfg.Tool("codex").MaxInstances)
+	}
+}
+
+func TestLoadAllProjectSocket(t *testing.T) {
+	_, project := setupLayers(t)
+	writeFile(t, filepath.Join(project, ProjectConfigName), "socket: proj-a\n")
+	cfg, sources, err := LoadAll(project)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if cfg.Socket != "proj-a" {
+		t.Errorf("socket = %q, want proj-a", cfg.Socket)
+	}
+	if got := sourceFor(sources, "socket"); got != filepath.Join(project, ProjectConfigName) {
+		t.Errorf("socket source = %q", got)
+	}
+}
+
+func TestLoadAllProjectReuseSessions(t *testing.T) {
+	_, project := setupLayers(t)
+	writeFile(t, filepath.Join(project, ProjectConfigName), "reuse_sessions: true\n")
+	cfg, sources, err := LoadAll(project)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if !cfg.ReuseSessions {
+		t.Error("expected reuse_sessions to be set")
+	}
+	if got := sourceFor(sources, "reuse_sessions"); got != filepath.Join(project, ProjectConfigName) {
+		t.Errorf("reuse_sessions source = %q", got)
+	}
+}
+
+func TestMergeColumns(t *testing.T) {
+	cfg := DefaultConfig()
+	if changed := cfg.Merge(&Config{}); len(changed) != 0 {
+		t.Fatalf("expected unset columns to leave defaults, got changes %v", changed)
+	}
+	changed := cfg.Merge(&Config{UI: UIConfig{Columns: []string{"name", "status"}}})
+	if len(changed) != 1 || changed[0] != "ui.columns" {
+		t.Fatalf("expected only ui.columns changed, got %v", changed)
+	}
+	if !slices.Equal(cfg.UI.Columns, []string{"name", "status"}) {
+		t.Errorf("columns = %v, want [name status]", cfg.UI.Columns)
+	}
+}
+
+func TestMergeToolsByName(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Tools = []ToolConfig{{Name: "aider", Command: "aider", Key: "a", Enabled: true}}
+	changed := cfg.Merge(&Config{Tools: []ToolConfig{
+		{Name: "aider", Command: "aider --restore-chat-history", Key: "a", Enabled: true},
+		{Name: "gemini", Command: "gemini", Key: "g", Enabled: true},
+	}})
+	if !slices.Equal(changed, []string{"aider.command", "tools.gemini"}) {
+		t.Fatalf("changed = %v, want [aider.command tools.gemini]", changed)
+	}
+	if len(cfg.Tools) != 2 || cfg.Tools[0].Command != "aider --restore-chat-history" {
+		t.Errorf("tools = %+v", cfg.Tools)
+	}
+}
+
+func TestMergeTaskFilterPresets(t *testing.T) {
+	cfg := DefaultConfig()
+	presets := []TaskFilterPreset{{Name: "build", NoisePatterns: []string{"node"}}}
+	changed := cfg.Merge(&Config{UI: UIConfig{TaskFilterPresets: presets}})
+	if len(changed) != 1 || changed[0] != "ui.task_filter_presets" {
+		t.Fatalf("expected only ui.task_filter_presets changed, got %v", changed)
+	}
+	if changed := cfg.Merge(&Config{UI: UIConfig{TaskFilterPresets: presets}}); len(changed) != 0 {
+		t.Fatalf("expected identical presets to be no change, got %v", changed)
+	}
+	presets[0].NoisePatterns[0] = "sleep"
+	if cfg.UI.TaskFilterPresets[0].NoisePatterns[0] != "node" {
+		t.Errorf("merged presets should not alias the layer's, got %+v", cfg.UI.TaskFilterPresets)
+	}
+}
+
+func TestMergeCwdAliasesByPrefix(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Merge(&Config{UI: UIConfig{CwdAliases: map[string]string{"/src": "src", "/work": "w"}}})
+	changed := cfg.Merge(&Config{UI: UIConfig{CwdAliases: map[string]string{"/work": "work", "/src": "src"}}})
+	if !slices.Equal(changed, []string{"ui.cwd_aliases./work"}) {
+		t.Fatalf("expected only the changed alias reported, got %v", changed)
+	}
+	if cfg.UI.CwdAliases["/src"] != "src" || cfg.UI.CwdAliases["/work"] != "work" {
+		t.Errorf("cwd_aliases = %v, want /src=src and /work=work", cfg.UI.CwdAliases)
+	}
+}
+
+func TestLoadAllAppendsProjectSessions(t *testing.T) {
+	home, project := setupLayers(t)
+	writeFile(t, filepath.Join(home, ".config", "pocketbot", "config.yaml"), `
+codex:
+  command: "codex"
+sessions:
+  - {name: logs, command: "tail -f log", key: l}
+`)
+	writeFile(t, filepath.Join(project, ProjectConfigName), `
+tools:
+  - name: claude
+    command: "claude --model opus"
+sessions:
+  - {name: dev, command: "npm run dev", key: v}
+`)
+
+	cfg, _, err := LoadAll(project)
+	if err != nil {
+		t.Fatalf("LoadAll: %v", err)
+	}
+	if cfg.Tool("claude").Command != "claude --model opus" || cfg.Tool("codex").Command != "codex" {
+		t.Errorf("claude = %+v, codex = %+v", cfg.Tool("claude"), cfg.Tool("codex"))
+	}
+	var names []string
+	for _, sess := range cfg.AllSessions() {
+		names = append(names, sess.Name)
+	}
+	if !slices.Equal(names, []string{"claude", "codex", "cursor", "logs", "dev"}) {
+		t.Errorf("AllSessions names = %v, want [claude codex cursor logs dev]", names)
+	}
+}
+
+func TestLoadAllRejectsProjectSessionNamedLikeUserSession(t *testing.T) {
+	home, project := setupLayers(t)
+	writeFile(t, filepath.Join(home, ".config", "pocketbot", "config.yaml"),
+		"sessions:\n  - {name: dev, command: \"npm run dev\", key: v}\n")
+	// Also from a project file further up, not just the nearest one.
+	writeFile(t, filepath.Join(home, "src", ProjectConfigName),
+		"sessions:\n  - {name: dev, command: \"pnpm dev\", key: p}\n")
+
+	if _, _, err := LoadAll(project); err == nil || err.Error() != `duplicate session "dev"` {
+		t.Fatalf("expected duplicate session error, got %v", err)
+	}
+	cfg, _, err := LoadAllUnvalidated(project)
+	if err != nil {
+		t.Fatalf("LoadAllUnvalidated: %v", err)
+	}
+	if len(cfg.Sessions) != 2 {
+		t.Errorf("expected both dev sessions to be kept for inspection, got %+v", cfg.Sessions)
+	}
+}
+
+func TestWatchPathsIncludesMissingProjectFiles(t *testing.T) {
+	home, project := setupLayers(t)
+	writeFile(t, filepath.Join(home, "src", ProjectConfigName), "reuse_sessions: true\n")
+
+	paths := WatchPaths(project)
+	global, _ := ConfigPath()
+	if len(paths) == 0 || paths[0] != global {
+		t.Fatalf("expected the global config first, got %v", paths)
+	}
+	for _, want := range []string{
+		filepath.Join(home, "src", ProjectConfigName),
+		filepath.Join(project, ProjectConfigName),
+	} {
+		if !slices.Contains(paths, want) {
+			t.Errorf("WatchPaths(%q) = %v, missing %s", project, paths, want)
+		}
+	}
+	if got := WatchPaths(""); !slices.Equal(got, []string{global}) {
+		t.Errorf("WatchPaths(\"\") = %v, want only the global config", got)
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TaskPatterns extends the built-in task filter used by `pb tasks` and the
+// home-screen task counts. Patterns are case-insensitive substrings of the
+// process command line.
+type TaskPatterns struct {
+	// Noise hides matching processes, in addition to the built-in noise.
+	Noise []string `yaml:"noise"`
+	// Highlight prefers matching processes when picking which command
+	// represents a process tree.
+	Highlight []string `yaml:"highlight"`
+}
+
+// TaskPatternsPath returns the path to the task pattern file.
+func TaskPatternsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".config", "pocketbot", "task-patterns.yaml"), nil
+}
+
+// loadTaskPatterns reads the task pattern file. A missing file yields empty
+// patterns.
+func loadTaskPatterns() (TaskPatterns, error) {
+	var patterns TaskPatterns
+	path, err := TaskPatternsPath()
+	if err != nil {
+		return patterns, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return patterns, nil
+	}
+	if err != nil {
+		return patterns, fmt.Errorf("failed to read task patterns: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &patterns); err != nil {
+		return patterns, fmt.Errorf("failed to parse task patterns %s: %w", path, err)
+	}
+	return patterns, nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadTaskPatternsMissingFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	patterns, err := loadTaskPatterns()
+	if err != nil {
+		t.Fatalf("loadTaskPatterns: %v", err)
+	}
+	if patterns.Noise != nil || patterns.Highlight != nil {
+		t.Errorf("expected empty patterns, got %+v", patterns)
+	}
+}
+
+func TestLoadAttachesTaskPatterns(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".config", "pocketbot")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	content := "noise:\n  - \"tail -f\"\n  - \"esbuild --watch\"\nhighlight:\n  - \"vite dev\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "task-patterns.yaml"), []byte(content), 0644); err != nil {
+		t.Fatalf("write task patterns: %v", err)
+	}
+
+	cfg, _, err := LoadAll("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !slices.Equal(cfg.TaskPatterns.Noise, []string{"tail -f", "esbuild --watch"}) {
+		t.Errorf("unexpected noise patterns: %v", cfg.TaskPatterns.Noise)
+	}
+	if !slices.Equal(cfg.TaskPatterns.Highlight, []string{"vite dev"}) {
+		t.Errorf("unexpected highlight patterns: %v", cfg.TaskPatterns.Highlight)
+	}
+}
+
+func TestLoadTaskPatternsInvalidYAML(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".config", "pocketbot", "task-patterns.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte("noise: [unterminated\n"), 0644); err != nil {
+		t.Fatalf("write task patterns: %v", err)
+	}
+
+	if _, err := loadTaskPatterns(); err == nil {
+		t.Fatal("expected parse error")
+	}
+}
@@ -0,0 +1,76 @@
+// Package log writes pb's debug log. The TUI owns the terminal, so anything
+// printed to stderr while it runs corrupts the display; log lines go to the
+// file named by --log-file instead, and are dropped when no file is set.
+package log
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timestampFormat prefixes every line; milliseconds help order tick output.
+const timestampFormat = "2006-01-02T15:04:05.000Z07:00"
+
+var (
+	mu  sync.Mutex
+	out *os.File
+	now = time.Now
+)
+
+// Open appends subsequent log lines to path, creating it if needed. It
+// replaces (and closes) any file opened earlier.
+func Open(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if out != nil {
+		_ = out.Close()
+	}
+	out = f
+	return nil
+}
+
+// Close stops logging; later calls are no-ops until Open is called again.
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if out == nil {
+		return nil
+	}
+	err := out.Close()
+	out = nil
+	return err
+}
+
+// Enabled reports whether a log file is open.
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return out != nil
+}
+
+// Debug logs a diagnostic line.
+func Debug(format string, args ...any) {
+	write("DEBUG", format, args...)
+}
+
+// Error logs a failure that pb recovered from or could not show on screen.
+func Error(format string, args ...any) {
+	write("ERROR", format, args...)
+}
+
+func write(level, format string, args ...any) {
+	mu.Lock()
+	defer mu.Unlock()
+	if out == nil {
+		return
+	}
+	msg := strings.TrimRight(fmt.Sprintf(format, args...), "\n")
+	fmt.Fprintf(out, "%s %s %s\n", now().Format(timestampFormat), level, msg)
+}
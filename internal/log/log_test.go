@@ -0,0 +1,87 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogWritesTimestampedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pb.log")
+	if err := Open(path); err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	defer Close()
+
+	Debug("tick %d", 3)
+	Error("attach %s failed\n", "codex")
+	if err := Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %q", data)
+	}
+	stamped := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}\S* `)
+	for i, want := range []string{"DEBUG tick 3", "ERROR attach codex failed"} {
+		if !stamped.MatchString(lines[i]) {
+			t.Errorf("line %d has no timestamp: %q", i, lines[i])
+		}
+		if !strings.HasSuffix(lines[i], " "+want) {
+			t.Errorf("line %d = %q, want suffix %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestLogTimestampUsesClock(t *testing.T) {
+	originalNow := now
+	defer func() { now = originalNow }()
+	now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 6e6, time.UTC) }
+
+	path := filepath.Join(t.TempDir(), "pb.log")
+	if err := Open(path); err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	Debug("hello")
+	Close()
+
+	data, _ := os.ReadFile(path)
+	if got := string(data); got != "2026-01-02T03:04:05.006Z DEBUG hello\n" {
+		t.Fatalf("log line = %q", got)
+	}
+}
+
+func TestLogIsNoOpWithoutFile(t *testing.T) {
+	Close()
+	if Enabled() {
+		t.Fatal("expected logging disabled")
+	}
+	// Must not panic or write anywhere.
+	Debug("dropped")
+	Error("dropped")
+}
+
+func TestOpenAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pb.log")
+	if err := os.WriteFile(path, []byte("earlier\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Open(path); err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	Debug("later")
+	Close()
+
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "earlier\n") || !strings.HasSuffix(string(data), " DEBUG later\n") {
+		t.Fatalf("expected appended log, got %q", data)
+	}
+}
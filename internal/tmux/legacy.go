@@ -0,0 +1,110 @@
+package tmux
+
+import (
+	"strconv"
+	"strings"
+)
+
+// supportsCustomOptions reports whether the server can store @pb_* user
+// options on sessions (tmux >= 3.0). When false, session options live in a
+// JSON file instead; see sessionOptsPath.
+var supportsCustomOptions = true
+
+// DetectOptionSupport checks the running server's version once at startup
+// and switches session options to the file-based fallback on tmux < 3.0. It
+// leaves the default in place when the server is not running yet.
+func DetectOptionSupport() {
+	info, err := GetServerInfo()
+	if err != nil {
+		return
+	}
+	supportsCustomOptions = versionAtLeast(info.Version, 3, 0)
+}
+
+// versionAtLeast compares a tmux version such as "3.3a" or "next-3.4"
+// against major.minor. Unparseable versions are assumed to be new enough.
+func versionAtLeast(version string, major, minor int) bool {
+	v := strings.TrimPrefix(strings.TrimSpace(version), "next-")
+	majorStr, minorStr, _ := strings.Cut(v, ".")
+	gotMajor, err := strconv.Atoi(majorStr)
+	if err != nil {
+		return true
+	}
+	if gotMajor != major {
+		return gotMajor > major
+	}
+	gotMinor, _ := strconv.Atoi(strings.TrimRightFunc(minorStr, func(r rune) bool {
+		return r < '0' || r > '9'
+	}))
+	return gotMinor >= minor
+}
+
+// sessionOptions maps session name to option name to value.
+type sessionOptions map[string]map[string]string
+
+// sessionOptsPath returns the fallback option store used on old tmux.
+func sessionOptsPath() (string, error) {
+	return dataFilePath("session-opts.json")
+}
+
+// withSessionOptsFile opens the fallback store under a flock and passes the
+// decoded contents to fn. When write is true the lock is exclusive and the
+// (possibly modified) contents are written back after fn returns.
+func withSessionOptsFile(write bool, fn func(sessionOptions)) error {
+	path, err := sessionOptsPath()
+	if err != nil {
+		return err
+	}
+	opts := sessionOptions{}
+	return withLockedJSONFile(path, write, &opts, func() { fn(opts) })
+}
+
+func getFileSessionOption(sessionName, option string) string {
+	var value string
+	_ = withSessionOptsFile(false, func(opts sessionOptions) {
+		value = opts[sessionName][option]
+	})
+	return value
+}
+
+// getFileSessionOptions returns a copy of every option stored for a session.
+func getFileSessionOptions(sessionName string) map[string]string {
+	values := make(map[string]string)
+	_ = withSessionOptsFile(false, func(opts sessionOptions) {
+		for option, value := range opts[sessionName] {
+			values[option] = value
+		}
+	})
+	return values
+}
+
+func setFileSessionOption(sessionName, option, value string) error {
+	return withSessionOptsFile(true, func(opts sessionOptions) {
+		if value == "" {
+			delete(opts[sessionName], option)
+			if len(opts[sessionName]) == 0 {
+				delete(opts, sessionName)
+			}
+			return
+		}
+		if opts[sessionName] == nil {
+			opts[sessionName] = map[string]string{}
+		}
+		opts[sessionName][option] = value
+	})
+}
+
+// renameFileSessionOptions moves stored options to a renamed session, or
+// drops them when newName is empty (the session was killed).
+func renameFileSessionOptions(oldName, newName string) error {
+	return withSessionOptsFile(true, func(opts sessionOptions) {
+		moved, ok := opts[oldName]
+		if !ok {
+			return
+		}
+		delete(opts, oldName)
+		if newName != "" {
+			opts[newName] = moved
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package tmux
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "3.3a", want: true},
+		{version: "3.0", want: true},
+		{version: "next-3.4", want: true},
+		{version: "2.9a", want: false},
+		{version: "2.9", want: false},
+		{version: "1.8", want: false},
+		{version: "openbsd-7.4", want: true},
+	}
+	for _, tt := range tests {
+		if got := versionAtLeast(tt.version, 3, 0); got != tt.want {
+			t.Fatalf("versionAtLeast(%q, 3, 0)=%v, want %v", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestSessionOptionUsesTmuxWhenSupported(t *testing.T) {
+	originalExec := execCommand
+	defer func() { execCommand = originalExec }()
+	t.Setenv("PB_LEVEL", "")
+	t.Setenv("HOME", t.TempDir())
+
+	var calls [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		calls = append(calls, append([]string{name}, args...))
+		switch args[2] {
+		case "list-sessions":
+			return exec.Command("false")
+		case "show-options":
+			return exec.Command("echo", "claude")
+		}
+		return exec.Command("true")
+	}
+
+	if err := SetSessionOption("work", "@pb_tool", "claude"); err != nil {
+		t.Fatalf("SetSessionOption returned error: %v", err)
+	}
+	want := []string{"tmux", "-L", "pocketbot", "set-option", "-t", "work", "@pb_tool", "claude"}
+	if last := calls[len(calls)-1]; !reflect.DeepEqual(last, want) {
+		t.Fatalf("set invocation = %v, want %v", last, want)
+	}
+	if got := GetSessionTool("work"); got != "claude" {
+		t.Fatalf("GetSessionTool()=%q, want claude", got)
+	}
+	if _, err := os.Stat(filepath.Join(os.Getenv("HOME"), ".local", "share", "pocketbot")); !os.IsNotExist(err) {
+		t.Fatalf("expected no fallback store to be created, stat err=%v", err)
+	}
+}
+
+func TestSessionOptionFallsBackToFileOnOldTmux(t *testing.T) {
+	originalExec := execCommand
+	defer func() {
+		execCommand = originalExec
+		supportsCustomOptions = true
+	}()
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		t.Fatalf("unexpected tmux invocation: %v", args)
+		return nil
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	supportsCustomOptions = false
+
+	if got := GetSessionOption("work", "@pb_memo"); got != "" {
+		t.Fatalf("expected empty option before any write, got %q", got)
+	}
+	if err := SetSessionOption("work", "@pb_memo", "fix flaky test"); err != nil {
+		t.Fatalf("SetSessionOption returned error: %v", err)
+	}
+	if err := SetSessionYolo("work", true); err != nil {
+		t.Fatalf("SetSessionYolo returned error: %v", err)
+	}
+	if got := GetSessionOption("work", "@pb_memo"); got != "fix flaky test" {
+		t.Fatalf("GetSessionOption()=%q, want memo", got)
+	}
+	if !GetSessionYolo("work") {
+		t.Fatal("expected yolo to round-trip through the file store")
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, ".local", "share", "pocketbot", "session-opts.json"))
+	if err != nil {
+		t.Fatalf("read fallback store: %v", err)
+	}
+	if !strings.Contains(string(data), `"@pb_memo": "fix flaky test"`) {
+		t.Fatalf("expected memo in fallback store, got %s", data)
+	}
+
+	if err := SetSessionOption("work", "@pb_memo", ""); err != nil {
+		t.Fatalf("unset returned error: %v", err)
+	}
+	if got := GetSessionOption("work", "@pb_memo"); got != "" {
+		t.Fatalf("expected memo unset, got %q", got)
+	}
+}
+
+func TestRenameFileSessionOptions(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if err := setFileSessionOption("codex", "@pb_tool", "codex"); err != nil {
+		t.Fatalf("setFileSessionOption returned error: %v", err)
+	}
+	if err := renameFileSessionOptions("codex", "codex-api"); err != nil {
+		t.Fatalf("rename returned error: %v", err)
+	}
+	if got := getFileSessionOption("codex-api", "@pb_tool"); got != "codex" {
+		t.Fatalf("expected options to follow rename, got %q", got)
+	}
+	if got := getFileSessionOption("codex", "@pb_tool"); got != "" {
+		t.Fatalf("expected old name cleared, got %q", got)
+	}
+
+	if err := renameFileSessionOptions("codex-api", ""); err != nil {
+		t.Fatalf("drop returned error: %v", err)
+	}
+	if got := getFileSessionOption("codex-api", "@pb_tool"); got != "" {
+		t.Fatalf("expected options dropped, got %q", got)
+	}
+}
+
+func TestGetAllSessionPBOptionsReadsFileStoreOnOldTmux(t *testing.T) {
+	originalExec := execCommand
+	defer func() {
+		execCommand = originalExec
+		supportsCustomOptions = true
+	}()
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		t.Fatalf("unexpected tmux invocation: %v", args)
+		return nil
+	}
+	t.Setenv("HOME", t.TempDir())
+	supportsCustomOptions = false
+
+	if err := SetSessionOption("work", "@pb_memo", "fix flaky test"); err != nil {
+		t.Fatalf("SetSessionOption returned error: %v", err)
+	}
+	if err := SetSessionYolo("work", true); err != nil {
+		t.Fatalf("SetSessionYolo returned error: %v", err)
+	}
+	opts, err := GetAllSessionPBOptions("work")
+	if err != nil {
+		t.Fatalf("GetAllSessionPBOptions returned error: %v", err)
+	}
+	if want := map[string]string{"@pb_memo": "fix flaky test", "@pb_yolo": "1"}; !reflect.DeepEqual(opts, want) {
+		t.Fatalf("options = %v, want %v", opts, want)
+	}
+}
@@ -0,0 +1,112 @@
+package tmux
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// dataFilePath returns the path of a file in pocketbot's data directory.
+func dataFilePath(name string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", "pocketbot", name), nil
+}
+
+// withLockedJSONFile opens path under a flock, decodes it into v and calls
+// fn. When write is true the lock is exclusive and v is written back after
+// fn returns. A missing file reads as empty.
+func withLockedJSONFile(path string, write bool, v any, fn func()) error {
+	flag, how := os.O_RDONLY, syscall.LOCK_SH
+	if write {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+		}
+		flag, how = os.O_RDWR|os.O_CREATE, syscall.LOCK_EX
+	}
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		return fmt.Errorf("lock %s: %w", path, err)
+	}
+	defer syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	fn()
+	if !write {
+		return nil
+	}
+
+	data, err = json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if _, err := f.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	return nil
+}
+
+// state is pocketbot's persistent state file. Values that belong to a
+// particular tmux server are keyed by SocketName so that isolated sockets
+// (tests, PB_SOCKET) don't see each other's state.
+type state struct {
+	LastAttached map[string]string `json:"last_attached,omitempty"`
+}
+
+func withStateFile(write bool, fn func(*state)) error {
+	path, err := dataFilePath("state.json")
+	if err != nil {
+		return err
+	}
+	var st state
+	return withLockedJSONFile(path, write, &st, func() { fn(&st) })
+}
+
+// SetLastAttached records the most recently attached session in the state
+// file so `pb attach --last` can find it again.
+func SetLastAttached(name string) error {
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	socket := SocketName()
+	return withStateFile(true, func(st *state) {
+		if name == "" {
+			delete(st.LastAttached, socket)
+			return
+		}
+		if st.LastAttached == nil {
+			st.LastAttached = map[string]string{}
+		}
+		st.LastAttached[socket] = name
+	})
+}
+
+// GetLastAttached returns the most recently attached session name, or "" if
+// none has been recorded for this server.
+func GetLastAttached() string {
+	var name string
+	_ = withStateFile(false, func(st *state) {
+		name = st.LastAttached[SocketName()]
+	})
+	return name
+}
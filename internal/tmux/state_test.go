@@ -0,0 +1,49 @@
+package tmux
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLastAttachedPersistsPerSocket(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PB_TMUX_SOCKET_DIR", "")
+	t.Setenv("PB_LEVEL", "")
+
+	if got := GetLastAttached(); got != "" {
+		t.Fatalf("GetLastAttached() = %q before anything was recorded", got)
+	}
+	if err := SetLastAttached("claude"); err != nil {
+		t.Fatalf("SetLastAttached returned error: %v", err)
+	}
+	if got := GetLastAttached(); got != "claude" {
+		t.Fatalf("GetLastAttached() = %q, want claude", got)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".local", "share", "pocketbot", "state.json")); err != nil {
+		t.Fatalf("expected state file to be written: %v", err)
+	}
+
+	t.Setenv("PB_LEVEL", "1")
+	if got := GetLastAttached(); got != "" {
+		t.Fatalf("nested server saw %q from the top-level server", got)
+	}
+	if err := SetLastAttached("codex"); err != nil {
+		t.Fatalf("SetLastAttached returned error: %v", err)
+	}
+	t.Setenv("PB_LEVEL", "")
+	if got := GetLastAttached(); got != "claude" {
+		t.Fatalf("GetLastAttached() = %q after nested write, want claude", got)
+	}
+}
+
+func TestSetLastAttachedRefusedWhenReadOnly(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	readOnly = true
+	defer func() { readOnly = false }()
+
+	if err := SetLastAttached("claude"); err != ErrReadOnly {
+		t.Fatalf("SetLastAttached error = %v, want ErrReadOnly", err)
+	}
+}
@@ -1,6 +1,7 @@
 package tmux
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
 	"path/filepath"
@@ -17,6 +18,21 @@ type Task struct {
 	Command string
 }
 
+// FilterOptions extends the built-in user-task filter with patterns from
+// the user's task-patterns.yaml. Patterns are case-insensitive substrings.
+type FilterOptions struct {
+	Noise     []string // Hide matching commands, checked after built-in noise
+	Highlight []string // Prefer matching commands as a branch's representative
+}
+
+// taskFilter is applied by SessionUserTasks; see SetTaskFilterOptions.
+var taskFilter FilterOptions
+
+// SetTaskFilterOptions sets the user patterns SessionUserTasks filters with.
+func SetTaskFilterOptions(opts FilterOptions) {
+	taskFilter = opts
+}
+
 type processInfo struct {
 	pid     int
 	ppid    int
@@ -48,13 +64,106 @@ func SessionUserTasks(sessionName string) ([]Task, error) {
 	if err != nil {
 		return nil, err
 	}
-	return filterUserTasks(tasks), nil
+	return filterUserTasks(tasks, taskFilter), nil
+}
+
+// ListPanePIDs returns the PID of each pane's root process in a session.
+func ListPanePIDs(sessionName string) ([]int, error) {
+	return panePIDs(sessionName)
+}
+
+// SessionCPU returns the summed CPU percentage (ps pcpu) of a session's pane
+// root processes.
+func SessionCPU(sessionName string) (float64, error) {
+	pids, err := panePIDs(sessionName)
+	if err != nil {
+		return 0, err
+	}
+	if len(pids) == 0 {
+		return 0, nil
+	}
+	ids := make([]string, len(pids))
+	for i, pid := range pids {
+		ids[i] = strconv.Itoa(pid)
+	}
+	// ps exits non-zero when a listed PID is gone; whatever it did print
+	// still counts.
+	out, err := exec.Command("ps", "-o", "pcpu=", "-p", strings.Join(ids, ",")).Output()
+	if err != nil && len(out) == 0 {
+		return 0, err
+	}
+	return parseCPUPercents(string(out))
+}
+
+// parseCPUPercents sums the pcpu column printed by ps, one value per line.
+func parseCPUPercents(raw string) (float64, error) {
+	var total float64
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// Some locales print a decimal comma.
+		pct, err := strconv.ParseFloat(strings.Replace(line, ",", ".", 1), 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse cpu percent %q: %w", line, err)
+		}
+		total += pct
+	}
+	return total, nil
+}
+
+// ZombieSessions reports which of the named sessions have a pane whose root
+// process has exited without being reaped (ps state Z). One process snapshot
+// is shared across all sessions; sessions whose panes cannot be listed are
+// left out.
+func ZombieSessions(sessionNames []string) (map[string]bool, error) {
+	zombies := make(map[string]bool)
+	if len(sessionNames) == 0 {
+		return zombies, nil
+	}
+	processes, err := listProcesses()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range sessionNames {
+		pids, err := panePIDs(name)
+		if err != nil {
+			continue
+		}
+		if hasZombie(pids, processes) {
+			zombies[name] = true
+		}
+	}
+	return zombies, nil
+}
+
+// isZombieState reports whether a ps stat column describes a zombie.
+func isZombieState(state string) bool {
+	return strings.HasPrefix(state, "Z")
+}
+
+func hasZombie(pids []int, processes map[int]processInfo) bool {
+	for _, pid := range pids {
+		if p, ok := processes[pid]; ok && isZombieState(p.state) {
+			return true
+		}
+	}
+	return false
 }
 
 func panePIDs(sessionName string) ([]int, error) {
+	// Output keeps stdout clean for parsing and still records stderr on the
+	// ExitError, which is where tmux explains what went wrong.
 	out, err := cmd("list-panes", "-t", sessionTarget(sessionName), "-F", "#{pane_pid}").Output()
 	if err != nil {
-		return nil, err
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+				return nil, fmt.Errorf("list-panes for %s failed: %w: %s", sessionName, err, msg)
+			}
+		}
+		return nil, fmt.Errorf("list-panes for %s failed: %w", sessionName, err)
 	}
 	return parsePIDs(string(out))
 }
@@ -159,7 +268,7 @@ func collectDescendantTasks(rootPIDs []int, processes map[int]processInfo) []Tas
 	return tasks
 }
 
-func filterUserTasks(tasks []Task) []Task {
+func filterUserTasks(tasks []Task, opts FilterOptions) []Task {
 	if len(tasks) == 0 {
 		return nil
 	}
@@ -182,7 +291,7 @@ func filterUserTasks(tasks []Task) []Task {
 	selected := make(map[int]bool)
 	out := make([]Task, 0, len(roots))
 	for _, root := range roots {
-		reps := collectRepresentatives(root, children)
+		reps := collectRepresentatives(root, children, opts)
 		for _, rep := range reps {
 			if selected[rep.PID] {
 				continue
@@ -198,14 +307,14 @@ func filterUserTasks(tasks []Task) []Task {
 	return out
 }
 
-func collectRepresentatives(root Task, children map[int][]Task) []Task {
+func collectRepresentatives(root Task, children map[int][]Task, opts FilterOptions) []Task {
 	// Roots with multiple children usually represent independent branches.
 	// Split by direct child so parallel tasks are preserved.
 	kids := children[root.PID]
 	if len(kids) > 1 || isShellWrapper(root.Command) {
 		var reps []Task
 		for _, child := range kids {
-			rep, ok := chooseRepresentative(child, children)
+			rep, ok := chooseRepresentative(child, children, opts)
 			if !ok {
 				continue
 			}
@@ -216,7 +325,7 @@ func collectRepresentatives(root Task, children map[int][]Task) []Task {
 		}
 	}
 
-	rep, ok := chooseRepresentative(root, children)
+	rep, ok := chooseRepresentative(root, children, opts)
 	if !ok {
 		return nil
 	}
@@ -228,7 +337,7 @@ type taskNode struct {
 	depth int
 }
 
-func chooseRepresentative(root Task, children map[int][]Task) (Task, bool) {
+func chooseRepresentative(root Task, children map[int][]Task, opts FilterOptions) (Task, bool) {
 	queue := []taskNode{{task: root, depth: 0}}
 	bestScore := -1
 	bestDepth := 1 << 20
@@ -238,7 +347,7 @@ func chooseRepresentative(root Task, children map[int][]Task) (Task, bool) {
 		node := queue[0]
 		queue = queue[1:]
 
-		score := taskScore(node.task.Command)
+		score := taskScore(node.task.Command, opts)
 		if score > bestScore ||
 			(score == bestScore && isShellWrapper(best.Command) && !isShellWrapper(node.task.Command)) ||
 			(score == bestScore && node.depth < bestDepth) {
@@ -257,8 +366,8 @@ func chooseRepresentative(root Task, children map[int][]Task) (Task, bool) {
 	return best, true
 }
 
-func taskScore(command string) int {
-	if isNoiseCommand(command) {
+func taskScore(command string, opts FilterOptions) int {
+	if isNoiseCommand(command, opts.Noise) {
 		return -1
 	}
 	cmd := strings.TrimSpace(strings.ToLower(command))
@@ -271,6 +380,9 @@ func taskScore(command string) int {
 	if filepath.Base(words[0]) == "make" {
 		return 100
 	}
+	if matchesAnyPattern(cmd, opts.Highlight) {
+		return 99
+	}
 	if strings.Contains(cmd, "/.bin/nx serve ") {
 		return 98
 	}
@@ -289,89 +401,89 @@ func taskScore(command string) int {
 	return 50
 }
 
-func isNoiseCommand(command string) bool {
-	cmd := strings.TrimSpace(strings.ToLower(command))
-	if cmd == "" {
-		return true
-	}
+// noiseRule is one built-in noise check. pattern is how
+// BuiltinNoisePatterns shows it; match gets the lower-cased command and
+// the base name of its first word.
+type noiseRule struct {
+	pattern string
+	match   func(cmd, bin string) bool
+}
 
-	words := strings.Fields(cmd)
-	if len(words) == 0 {
-		return true
-	}
-	if isAgentLauncherCommand(cmd) {
-		return true
-	}
-	bin := filepath.Base(words[0])
+func noiseBinary(name string) noiseRule {
+	return noiseRule{"bin:" + name, func(_, bin string) bool { return bin == name }}
+}
 
-	// Agent runtimes and helpers are not user-level tasks.
-	switch bin {
-	case "claude", "codex", "agent":
-		return true
-	case "gopls", "caffeinate":
-		return true
-	}
-	if strings.Contains(cmd, " pb.test ") || strings.Contains(cmd, "/pb.test ") {
-		return true
-	}
-	if strings.Contains(cmd, " tmux.test ") || strings.Contains(cmd, "/tmux.test ") {
-		return true
-	}
-	if strings.HasPrefix(cmd, "ps -axo ") || strings.Contains(cmd, " ps -axo ") {
-		return true
-	}
-	if strings.Contains(cmd, "go run ./cmd/pb tasks") {
-		return true
-	}
-	if strings.Contains(cmd, "/exe/pb tasks") {
-		return true
-	}
-	if strings.Contains(cmd, "go test ./internal/tmux ./cmd/pb") ||
-		strings.Contains(cmd, "go test ./cmd/pb ./internal/tmux") {
-		return true
-	}
-	if strings.Contains(cmd, "gopls ** telemetry **") {
-		return true
-	}
-	// Common build/watch helper workers that are usually noise in task views.
-	if strings.Contains(cmd, "fork-ts-checker-webpack-plugin") {
-		return true
-	}
-	if strings.Contains(cmd, "nx/src/daemon/server/start.js") {
-		return true
-	}
-	if strings.Contains(cmd, "@esbuild/") && strings.Contains(cmd, "--service=") {
-		return true
-	}
-	if strings.Contains(cmd, "docker-buildx") && strings.Contains(cmd, " bake ") {
-		return true
-	}
-	if strings.Contains(cmd, "docker-compose compose up") {
-		return true
-	}
-	if strings.Contains(cmd, "worker.js") || strings.Contains(cmd, "/worker/") {
-		return true
-	}
-	if strings.Contains(cmd, "--inspect=localhost:") {
-		return true
+func noiseSubstring(sub string) noiseRule {
+	return noiseRule{sub, func(cmd, _ string) bool { return strings.Contains(cmd, sub) }}
+}
+
+// builtinNoiseRules is the built-in noise filter: agent runtimes and
+// launcher wrappers that only represent entering an agent session,
+// pocketbot's own test and task-listing processes, and common build/watch
+// helper workers.
+var builtinNoiseRules = []noiseRule{
+	noiseBinary("claude"), noiseBinary("codex"), noiseBinary("agent"),
+	noiseBinary("gopls"), noiseBinary("caffeinate"),
+	// e.g. `node /opt/homebrew/bin/codex resume --last`
+	noiseSubstring("codex resume --last"),
+	noiseSubstring("agent resume"),
+	noiseSubstring("claude --continue --permission-mode acceptedits"),
+	noiseSubstring(" pb.test "), noiseSubstring("/pb.test "),
+	noiseSubstring(" tmux.test "), noiseSubstring("/tmux.test "),
+	noiseSubstring("go run ./cmd/pb tasks"),
+	noiseSubstring("/exe/pb tasks"),
+	noiseSubstring("go test ./internal/tmux ./cmd/pb"),
+	noiseSubstring("go test ./cmd/pb ./internal/tmux"),
+	noiseSubstring("gopls ** telemetry **"),
+	noiseSubstring("fork-ts-checker-webpack-plugin"),
+	noiseSubstring("nx/src/daemon/server/start.js"),
+	noiseSubstring("docker-compose compose up"),
+	noiseSubstring("worker.js"), noiseSubstring("/worker/"),
+	noiseSubstring("--inspect=localhost:"),
+	{"ps -axo ", func(cmd, _ string) bool {
+		return strings.HasPrefix(cmd, "ps -axo ") || strings.Contains(cmd, " ps -axo ")
+	}},
+	{"@esbuild/ + --service=", func(cmd, _ string) bool {
+		return strings.Contains(cmd, "@esbuild/") && strings.Contains(cmd, "--service=")
+	}},
+	{"docker-buildx + bake", func(cmd, _ string) bool {
+		return strings.Contains(cmd, "docker-buildx") && strings.Contains(cmd, " bake ")
+	}},
+}
+
+// BuiltinNoisePatterns describes the built-in noise filter for display,
+// e.g. by `pb tasks --list-patterns`.
+func BuiltinNoisePatterns() []string {
+	out := make([]string, len(builtinNoiseRules))
+	for i, rule := range builtinNoiseRules {
+		out[i] = rule.pattern
 	}
+	return out
+}
 
+func matchesAnyPattern(cmd string, patterns []string) bool {
+	for _, pattern := range patterns {
+		p := strings.TrimSpace(pattern)
+		if p != "" && strings.Contains(cmd, strings.ToLower(p)) {
+			return true
+		}
+	}
 	return false
 }
 
-func isAgentLauncherCommand(cmd string) bool {
-	// Suppress launcher wrappers that only represent entering an agent session,
-	// e.g. `node /opt/homebrew/bin/codex resume --last`.
-	if strings.Contains(cmd, "codex resume --last") {
-		return true
-	}
-	if strings.Contains(cmd, "agent resume") {
+func isNoiseCommand(command string, userPatterns []string) bool {
+	cmd := strings.TrimSpace(strings.ToLower(command))
+	words := strings.Fields(cmd)
+	if len(words) == 0 {
 		return true
 	}
-	if strings.Contains(cmd, "claude --continue --permission-mode acceptedits") {
-		return true
+	bin := filepath.Base(words[0])
+	for _, rule := range builtinNoiseRules {
+		if rule.match(cmd, bin) {
+			return true
+		}
 	}
-	return false
+	return matchesAnyPattern(cmd, userPatterns)
 }
 
 func isShellWrapper(command string) bool {
@@ -1,8 +1,11 @@
 package tmux
 
 import (
+	"errors"
+	"os/exec"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -25,6 +28,22 @@ func TestParseProcessSnapshot(t *testing.T) {
 	}
 }
 
+func TestParseCPUPercents(t *testing.T) {
+	got, err := parseCPUPercents(" 95.5\n  2,5\n\n 0.0\n")
+	if err != nil {
+		t.Fatalf("parseCPUPercents returned error: %v", err)
+	}
+	if got != 98 {
+		t.Fatalf("expected summed cpu 98, got %v", got)
+	}
+	if got, err := parseCPUPercents(""); err != nil || got != 0 {
+		t.Fatalf("expected 0 for empty output, got %v, %v", got, err)
+	}
+	if _, err := parseCPUPercents("busy\n"); err == nil {
+		t.Fatal("expected an error for a non-numeric value")
+	}
+}
+
 func TestCollectDescendantTasks(t *testing.T) {
 	processes := map[int]processInfo{
 		100: {pid: 100, ppid: 1, state: "S+", command: "/bin/zsh"},
@@ -50,7 +69,7 @@ func TestFilterUserTasksPrefersLeafNonInfrastructure(t *testing.T) {
 		{PID: 113, PPID: 111, State: "S+", Command: "sleep 300"},
 	}
 
-	got := filterUserTasks(tasks)
+	got := filterUserTasks(tasks, FilterOptions{})
 	want := []Task{
 		{PID: 113, PPID: 111, State: "S+", Command: "sleep 300"},
 	}
@@ -65,7 +84,7 @@ func TestFilterUserTasksDropsInfrastructureOnlyTrees(t *testing.T) {
 		{PID: 112, PPID: 111, State: "S+", Command: "gopls"},
 	}
 
-	got := filterUserTasks(tasks)
+	got := filterUserTasks(tasks, FilterOptions{})
 	if len(got) != 0 {
 		t.Fatalf("filterUserTasks infrastructure-only mismatch:\n got: %#v\nwant empty", got)
 	}
@@ -81,18 +100,67 @@ func TestFilterUserTasksDropsKnownNodeWorkerNoise(t *testing.T) {
 		{PID: 4088, PPID: 3143, State: "S", Command: "/opt/homebrew/bin/node --inspect=localhost:9229 /repo/node_modules/@nx/js/src/executors/node/node-with-require-overrides"},
 	}
 
-	got := filterUserTasks(tasks)
+	got := filterUserTasks(tasks, FilterOptions{})
 	if len(got) != 0 {
 		t.Fatalf("filterUserTasks node-noise mismatch:\n got: %#v\nwant empty", got)
 	}
 }
 
+func TestBuiltinNoisePatternsMatchTheFilter(t *testing.T) {
+	for _, pattern := range BuiltinNoisePatterns() {
+		command := "run " + strings.ReplaceAll(pattern, " + ", " ") + " now"
+		if bin, ok := strings.CutPrefix(pattern, "bin:"); ok {
+			command = "/usr/local/bin/" + bin + " --flag"
+		}
+		if !isNoiseCommand(command, nil) {
+			t.Errorf("pattern %q listed but %q is not noise", pattern, command)
+		}
+	}
+	if isNoiseCommand("sleep 300", nil) {
+		t.Fatal("expected sleep 300 to be a user task")
+	}
+}
+
+func TestFilterUserTasksUserNoisePattern(t *testing.T) {
+	tasks := []Task{
+		{PID: 111, PPID: 100, State: "S+", Command: "/usr/bin/bash"},
+		{PID: 112, PPID: 111, State: "S+", Command: "tail -f /var/log/syslog"},
+		{PID: 113, PPID: 111, State: "S+", Command: "sleep 300"},
+	}
+
+	got := filterUserTasks(tasks, FilterOptions{Noise: []string{"  ", "TAIL -F"}})
+	want := []Task{
+		{PID: 113, PPID: 111, State: "S+", Command: "sleep 300"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("filterUserTasks user-noise mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+	if got := filterUserTasks(tasks, FilterOptions{}); len(got) != 2 {
+		t.Fatalf("expected both tasks without user patterns, got %#v", got)
+	}
+}
+
+func TestFilterUserTasksUserHighlightPattern(t *testing.T) {
+	tasks := []Task{
+		{PID: 111, PPID: 100, State: "S+", Command: "npm exec vite"},
+		{PID: 112, PPID: 111, State: "S+", Command: "node vite dev"},
+	}
+
+	got := filterUserTasks(tasks, FilterOptions{Highlight: []string{"vite dev"}})
+	want := []Task{
+		{PID: 112, PPID: 111, State: "S+", Command: "node vite dev"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("filterUserTasks highlight mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
 func TestFilterUserTasksDropsAgentLauncherWrapper(t *testing.T) {
 	tasks := []Task{
 		{PID: 101, PPID: 100, State: "S", Command: "node /opt/homebrew/bin/codex resume --last"},
 	}
 
-	got := filterUserTasks(tasks)
+	got := filterUserTasks(tasks, FilterOptions{})
 	if len(got) != 0 {
 		t.Fatalf("filterUserTasks launcher-wrapper mismatch:\n got: %#v\nwant empty", got)
 	}
@@ -107,7 +175,7 @@ func TestFilterUserTasksKeepsRelevantOrchestrators(t *testing.T) {
 		{PID: 42609, PPID: 42569, State: "S", Command: "/Users/zak/.docker/cli-plugins/docker-buildx bake --file - --progress rawjson"},
 	}
 
-	got := filterUserTasks(tasks)
+	got := filterUserTasks(tasks, FilterOptions{})
 	sort.Slice(got, func(i, j int) bool { return got[i].PID < got[j].PID })
 	want := []Task{
 		{PID: 3087, PPID: 3056, State: "S", Command: "/opt/homebrew/bin/node /repo/node_modules/.bin/nx serve webportal --host=0.0.0.0"},
@@ -125,7 +193,7 @@ func TestFilterUserTasksSkipsShellWrapperWhenNoBetterSignal(t *testing.T) {
 		{PID: 11, PPID: 10, State: "S", Command: "sleep 300"},
 	}
 
-	got := filterUserTasks(tasks)
+	got := filterUserTasks(tasks, FilterOptions{})
 	want := []Task{
 		{PID: 11, PPID: 10, State: "S", Command: "sleep 300"},
 	}
@@ -147,7 +215,7 @@ func TestFilterUserTasksKeepsMultipleBranchesFromSingleShellRoot(t *testing.T) {
 		{PID: 59243, PPID: 59224, State: "S", Command: "/usr/bin/make integration-test-backend"},
 	}
 
-	got := filterUserTasks(tasks)
+	got := filterUserTasks(tasks, FilterOptions{})
 	sort.Slice(got, func(i, j int) bool { return got[i].PID < got[j].PID })
 	want := []Task{
 		{PID: 3087, PPID: 3056, State: "S", Command: "node /repo/node_modules/.bin/nx serve webportal --host=0.0.0.0"},
@@ -162,7 +230,96 @@ func TestFilterUserTasksKeepsMultipleBranchesFromSingleShellRoot(t *testing.T) {
 func TestTaskScorePrefersNodeNxServeOverNpmExecWrapper(t *testing.T) {
 	npm := "npm exec nx serve webportal --host=0.0.0.0"
 	node := "node /repo/node_modules/.bin/nx serve webportal --host=0.0.0.0"
-	if taskScore(node) <= taskScore(npm) {
-		t.Fatalf("expected node nx serve to outrank npm wrapper, got node=%d npm=%d", taskScore(node), taskScore(npm))
+	if taskScore(node, FilterOptions{}) <= taskScore(npm, FilterOptions{}) {
+		t.Fatalf("expected node nx serve to outrank npm wrapper, got node=%d npm=%d", taskScore(node, FilterOptions{}), taskScore(npm, FilterOptions{}))
+	}
+}
+
+func TestListPanePIDsWrapsTmuxStderr(t *testing.T) {
+	originalExec := execCommand
+	defer func() { execCommand = originalExec }()
+	t.Setenv("PB_LEVEL", "")
+
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		if args[2] == "list-panes" {
+			return exec.Command("sh", "-c", "echo 'no server running on /tmp/tmux-501/pocketbot' >&2; exit 1")
+		}
+		return exec.Command("false")
+	}
+	_, err := ListPanePIDs("claude")
+	if err == nil {
+		t.Fatal("expected error when tmux fails")
+	}
+	want := "list-panes for claude failed: exit status 1: no server running on /tmp/tmux-501/pocketbot"
+	if err.Error() != want {
+		t.Fatalf("error = %q, want %q", err.Error(), want)
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Fatalf("expected wrapped *exec.ExitError, got %T", err)
+	}
+
+	// Without stderr the message still names the session and exit status.
+	execCommand = func(name string, args ...string) *exec.Cmd { return exec.Command("false") }
+	if _, err := ListPanePIDs("codex"); err == nil || err.Error() != "list-panes for codex failed: exit status 1" {
+		t.Fatalf("error = %v, want bare exit status", err)
+	}
+}
+
+func TestListPanePIDsParsesOutput(t *testing.T) {
+	originalExec := execCommand
+	defer func() { execCommand = originalExec }()
+	t.Setenv("PB_LEVEL", "")
+
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		if args[2] == "list-panes" {
+			return exec.Command("printf", "101\n102\n101\n")
+		}
+		return exec.Command("false")
+	}
+	got, err := ListPanePIDs("claude")
+	if err != nil {
+		t.Fatalf("ListPanePIDs returned error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []int{101, 102}) {
+		t.Fatalf("ListPanePIDs()=%v, want [101 102]", got)
+	}
+}
+
+func TestHasZombieChecksPaneRootState(t *testing.T) {
+	processes, err := parseProcessSnapshot(`
+  100   1 Ss  /bin/zsh
+  111 100 S+  claude --continue
+  200   1 Z   [codex] <defunct>
+  201   1 S   /bin/zsh
+  300   1 S+  /bin/zsh
+  301 300 Z+  [node] <defunct>
+`)
+	if err != nil {
+		t.Fatalf("parseProcessSnapshot returned error: %v", err)
+	}
+
+	cases := []struct {
+		name string
+		pids []int
+		want bool
+	}{
+		{"healthy pane", []int{100}, false},
+		{"zombie pane root", []int{200}, true},
+		{"one of several panes", []int{201, 200}, true},
+		{"zombie descendant only", []int{300}, false},
+		{"pane pid gone from snapshot", []int{999}, false},
+	}
+	for _, tc := range cases {
+		if got := hasZombie(tc.pids, processes); got != tc.want {
+			t.Errorf("%s: hasZombie(%v)=%v, want %v", tc.name, tc.pids, got, tc.want)
+		}
+	}
+}
+
+func TestZombieSessionsWithNoSessionsSkipsSnapshot(t *testing.T) {
+	got, err := ZombieSessions(nil)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("ZombieSessions(nil)=%v, %v; want empty", got, err)
 	}
 }
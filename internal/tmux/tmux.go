@@ -1,15 +1,36 @@
 package tmux
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/zakandrewking/pocketbot/internal/log"
 )
 
+// ErrSessionAttached is returned when an operation is refused because a client
+// is attached to the session.
+var ErrSessionAttached = errors.New("session is currently attached")
+
+// ErrReadOnly is returned by mutating operations while observing a foreign
+// tmux server or after RefuseWrites.
+var ErrReadOnly = errors.New("tmux server is read-only")
+
+// execCommand builds tmux subprocesses; tests swap it to capture invocations.
+var execCommand = exec.Command
+
+// sessionClientsFn is swapped in tests to fake attached clients.
+var sessionClientsFn = SessionClients
+
 // IdleTimeout is how long without changes before marking session as idle
 const IdleTimeout = 5 * time.Second
 
@@ -19,13 +40,95 @@ const (
 	activityConfirmWindow    = 500 * time.Millisecond
 )
 
+// observeArgs, when set by Observe, replace pocketbot's own socket flags so
+// every command targets a foreign server; readOnly blocks mutations there.
+// writesRefused blocks them on pocketbot's own server (--readonly).
+var (
+	observeArgs   []string
+	readOnly      bool
+	writesRefused bool
+)
+
+// Observe points all commands at an existing tmux server, given by socket
+// name (-L) or socket path (-S), and makes every mutating operation fail
+// with ErrReadOnly. Reads, captures and attach keep working.
+func Observe(socketName, socketPath string) {
+	if socketPath != "" {
+		observeArgs = []string{"-S", socketPath}
+	} else {
+		observeArgs = []string{"-L", socketName}
+	}
+	readOnly = true
+	invalidateListSessions()
+}
+
+// ReadOnly reports whether pocketbot is observing a foreign server.
+func ReadOnly() bool {
+	return readOnly
+}
+
+// RefuseWrites makes every mutating operation fail with ErrReadOnly, as in
+// observe mode, while commands keep targeting pocketbot's own server.
+func RefuseWrites() {
+	writesRefused = true
+}
+
+func guardWrite() error {
+	if readOnly || writesRefused {
+		return ErrReadOnly
+	}
+	return nil
+}
+
+// DefaultSocketName is the socket pocketbot's own server listens on at the
+// top nesting level.
+const DefaultSocketName = "pocketbot"
+
+// socketBase is the socket name before any nesting suffix; see SetSocketName.
+var socketBase = DefaultSocketName
+
+// SetSocketName makes pocketbot run its server on name instead of
+// DefaultSocketName, e.g. to keep one project's sessions apart from
+// another's. Nested levels still append -<PB_LEVEL>. An empty name restores
+// the default. Call it before any other tmux function.
+func SetSocketName(name string) {
+	if name == "" {
+		name = DefaultSocketName
+	}
+	socketBase = name
+	invalidateListSessions()
+}
+
 // getSocketName returns the tmux socket name for the current nesting level
 func getSocketName() string {
 	level := os.Getenv("PB_LEVEL")
 	if level == "" {
-		return "pocketbot"
+		return socketBase
 	}
-	return fmt.Sprintf("pocketbot-%s", level)
+	return fmt.Sprintf("%s-%s", socketBase, level)
+}
+
+// socketPath returns the full socket path when PB_TMUX_SOCKET_DIR is set,
+// or "" to let tmux pick its default socket directory.
+func socketPath() string {
+	dir := os.Getenv("PB_TMUX_SOCKET_DIR")
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, getSocketName())
+}
+
+// SocketName returns the tmux socket name pocketbot uses at this nesting
+// level (a full path under PB_TMUX_SOCKET_DIR when set), or the observed
+// socket name or path in observe mode.
+func SocketName() string {
+	if len(observeArgs) == 2 {
+		return observeArgs[1]
+	}
+	if path := socketPath(); path != "" {
+		return path
+	}
+	return getSocketName()
 }
 
 // getNestingLevel returns the current pb nesting level
@@ -38,10 +141,27 @@ func getNestingLevel() int {
 	return n
 }
 
+// socketArgs returns the flags selecting pocketbot's (or the observed) server.
+func socketArgs() []string {
+	if observeArgs != nil {
+		return observeArgs
+	}
+	if path := socketPath(); path != "" {
+		return []string{"-S", path}
+	}
+	return []string{"-L", getSocketName()}
+}
+
+// SocketArgs returns the tmux flags (-L name or -S path) that select
+// pocketbot's server, for running tmux directly.
+func SocketArgs() []string {
+	return append([]string(nil), socketArgs()...)
+}
+
 // cmd creates a tmux command using pocketbot's socket
 func cmd(args ...string) *exec.Cmd {
-	fullArgs := append([]string{"-L", getSocketName()}, args...)
-	c := exec.Command("tmux", fullArgs...)
+	fullArgs := append(append([]string{}, socketArgs()...), args...)
+	c := execCommand("tmux", fullArgs...)
 	c.Env = withoutEnv(os.Environ(), "TMUX")
 	return c
 }
@@ -64,6 +184,7 @@ func runCmd(args ...string) error {
 		return nil
 	}
 	msg := strings.TrimSpace(string(out))
+	log.Debug("tmux %s failed: %v: %s", strings.Join(args, " "), err, msg)
 	if msg == "" {
 		return fmt.Errorf("tmux %s: %w", strings.Join(args, " "), err)
 	}
@@ -71,7 +192,10 @@ func runCmd(args ...string) error {
 }
 
 func sessionIDByName(name string) string {
-	out, err := cmd("list-sessions", "-F", "#{session_name}\t#{session_id}").Output()
+	// Put the ID first and split on the first space: IDs never contain
+	// spaces, while names may. Tabs are avoided because some tmux builds
+	// escape them to "_" in format output.
+	out, err := cmd("list-sessions", "-F", "#{session_id} #{session_name}").Output()
 	if err != nil {
 		return ""
 	}
@@ -79,12 +203,12 @@ func sessionIDByName(name string) string {
 		if strings.TrimSpace(line) == "" {
 			continue
 		}
-		parts := strings.SplitN(line, "\t", 2)
+		parts := strings.SplitN(line, " ", 2)
 		if len(parts) != 2 {
 			continue
 		}
-		if parts[0] == name {
-			return strings.TrimSpace(parts[1])
+		if parts[1] == name {
+			return strings.TrimSpace(parts[0])
 		}
 	}
 	return ""
@@ -110,26 +234,70 @@ func SessionExists(name string) bool {
 	return sessionIDByName(name) != ""
 }
 
+// IsSessionAlive reports whether any pane of the session still runs its
+// process. tmux keeps panes whose process exited only when remain-on-exit
+// is set; such a session is not alive. If the panes cannot be listed, the
+// session counts as alive while it exists.
+func IsSessionAlive(name string) bool {
+	out, err := cmd("list-panes", "-s", "-t", sessionTarget(name), "-F", "#{pane_dead}").Output()
+	if err != nil {
+		return SessionExists(name)
+	}
+	return parsePaneDead(string(out))
+}
+
+// parsePaneDead reports whether any #{pane_dead} row is 0.
+func parsePaneDead(raw string) bool {
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.TrimSpace(line) == "0" {
+			return true
+		}
+	}
+	return false
+}
+
 // CreateSession creates a new detached tmux session running the given command
 func CreateSession(name, command string) error {
+	return CreateSessionInDir(name, command, "")
+}
+
+// CreateSessionInDir is CreateSession launched in dir, which is also stored
+// as the session's @pb_cwd. An empty dir uses the current directory.
+func CreateSessionInDir(name, command, dir string) error {
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	defer invalidateListSessions()
 	// Get current working directory to store with session
-	cwd, _ := os.Getwd()
+	cwd := dir
+	if cwd == "" {
+		cwd, _ = os.Getwd()
+	}
 
 	// Set PB_LEVEL environment variable for nested pb instances
 	// Also set PB_CWD to track where session was launched from
 	nextLevel := getNestingLevel() + 1
 	envCmd := fmt.Sprintf("export PB_LEVEL=%d; export PB_CWD='%s'; %s", nextLevel, cwd, command)
 
-	if err := runCmd("new-session", "-d", "-s", name, "-c", cwd, "sh", "-c", envCmd); err != nil {
-		return err
+	newSession := []string{"new-session", "-d", "-s", name, "-c", cwd, "sh", "-c", envCmd}
+	if err := runCmd(newSession...); err != nil {
+		if !isServerStartupErr(err) {
+			return err
+		}
+		// The first command after a reboot can race with server startup.
+		// Start the server explicitly and retry once.
+		_ = ensureServer()
+		if err := runCmd(newSession...); err != nil {
+			return err
+		}
 	}
 
 	// Store the launch directory as a tmux session option (for easy querying)
-	if err := runCmd("set-option", "-t", sessionTarget(name), "@pb_cwd", cwd); err != nil {
+	if err := SetSessionOption(name, "@pb_cwd", cwd); err != nil {
 		// Non-fatal - just means we can't check directory later
 	}
 	// Store which configured command this session belongs to.
-	if err := runCmd("set-option", "-t", sessionTarget(name), "@pb_command", name); err != nil {
+	if err := SetSessionOption(name, "@pb_command", name); err != nil {
 		// Non-fatal - binding can still fall back to session name.
 	}
 
@@ -152,6 +320,27 @@ func CreateSession(name, command string) error {
 	return nil
 }
 
+// ensureServer starts the pocketbot tmux server if it is not running.
+func ensureServer() error {
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	return runCmd("start-server")
+}
+
+// isServerStartupErr reports whether err looks like the server was not up
+// (or went away) while handling a command, which is worth one retry.
+func isServerStartupErr(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "no server running") ||
+		strings.Contains(msg, "server exited unexpectedly") ||
+		strings.Contains(msg, "lost server") ||
+		strings.Contains(msg, "error connecting to")
+}
+
 // AttachSession attaches to an existing tmux session
 // This takes over stdin/stdout until the user detaches
 func AttachSession(name string) error {
@@ -162,18 +351,249 @@ func AttachSession(name string) error {
 	return c.Run()
 }
 
-// KillSession terminates a tmux session
-func KillSession(name string) error {
-	return cmd("kill-session", "-t", sessionTarget(name)).Run()
+// AttachSessionTarget attaches to an explicit tmux target such as
+// "name:window.pane". tmux selects the targeted window and pane before the
+// client takes over, so this jumps straight to a specific pane.
+func AttachSessionTarget(target string) error {
+	c := cmd("attach-session", "-t", target)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c.Run()
+}
+
+// NewWindow opens a new window running command in an existing session. The
+// window starts in the session's launch directory and gets the same
+// PB_LEVEL/PB_CWD environment as the session's first window.
+func NewWindow(sessionName, command string) error {
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	cwd := GetSessionCwd(sessionName)
+	if cwd == "" {
+		cwd, _ = os.Getwd()
+	}
+	envCmd := fmt.Sprintf("export PB_LEVEL=%d; export PB_CWD='%s'; %s", getNestingLevel()+1, cwd, command)
+	return runCmd("new-window", "-t", sessionTarget(sessionName), "-c", cwd, "sh", "-c", envCmd)
+}
+
+// Pane identifies a pane within one of a session's windows.
+type Pane struct {
+	WindowIndex int
+	PaneIndex   int
+	Active      bool
+	Command     string
+}
+
+// Index returns the pane's "window.pane" index string.
+func (p Pane) Index() string {
+	return fmt.Sprintf("%d.%d", p.WindowIndex, p.PaneIndex)
+}
+
+// ListPanes returns every pane across all windows of a session.
+func ListPanes(sessionName string) ([]Pane, error) {
+	out, err := cmd("list-panes", "-s", "-t", sessionTarget(sessionName), "-F",
+		"#{window_index} #{pane_index} #{pane_active} #{pane_current_command}").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parsePanes(string(out))
+}
+
+func parsePanes(raw string) ([]Pane, error) {
+	var panes []Pane
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSpace(line), " ", 4)
+		if len(parts) < 3 {
+			return nil, fmt.Errorf("unexpected list-panes row format: %q", line)
+		}
+		window, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("parse window index from %q: %w", line, err)
+		}
+		pane, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("parse pane index from %q: %w", line, err)
+		}
+		p := Pane{
+			WindowIndex: window,
+			PaneIndex:   pane,
+			Active:      strings.TrimSpace(parts[2]) == "1",
+		}
+		if len(parts) == 4 {
+			p.Command = strings.TrimSpace(parts[3])
+		}
+		panes = append(panes, p)
+	}
+	return panes, nil
+}
+
+// PaneTarget builds a tmux target addressing one pane of a session.
+func PaneTarget(sessionName string, window, pane int) string {
+	return fmt.Sprintf("%s:%d.%d", sessionTarget(sessionName), window, pane)
+}
+
+// KillOptions adjusts how KillSession stops a session.
+type KillOptions struct {
+	// PreKillKeys are tmux key names (e.g. "C-c" or "q Enter") sent to the
+	// session before it is killed so the tool can shut down gracefully.
+	PreKillKeys string
 }
 
-// RenameSession renames a tmux session.
+// preKillDelay is how long KillSession waits after sending PreKillKeys.
+var preKillDelay = time.Second
+
+// KillSession terminates a tmux session. If opts carries PreKillKeys they
+// are sent first, followed by a short wait; the kill proceeds even if the
+// keys cannot be sent.
+func KillSession(name string, opts ...KillOptions) error {
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	// Invalidate once tmux is done, so a listing taken while the pre-kill
+	// keys are handled does not keep the session cached.
+	defer invalidateListSessions()
+	for _, opt := range opts {
+		if opt.PreKillKeys == "" {
+			continue
+		}
+		if err := sendKeyNames(name, opt.PreKillKeys); err == nil {
+			time.Sleep(preKillDelay)
+		}
+	}
+	if err := cmd("kill-session", "-t", sessionTarget(name)).Run(); err != nil {
+		return err
+	}
+	if !supportsCustomOptions {
+		_ = renameFileSessionOptions(name, "")
+	}
+	return nil
+}
+
+// SessionClients returns the names of clients attached to a session.
+func SessionClients(sessionName string) ([]string, error) {
+	out, err := cmd("list-clients", "-t", sessionTarget(sessionName), "-F", "#{client_name}").Output()
+	if err != nil {
+		return nil, err
+	}
+	var clients []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if name := strings.TrimSpace(line); name != "" {
+			clients = append(clients, name)
+		}
+	}
+	return clients, nil
+}
+
+// RenameSession renames a tmux session. It refuses with ErrSessionAttached
+// while any client is attached, since the client may lose its session
+// reference; use RenameSessionForce to bypass the check.
 func RenameSession(oldName, newName string) error {
-	return cmd("rename-session", "-t", sessionTarget(oldName), newName).Run()
+	// A listing error (e.g. missing session) falls through so rename-session
+	// reports the real failure.
+	if clients, err := sessionClientsFn(oldName); err == nil && len(clients) > 0 {
+		return fmt.Errorf("%s: %w", oldName, ErrSessionAttached)
+	}
+	return RenameSessionForce(oldName, newName)
+}
+
+// RenameSessionForce renames a tmux session without checking for attached
+// clients.
+func RenameSessionForce(oldName, newName string) error {
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	defer invalidateListSessions()
+	if err := cmd("rename-session", "-t", sessionTarget(oldName), newName).Run(); err != nil {
+		return err
+	}
+	if !supportsCustomOptions {
+		_ = renameFileSessionOptions(oldName, newName)
+	}
+	return nil
+}
+
+// DetachAllClients detaches every client attached to a session, sending each
+// back to whatever launched the attach (normally the pb home screen).
+func DetachAllClients(sessionName string) error {
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	return runCmd("detach-client", "-s", sessionTarget(sessionName))
+}
+
+// DetachAllSessionClients detaches all clients from every pocketbot session.
+// It keeps going past failures and returns the first error.
+func DetachAllSessionClients() error {
+	var firstErr error
+	for _, name := range ListSessions() {
+		if err := DetachAllClients(name); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// SendKeys types keys into a session's active pane as literal text, then
+// presses Enter.
+func SendKeys(sessionName, keys string) error {
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	target := sessionTarget(sessionName)
+	if keys != "" {
+		if err := runCmd("send-keys", "-t", target, "-l", keys); err != nil {
+			return err
+		}
+	}
+	return runCmd("send-keys", "-t", target, "Enter")
+}
+
+// sendKeyNames sends space-separated tmux key names (not literal text) to a
+// session's active pane.
+func sendKeyNames(sessionName, keys string) error {
+	names := strings.Fields(keys)
+	if len(names) == 0 {
+		return nil
+	}
+	return runCmd(append([]string{"send-keys", "-t", sessionTarget(sessionName)}, names...)...)
+}
+
+// BroadcastKeys sends keys to every named session concurrently via SendKeys.
+// It returns one error per session that failed, in sessionNames order, or
+// nil if all succeeded.
+func BroadcastKeys(sessionNames []string, keys string) []error {
+	results := make([]error, len(sessionNames))
+	var wg sync.WaitGroup
+	for i, name := range sessionNames {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := SendKeys(name, keys); err != nil {
+				results[i] = fmt.Errorf("%s: %w", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, err := range results {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
 }
 
 // KillServer kills the entire pocketbot tmux server
 func KillServer() error {
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	defer invalidateListSessions()
 	return cmd("kill-server").Run()
 }
 
@@ -188,34 +608,167 @@ func CapturePane(sessionName string) (string, error) {
 
 // GetSessionCwd returns the working directory where a session was launched
 func GetSessionCwd(sessionName string) string {
-	out, err := cmd("show-options", "-t", sessionTarget(sessionName), "-v", "@pb_cwd").Output()
-	if err != nil {
-		return ""
-	}
-	return strings.TrimSpace(string(out))
+	return GetSessionOption(sessionName, "@pb_cwd")
 }
 
 // GetSessionCommand returns the configured command binding for a session.
 func GetSessionCommand(sessionName string) string {
-	out, err := cmd("show-options", "-t", sessionTarget(sessionName), "-v", "@pb_command").Output()
+	return GetSessionOption(sessionName, "@pb_command")
+}
+
+// SetSessionOption sets a user option (e.g. "@pb_memo") on a session. An
+// empty value unsets the option. On tmux < 3.0 the option is written to the
+// fallback file store instead.
+func SetSessionOption(sessionName, option, value string) error {
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	if !supportsCustomOptions {
+		return setFileSessionOption(sessionName, option, value)
+	}
+	if value == "" {
+		return cmd("set-option", "-u", "-t", sessionTarget(sessionName), option).Run()
+	}
+	return cmd("set-option", "-t", sessionTarget(sessionName), option, value).Run()
+}
+
+// SetSessionOptionIfChanged is like SetSessionOption but reads the option
+// first and skips the write when it already holds value. Use SetSessionOption
+// when the write must happen regardless.
+func SetSessionOptionIfChanged(sessionName, option, value string) error {
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	if GetSessionOption(sessionName, option) == value {
+		return nil
+	}
+	return SetSessionOption(sessionName, option, value)
+}
+
+// GetSessionOption returns a user option on a session, or "" if unset.
+func GetSessionOption(sessionName, option string) string {
+	if !supportsCustomOptions {
+		return getFileSessionOption(sessionName, option)
+	}
+	out, err := cmd("show-options", "-t", sessionTarget(sessionName), "-v", option).Output()
 	if err != nil {
 		return ""
 	}
 	return strings.TrimSpace(string(out))
 }
 
+// GetAllSessionPBOptions returns every @pb_* option set on a session from a
+// single show-options call, keyed by option name. Unset options are absent.
+func GetAllSessionPBOptions(sessionName string) (map[string]string, error) {
+	if !supportsCustomOptions {
+		return getFileSessionOptions(sessionName), nil
+	}
+	out, err := cmd("show-options", "-q", "-t", sessionTarget(sessionName)).Output()
+	if err != nil {
+		return nil, err
+	}
+	return parsePBOptions(string(out)), nil
+}
+
+// parsePBOptions reads show-options output ("name value" per line) and keeps
+// the @pb_* options. tmux quotes values with spaces or special characters;
+// those quotes are removed.
+func parsePBOptions(out string) map[string]string {
+	opts := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		name, value, _ := strings.Cut(strings.TrimRight(line, "\r"), " ")
+		if !strings.HasPrefix(name, "@pb_") {
+			continue
+		}
+		opts[name] = unquoteOptionValue(value)
+	}
+	return opts
+}
+
+// unquoteOptionValue undoes tmux's quoting of an option value: "..." with
+// backslash escapes, or '...' taken literally.
+func unquoteOptionValue(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+	switch first, last := value[0], value[len(value)-1]; {
+	case first == '"' && last == '"':
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+		return value[1 : len(value)-1]
+	case first == '\'' && last == '\'':
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// SetServerOption sets a pocketbot-wide user option (e.g. "@pb_global_yolo")
+// on the server rather than on a session. An empty value unsets it.
+func SetServerOption(key, value string) error {
+	if !validServerOptionKey(key) {
+		return fmt.Errorf("invalid server option %q: want @pb_<name>", key)
+	}
+	if err := guardWrite(); err != nil {
+		return err
+	}
+	if value == "" {
+		return cmd("set-option", "-s", "-u", key).Run()
+	}
+	return cmd("set-option", "-s", key, value).Run()
+}
+
+// GetServerOption returns a pocketbot-wide user option from the server.
+func GetServerOption(key string) (string, error) {
+	if !validServerOptionKey(key) {
+		return "", fmt.Errorf("invalid server option %q: want @pb_<name>", key)
+	}
+	out, err := cmd("show-options", "-s", "-v", key).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// validServerOptionKey accepts "@pb_" followed by lowercase letters, digits
+// and underscores, keeping server options inside pocketbot's namespace.
+func validServerOptionKey(key string) bool {
+	name, ok := strings.CutPrefix(key, "@pb_")
+	if !ok || name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// SetGlobalYolo records whether new sessions default to yolo mode as a
+// server option, so the choice survives pb restarts while the server runs.
+func SetGlobalYolo(enabled bool) error {
+	val := "0"
+	if enabled {
+		val = "1"
+	}
+	return SetServerOption("@pb_global_yolo", val)
+}
+
+// GetGlobalYolo reports whether new sessions default to yolo mode.
+func GetGlobalYolo() bool {
+	v, err := GetServerOption("@pb_global_yolo")
+	return err == nil && v == "1"
+}
+
 // SetSessionTool persists the logical built-in tool for a session.
 func SetSessionTool(sessionName, tool string) error {
-	return cmd("set-option", "-t", sessionTarget(sessionName), "@pb_tool", tool).Run()
+	return SetSessionOption(sessionName, "@pb_tool", tool)
 }
 
 // GetSessionTool returns the logical built-in tool for a session.
 func GetSessionTool(sessionName string) string {
-	out, err := cmd("show-options", "-t", sessionTarget(sessionName), "-v", "@pb_tool").Output()
-	if err != nil {
-		return ""
-	}
-	return strings.TrimSpace(string(out))
+	return GetSessionOption(sessionName, "@pb_tool")
 }
 
 // SetSessionYolo marks whether a session was launched in yolo mode.
@@ -224,21 +777,125 @@ func SetSessionYolo(sessionName string, enabled bool) error {
 	if enabled {
 		val = "1"
 	}
-	return cmd("set-option", "-t", sessionTarget(sessionName), "@pb_yolo", val).Run()
+	return SetSessionOption(sessionName, "@pb_yolo", val)
 }
 
 // GetSessionYolo reports whether a session was launched in yolo mode.
 func GetSessionYolo(sessionName string) bool {
-	out, err := cmd("show-options", "-t", sessionTarget(sessionName), "-v", "@pb_yolo").Output()
+	return ParseYolo(GetSessionOption(sessionName, "@pb_yolo"))
+}
+
+// ParseYolo reads an @pb_yolo value as stored by SetSessionYolo.
+func ParseYolo(value string) bool {
+	v := strings.ToLower(value)
+	return v == "1" || v == "on" || v == "true" || v == "yes"
+}
+
+// PaneHash returns a short stable hash of captured pane content.
+func PaneHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:8])
+}
+
+// SetSessionSeenHash records the pane hash the user last saw for a session.
+func SetSessionSeenHash(sessionName, hash string) error {
+	return SetSessionOption(sessionName, "@pb_seen_hash", hash)
+}
+
+// GetSessionSeenHash returns the pane hash recorded when the user last
+// detached from a session, or "" if none was recorded.
+func GetSessionSeenHash(sessionName string) string {
+	return GetSessionOption(sessionName, "@pb_seen_hash")
+}
+
+// ServerInfo describes the pocketbot tmux server for diagnostics.
+type ServerInfo struct {
+	Version      string
+	SocketPath   string
+	SessionCount int
+	ClientCount  int
+}
+
+// String formats the server info for display.
+func (i ServerInfo) String() string {
+	return fmt.Sprintf("tmux %s socket=%s sessions=%d clients=%d", i.Version, i.SocketPath, i.SessionCount, i.ClientCount)
+}
+
+// GetServerInfo returns metadata about the running pocketbot tmux server. It
+// returns an error when the server is not running.
+func GetServerInfo() (ServerInfo, error) {
+	out, err := cmd("display-message", "-p", "#{version} #{socket_path}").CombinedOutput()
 	if err != nil {
-		return false
+		msg := strings.TrimSpace(string(out))
+		if msg == "" {
+			return ServerInfo{}, fmt.Errorf("tmux display-message: %w", err)
+		}
+		return ServerInfo{}, fmt.Errorf("tmux display-message: %w: %s", err, msg)
 	}
-	v := strings.TrimSpace(strings.ToLower(string(out)))
-	return v == "1" || v == "on" || v == "true" || v == "yes"
+	sessions, _ := cmd("list-sessions", "-F", "#{session_id}").Output()
+	clients, _ := cmd("list-clients", "-F", "#{client_name}").Output()
+	return parseServerInfo(string(out), string(sessions), string(clients))
+}
+
+func parseServerInfo(display, sessions, clients string) (ServerInfo, error) {
+	parts := strings.SplitN(strings.TrimSpace(display), " ", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return ServerInfo{}, fmt.Errorf("unexpected server info format: %q", display)
+	}
+	return ServerInfo{
+		Version:      parts[0],
+		SocketPath:   strings.TrimSpace(parts[1]),
+		SessionCount: countLines(sessions),
+		ClientCount:  countLines(clients),
+	}, nil
+}
+
+func countLines(raw string) int {
+	n := 0
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.TrimSpace(line) != "" {
+			n++
+		}
+	}
+	return n
 }
 
 // ListSessions returns all active session names
 func ListSessions() []string {
+	listSessionsMu.Lock()
+	defer listSessionsMu.Unlock()
+
+	key := strings.Join(socketArgs(), " ")
+	now := cacheNow()
+	if key == listSessionsCacheKey && now.Before(listSessionsCacheExpiry) {
+		return slices.Clone(listSessionsCache)
+	}
+	listSessionsCache = listSessionsUncached()
+	listSessionsCacheKey = key
+	listSessionsCacheExpiry = now.Add(listSessionsTTL)
+	return slices.Clone(listSessionsCache)
+}
+
+// listSessionsTTL is how long ListSessions reuses its last answer. The home
+// screen lists sessions on every keystroke; pb's own create, kill and rename
+// drop the cache at once, so only outside changes can be this stale.
+const listSessionsTTL = 200 * time.Millisecond
+
+var (
+	listSessionsMu          sync.Mutex
+	listSessionsCache       []string
+	listSessionsCacheKey    string // socket flags the cache was filled from
+	listSessionsCacheExpiry time.Time
+	cacheNow                = time.Now
+)
+
+func invalidateListSessions() {
+	listSessionsMu.Lock()
+	defer listSessionsMu.Unlock()
+	listSessionsCacheExpiry = time.Time{}
+}
+
+func listSessionsUncached() []string {
 	out, err := cmd("list-sessions", "-F", "#{session_name}").Output()
 	if err != nil {
 		return nil
@@ -250,6 +907,123 @@ func ListSessions() []string {
 	return lines
 }
 
+// SessionInfo is a summary row for one session from list-sessions.
+type SessionInfo struct {
+	Name         string
+	Created      time.Time
+	LastActivity time.Time
+	LastAttached time.Time // zero if never attached
+	Attached     int       // number of attached clients
+}
+
+// ListSessionsDetailed returns every session with its creation time, last
+// activity, last attach time and attached client count.
+func ListSessionsDetailed() ([]SessionInfo, error) {
+	out, err := cmd("list-sessions", "-F", "#{session_created} #{session_activity} #{session_attached} #{session_last_attached} #{session_name}").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parseSessionInfos(string(out))
+}
+
+// ListSessionsCreatedAfter returns the sessions created strictly after
+// since, in list-sessions order.
+func ListSessionsCreatedAfter(since time.Time) ([]string, error) {
+	infos, err := ListSessionsDetailed()
+	if err != nil {
+		return nil, err
+	}
+	return sessionsCreatedAfter(infos, since), nil
+}
+
+// sessionsCreatedAfter keeps the names of sessions created after since.
+func sessionsCreatedAfter(infos []SessionInfo, since time.Time) []string {
+	var names []string
+	for _, info := range infos {
+		if info.Created.After(since) {
+			names = append(names, info.Name)
+		}
+	}
+	return names
+}
+
+func parseSessionInfos(raw string) ([]SessionInfo, error) {
+	var infos []SessionInfo
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		// The name goes last since it may contain spaces.
+		parts := strings.SplitN(line, " ", 5)
+		if len(parts) != 5 {
+			return nil, fmt.Errorf("unexpected list-sessions row format: %q", line)
+		}
+		created, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse session_created from %q: %w", line, err)
+		}
+		activity, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse session_activity from %q: %w", line, err)
+		}
+		attached, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("parse session_attached from %q: %w", line, err)
+		}
+		// session_last_attached is empty (or 0) until a client attaches.
+		var lastAttached time.Time
+		if parts[3] != "" && parts[3] != "0" {
+			unix, err := strconv.ParseInt(parts[3], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse session_last_attached from %q: %w", line, err)
+			}
+			lastAttached = time.Unix(unix, 0)
+		}
+		infos = append(infos, SessionInfo{
+			Name:         parts[4],
+			Created:      time.Unix(created, 0),
+			LastActivity: time.Unix(activity, 0),
+			LastAttached: lastAttached,
+			Attached:     attached,
+		})
+	}
+	return infos, nil
+}
+
+// PaneActivity returns each session's latest #{window_activity} from a
+// single list-windows call. tmux bumps window activity on pane output, while
+// session_activity only moves on client input, so this is the stamp that
+// says whether a pane may have changed.
+func PaneActivity() (map[string]time.Time, error) {
+	out, err := cmd("list-windows", "-a", "-F", "#{window_activity} #{session_name}").Output()
+	if err != nil {
+		return nil, err
+	}
+	return parsePaneActivity(string(out))
+}
+
+func parsePaneActivity(raw string) (map[string]time.Time, error) {
+	stamps := make(map[string]time.Time)
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		// The name goes last since it may contain spaces.
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("unexpected list-windows row format: %q", line)
+		}
+		unix, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse window_activity from %q: %w", line, err)
+		}
+		if stamp := time.Unix(unix, 0); stamp.After(stamps[parts[1]]) {
+			stamps[parts[1]] = stamp
+		}
+	}
+	return stamps, nil
+}
+
 // Session represents a tmux-backed session
 type Session struct {
 	name         string
@@ -259,14 +1033,65 @@ type Session struct {
 	lastActivity time.Time
 	nextPollAt   time.Time
 	pendingSince time.Time
+	// capturedAt and seenActivity record when the last capture ran and the
+	// tmux activity stamp it was taken against, for UpdateActivityFrom.
+	capturedAt   time.Time
+	seenActivity time.Time
+	// idleTimeout overrides IdleTimeout for this session when non-zero.
+	idleTimeout time.Duration
+}
+
+// SessionOption customizes a Session built by NewSessionWithOptions.
+type SessionOption func(*Session)
+
+// WithIdleTimeout marks the session idle after d without pane changes
+// instead of IdleTimeout. Zero keeps IdleTimeout.
+func WithIdleTimeout(d time.Duration) SessionOption {
+	return func(s *Session) {
+		s.idleTimeout = d
+	}
 }
 
 // NewSession creates a new tmux session wrapper
 func NewSession(name, command string) *Session {
-	return &Session{
+	return NewSessionWithOptions(name, command)
+}
+
+// NewSessionWithOptions is NewSession with options applied.
+func NewSessionWithOptions(name, command string, opts ...SessionOption) *Session {
+	s := &Session{
 		name:    name,
 		command: command,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Apply applies opts to an existing session, e.g. a new idle timeout
+// after the config reloads.
+func (s *Session) Apply(opts ...SessionOption) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, opt := range opts {
+		opt(s)
+	}
+}
+
+// idleAfter returns the session's idle timeout, falling back to
+// IdleTimeout.
+func (s *Session) idleAfter() time.Duration {
+	if s.idleTimeout > 0 {
+		return s.idleTimeout
+	}
+	return IdleTimeout
+}
+
+// Command returns the command the session was launched with, or "" for
+// sessions discovered on the server.
+func (s *Session) Command() string {
+	return s.command
 }
 
 // IsRunning returns whether the tmux session exists
@@ -304,8 +1129,14 @@ func (s *Session) Attach() error {
 
 // capturePane captures the current pane content (last 10 lines only for efficiency)
 func (s *Session) capturePane() (string, error) {
+	return CaptureRecent(s.name)
+}
+
+// CaptureRecent captures a pane the way activity tracking does: the visible
+// screen plus the last 10 lines of scrollback.
+func CaptureRecent(sessionName string) (string, error) {
 	// Only capture last 10 lines to reduce overhead
-	out, err := cmd("capture-pane", "-t", sessionTarget(s.name), "-p", "-S", "-10").Output()
+	out, err := cmd("capture-pane", "-t", sessionTarget(sessionName), "-p", "-S", "-10").Output()
 	if err != nil {
 		return "", err
 	}
@@ -319,11 +1150,50 @@ func (s *Session) UpdateActivity() bool {
 	defer s.mu.Unlock()
 
 	if !SessionExists(s.name) {
+		// Drop the baseline so ActivityKnown stops classifying a dead session.
+		s.lastCapture = ""
+		return false
+	}
+	return s.pollActivity(time.Time{})
+}
+
+// UpdateActivityFrom is UpdateActivity for callers that fetched
+// PaneActivity once for every session. A session missing from stamps is
+// treated as gone, and capture-pane is skipped while its stamp shows no
+// output since the last capture.
+func (s *Session) UpdateActivityFrom(stamps map[string]time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stamp, ok := stamps[s.name]
+	if !ok {
+		s.lastCapture = ""
+		return false
+	}
+	return s.pollActivity(stamp)
+}
+
+// paneUnchanged reports whether capturing now would repeat the last
+// capture: there is a baseline, no change awaits confirmation, and the
+// activity stamp has not moved since a capture taken after that stamp's
+// second ended (tmux stamps are whole seconds). A zero stamp never skips.
+func (s *Session) paneUnchanged(stamp time.Time) bool {
+	if stamp.IsZero() || s.lastCapture == "" || !s.pendingSince.IsZero() {
 		return false
 	}
+	return !stamp.After(s.seenActivity) && !s.capturedAt.Before(stamp.Add(time.Second))
+}
+
+// pollActivity runs one activity poll; the caller holds s.mu and has
+// confirmed the session exists.
+func (s *Session) pollActivity(stamp time.Time) bool {
 	now := time.Now()
 	if !s.nextPollAt.IsZero() && now.Before(s.nextPollAt) {
-		return now.Sub(s.lastActivity) < IdleTimeout
+		return now.Sub(s.lastActivity) < s.idleAfter()
+	}
+	if s.paneUnchanged(stamp) {
+		s.nextPollAt = now.Add(nextActivityPollInterval(now.Sub(s.lastActivity)))
+		return now.Sub(s.lastActivity) < s.idleAfter()
 	}
 
 	// Capture current pane content
@@ -332,15 +1202,17 @@ func (s *Session) UpdateActivity() bool {
 	if err != nil {
 		// On error, assume no change but don't crash
 		s.nextPollAt = now.Add(3 * time.Second)
-		return now.Sub(s.lastActivity) < IdleTimeout
+		return now.Sub(s.lastActivity) < s.idleAfter()
 	}
+	s.capturedAt = now
+	s.seenActivity = stamp
 
 	// Baseline capture avoids treating initial pane snapshot as activity.
 	if s.lastCapture == "" {
 		s.lastCapture = current
 		s.pendingSince = time.Time{}
 		s.nextPollAt = now.Add(activePollInterval)
-		return now.Sub(s.lastActivity) < IdleTimeout
+		return now.Sub(s.lastActivity) < s.idleAfter()
 	}
 
 	// Check if content changed.
@@ -348,7 +1220,7 @@ func (s *Session) UpdateActivity() bool {
 		if s.pendingSince.IsZero() {
 			s.pendingSince = now
 			s.nextPollAt = now.Add(pendingActivityPollDelay)
-			return now.Sub(s.lastActivity) < IdleTimeout
+			return now.Sub(s.lastActivity) < s.idleAfter()
 		}
 		if now.Sub(s.pendingSince) >= activityConfirmWindow {
 			s.lastCapture = current
@@ -358,14 +1230,24 @@ func (s *Session) UpdateActivity() bool {
 			return true
 		}
 		s.nextPollAt = now.Add(pendingActivityPollDelay)
-		return now.Sub(s.lastActivity) < IdleTimeout
+		return now.Sub(s.lastActivity) < s.idleAfter()
 	}
 
 	s.pendingSince = time.Time{}
 	s.nextPollAt = now.Add(nextActivityPollInterval(now.Sub(s.lastActivity)))
 
 	// Content hasn't changed - check if idle timeout exceeded
-	return now.Sub(s.lastActivity) < IdleTimeout
+	return now.Sub(s.lastActivity) < s.idleAfter()
+}
+
+// RecentlyActive reports whether the last UpdateActivity saw recent
+// activity. Unlike IsActive it does not query tmux, so it is safe to call
+// while rendering.
+func (s *Session) RecentlyActive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return time.Since(s.lastActivity) < s.idleAfter()
 }
 
 // IsActive returns whether the session is currently active (has recent activity)
@@ -377,18 +1259,67 @@ func (s *Session) IsActive() bool {
 		return false
 	}
 
-	return time.Since(s.lastActivity) < IdleTimeout
+	return time.Since(s.lastActivity) < s.idleAfter()
+}
+
+// LastCapture returns the most recent confirmed pane snapshot used for
+// activity tracking. It is empty until the first successful capture.
+func (s *Session) LastCapture() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lastCapture
+}
+
+// ResetBaseline captures the pane now and makes it the confirmed snapshot,
+// dropping any pending change, so LastCapture matches what the user just
+// saw. It returns the capture.
+func (s *Session) ResetBaseline() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, err := s.capturePane()
+	if err != nil {
+		return "", err
+	}
+	s.lastCapture = current
+	s.pendingSince = time.Time{}
+	return current, nil
+}
+
+// MatchWarning returns the first pattern that appears in text, compared
+// case-insensitively, or "" when none match.
+func MatchWarning(text string, patterns []string) string {
+	lower := strings.ToLower(text)
+	for _, pattern := range patterns {
+		p := strings.TrimSpace(pattern)
+		if p == "" {
+			continue
+		}
+		if strings.Contains(lower, strings.ToLower(p)) {
+			return p
+		}
+	}
+	return ""
+}
+
+// IdleFor returns how long the session has gone without confirmed pane
+// changes. Sessions never seen active report the time since the zero time.
+func (s *Session) IdleFor() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return time.Since(s.lastActivity)
 }
 
 // ActivityKnown reports whether we've captured enough pane data to classify
-// activity for this running session.
+// activity for this running session. It reads cached state only, so views
+// can call it without querying tmux; UpdateActivity clears the baseline once
+// the session is gone.
 func (s *Session) ActivityKnown() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !SessionExists(s.name) {
-		return false
-	}
 	return s.lastCapture != ""
 }
 
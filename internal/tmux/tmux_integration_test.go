@@ -3,7 +3,9 @@ package tmux
 import (
 	"fmt"
 	"os"
+	"os/exec"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -115,3 +117,152 @@ func TestIntegrationBurstTransitionsResponsive(t *testing.T) {
 	}
 	t.Logf("idle latency from burst end: %v", idleLatencyFromBurstEnd)
 }
+
+func TestIntegrationPaneTargetSelectsSplitPane(t *testing.T) {
+	requireIntegrationEnv(t)
+	useIsolatedSocket(t)
+	defer KillServer()
+
+	name := fmt.Sprintf("itest-pane-%d", time.Now().UnixNano())
+	if err := CreateSession(name, "sleep 20"); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := runCmd("split-window", "-d", "-t", sessionTarget(name), "sleep 20"); err != nil {
+		t.Fatalf("split-window: %v", err)
+	}
+
+	panes, err := ListPanes(name)
+	if err != nil {
+		t.Fatalf("ListPanes: %v", err)
+	}
+	if len(panes) != 2 {
+		t.Fatalf("expected 2 panes after split, got %d", len(panes))
+	}
+
+	target := PaneTarget(name, panes[1].WindowIndex, panes[1].PaneIndex)
+	// Attaching needs a terminal, so resolve the same target tmux would
+	// select on attach and confirm it lands on pane 1.
+	out, err := cmd("display-message", "-p", "-t", target, "#{pane_index}").Output()
+	if err != nil {
+		t.Fatalf("display-message: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != strconv.Itoa(panes[1].PaneIndex) {
+		t.Fatalf("target %s resolved to pane %s, want %d", target, got, panes[1].PaneIndex)
+	}
+}
+
+func TestIntegrationDetachAllClientsLeavesNoClients(t *testing.T) {
+	requireIntegrationEnv(t)
+	useIsolatedSocket(t)
+	defer KillServer()
+
+	name := fmt.Sprintf("itest-detach-%d", time.Now().UnixNano())
+	if err := CreateSession(name, "sleep 20"); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	// Attach a real client by running `tmux attach` inside a session on a
+	// separate outer server, which provides the terminal attach needs.
+	outer := getSocketName() + "-outer"
+	attach := fmt.Sprintf("tmux -L %s attach-session -t %s", getSocketName(), name)
+	outerCmd := exec.Command("tmux", "-L", outer, "new-session", "-d", "-x", "80", "-y", "24", attach)
+	outerCmd.Env = withoutEnv(os.Environ(), "TMUX")
+	if err := outerCmd.Run(); err != nil {
+		t.Fatalf("start outer client: %v", err)
+	}
+	defer exec.Command("tmux", "-L", outer, "kill-server").Run()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		clients, _ := SessionClients(name)
+		if len(clients) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("client never attached")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if err := DetachAllClients(name); err != nil {
+		t.Fatalf("DetachAllClients: %v", err)
+	}
+	out, err := cmd("list-clients", "-t", sessionTarget(name)).Output()
+	if err != nil {
+		t.Fatalf("list-clients: %v", err)
+	}
+	if strings.TrimSpace(string(out)) != "" {
+		t.Fatalf("expected no clients after detach, got %q", out)
+	}
+}
+
+func TestIntegrationFirstSessionOnFreshSocketSucceeds(t *testing.T) {
+	requireIntegrationEnv(t)
+
+	for i := 0; i < 5; i++ {
+		t.Setenv("PB_LEVEL", fmt.Sprintf("%d%d", time.Now().UnixNano()%1_000_000, i))
+		name := fmt.Sprintf("itest-fresh-%d", i)
+		if err := CreateSession(name, "sleep 20"); err != nil {
+			KillServer()
+			t.Fatalf("CreateSession on fresh socket (attempt %d): %v", i, err)
+		}
+		if !SessionExists(name) {
+			KillServer()
+			t.Fatalf("session %s missing after create on fresh socket", name)
+		}
+		KillServer()
+	}
+}
+
+func TestIntegrationLastAttachedRoundTrip(t *testing.T) {
+	requireIntegrationEnv(t)
+	useIsolatedSocket(t)
+	t.Setenv("HOME", t.TempDir())
+	defer KillServer()
+
+	name := fmt.Sprintf("itest-last-%d", time.Now().UnixNano())
+	if err := CreateSession(name, "sleep 20"); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if got := GetLastAttached(); got != "" {
+		t.Fatalf("expected no last attached session, got %q", got)
+	}
+	if err := SetLastAttached(name); err != nil {
+		t.Fatalf("SetLastAttached: %v", err)
+	}
+	if got := GetLastAttached(); got != name {
+		t.Fatalf("GetLastAttached = %q, want %q", got, name)
+	}
+}
+
+func TestIntegrationIsSessionAliveDetectsDeadPane(t *testing.T) {
+	requireIntegrationEnv(t)
+	useIsolatedSocket(t)
+	defer KillServer()
+
+	name := fmt.Sprintf("itest-dead-%d", time.Now().UnixNano())
+	if err := CreateSession(name, "sleep 20"); err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if !IsSessionAlive(name) {
+		t.Fatal("expected a freshly started session to be alive")
+	}
+
+	// Keep the pane after its process exits, then let it exit.
+	if err := runCmd("set-option", "-w", "-t", sessionTarget(name), "remain-on-exit", "on"); err != nil {
+		t.Fatalf("set remain-on-exit: %v", err)
+	}
+	if err := runCmd("respawn-pane", "-k", "-t", sessionTarget(name), "true"); err != nil {
+		t.Fatalf("respawn-pane: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for IsSessionAlive(name) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the session to be dead after its pane exited")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !SessionExists(name) {
+		t.Fatal("expected remain-on-exit to keep the dead session")
+	}
+}
@@ -1,7 +1,12 @@
 package tmux
 
 import (
+	"errors"
+	"os/exec"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -62,3 +67,1062 @@ func TestNextActivityPollInterval(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchWarning(t *testing.T) {
+	patterns := []string{"rate limit", "quota exceeded", "context window"}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "no warning",
+			text: "> fix the tests\nRunning go test ./...\nok",
+			want: "",
+		},
+		{
+			name: "rate limit is case-insensitive",
+			text: "Error: Rate Limit reached for requests\nretrying in 30s",
+			want: "rate limit",
+		},
+		{
+			name: "quota exceeded",
+			text: "You have QUOTA EXCEEDED for this billing period",
+			want: "quota exceeded",
+		},
+		{
+			name: "first configured pattern wins",
+			text: "context window almost full; rate limit soon",
+			want: "rate limit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchWarning(tt.text, patterns); got != tt.want {
+				t.Fatalf("MatchWarning()=%q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchWarningIgnoresBlankPatterns(t *testing.T) {
+	if got := MatchWarning("anything at all", []string{"", "  "}); got != "" {
+		t.Fatalf("expected blank patterns to never match, got %q", got)
+	}
+}
+
+func TestParsePanes(t *testing.T) {
+	raw := "0 0 0 claude\n0 1 1 tail -f app.log\n1 0 0\n"
+	got, err := parsePanes(raw)
+	if err != nil {
+		t.Fatalf("parsePanes returned error: %v", err)
+	}
+	want := []Pane{
+		{WindowIndex: 0, PaneIndex: 0, Command: "claude"},
+		{WindowIndex: 0, PaneIndex: 1, Active: true, Command: "tail -f app.log"},
+		{WindowIndex: 1, PaneIndex: 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parsePanes mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+	if got[1].Index() != "0.1" {
+		t.Fatalf("expected index 0.1, got %q", got[1].Index())
+	}
+}
+
+func TestParsePaneDead(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want bool
+	}{
+		{raw: "0\n", want: true},
+		{raw: "1\n0\n", want: true},
+		{raw: "1\n1\n", want: false},
+		{raw: "", want: false},
+	}
+	for _, tt := range tests {
+		if got := parsePaneDead(tt.raw); got != tt.want {
+			t.Errorf("parsePaneDead(%q) = %v, want %v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParsePanesRejectsMalformedRow(t *testing.T) {
+	if _, err := parsePanes("not-a-pane\n"); err == nil {
+		t.Fatal("expected malformed row to return an error")
+	}
+}
+
+func TestRenameSessionRefusesWhenClientAttached(t *testing.T) {
+	original := sessionClientsFn
+	defer func() { sessionClientsFn = original }()
+
+	var asked string
+	sessionClientsFn = func(sessionName string) ([]string, error) {
+		asked = sessionName
+		return []string{"/dev/ttys004"}, nil
+	}
+
+	err := RenameSession("claude", "focus")
+	if !errors.Is(err, ErrSessionAttached) {
+		t.Fatalf("expected ErrSessionAttached, got %v", err)
+	}
+	if asked != "claude" {
+		t.Fatalf("expected clients lookup for claude, got %q", asked)
+	}
+}
+
+func TestPaneHashStable(t *testing.T) {
+	a := PaneHash("line one\nline two\n")
+	if a != PaneHash("line one\nline two\n") {
+		t.Fatal("expected identical content to hash identically")
+	}
+	if a == PaneHash("line one\nline three\n") {
+		t.Fatal("expected different content to hash differently")
+	}
+	if len(a) != 16 {
+		t.Fatalf("expected 16-char hash, got %q", a)
+	}
+}
+
+func TestDetachAllClientsInvokesDetachClient(t *testing.T) {
+	original := execCommand
+	defer func() { execCommand = original }()
+
+	var calls [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		calls = append(calls, append([]string{name}, args...))
+		// list-sessions (used to resolve the session ID) fails, so the
+		// session name is used as the target.
+		if len(args) > 2 && args[2] == "list-sessions" {
+			return exec.Command("false")
+		}
+		return exec.Command("true")
+	}
+	t.Setenv("PB_LEVEL", "")
+
+	if err := DetachAllClients("claude"); err != nil {
+		t.Fatalf("DetachAllClients returned error: %v", err)
+	}
+	last := calls[len(calls)-1]
+	want := []string{"tmux", "-L", "pocketbot", "detach-client", "-s", "claude"}
+	if !reflect.DeepEqual(last, want) {
+		t.Fatalf("detach invocation = %v, want %v", last, want)
+	}
+}
+
+func TestParseServerInfo(t *testing.T) {
+	got, err := parseServerInfo(
+		"3.3a /private/tmp/tmux-501/pocketbot\n",
+		"$0\n$1\n$4\n",
+		"/dev/ttys003\n",
+	)
+	if err != nil {
+		t.Fatalf("parseServerInfo returned error: %v", err)
+	}
+	want := ServerInfo{
+		Version:      "3.3a",
+		SocketPath:   "/private/tmp/tmux-501/pocketbot",
+		SessionCount: 3,
+		ClientCount:  1,
+	}
+	if got != want {
+		t.Fatalf("parseServerInfo = %+v, want %+v", got, want)
+	}
+	if s := got.String(); s != "tmux 3.3a socket=/private/tmp/tmux-501/pocketbot sessions=3 clients=1" {
+		t.Fatalf("unexpected String(): %q", s)
+	}
+}
+
+func TestParseServerInfoRejectsEmptyOutput(t *testing.T) {
+	if _, err := parseServerInfo("", "", ""); err == nil {
+		t.Fatal("expected error for empty display output")
+	}
+}
+
+func TestIsServerStartupErr(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: errors.New("tmux new-session: exit status 1: no server running on /tmp/tmux-501/pocketbot"), want: true},
+		{err: errors.New("tmux new-session: exit status 1: server exited unexpectedly"), want: true},
+		{err: errors.New("tmux new-session: exit status 1: duplicate session: claude"), want: false},
+	}
+	for _, tt := range tests {
+		if got := isServerStartupErr(tt.err); got != tt.want {
+			t.Fatalf("isServerStartupErr(%v)=%v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestIdleForNeverActiveSessionIsLarge(t *testing.T) {
+	s := NewSession("never-active", "")
+	if s.IdleFor() < 24*time.Hour {
+		t.Fatalf("expected never-active session to report long idle, got %v", s.IdleFor())
+	}
+}
+
+func TestWithIdleTimeoutKeepsSessionActiveLonger(t *testing.T) {
+	quiet := time.Now().Add(-10 * time.Second)
+	def := NewSession("default", "")
+	def.lastActivity = quiet
+	long := NewSessionWithOptions("server", "", WithIdleTimeout(time.Minute))
+	long.lastActivity = quiet
+	zero := NewSessionWithOptions("zero", "", WithIdleTimeout(0))
+	zero.lastActivity = quiet
+
+	if def.RecentlyActive() || zero.RecentlyActive() {
+		t.Error("expected the default timeout to mark a 10s-quiet session idle")
+	}
+	if !long.RecentlyActive() {
+		t.Error("expected a 1m timeout to keep a 10s-quiet session active")
+	}
+}
+
+func TestObserveTargetsForeignSocketAndBlocksMutations(t *testing.T) {
+	originalExec := execCommand
+	defer func() {
+		execCommand = originalExec
+		observeArgs = nil
+		readOnly = false
+	}()
+
+	var calls [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		calls = append(calls, append([]string{name}, args...))
+		return exec.Command("echo", "work")
+	}
+	Observe("main", "")
+
+	if got := ListSessions(); !reflect.DeepEqual(got, []string{"work"}) {
+		t.Fatalf("ListSessions()=%v, want [work]", got)
+	}
+	if want := []string{"tmux", "-L", "main", "list-sessions", "-F", "#{session_name}"}; !reflect.DeepEqual(calls[0], want) {
+		t.Fatalf("read invocation = %v, want %v", calls[0], want)
+	}
+
+	calls = nil
+	mutations := map[string]func() error{
+		"CreateSession":      func() error { return CreateSession("x", "true") },
+		"KillSession":        func() error { return KillSession("work") },
+		"RenameSession":      func() error { return RenameSessionForce("work", "w2") },
+		"DetachAllClients":   func() error { return DetachAllClients("work") },
+		"KillServer":         KillServer,
+		"SetSessionOption":   func() error { return SetSessionOption("work", "@pb_memo", "hi") },
+		"SetSessionTool":     func() error { return SetSessionTool("work", "claude") },
+		"SetLastAttached":    func() error { return SetLastAttached("work") },
+		"SetGlobalYolo":      func() error { return SetGlobalYolo(true) },
+		"SetSessionSeenHash": func() error { return SetSessionSeenHash("work", "abc") },
+	}
+	for name, fn := range mutations {
+		if err := fn(); !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("%s returned %v, want ErrReadOnly", name, err)
+		}
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expected no tmux invocations for blocked mutations, got %v", calls)
+	}
+}
+
+func TestRefuseWritesBlocksMutationsOnOwnServer(t *testing.T) {
+	originalExec := execCommand
+	defer func() {
+		execCommand = originalExec
+		writesRefused = false
+	}()
+
+	var calls [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		calls = append(calls, args)
+		return exec.Command("true")
+	}
+	RefuseWrites()
+
+	if ReadOnly() {
+		t.Fatal("expected RefuseWrites not to switch to observe mode")
+	}
+	for name, fn := range map[string]func() error{
+		"SetSessionOption":   func() error { return SetSessionOption("work", "@pb_attach_count", "2") },
+		"SetSessionSeenHash": func() error { return SetSessionSeenHash("work", "abc") },
+		"DetachAllClients":   func() error { return DetachAllClients("work") },
+	} {
+		if err := fn(); !errors.Is(err, ErrReadOnly) {
+			t.Fatalf("%s returned %v, want ErrReadOnly", name, err)
+		}
+	}
+	if len(calls) != 0 {
+		t.Fatalf("expected no tmux invocations for blocked mutations, got %v", calls)
+	}
+}
+
+func TestObserveBySocketPath(t *testing.T) {
+	defer func() {
+		observeArgs = nil
+		readOnly = false
+	}()
+	Observe("", "/tmp/tmux-501/default")
+	args := cmd("list-sessions").Args
+	if want := []string{"tmux", "-S", "/tmp/tmux-501/default", "list-sessions"}; !reflect.DeepEqual(args, want) {
+		t.Fatalf("cmd args = %v, want %v", args, want)
+	}
+	if SocketName() != "/tmp/tmux-501/default" {
+		t.Fatalf("SocketName()=%q", SocketName())
+	}
+}
+
+func TestParseSessionInfos(t *testing.T) {
+	got, err := parseSessionInfos("1700000000 1700000300 1 1700000200 claude\n1700000100 1700000100 0  codex api\n")
+	if err != nil {
+		t.Fatalf("parseSessionInfos returned error: %v", err)
+	}
+	want := []SessionInfo{
+		{Name: "claude", Created: time.Unix(1700000000, 0), LastActivity: time.Unix(1700000300, 0), LastAttached: time.Unix(1700000200, 0), Attached: 1},
+		{Name: "codex api", Created: time.Unix(1700000100, 0), LastActivity: time.Unix(1700000100, 0)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseSessionInfos mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+	if _, err := parseSessionInfos("oops claude\n"); err == nil {
+		t.Fatal("expected malformed row to return an error")
+	}
+}
+
+func TestSessionsCreatedAfter(t *testing.T) {
+	since := time.Unix(1700000100, 0)
+	infos := []SessionInfo{
+		{Name: "before", Created: time.Unix(1700000000, 0)},
+		{Name: "exactly", Created: since},
+		{Name: "after", Created: time.Unix(1700000101, 0)},
+	}
+	if got := sessionsCreatedAfter(infos, since); !reflect.DeepEqual(got, []string{"after"}) {
+		t.Fatalf("sessionsCreatedAfter = %v, want [after]", got)
+	}
+	if got := sessionsCreatedAfter(infos, time.Time{}); !reflect.DeepEqual(got, []string{"before", "exactly", "after"}) {
+		t.Fatalf("zero since should keep every session, got %v", got)
+	}
+}
+
+func TestListSessionsCreatedAfterQueriesOnce(t *testing.T) {
+	original := execCommand
+	defer func() { execCommand = original }()
+	t.Setenv("PB_LEVEL", "")
+
+	var calls [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		calls = append(calls, args)
+		return exec.Command("printf", "1700000000 1700000000 0  old\n1700000500 1700000500 1 1700000500 new one\n")
+	}
+	got, err := ListSessionsCreatedAfter(time.Unix(1700000100, 0))
+	if err != nil {
+		t.Fatalf("ListSessionsCreatedAfter returned error: %v", err)
+	}
+	if !reflect.DeepEqual(got, []string{"new one"}) {
+		t.Fatalf("ListSessionsCreatedAfter = %v, want [new one]", got)
+	}
+	if len(calls) != 1 || calls[0][2] != "list-sessions" {
+		t.Fatalf("calls = %v, want one list-sessions", calls)
+	}
+
+	execCommand = func(name string, args ...string) *exec.Cmd { return exec.Command("false") }
+	if _, err := ListSessionsCreatedAfter(time.Time{}); err == nil {
+		t.Fatal("expected an error when list-sessions fails")
+	}
+}
+
+func TestGlobalYoloIsAServerOption(t *testing.T) {
+	originalExec := execCommand
+	defer func() { execCommand = originalExec }()
+	t.Setenv("PB_LEVEL", "")
+
+	var calls [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		calls = append(calls, append([]string{name}, args...))
+		if args[2] == "show-options" {
+			return exec.Command("echo", "1")
+		}
+		return exec.Command("true")
+	}
+
+	if err := SetGlobalYolo(true); err != nil {
+		t.Fatalf("SetGlobalYolo returned error: %v", err)
+	}
+	if !GetGlobalYolo() {
+		t.Fatal("expected GetGlobalYolo to parse 1 as enabled")
+	}
+	want := [][]string{
+		{"tmux", "-L", "pocketbot", "set-option", "-s", "@pb_global_yolo", "1"},
+		{"tmux", "-L", "pocketbot", "show-options", "-s", "-v", "@pb_global_yolo"},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("invocations = %v, want %v", calls, want)
+	}
+}
+
+func TestServerOptionInvocations(t *testing.T) {
+	originalExec := execCommand
+	defer func() { execCommand = originalExec }()
+	t.Setenv("PB_LEVEL", "")
+
+	var calls [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		calls = append(calls, append([]string{name}, args...))
+		if args[2] == "show-options" {
+			return exec.Command("echo", "name")
+		}
+		return exec.Command("true")
+	}
+
+	if err := SetServerOption("@pb_sort_order_override", "name"); err != nil {
+		t.Fatalf("SetServerOption returned error: %v", err)
+	}
+	if err := SetServerOption("@pb_sort_order_override", ""); err != nil {
+		t.Fatalf("unset returned error: %v", err)
+	}
+	got, err := GetServerOption("@pb_sort_order_override")
+	if err != nil || got != "name" {
+		t.Fatalf("GetServerOption = %q, %v; want name", got, err)
+	}
+	want := [][]string{
+		{"tmux", "-L", "pocketbot", "set-option", "-s", "@pb_sort_order_override", "name"},
+		{"tmux", "-L", "pocketbot", "set-option", "-s", "-u", "@pb_sort_order_override"},
+		{"tmux", "-L", "pocketbot", "show-options", "-s", "-v", "@pb_sort_order_override"},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("invocations = %v, want %v", calls, want)
+	}
+}
+
+func TestServerOptionRejectsMalformedKeys(t *testing.T) {
+	originalExec := execCommand
+	defer func() { execCommand = originalExec }()
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		t.Fatalf("unexpected tmux invocation: %v", args)
+		return nil
+	}
+
+	for _, key := range []string{"", "global_yolo", "@global_yolo", "@pb_", "@pb_Global", "@pb_a-b", "@pb_x y", "pb_x"} {
+		if validServerOptionKey(key) {
+			t.Errorf("validServerOptionKey(%q) = true, want false", key)
+		}
+		if err := SetServerOption(key, "1"); err == nil {
+			t.Errorf("SetServerOption(%q) succeeded, want error", key)
+		}
+		if _, err := GetServerOption(key); err == nil {
+			t.Errorf("GetServerOption(%q) succeeded, want error", key)
+		}
+	}
+	if !validServerOptionKey("@pb_global_yolo") {
+		t.Error("expected @pb_global_yolo to be valid")
+	}
+}
+
+func TestParsePaneActivityKeepsLatestWindowPerSession(t *testing.T) {
+	got, err := parsePaneActivity("1700000000 claude\n1700000300 claude\n1700000100 codex api\n")
+	if err != nil {
+		t.Fatalf("parsePaneActivity returned error: %v", err)
+	}
+	want := map[string]time.Time{
+		"claude":    time.Unix(1700000300, 0),
+		"codex api": time.Unix(1700000100, 0),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parsePaneActivity mismatch:\n got: %#v\nwant: %#v", got, want)
+	}
+	if _, err := parsePaneActivity("oops claude\n"); err == nil {
+		t.Fatal("expected malformed row to return an error")
+	}
+}
+
+func TestUpdateActivityFromSkipsCaptureUntilStampAdvances(t *testing.T) {
+	originalExec := execCommand
+	defer func() { execCommand = originalExec }()
+
+	pane := "prompt\n"
+	captures := 0
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		switch args[2] {
+		case "capture-pane":
+			captures++
+			return exec.Command("printf", "%s", pane)
+		case "list-sessions":
+			// No session ID, so the name is the capture target.
+			return exec.Command("false")
+		}
+		t.Fatalf("unexpected tmux invocation: %v", args)
+		return nil
+	}
+	t.Setenv("PB_LEVEL", "")
+
+	s := NewSession("claude", "")
+	poll := func(stamp time.Time) {
+		t.Helper()
+		s.nextPollAt = time.Time{}
+		s.UpdateActivityFrom(map[string]time.Time{"claude": stamp})
+	}
+	old := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	poll(old)
+	if captures != 1 || !s.ActivityKnown() {
+		t.Fatalf("expected baseline capture, got %d captures", captures)
+	}
+	poll(old)
+	if captures != 1 {
+		t.Fatalf("expected unchanged stamp to skip capture, got %d captures", captures)
+	}
+
+	// Output advances the stamp; the change still needs confirming, so the
+	// next poll captures even though the stamp holds still.
+	pane = "working\n"
+	newer := old.Add(10 * time.Second)
+	poll(newer)
+	if captures != 2 || s.pendingSince.IsZero() {
+		t.Fatalf("expected capture and pending change, got %d captures", captures)
+	}
+	s.pendingSince = s.pendingSince.Add(-activityConfirmWindow)
+	poll(newer)
+	if captures != 3 || !s.RecentlyActive() {
+		t.Fatalf("expected confirmed activity after debounce, got %d captures", captures)
+	}
+	poll(newer)
+	if captures != 3 {
+		t.Fatalf("expected settled session to skip capture, got %d captures", captures)
+	}
+
+	// Until a stamp's second has ended, output may follow the capture.
+	// (A stamp in the future keeps this from racing the clock.)
+	unfinished := time.Now().Add(time.Hour).Truncate(time.Second)
+	poll(unfinished)
+	poll(unfinished)
+	if captures != 5 {
+		t.Fatalf("expected same-second stamps to keep capturing, got %d captures", captures)
+	}
+
+	s.UpdateActivityFrom(map[string]time.Time{})
+	if s.ActivityKnown() || captures != 5 {
+		t.Fatalf("expected missing session to drop its baseline without capturing")
+	}
+}
+
+func TestResetBaselineDropsPendingChange(t *testing.T) {
+	originalExec := execCommand
+	defer func() { execCommand = originalExec }()
+
+	pane := "prompt\n"
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		switch args[2] {
+		case "capture-pane":
+			return exec.Command("printf", "%s", pane)
+		case "list-sessions":
+			return exec.Command("false")
+		}
+		t.Fatalf("unexpected tmux invocation: %v", args)
+		return nil
+	}
+	t.Setenv("PB_LEVEL", "")
+
+	s := NewSession("claude", "")
+	stamps := map[string]time.Time{"claude": time.Now().Add(time.Hour).Truncate(time.Second)}
+	s.UpdateActivityFrom(stamps)
+	pane = "prompt\nreply\n"
+	s.nextPollAt = time.Time{}
+	s.UpdateActivityFrom(stamps)
+	if s.pendingSince.IsZero() || s.LastCapture() != "prompt\n" {
+		t.Fatalf("expected unconfirmed change, got %q", s.LastCapture())
+	}
+
+	got, err := s.ResetBaseline()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != pane || s.LastCapture() != pane || !s.pendingSince.IsZero() {
+		t.Fatalf("expected baseline %q with nothing pending, got %q", pane, s.LastCapture())
+	}
+}
+
+func TestListSessionsCachesWithinTTL(t *testing.T) {
+	originalExec, originalNow := execCommand, cacheNow
+	defer func() {
+		execCommand, cacheNow = originalExec, originalNow
+		invalidateListSessions()
+	}()
+	t.Setenv("PB_LEVEL", "")
+	invalidateListSessions()
+
+	now := time.Unix(1700000000, 0)
+	cacheNow = func() time.Time { return now }
+	lists := 0
+	names := "claude"
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		if args[2] == "list-sessions" && args[4] == "#{session_name}" {
+			lists++
+			return exec.Command("echo", names)
+		}
+		// Everything else (session ID lookups, writes) succeeds silently.
+		return exec.Command("true")
+	}
+
+	if got := ListSessions(); !reflect.DeepEqual(got, []string{"claude"}) || lists != 1 {
+		t.Fatalf("ListSessions()=%v after %d lists, want [claude] after 1", got, lists)
+	}
+	names = "claude\ncodex"
+	now = now.Add(listSessionsTTL - time.Millisecond)
+	if got := ListSessions(); !reflect.DeepEqual(got, []string{"claude"}) || lists != 1 {
+		t.Fatalf("expected cache hit within TTL, got %v after %d lists", got, lists)
+	}
+	now = now.Add(time.Millisecond)
+	if got := ListSessions(); !reflect.DeepEqual(got, []string{"claude", "codex"}) || lists != 2 {
+		t.Fatalf("expected refresh once TTL passed, got %v after %d lists", got, lists)
+	}
+
+	// Callers may modify the result without corrupting the cache.
+	got := ListSessions()
+	got[0] = "mutated"
+	if again := ListSessions(); again[0] != "claude" {
+		t.Fatalf("cache was modified through a returned slice: %v", again)
+	}
+
+	// A different socket never reuses another server's list.
+	t.Setenv("PB_LEVEL", "2")
+	ListSessions()
+	if lists != 3 {
+		t.Fatalf("expected a fresh list for another socket, got %d lists", lists)
+	}
+}
+
+func TestListSessionsCacheInvalidatedByMutations(t *testing.T) {
+	originalExec, originalNow := execCommand, cacheNow
+	defer func() {
+		execCommand, cacheNow = originalExec, originalNow
+		invalidateListSessions()
+	}()
+	t.Setenv("PB_LEVEL", "")
+	invalidateListSessions()
+
+	now := time.Unix(1700000000, 0)
+	cacheNow = func() time.Time { return now }
+	lists := 0
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		if args[2] == "list-sessions" && args[4] == "#{session_name}" {
+			lists++
+			return exec.Command("echo", "claude")
+		}
+		return exec.Command("true")
+	}
+	originalClients := sessionClientsFn
+	defer func() { sessionClientsFn = originalClients }()
+	sessionClientsFn = func(string) ([]string, error) { return nil, nil }
+
+	mutations := []struct {
+		name string
+		run  func() error
+	}{
+		{"CreateSession", func() error { return CreateSession("codex", "codex") }},
+		{"KillSession", func() error { return KillSession("codex") }},
+		{"RenameSession", func() error { return RenameSession("claude", "claude-2") }},
+		{"KillServer", KillServer},
+	}
+	for _, mutation := range mutations {
+		ListSessions()
+		before := lists
+		if err := mutation.run(); err != nil {
+			t.Fatalf("%s returned error: %v", mutation.name, err)
+		}
+		ListSessions()
+		if lists != before+1 {
+			t.Errorf("expected %s to invalidate the session list cache", mutation.name)
+		}
+	}
+
+	// A tick listing sessions while the kill is under way must not leave
+	// the killed session cached.
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		switch {
+		case args[2] == "list-sessions" && args[4] == "#{session_name}":
+			lists++
+			return exec.Command("echo", "claude")
+		case args[2] == "kill-session":
+			ListSessions()
+		}
+		return exec.Command("true")
+	}
+	ListSessions()
+	if err := KillSession("claude"); err != nil {
+		t.Fatal(err)
+	}
+	before := lists
+	ListSessions()
+	if lists != before+1 {
+		t.Error("expected a listing taken during the kill to be invalidated")
+	}
+}
+
+func TestSendKeysTypesLiteralTextThenEnter(t *testing.T) {
+	original := execCommand
+	defer func() { execCommand = original }()
+	t.Setenv("PB_LEVEL", "")
+
+	var calls [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		if args[2] == "list-sessions" {
+			return exec.Command("false")
+		}
+		calls = append(calls, args[2:])
+		return exec.Command("true")
+	}
+
+	if err := SendKeys("claude", "run tests"); err != nil {
+		t.Fatalf("SendKeys returned error: %v", err)
+	}
+	want := [][]string{
+		{"send-keys", "-t", "claude", "-l", "run tests"},
+		{"send-keys", "-t", "claude", "Enter"},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("send-keys invocations = %v, want %v", calls, want)
+	}
+}
+
+func TestNewWindowStartsInSessionCwd(t *testing.T) {
+	original := execCommand
+	defer func() { execCommand = original }()
+	t.Setenv("PB_LEVEL", "")
+
+	var calls [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		switch {
+		case args[2] == "list-sessions":
+			return exec.Command("false")
+		case args[2] == "show-options":
+			return exec.Command("echo", "/src/app")
+		}
+		calls = append(calls, args[2:])
+		return exec.Command("true")
+	}
+
+	if err := NewWindow("claude", "claude --continue"); err != nil {
+		t.Fatalf("NewWindow returned error: %v", err)
+	}
+	want := [][]string{{"new-window", "-t", "claude", "-c", "/src/app", "sh", "-c",
+		"export PB_LEVEL=1; export PB_CWD='/src/app'; claude --continue"}}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("new-window invocations = %v, want %v", calls, want)
+	}
+}
+
+func TestBroadcastKeysSendsConcurrentlyAndCollectsErrors(t *testing.T) {
+	original := execCommand
+	defer func() { execCommand = original }()
+	t.Setenv("PB_LEVEL", "")
+
+	names := []string{"claude", "claude-2", "claude-3"}
+	var (
+		mu      sync.Mutex
+		started int
+		sent    []string
+	)
+	allStarted := make(chan struct{})
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		if args[2] == "list-sessions" {
+			return exec.Command("false")
+		}
+		target := args[4]
+		if args[len(args)-1] != "Enter" {
+			// Hold every literal send until all sessions have started one, so
+			// a sequential implementation times out here.
+			mu.Lock()
+			started++
+			if started == len(names) {
+				close(allStarted)
+			}
+			mu.Unlock()
+			select {
+			case <-allStarted:
+			case <-time.After(2 * time.Second):
+				t.Errorf("send to %s did not overlap with the others", target)
+			}
+			mu.Lock()
+			sent = append(sent, target)
+			mu.Unlock()
+		}
+		if target == "claude-2" {
+			return exec.Command("false")
+		}
+		return exec.Command("true")
+	}
+
+	errs := BroadcastKeys(names, "run tests")
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "claude-2: ") {
+		t.Fatalf("expected one error for claude-2, got %v", errs)
+	}
+	sort.Strings(sent)
+	if !reflect.DeepEqual(sent, names) {
+		t.Fatalf("expected keys sent to every session, got %v", sent)
+	}
+
+	if errs := BroadcastKeys(nil, "run tests"); errs != nil {
+		t.Fatalf("expected no errors for no sessions, got %v", errs)
+	}
+}
+
+func TestBroadcastKeysBlockedInObserveMode(t *testing.T) {
+	defer func() {
+		observeArgs = nil
+		readOnly = false
+	}()
+	Observe("main", "")
+	errs := BroadcastKeys([]string{"work"}, "ls")
+	if len(errs) != 1 || !errors.Is(errs[0], ErrReadOnly) {
+		t.Fatalf("expected ErrReadOnly, got %v", errs)
+	}
+}
+
+func TestSocketNameFollowsEnvironment(t *testing.T) {
+	cases := []struct {
+		level, dir string
+		wantName   string
+		wantArgs   []string
+	}{
+		{"", "", "pocketbot", []string{"-L", "pocketbot"}},
+		{"2", "", "pocketbot-2", []string{"-L", "pocketbot-2"}},
+		{"", "/run/pb", "/run/pb/pocketbot", []string{"-S", "/run/pb/pocketbot"}},
+		{"2", "/run/pb", "/run/pb/pocketbot-2", []string{"-S", "/run/pb/pocketbot-2"}},
+	}
+	for _, tc := range cases {
+		t.Setenv("PB_LEVEL", tc.level)
+		t.Setenv("PB_TMUX_SOCKET_DIR", tc.dir)
+		if got := SocketName(); got != tc.wantName {
+			t.Errorf("level=%q dir=%q: SocketName()=%q, want %q", tc.level, tc.dir, got, tc.wantName)
+		}
+		if got := SocketArgs(); !reflect.DeepEqual(got, tc.wantArgs) {
+			t.Errorf("level=%q dir=%q: SocketArgs()=%v, want %v", tc.level, tc.dir, got, tc.wantArgs)
+		}
+	}
+}
+
+func TestSetSocketNameKeepsNesting(t *testing.T) {
+	defer SetSocketName("")
+
+	cases := []struct {
+		socket, level, dir string
+		wantName           string
+		wantArgs           []string
+	}{
+		{"proj-a", "", "", "proj-a", []string{"-L", "proj-a"}},
+		{"proj-a", "2", "", "proj-a-2", []string{"-L", "proj-a-2"}},
+		{"proj-a", "1", "/run/pb", "/run/pb/proj-a-1", []string{"-S", "/run/pb/proj-a-1"}},
+		{"", "", "", "pocketbot", []string{"-L", "pocketbot"}},
+	}
+	for _, tc := range cases {
+		SetSocketName(tc.socket)
+		t.Setenv("PB_LEVEL", tc.level)
+		t.Setenv("PB_TMUX_SOCKET_DIR", tc.dir)
+		if got := SocketName(); got != tc.wantName {
+			t.Errorf("socket=%q level=%q: SocketName()=%q, want %q", tc.socket, tc.level, got, tc.wantName)
+		}
+		if got := SocketArgs(); !reflect.DeepEqual(got, tc.wantArgs) {
+			t.Errorf("socket=%q level=%q: SocketArgs()=%v, want %v", tc.socket, tc.level, got, tc.wantArgs)
+		}
+	}
+}
+
+func TestSetSessionOptionIfChangedSkipsUnchangedValue(t *testing.T) {
+	original := execCommand
+	defer func() { execCommand = original }()
+	t.Setenv("PB_LEVEL", "")
+
+	current := "claude"
+	var sets [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		for _, arg := range args {
+			switch arg {
+			case "show-options":
+				return exec.Command("echo", current)
+			case "set-option":
+				sets = append(sets, args)
+				return exec.Command("true")
+			}
+		}
+		return exec.Command("true")
+	}
+
+	if err := SetSessionOptionIfChanged("work", "@pb_tool", "claude"); err != nil {
+		t.Fatalf("SetSessionOptionIfChanged returned error: %v", err)
+	}
+	if len(sets) != 0 {
+		t.Fatalf("expected no set-option for an unchanged value, got %v", sets)
+	}
+
+	if err := SetSessionOptionIfChanged("work", "@pb_tool", "codex"); err != nil {
+		t.Fatalf("SetSessionOptionIfChanged returned error: %v", err)
+	}
+	want := []string{"-L", "pocketbot", "set-option", "-t", "work", "@pb_tool", "codex"}
+	if len(sets) != 1 || !reflect.DeepEqual(sets[0], want) {
+		t.Fatalf("set-option calls = %v, want [%v]", sets, want)
+	}
+
+	sets = nil
+	if err := SetSessionOption("work", "@pb_tool", "claude"); err != nil {
+		t.Fatalf("SetSessionOption returned error: %v", err)
+	}
+	if err := SetSessionTool("work", "claude"); err != nil {
+		t.Fatalf("SetSessionTool returned error: %v", err)
+	}
+	if len(sets) != 2 {
+		t.Fatalf("SetSessionOption and SetSessionTool should always write, got %v", sets)
+	}
+}
+
+func TestKillSessionSendsPreKillKeysFirst(t *testing.T) {
+	originalExec, originalDelay := execCommand, preKillDelay
+	defer func() { execCommand, preKillDelay = originalExec, originalDelay }()
+	preKillDelay = 0
+	t.Setenv("PB_LEVEL", "")
+
+	var calls [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		if len(args) > 2 && args[2] == "list-sessions" {
+			return exec.Command("false")
+		}
+		calls = append(calls, args[2:])
+		return exec.Command("true")
+	}
+
+	if err := KillSession("work", KillOptions{PreKillKeys: "C-c q Enter"}); err != nil {
+		t.Fatalf("KillSession returned error: %v", err)
+	}
+	want := [][]string{
+		{"send-keys", "-t", "work", "C-c", "q", "Enter"},
+		{"kill-session", "-t", "work"},
+	}
+	if !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls = %v, want %v", calls, want)
+	}
+
+	calls = nil
+	if err := KillSession("work"); err != nil {
+		t.Fatalf("KillSession returned error: %v", err)
+	}
+	if want := [][]string{{"kill-session", "-t", "work"}}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("calls without options = %v, want %v", calls, want)
+	}
+}
+
+func TestKillSessionProceedsWhenPreKillKeysFail(t *testing.T) {
+	originalExec, originalDelay := execCommand, preKillDelay
+	defer func() { execCommand, preKillDelay = originalExec, originalDelay }()
+	preKillDelay = time.Hour // must not be waited on after a failed send
+	t.Setenv("PB_LEVEL", "")
+
+	killed := false
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		switch args[2] {
+		case "send-keys":
+			return exec.Command("false")
+		case "kill-session":
+			killed = true
+		}
+		return exec.Command("true")
+	}
+
+	if err := KillSession("work", KillOptions{PreKillKeys: "q"}); err != nil {
+		t.Fatalf("KillSession returned error: %v", err)
+	}
+	if !killed {
+		t.Fatal("expected kill-session after send-keys failed")
+	}
+}
+
+func TestParsePBOptions(t *testing.T) {
+	out := "destroy-unattached off\n" +
+		"@pb_cwd /home/me/src/pocketbot\n" +
+		"@pb_memo \"fix the \\\"flaky\\\" test\"\n" +
+		"@pb_command 'codex resume --last'\n" +
+		"@pb_yolo 1\n" +
+		"@pb_tool\n" +
+		"@other value\n"
+	want := map[string]string{
+		"@pb_cwd":     "/home/me/src/pocketbot",
+		"@pb_memo":    `fix the "flaky" test`,
+		"@pb_command": "codex resume --last",
+		"@pb_yolo":    "1",
+		"@pb_tool":    "",
+	}
+	if got := parsePBOptions(out); !reflect.DeepEqual(got, want) {
+		t.Fatalf("parsePBOptions() = %v, want %v", got, want)
+	}
+	if got := parsePBOptions(""); len(got) != 0 {
+		t.Fatalf("expected no options from empty output, got %v", got)
+	}
+}
+
+func TestGetAllSessionPBOptionsUsesOneCall(t *testing.T) {
+	original := execCommand
+	defer func() { execCommand = original }()
+	t.Setenv("PB_LEVEL", "")
+
+	var shows [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		switch args[2] {
+		case "list-sessions":
+			return exec.Command("false")
+		case "show-options":
+			shows = append(shows, args)
+			return exec.Command("printf", "@pb_tool codex\\n@pb_priority 2\\nstatus on\\n")
+		}
+		return exec.Command("true")
+	}
+
+	opts, err := GetAllSessionPBOptions("work")
+	if err != nil {
+		t.Fatalf("GetAllSessionPBOptions returned error: %v", err)
+	}
+	if want := map[string]string{"@pb_tool": "codex", "@pb_priority": "2"}; !reflect.DeepEqual(opts, want) {
+		t.Fatalf("options = %v, want %v", opts, want)
+	}
+	want := []string{"-L", "pocketbot", "show-options", "-q", "-t", "work"}
+	if len(shows) != 1 || !reflect.DeepEqual(shows[0], want) {
+		t.Fatalf("show-options calls = %v, want [%v]", shows, want)
+	}
+
+	execCommand = func(name string, args ...string) *exec.Cmd { return exec.Command("false") }
+	if _, err := GetAllSessionPBOptions("gone"); err == nil {
+		t.Fatal("expected an error when show-options fails")
+	}
+}
+
+func TestCreateSessionInDirLaunchesAndRecordsDir(t *testing.T) {
+	original := execCommand
+	defer func() { execCommand = original }()
+	t.Setenv("PB_LEVEL", "")
+	invalidateListSessions()
+
+	var calls [][]string
+	execCommand = func(name string, args ...string) *exec.Cmd {
+		calls = append(calls, args[2:])
+		if args[2] == "list-sessions" {
+			return exec.Command("false")
+		}
+		return exec.Command("true")
+	}
+
+	if err := CreateSessionInDir("api", "go run ./cmd/api", "/src/api"); err != nil {
+		t.Fatalf("CreateSessionInDir returned error: %v", err)
+	}
+	var launched, stored bool
+	for _, call := range calls {
+		joined := strings.Join(call, " ")
+		if call[0] == "new-session" && strings.Contains(joined, "-c /src/api sh -c") &&
+			strings.Contains(joined, "export PB_CWD='/src/api'") {
+			launched = true
+		}
+		if call[0] == "set-option" && strings.HasSuffix(joined, "@pb_cwd /src/api") {
+			stored = true
+		}
+	}
+	if !launched || !stored {
+		t.Fatalf("expected launch in and @pb_cwd of /src/api, got calls %v", calls)
+	}
+}